@@ -0,0 +1,43 @@
+package AlterClientQuotas
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.8+, KIP-546) setting and removing
+// quotas of entities. With validateOnly the changes are validated but not
+// made. The request uses the flexible encoding.
+func NewRequest(entries []Entry, validateOnly bool) *api.Request {
+	return &api.Request{
+		ApiKey:     api.AlterClientQuotas,
+		ApiVersion: 1,
+		Body: Request{
+			Entries:      entries,
+			ValidateOnly: validateOnly,
+		},
+	}
+}
+
+type Request struct {
+	Entries      []Entry
+	ValidateOnly bool
+}
+
+type Entry struct {
+	Entity []Entity
+	Ops    []Op
+}
+
+type Entity struct {
+	// EntityType is "user", "client-id", or "ip".
+	EntityType string
+	// EntityName empty (sent as null) is the default entity.
+	EntityName string `wire:"nullable"`
+}
+
+type Op struct {
+	Key   string
+	Value float64
+	// Remove the quota (Value is ignored).
+	Remove bool
+}
@@ -0,0 +1,12 @@
+package AlterClientQuotas
+
+type Response struct {
+	ThrottleTimeMs int32
+	Entries        []EntryResponse
+}
+
+type EntryResponse struct {
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+	Entity       []Entity
+}
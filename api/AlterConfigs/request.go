@@ -0,0 +1,38 @@
+package AlterConfigs
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v0 request (Kafka 0.11+). Configs of each resource are
+// replaced: configs which are not in the request are reverted to their
+// defaults (see IncrementalAlterConfigs). Configs of broker resources (other
+// than the cluster wide defaults, which have empty resource name) must be
+// altered by the broker itself. Resource types are the same as in
+// DescribeConfigs.
+func NewRequest(resources []Resource, validateOnly bool) *api.Request {
+	return &api.Request{
+		ApiKey:     api.AlterConfigs,
+		ApiVersion: 0,
+		Body: Request{
+			Resources:    resources,
+			ValidateOnly: validateOnly,
+		},
+	}
+}
+
+type Request struct {
+	Resources    []Resource
+	ValidateOnly bool
+}
+
+type Resource struct {
+	ResourceType int8
+	ResourceName string
+	Configs      []Config
+}
+
+type Config struct {
+	Name  string
+	Value string `wire:"nullable"`
+}
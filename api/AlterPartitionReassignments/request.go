@@ -0,0 +1,36 @@
+package AlterPartitionReassignments
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v0 request (Kafka 2.4+, KIP-455). It must be sent to the
+// controller. The request uses the flexible encoding.
+func NewRequest(topics []Topic, timeoutMs int32) *api.Request {
+	return &api.Request{
+		ApiKey:     api.AlterPartitionReassignments,
+		ApiVersion: 0,
+		Body: Request{
+			TimeoutMs: timeoutMs,
+			Topics:    topics,
+		},
+	}
+}
+
+type Request struct {
+	TimeoutMs int32
+	Topics    []Topic
+}
+
+type Topic struct {
+	Name       string
+	Partitions []Partition
+}
+
+type Partition struct {
+	PartitionIndex int32
+	// Replicas is the target replica assignment (the first replica is the
+	// preferred leader). Nil (sent as null) cancels the reassignment of the
+	// partition in progress.
+	Replicas []int32
+}
@@ -0,0 +1,19 @@
+package AlterPartitionReassignments
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+	Responses      []TopicResponse
+}
+
+type TopicResponse struct {
+	Name       string
+	Partitions []PartitionResponse
+}
+
+type PartitionResponse struct {
+	PartitionIndex int32
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+}
@@ -0,0 +1,33 @@
+package AlterReplicaLogDirs
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.0+) moving replicas of partitions on
+// the broker to which it is sent to log dirs. Replicas which are not (yet) on
+// the broker are placed in the log dirs when they are created.
+func NewRequest(dirs []Dir) *api.Request {
+	return &api.Request{
+		ApiKey:     api.AlterReplicaLogDirs,
+		ApiVersion: 1,
+		Body: Request{
+			Dirs: dirs,
+		},
+	}
+}
+
+type Request struct {
+	Dirs []Dir
+}
+
+type Dir struct {
+	// Path is the absolute path of the log dir.
+	Path   string
+	Topics []Topic
+}
+
+type Topic struct {
+	Name       string
+	Partitions []int32
+}
@@ -0,0 +1,16 @@
+package AlterReplicaLogDirs
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []TopicResult
+}
+
+type TopicResult struct {
+	TopicName  string
+	Partitions []PartitionResult
+}
+
+type PartitionResult struct {
+	PartitionIndex int32
+	ErrorCode      int16
+}
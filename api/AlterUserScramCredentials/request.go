@@ -0,0 +1,44 @@
+package AlterUserScramCredentials
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v0 request (Kafka 2.7+, KIP-554) deleting and upserting
+// SCRAM credentials. Mechanisms are DescribeUserScramCredentials.Scram*
+// values. The salted password is computed by the client: the password is
+// never sent to the broker. The request uses the flexible encoding.
+func NewRequest(deletions []Deletion, upsertions []Upsertion) *api.Request {
+	if deletions == nil {
+		deletions = []Deletion{}
+	}
+	if upsertions == nil {
+		upsertions = []Upsertion{}
+	}
+	return &api.Request{
+		ApiKey:     api.AlterUserScramCredentials,
+		ApiVersion: 0,
+		Body: Request{
+			Deletions:  deletions,
+			Upsertions: upsertions,
+		},
+	}
+}
+
+type Request struct {
+	Deletions  []Deletion
+	Upsertions []Upsertion
+}
+
+type Deletion struct {
+	Name      string
+	Mechanism int8
+}
+
+type Upsertion struct {
+	Name           string
+	Mechanism      int8
+	Iterations     int32
+	Salt           []byte
+	SaltedPassword []byte
+}
@@ -0,0 +1,13 @@
+package AlterUserScramCredentials
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []Result
+}
+
+// Result per user (not per deletion or upsertion).
+type Result struct {
+	User         string
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+}
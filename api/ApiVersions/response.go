@@ -10,3 +10,20 @@ type ApiKeyVersion struct {
 	MinVersion int16
 	MaxVersion int16
 }
+
+// Version returns the range of versions supported for the api key. If the api
+// key is not supported, returns -1, -1.
+func (r *Response) Version(apiKey int16) (min, max int16) {
+	for _, k := range r.ApiKeys {
+		if k.ApiKey == apiKey {
+			return k.MinVersion, k.MaxVersion
+		}
+	}
+	return -1, -1
+}
+
+// Supports returns true if the api key is supported at the given version.
+func (r *Response) Supports(apiKey, version int16) bool {
+	min, max := r.Version(apiKey)
+	return min >= 0 && version >= min && version <= max
+}
@@ -0,0 +1,58 @@
+package ConsumerProtocol
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/mkocikowski/libkafka/wire"
+)
+
+// Assignment has the same layout in all versions. Version 1 (Kafka 2.4) was
+// introduced for cooperative rebalancing (KIP-429) and versions 2 and 3 to
+// follow the subscription versions.
+// https://github.com/apache/kafka/blob/3.5/clients/src/main/resources/common/message/ConsumerProtocolAssignment.json
+type Assignment struct {
+	Version            int16
+	AssignedPartitions []TopicPartitions
+	UserData           []byte // nullable
+}
+
+func NewAssignment(partitions []TopicPartitions) *Assignment {
+	return &Assignment{
+		Version:            AssignmentVersion,
+		AssignedPartitions: partitions,
+	}
+}
+
+// Marshal the assignment according to its Version.
+func (a *Assignment) Marshal() ([]byte, error) {
+	if a.Version < 0 {
+		return nil, fmt.Errorf("invalid version %d", a.Version)
+	}
+	c := *a
+	c.AssignedPartitions = notNull(c.AssignedPartitions)
+	buf := new(bytes.Buffer)
+	if err := wire.WriteVersion(buf, reflect.ValueOf(&c), c.Version); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalAssignment decodes assignment of any version. Empty input (which is
+// what the coordinator sends to members that were not assigned anything by
+// some clients) decodes to an empty assignment.
+func UnmarshalAssignment(b []byte) (*Assignment, error) {
+	if len(b) == 0 {
+		return &Assignment{AssignedPartitions: []TopicPartitions{}}, nil
+	}
+	v, err := readVersion(b, AssignmentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding assignment: %w", err)
+	}
+	a := &Assignment{}
+	if err := wire.ReadVersion(bytes.NewReader(b), reflect.ValueOf(a), v); err != nil {
+		return nil, fmt.Errorf("error decoding assignment: %w", err)
+	}
+	return a, nil
+}
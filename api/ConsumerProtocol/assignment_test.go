@@ -0,0 +1,55 @@
+package ConsumerProtocol
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUnitAssignment(t *testing.T) {
+	tests := []struct {
+		a       Assignment
+		fixture string
+	}{
+		{
+			Assignment{Version: 0, AssignedPartitions: []TopicPartitions{}},
+			"0000 00000000 ffffffff",
+		},
+		{
+			Assignment{Version: 1, AssignedPartitions: []TopicPartitions{{Topic: "foo", Partitions: []int32{0, 2}}}, UserData: []byte{}},
+			"0001 00000001 0003666f6f 00000002 00000000 00000002 00000000",
+		},
+		{
+			Assignment{Version: 3, AssignedPartitions: []TopicPartitions{{Topic: "foo", Partitions: []int32{}}}, UserData: []byte{1}},
+			"0003 00000001 0003666f6f 00000000 0000000101",
+		},
+	}
+	for i, test := range tests {
+		b, err := test.a.Marshal()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if want := unhex(test.fixture); !bytes.Equal(b, want) {
+			t.Fatalf("%d %x", i, b)
+		}
+		a, err := UnmarshalAssignment(b)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if !reflect.DeepEqual(*a, test.a) {
+			t.Fatalf("%d %+v", i, a)
+		}
+	}
+}
+
+func TestUnitAssignmentEmpty(t *testing.T) {
+	a, err := UnmarshalAssignment(nil)
+	if err != nil || len(a.AssignedPartitions) != 0 {
+		t.Fatal(a, err)
+	}
+	// nil partitions are encoded as empty arrays, not as null
+	b, _ := NewAssignment([]TopicPartitions{{Topic: "foo"}}).Marshal()
+	if want := unhex("0003 00000001 0003666f6f 00000000 ffffffff"); !bytes.Equal(b, want) {
+		t.Fatalf("%x", b)
+	}
+}
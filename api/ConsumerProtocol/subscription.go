@@ -0,0 +1,112 @@
+// Package ConsumerProtocol implements encoding of member metadata
+// (Subscription) and of member assignments (Assignment) for groups with the
+// "consumer" protocol type. These are the opaque bytes in JoinGroup and
+// SyncGroup requests and responses. The encoding is compatible with the Java
+// client (and so with librdkafka and others), which makes it possible for
+// libkafka consumers to be in the same group as consumers built with other
+// clients. Both structures start with an int16 version. When decoding a
+// version newer than the newest known here, fields known here are read and
+// the rest is ignored (same as in the Java client).
+// https://github.com/apache/kafka/blob/3.5/clients/src/main/resources/common/message/ConsumerProtocolSubscription.json
+package ConsumerProtocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	"github.com/mkocikowski/libkafka/wire"
+)
+
+const ProtocolType = "consumer"
+
+const (
+	SubscriptionVersion = 3 // newest subscription version
+	AssignmentVersion   = 3 // newest assignment version
+)
+
+type Subscription struct {
+	Version         int16
+	Topics          []string
+	UserData        []byte            // nullable
+	OwnedPartitions []TopicPartitions `versions:"1+"`
+	GenerationId    int32             `versions:"2+"` // -1 if unknown
+	RackId          string            `versions:"3+" wire:"nullable"`
+}
+
+type TopicPartitions struct {
+	Topic      string
+	Partitions []int32
+}
+
+// NewSubscription returns subscription of the newest version, with no user
+// data, owned partitions, or rack.
+func NewSubscription(topics []string) *Subscription {
+	return &Subscription{
+		Version:         SubscriptionVersion,
+		Topics:          topics,
+		OwnedPartitions: []TopicPartitions{},
+		GenerationId:    -1,
+	}
+}
+
+// version to read the message as: same as the encoded version, unless it is
+// newer than the newest known version.
+func readVersion(b []byte, newest int16) (int16, error) {
+	if len(b) < 2 {
+		return 0, fmt.Errorf("message too short: %d bytes", len(b))
+	}
+	v := int16(binary.BigEndian.Uint16(b))
+	if v < 0 {
+		return 0, fmt.Errorf("invalid version %d", v)
+	}
+	if v > newest {
+		v = newest
+	}
+	return v, nil
+}
+
+// arrays (other than the nullable UserData) must not be encoded as null
+func notNull(partitions []TopicPartitions) []TopicPartitions {
+	out := make([]TopicPartitions, len(partitions))
+	for i, p := range partitions {
+		out[i] = TopicPartitions{Topic: p.Topic, Partitions: p.Partitions}
+		if out[i].Partitions == nil {
+			out[i].Partitions = []int32{}
+		}
+	}
+	return out
+}
+
+// Marshal the subscription according to its Version.
+func (s *Subscription) Marshal() ([]byte, error) {
+	if s.Version < 0 {
+		return nil, fmt.Errorf("invalid version %d", s.Version)
+	}
+	c := *s
+	if c.Topics == nil {
+		c.Topics = []string{}
+	}
+	c.OwnedPartitions = notNull(c.OwnedPartitions)
+	buf := new(bytes.Buffer)
+	if err := wire.WriteVersion(buf, reflect.ValueOf(&c), c.Version); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSubscription decodes subscription of any version. Fields not
+// present in the encoded version have their default values (GenerationId is
+// -1).
+func UnmarshalSubscription(b []byte) (*Subscription, error) {
+	v, err := readVersion(b, SubscriptionVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding subscription: %w", err)
+	}
+	s := &Subscription{GenerationId: -1}
+	if err := wire.ReadVersion(bytes.NewReader(b), reflect.ValueOf(s), v); err != nil {
+		return nil, fmt.Errorf("error decoding subscription: %w", err)
+	}
+	return s, nil
+}
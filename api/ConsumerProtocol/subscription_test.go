@@ -0,0 +1,100 @@
+package ConsumerProtocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// fixtures are encoded as by the java client
+func TestUnitSubscription(t *testing.T) {
+	owned := []TopicPartitions{{Topic: "foo", Partitions: []int32{0}}}
+	tests := []struct {
+		s       Subscription
+		fixture string
+	}{
+		{
+			Subscription{Version: 0, Topics: []string{"foo"}, GenerationId: -1},
+			"0000 00000001 0003666f6f ffffffff",
+		},
+		{
+			Subscription{Version: 0, Topics: []string{"foo", "bar"}, UserData: []byte{1}, GenerationId: -1},
+			"0000 00000002 0003666f6f 0003626172 0000000101",
+		},
+		{
+			Subscription{Version: 1, Topics: []string{"foo"}, OwnedPartitions: owned, GenerationId: -1},
+			"0001 00000001 0003666f6f ffffffff 00000001 0003666f6f 00000001 00000000",
+		},
+		{
+			Subscription{Version: 2, Topics: []string{"foo"}, OwnedPartitions: []TopicPartitions{}, GenerationId: 5},
+			"0002 00000001 0003666f6f ffffffff 00000000 00000005",
+		},
+		{
+			Subscription{Version: 3, Topics: []string{"foo"}, OwnedPartitions: owned, GenerationId: 5, RackId: "a"},
+			"0003 00000001 0003666f6f ffffffff 00000001 0003666f6f 00000001 00000000 00000005 000161",
+		},
+		{
+			Subscription{Version: 3, Topics: []string{"foo"}, OwnedPartitions: []TopicPartitions{}, GenerationId: -1},
+			"0003 00000001 0003666f6f ffffffff 00000000 ffffffff ffff",
+		},
+	}
+	for i, test := range tests {
+		b, err := test.s.Marshal()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if want := unhex(test.fixture); !bytes.Equal(b, want) {
+			t.Fatalf("%d %x", i, b)
+		}
+		s, err := UnmarshalSubscription(b)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if !reflect.DeepEqual(*s, test.s) {
+			t.Fatalf("%d %+v", i, s)
+		}
+	}
+}
+
+func TestUnitSubscriptionNewerVersion(t *testing.T) {
+	// version 4 with some unknown trailing field
+	b := unhex("0004 00000001 0003666f6f ffffffff 00000000 00000005 000161 0102")
+	s, err := UnmarshalSubscription(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 4 || s.GenerationId != 5 || s.RackId != "a" {
+		t.Fatalf("%+v", s)
+	}
+}
+
+func TestUnitSubscriptionErrors(t *testing.T) {
+	for _, fixture := range []string{"", "00", "ffff", "0001 00000001 0003666f"} {
+		if _, err := UnmarshalSubscription(unhex(fixture)); err == nil {
+			t.Fatal(fixture)
+		}
+	}
+	if _, err := (&Subscription{Version: -1}).Marshal(); err == nil {
+		t.Fatal()
+	}
+}
+
+func TestUnitNewSubscription(t *testing.T) {
+	b, err := NewSubscription(nil).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex("0003 00000000 ffffffff 00000000 ffffffff ffff"); !bytes.Equal(b, want) {
+		t.Fatalf("%x", b)
+	}
+}
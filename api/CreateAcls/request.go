@@ -0,0 +1,31 @@
+package CreateAcls
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.0+). See client/admin for the values
+// of resource types, pattern types, operations, and permission types.
+func NewRequest(creations []Creation) *api.Request {
+	return &api.Request{
+		ApiKey:     api.CreateAcls,
+		ApiVersion: 1,
+		Body: Request{
+			Creations: creations,
+		},
+	}
+}
+
+type Request struct {
+	Creations []Creation
+}
+
+type Creation struct {
+	ResourceType        int8
+	ResourceName        string
+	ResourcePatternType int8 `versions:"1+"`
+	Principal           string
+	Host                string
+	Operation           int8
+	PermissionType      int8
+}
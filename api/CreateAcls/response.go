@@ -0,0 +1,11 @@
+package CreateAcls
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []Result // in the order of creations in the request
+}
+
+type Result struct {
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+}
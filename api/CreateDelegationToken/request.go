@@ -0,0 +1,33 @@
+package CreateDelegationToken
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 1.1+). The token owner is the
+// principal of the connection, which must not be authenticated with a
+// delegation token. MaxLifetimeMs -1 uses the broker default
+// (delegation.token.max.lifetime.ms).
+func NewRequest(renewers []Renewer, maxLifetimeMs int64) *api.Request {
+	if renewers == nil {
+		renewers = []Renewer{}
+	}
+	return &api.Request{
+		ApiKey:     api.CreateDelegationToken,
+		ApiVersion: 1,
+		Body: Request{
+			Renewers:      renewers,
+			MaxLifetimeMs: maxLifetimeMs,
+		},
+	}
+}
+
+type Request struct {
+	Renewers      []Renewer
+	MaxLifetimeMs int64
+}
+
+type Renewer struct {
+	PrincipalType string // "User"
+	PrincipalName string
+}
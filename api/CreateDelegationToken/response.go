@@ -0,0 +1,13 @@
+package CreateDelegationToken
+
+type Response struct {
+	ErrorCode         int16
+	PrincipalType     string
+	PrincipalName     string
+	IssueTimestampMs  int64
+	ExpiryTimestampMs int64
+	MaxTimestampMs    int64
+	TokenId           string
+	Hmac              []byte
+	ThrottleTimeMs    int32
+}
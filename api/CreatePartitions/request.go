@@ -0,0 +1,40 @@
+package CreatePartitions
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.0+). It must be sent to the
+// controller. With validateOnly the request is validated but no partitions
+// are created.
+func NewRequest(topics []Topic, timeoutMs int32, validateOnly bool) *api.Request {
+	return &api.Request{
+		ApiKey:     api.CreatePartitions,
+		ApiVersion: 1,
+		Body: Request{
+			Topics:       topics,
+			TimeoutMs:    timeoutMs,
+			ValidateOnly: validateOnly,
+		},
+	}
+}
+
+type Request struct {
+	Topics       []Topic
+	TimeoutMs    int32
+	ValidateOnly bool
+}
+
+type Topic struct {
+	Name string
+	// Count is the new total number of partitions (not the number of
+	// partitions to add).
+	Count int32
+	// Assignments of replicas to the new partitions, one per new
+	// partition. Nil (sent as null) lets the controller assign replicas.
+	Assignments []Assignment
+}
+
+type Assignment struct {
+	BrokerIds []int32
+}
@@ -0,0 +1,12 @@
+package CreatePartitions
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []Result
+}
+
+type Result struct {
+	Name         string
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+}
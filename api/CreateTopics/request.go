@@ -30,6 +30,33 @@ func NewRequest(topic string, numPartitions int32, replicationFactor int16, conf
 	}
 }
 
+// NewMultipleTopicsRequest returns v2 request (Kafka 1.0+) for creating
+// topics. It must be sent to the controller. Set NumPartitions and
+// ReplicationFactor to -1 when specifying Assignments. With validateOnly the
+// request is validated but the topics are not created. Nil Assignments and
+// Configs are sent as empty arrays.
+func NewMultipleTopicsRequest(topics []Topic, timeoutMs int32, validateOnly bool) *api.Request {
+	t := make([]Topic, len(topics))
+	for i, topic := range topics {
+		t[i] = topic
+		if t[i].Assignments == nil {
+			t[i].Assignments = []Assignment{}
+		}
+		if t[i].Configs == nil {
+			t[i].Configs = []Config{}
+		}
+	}
+	return &api.Request{
+		ApiKey:     api.CreateTopics,
+		ApiVersion: 2,
+		Body: Request{
+			Topics:       t,
+			TimeoutMs:    timeoutMs,
+			ValidateOnly: validateOnly,
+		},
+	}
+}
+
 type Request struct {
 	Topics       []Topic
 	TimeoutMs    int32
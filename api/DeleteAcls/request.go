@@ -0,0 +1,33 @@
+package DeleteAcls
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.0+). See client/admin for the values
+// of resource types, pattern types, operations, and permission types.
+func NewRequest(filters []Filter) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DeleteAcls,
+		ApiVersion: 1,
+		Body: Request{
+			Filters: filters,
+		},
+	}
+}
+
+type Request struct {
+	Filters []Filter
+}
+
+// Filter of ACLs to delete: ACLs matching all its fields are deleted. Empty
+// strings (sent as null) match any value.
+type Filter struct {
+	ResourceTypeFilter int8
+	ResourceNameFilter string `wire:"nullable"`
+	PatternTypeFilter  int8   `versions:"1+"`
+	PrincipalFilter    string `wire:"nullable"`
+	HostFilter         string `wire:"nullable"`
+	Operation          int8
+	PermissionType     int8
+}
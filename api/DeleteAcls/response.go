@@ -0,0 +1,24 @@
+package DeleteAcls
+
+type Response struct {
+	ThrottleTimeMs int32
+	FilterResults  []FilterResult // in the order of filters in the request
+}
+
+type FilterResult struct {
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+	MatchingAcls []MatchingAcl
+}
+
+type MatchingAcl struct {
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+	ResourceType   int8
+	ResourceName   string
+	PatternType    int8 `versions:"1+"`
+	Principal      string
+	Host           string
+	Operation      int8
+	PermissionType int8
+}
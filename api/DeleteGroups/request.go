@@ -0,0 +1,22 @@
+package DeleteGroups
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v0 request (Kafka 1.1+). Groups must be coordinated by
+// the broker which receives the request. Only empty groups (with no members)
+// can be deleted.
+func NewRequest(groups []string) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DeleteGroups,
+		ApiVersion: 0,
+		Body: Request{
+			GroupsNames: groups,
+		},
+	}
+}
+
+type Request struct {
+	GroupsNames []string
+}
@@ -0,0 +1,11 @@
+package DeleteGroups
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []Result
+}
+
+type Result struct {
+	GroupId   string
+	ErrorCode int16 // for example ERR_NON_EMPTY_GROUP or ERR_GROUP_ID_NOT_FOUND
+}
@@ -0,0 +1,41 @@
+package DeleteRecords
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// HighWatermark as the offset deletes all records up to the partition high
+// watermark.
+const HighWatermark int64 = -1
+
+// NewRequest returns v1 request (Kafka 1.0+) deleting records with offsets
+// lower than offset (which becomes the partition log start offset, the "low
+// watermark"). It must be sent to the partition leader. TimeoutMs is how long
+// the leader waits for the followers to advance their log start offsets.
+func NewRequest(topic string, partition int32, offset int64, timeoutMs int32) *api.Request {
+	p := []Partition{{PartitionIndex: partition, Offset: offset}}
+	t := []Topic{{Name: topic, Partitions: p}}
+	return &api.Request{
+		ApiKey:     api.DeleteRecords,
+		ApiVersion: 1,
+		Body: Request{
+			Topics:    t,
+			TimeoutMs: timeoutMs,
+		},
+	}
+}
+
+type Request struct {
+	Topics    []Topic
+	TimeoutMs int32
+}
+
+type Topic struct {
+	Name       string
+	Partitions []Partition
+}
+
+type Partition struct {
+	PartitionIndex int32
+	Offset         int64
+}
@@ -0,0 +1,17 @@
+package DeleteRecords
+
+type Response struct {
+	ThrottleTimeMs int32
+	Topics         []TopicResponse
+}
+
+type TopicResponse struct {
+	Name       string
+	Partitions []PartitionResponse
+}
+
+type PartitionResponse struct {
+	PartitionIndex int32
+	LowWatermark   int64
+	ErrorCode      int16
+}
@@ -0,0 +1,24 @@
+package DeleteTopics
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 1.0+). It must be sent to the
+// controller. TimeoutMs is how long to wait for the topics to be deleted on
+// all brokers.
+func NewRequest(topics []string, timeoutMs int32) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DeleteTopics,
+		ApiVersion: 1,
+		Body: Request{
+			TopicNames: topics,
+			TimeoutMs:  timeoutMs,
+		},
+	}
+}
+
+type Request struct {
+	TopicNames []string
+	TimeoutMs  int32
+}
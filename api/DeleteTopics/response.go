@@ -0,0 +1,11 @@
+package DeleteTopics
+
+type Response struct {
+	ThrottleTimeMs int32 `versions:"1+"`
+	Responses      []TopicResponse
+}
+
+type TopicResponse struct {
+	Name      string
+	ErrorCode int16
+}
@@ -0,0 +1,27 @@
+package DescribeAcls
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.0+). See client/admin for the values
+// of resource types, pattern types, operations, and permission types.
+func NewRequest(filter *Request) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DescribeAcls,
+		ApiVersion: 1,
+		Body:       *filter,
+	}
+}
+
+// Request is a filter: ACLs matching all its fields are described. Empty
+// strings (sent as null) match any value.
+type Request struct {
+	ResourceTypeFilter int8
+	ResourceNameFilter string `wire:"nullable"`
+	PatternTypeFilter  int8   `versions:"1+"`
+	PrincipalFilter    string `wire:"nullable"`
+	HostFilter         string `wire:"nullable"`
+	Operation          int8
+	PermissionType     int8
+}
@@ -0,0 +1,22 @@
+package DescribeAcls
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+	Resources      []Resource
+}
+
+type Resource struct {
+	ResourceType int8
+	ResourceName string
+	PatternType  int8 `versions:"1+"`
+	Acls         []Acl
+}
+
+type Acl struct {
+	Principal      string
+	Host           string
+	Operation      int8
+	PermissionType int8
+}
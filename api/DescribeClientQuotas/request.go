@@ -0,0 +1,44 @@
+package DescribeClientQuotas
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// Component match types.
+const (
+	// MatchExact matches entities with the component name equal to Match.
+	MatchExact int8 = 0
+	// MatchDefault matches default entities of the component type.
+	MatchDefault int8 = 1
+	// MatchAny matches entities with any name (including default) of the
+	// component type.
+	MatchAny int8 = 2
+)
+
+// NewRequest returns v1 request (Kafka 2.8+, KIP-546) describing quotas of
+// entities matching all the components. With strict, only entities with
+// exactly the component types are matched (otherwise entities with
+// additional types match as well). The request uses the flexible encoding.
+func NewRequest(components []Component, strict bool) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DescribeClientQuotas,
+		ApiVersion: 1,
+		Body: Request{
+			Components: components,
+			Strict:     strict,
+		},
+	}
+}
+
+type Request struct {
+	Components []Component
+	Strict     bool
+}
+
+type Component struct {
+	// EntityType is "user", "client-id", or "ip".
+	EntityType string
+	MatchType  int8
+	// Match is the entity name for MatchExact (null otherwise).
+	Match string `wire:"nullable"`
+}
@@ -0,0 +1,24 @@
+package DescribeClientQuotas
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+	Entries        []Entry
+}
+
+type Entry struct {
+	Entity []Entity
+	Values []Value
+}
+
+type Entity struct {
+	EntityType string
+	// EntityName is null (read as empty string) for the default entity.
+	EntityName string `wire:"nullable"`
+}
+
+type Value struct {
+	Key   string
+	Value float64
+}
@@ -0,0 +1,40 @@
+package DescribeConfigs
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// Config resource types.
+const (
+	ResourceUnknown      int8 = 0
+	ResourceTopic        int8 = 2
+	ResourceBroker       int8 = 4
+	ResourceBrokerLogger int8 = 8
+)
+
+// NewRequest returns v1 request (Kafka 1.1+). Configs of broker resources
+// (other than the cluster wide defaults, which have empty resource name) must
+// be described by the broker itself.
+func NewRequest(resources []Resource, includeSynonyms bool) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DescribeConfigs,
+		ApiVersion: 1,
+		Body: Request{
+			Resources:       resources,
+			IncludeSynonyms: includeSynonyms,
+		},
+	}
+}
+
+type Request struct {
+	Resources       []Resource
+	IncludeSynonyms bool `versions:"1+"`
+}
+
+type Resource struct {
+	ResourceType int8
+	ResourceName string
+	// ConfigurationKeys to describe. Nil (sent as null) describes all
+	// configs of the resource.
+	ConfigurationKeys []string
+}
@@ -0,0 +1,46 @@
+package DescribeConfigs
+
+// Config sources (v1+).
+const (
+	SourceUnknown                    int8 = 0
+	SourceDynamicTopicConfig         int8 = 1
+	SourceDynamicBrokerConfig        int8 = 2
+	SourceDynamicDefaultBrokerConfig int8 = 3
+	SourceStaticBrokerConfig         int8 = 4
+	SourceDefaultConfig              int8 = 5
+	SourceDynamicBrokerLoggerConfig  int8 = 6
+)
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []Result
+}
+
+type Result struct {
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+	ResourceType int8
+	ResourceName string
+	Configs      []Config
+}
+
+type Config struct {
+	Name string
+	// Value is empty for sensitive configs (and for configs with no
+	// value).
+	Value     string `wire:"nullable"`
+	ReadOnly  bool
+	IsDefault bool `versions:"0"` // in v1+ ConfigSource is SourceDefaultConfig
+	// ConfigSource is one of the Source* values.
+	ConfigSource int8 `versions:"1+"`
+	IsSensitive  bool
+	// Synonyms are other configs (with different sources) which set the
+	// same value, in order of precedence. Included only if requested.
+	Synonyms []Synonym `versions:"1+"`
+}
+
+type Synonym struct {
+	Name   string
+	Value  string `wire:"nullable"`
+	Source int8
+}
@@ -0,0 +1,27 @@
+package DescribeDelegationToken
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 1.1+) describing tokens owned by the
+// owners (nil, sent as null, for all tokens). Only tokens which the principal
+// of the connection owns, renews, or may describe are returned.
+func NewRequest(owners []Owner) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DescribeDelegationToken,
+		ApiVersion: 1,
+		Body: Request{
+			Owners: owners,
+		},
+	}
+}
+
+type Request struct {
+	Owners []Owner
+}
+
+type Owner struct {
+	PrincipalType string
+	PrincipalName string
+}
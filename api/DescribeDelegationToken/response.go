@@ -0,0 +1,18 @@
+package DescribeDelegationToken
+
+type Response struct {
+	ErrorCode      int16
+	Tokens         []Token
+	ThrottleTimeMs int32
+}
+
+type Token struct {
+	PrincipalType     string
+	PrincipalName     string
+	IssueTimestampMs  int64
+	ExpiryTimestampMs int64
+	MaxTimestampMs    int64
+	TokenId           string
+	Hmac              []byte
+	Renewers          []Owner
+}
@@ -0,0 +1,22 @@
+package DescribeGroups
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request. Groups must be coordinated by the broker
+// which receives the request.
+func NewRequest(groups []string) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DescribeGroups,
+		ApiVersion: 1,
+		Body: Request{
+			Groups: groups,
+		},
+	}
+}
+
+type Request struct {
+	Groups                      []string
+	IncludeAuthorizedOperations bool `versions:"3+"`
+}
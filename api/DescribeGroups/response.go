@@ -0,0 +1,34 @@
+package DescribeGroups
+
+// Group states
+const (
+	StateEmpty               = "Empty"
+	StatePreparingRebalance  = "PreparingRebalance"
+	StateCompletingRebalance = "CompletingRebalance" // "AwaitingSync" before Kafka 2.0
+	StateStable              = "Stable"
+	StateDead                = "Dead"
+)
+
+type Response struct {
+	ThrottleTimeMs int32
+	Groups         []Group
+}
+
+type Group struct {
+	ErrorCode            int16
+	GroupId              string
+	GroupState           string
+	ProtocolType         string
+	ProtocolData         string // name of the protocol selected for the group
+	Members              []Member
+	AuthorizedOperations int32 `versions:"3+"`
+}
+
+type Member struct {
+	MemberId         string
+	GroupInstanceId  string `versions:"4+" wire:"nullable"`
+	ClientId         string
+	ClientHost       string
+	MemberMetadata   []byte // JoinGroup metadata
+	MemberAssignment []byte // SyncGroup assignment
+}
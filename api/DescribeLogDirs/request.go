@@ -0,0 +1,27 @@
+package DescribeLogDirs
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.0+) describing the log dirs of the
+// broker to which it is sent. Nil topics (sent as null) describe all
+// partitions which have replicas on the broker.
+func NewRequest(topics []Topic) *api.Request {
+	return &api.Request{
+		ApiKey:     api.DescribeLogDirs,
+		ApiVersion: 1,
+		Body: Request{
+			Topics: topics,
+		},
+	}
+}
+
+type Request struct {
+	Topics []Topic
+}
+
+type Topic struct {
+	Topic            string
+	PartitionIndexes []int32
+}
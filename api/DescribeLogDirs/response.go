@@ -0,0 +1,32 @@
+package DescribeLogDirs
+
+type Response struct {
+	ThrottleTimeMs int32
+	Results        []Result
+}
+
+// Result for a log dir. ErrorCode is ERR_KAFKA_STORAGE_ERROR if the log dir
+// is offline.
+type Result struct {
+	ErrorCode int16
+	LogDir    string
+	Topics    []TopicResult
+}
+
+type TopicResult struct {
+	Name       string
+	Partitions []Partition
+}
+
+type Partition struct {
+	PartitionIndex int32
+	// PartitionSize is the size of the replica log in bytes.
+	PartitionSize int64
+	// OffsetLag is how far behind the replica is: the difference between
+	// the high watermark and the replica log end offset (or, for a future
+	// replica, between the current and the future replica log end offsets).
+	OffsetLag int64
+	// IsFutureKey is true for future replicas: replicas being moved to
+	// the log dir (with AlterReplicaLogDirs).
+	IsFutureKey bool
+}
@@ -0,0 +1,41 @@
+package DescribeUserScramCredentials
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// SCRAM mechanisms.
+const (
+	MechanismUnknown int8 = 0
+	ScramSha256      int8 = 1
+	ScramSha512      int8 = 2
+)
+
+// NewRequest returns v0 request (Kafka 2.7+, KIP-554) describing SCRAM
+// credentials of the users (nil, sent as null, for all users). An empty users
+// slice is sent as an empty array, which brokers also treat as all users. The
+// request uses the flexible encoding.
+func NewRequest(users []string) *api.Request {
+	var u []User
+	if users != nil {
+		u = make([]User, 0, len(users))
+	}
+	for _, name := range users {
+		u = append(u, User{Name: name})
+	}
+	return &api.Request{
+		ApiKey:     api.DescribeUserScramCredentials,
+		ApiVersion: 0,
+		Body: Request{
+			Users: u,
+		},
+	}
+}
+
+type Request struct {
+	Users []User
+}
+
+type User struct {
+	Name string
+}
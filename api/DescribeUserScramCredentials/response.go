@@ -0,0 +1,22 @@
+package DescribeUserScramCredentials
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+	Results        []Result
+}
+
+type Result struct {
+	User string
+	// ErrorCode is ERR_RESOURCE_NOT_FOUND for requested users without
+	// credentials.
+	ErrorCode       int16
+	ErrorMessage    string `wire:"nullable"`
+	CredentialInfos []CredentialInfo
+}
+
+type CredentialInfo struct {
+	Mechanism  int8
+	Iterations int32
+}
@@ -0,0 +1,41 @@
+package ElectLeaders
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// Election types (KIP-460).
+const (
+	// Preferred elects the preferred replica (the first replica in the
+	// assignment) if it is in sync.
+	Preferred int8 = 0
+	// Unclean elects any live replica, also one which is not in sync (and
+	// which may be missing records), if no in sync replica is available.
+	Unclean int8 = 1
+)
+
+// NewRequest returns v1 request (Kafka 2.4+). It must be sent to the
+// controller. Nil partitions (sent as null) run the election for all
+// partitions.
+func NewRequest(electionType int8, partitions []TopicPartitions, timeoutMs int32) *api.Request {
+	return &api.Request{
+		ApiKey:     api.ElectLeaders,
+		ApiVersion: 1,
+		Body: Request{
+			ElectionType:    electionType,
+			TopicPartitions: partitions,
+			TimeoutMs:       timeoutMs,
+		},
+	}
+}
+
+type Request struct {
+	ElectionType    int8 `versions:"1+"`
+	TopicPartitions []TopicPartitions
+	TimeoutMs       int32
+}
+
+type TopicPartitions struct {
+	Topic      string
+	Partitions []int32
+}
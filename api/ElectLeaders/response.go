@@ -0,0 +1,18 @@
+package ElectLeaders
+
+type Response struct {
+	ThrottleTimeMs         int32
+	ErrorCode              int16 `versions:"1+"`
+	ReplicaElectionResults []ReplicaElectionResult
+}
+
+type ReplicaElectionResult struct {
+	Topic           string
+	PartitionResult []PartitionResult
+}
+
+type PartitionResult struct {
+	PartitionId  int32
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+}
@@ -0,0 +1,30 @@
+package ElectPreferredLeaders
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v0 request (Kafka 2.2+) electing the preferred replicas
+// as partition leaders. It must be sent to the controller. Nil partitions
+// (sent as null) elect preferred leaders for all partitions. In Kafka 2.4+
+// this api is ElectLeaders (which adds election types).
+func NewRequest(partitions []TopicPartitions, timeoutMs int32) *api.Request {
+	return &api.Request{
+		ApiKey:     api.ElectPreferredLeaders,
+		ApiVersion: 0,
+		Body: Request{
+			TopicPartitions: partitions,
+			TimeoutMs:       timeoutMs,
+		},
+	}
+}
+
+type Request struct {
+	TopicPartitions []TopicPartitions
+	TimeoutMs       int32
+}
+
+type TopicPartitions struct {
+	Topic       string
+	PartitionId []int32
+}
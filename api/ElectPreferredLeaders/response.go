@@ -0,0 +1,17 @@
+package ElectPreferredLeaders
+
+type Response struct {
+	ThrottleTimeMs         int32
+	ReplicaElectionResults []ReplicaElectionResult
+}
+
+type ReplicaElectionResult struct {
+	Topic           string
+	PartitionResult []PartitionResult
+}
+
+type PartitionResult struct {
+	PartitionId  int32
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+}
@@ -0,0 +1,24 @@
+package ExpireDelegationToken
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 1.1+) setting the token expiry time
+// to now + expiryTimePeriodMs. Negative period expires the token
+// immediately. It must be sent by the token owner or a renewer.
+func NewRequest(hmac []byte, expiryTimePeriodMs int64) *api.Request {
+	return &api.Request{
+		ApiKey:     api.ExpireDelegationToken,
+		ApiVersion: 1,
+		Body: Request{
+			Hmac:               hmac,
+			ExpiryTimePeriodMs: expiryTimePeriodMs,
+		},
+	}
+}
+
+type Request struct {
+	Hmac               []byte
+	ExpiryTimePeriodMs int64
+}
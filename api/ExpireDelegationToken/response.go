@@ -0,0 +1,7 @@
+package ExpireDelegationToken
+
+type Response struct {
+	ErrorCode         int16
+	ExpiryTimestampMs int64
+	ThrottleTimeMs    int32
+}
@@ -12,17 +12,99 @@ type Args struct {
 	MinBytes      int32
 	MaxBytes      int32
 	MaxWaitTimeMs int32
+	// If RackId is set the request is v11 (Kafka 2.4+) and the leader
+	// may respond with a PreferredReadReplica in the same rack (KIP-392).
+	RackId string
+	// If LeaderEpochs is set the request is at least v9 (Kafka 2.1+) and
+	// has CurrentLeaderEpoch (-1 if unknown) of the partition (KIP-320).
+	// The leader responds with ERR_FENCED_LEADER_EPOCH if the epoch is
+	// older than its own, and with ERR_UNKNOWN_LEADER_EPOCH if it is
+	// newer.
+	LeaderEpochs       bool
+	CurrentLeaderEpoch int32
 }
 
 func NewRequest(args *Args) *api.Request {
 	p := Partition{
-		Partition:         args.Partition,
-		FetchOffset:       args.Offset,
-		PartitionMaxBytes: args.MaxBytes,
+		Partition:          args.Partition,
+		CurrentLeaderEpoch: -1,
+		FetchOffset:        args.Offset,
+		PartitionMaxBytes:  args.MaxBytes,
+	}
+	if args.LeaderEpochs {
+		p.CurrentLeaderEpoch = args.CurrentLeaderEpoch
 	}
 	t := Topic{
 		Topic:      args.Topic,
-		Partitions: []Partition{p, p},
+		Partitions: []Partition{p},
+	}
+	req := &api.Request{
+		ApiKey:        api.Fetch,
+		ApiVersion:    6,
+		CorrelationId: 0,
+		ClientId:      args.ClientId,
+		Body: Request{
+			ReplicaId:       -1,
+			MaxWaitTimeMs:   args.MaxWaitTimeMs,
+			MinBytes:        args.MinBytes,
+			MaxBytes:        args.MaxBytes,
+			SessionId:       0,
+			SessionEpoch:    FinalEpoch, // no session
+			Topics:          []Topic{t},
+			ForgottenTopics: []ForgottenTopic{},
+			RackId:          args.RackId,
+		},
+	}
+	if args.LeaderEpochs {
+		req.ApiVersion = 9
+	}
+	if args.RackId != "" {
+		req.ApiVersion = 11
+	}
+	return req
+}
+
+// PartitionArgs specify the fetch offset and max bytes for a single topic
+// partition in a multiple partitions fetch request.
+type PartitionArgs struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	MaxBytes  int32
+}
+
+type MultiplePartitionsArgs struct {
+	ClientId      string
+	MinBytes      int32
+	MaxBytes      int32 // for the whole response, see PartitionArgs.MaxBytes
+	MaxWaitTimeMs int32
+	Partitions    []PartitionArgs
+}
+
+// NewMultiplePartitionsRequest constructs a Fetch request for multiple topic
+// partitions. All partitions must be led by the broker to which the request is
+// sent. Partitions are grouped by topic, in the order in which the topics first
+// appear in args.Partitions.
+func NewMultiplePartitionsRequest(args *MultiplePartitionsArgs) *api.Request {
+	var topics []Topic
+	index := make(map[string]int)
+	for _, a := range args.Partitions {
+		i, ok := index[a.Topic]
+		if !ok {
+			i = len(topics)
+			index[a.Topic] = i
+			topics = append(topics, Topic{Topic: a.Topic, Partitions: []Partition{}})
+		}
+		p := Partition{
+			Partition:          a.Partition,
+			CurrentLeaderEpoch: -1,
+			FetchOffset:        a.Offset,
+			PartitionMaxBytes:  a.MaxBytes,
+		}
+		topics[i].Partitions = append(topics[i].Partitions, p)
+	}
+	if topics == nil {
+		topics = []Topic{}
 	}
 	return &api.Request{
 		ApiKey:        api.Fetch,
@@ -34,18 +116,50 @@ func NewRequest(args *Args) *api.Request {
 			MaxWaitTimeMs: args.MaxWaitTimeMs,
 			MinBytes:      args.MinBytes,
 			MaxBytes:      args.MaxBytes,
-			Topics:        []Topic{t},
+			Topics:        topics,
 		},
 	}
 }
 
+// NewSessionRequest constructs a Fetch v7 request which is part of a fetch
+// session (KIP-227). To create a new session send a "full" request with
+// sessionId and sessionEpoch both 0 and with all partitions. To make an
+// "incremental" request within an existing session, send the session id and
+// the next epoch, and only the partitions that have been added or changed
+// since the last request. Partitions to be removed from the session are listed
+// in forgotten. To close a session, send its id with epoch -1.
+func NewSessionRequest(args *MultiplePartitionsArgs, sessionId, sessionEpoch int32, forgotten []ForgottenTopic) *api.Request {
+	req := NewMultiplePartitionsRequest(args)
+	req.ApiVersion = 7
+	body := req.Body.(Request)
+	body.SessionId = sessionId
+	body.SessionEpoch = sessionEpoch
+	body.ForgottenTopics = forgotten
+	if body.ForgottenTopics == nil {
+		body.ForgottenTopics = []ForgottenTopic{}
+	}
+	req.Body = body
+	return req
+}
+
+const (
+	// session epoch which, sent with session id 0, means a full fetch
+	// request without creating a session; sent with a session id it
+	// closes the session
+	FinalEpoch int32 = -1
+)
+
 type Request struct {
-	ReplicaId      int32
-	MaxWaitTimeMs  int32
-	MinBytes       int32
-	MaxBytes       int32
-	IsolationLevel int8 // not used
-	Topics         []Topic
+	ReplicaId       int32
+	MaxWaitTimeMs   int32
+	MinBytes        int32
+	MaxBytes        int32
+	IsolationLevel  int8  // not used
+	SessionId       int32 `versions:"7+"`
+	SessionEpoch    int32 `versions:"7+"`
+	Topics          []Topic
+	ForgottenTopics []ForgottenTopic `versions:"7+"`
+	RackId          string           `versions:"11+"`
 }
 
 type Topic struct {
@@ -54,8 +168,14 @@ type Topic struct {
 }
 
 type Partition struct {
-	Partition         int32
-	FetchOffset       int64
-	LogStartOffset    int64 // not used
-	PartitionMaxBytes int32
+	Partition          int32
+	CurrentLeaderEpoch int32 `versions:"9+"` // -1 means unknown
+	FetchOffset        int64
+	LogStartOffset     int64 // not used
+	PartitionMaxBytes  int32
+}
+
+type ForgottenTopic struct {
+	Topic      string
+	Partitions []int32
 }
@@ -2,6 +2,8 @@ package Fetch
 
 type Response struct {
 	ThrottleTimeMs int32
+	ErrorCode      int16 `versions:"7+"` // top level (session) error
+	SessionId      int32 `versions:"7+"`
 	TopicResponses []TopicResponse
 }
 
@@ -22,6 +24,8 @@ type PartitionResponse struct {
 	LastStableOffset    int64
 	LogStartOffset      int64
 	AbortedTransactions []AbortedTransaction
+	// node id of the replica from which the client should fetch, or -1
+	PreferredReadReplica int32 `versions:"11+"`
 	//
 	RecordSet []byte // NULLABLE_BYTES
 }
@@ -5,19 +5,31 @@ import (
 )
 
 func NewRequest(group, member string, generation int32) *api.Request {
-	return &api.Request{
+	return NewStaticRequest(group, member, "", generation)
+}
+
+// NewStaticRequest returns v1 request, or v3 (Kafka 2.3+) if groupInstanceId
+// is set (static membership, KIP-345).
+func NewStaticRequest(group, member, groupInstanceId string, generation int32) *api.Request {
+	req := &api.Request{
 		ApiKey:     api.Heartbeat,
 		ApiVersion: 1,
 		Body: Request{
-			GroupId:      group,
-			GenerationId: generation,
-			MemberId:     member,
+			GroupId:         group,
+			GenerationId:    generation,
+			MemberId:        member,
+			GroupInstanceId: groupInstanceId,
 		},
 	}
+	if groupInstanceId != "" {
+		req.ApiVersion = 3
+	}
+	return req
 }
 
 type Request struct {
-	GroupId      string
-	GenerationId int32
-	MemberId     string
+	GroupId         string
+	GenerationId    int32
+	MemberId        string
+	GroupInstanceId string `versions:"3+" wire:"nullable"`
 }
@@ -0,0 +1,45 @@
+package IncrementalAlterConfigs
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// Config operations (KIP-339).
+const (
+	OpSet      int8 = 0
+	OpDelete   int8 = 1 // revert to default
+	OpAppend   int8 = 2 // append to list config
+	OpSubtract int8 = 3 // remove from list config
+)
+
+// NewRequest returns v0 request (Kafka 2.3+). Unlike AlterConfigs, only the
+// configs in the request are changed. Configs of broker resources (other than
+// the cluster wide defaults, which have empty resource name) must be altered
+// by the broker itself. Resource types are the same as in DescribeConfigs.
+func NewRequest(resources []Resource, validateOnly bool) *api.Request {
+	return &api.Request{
+		ApiKey:     api.IncrementalAlterConfigs,
+		ApiVersion: 0,
+		Body: Request{
+			Resources:    resources,
+			ValidateOnly: validateOnly,
+		},
+	}
+}
+
+type Request struct {
+	Resources    []Resource
+	ValidateOnly bool
+}
+
+type Resource struct {
+	ResourceType int8
+	ResourceName string
+	Configs      []Config
+}
+
+type Config struct {
+	Name            string
+	ConfigOperation int8
+	Value           string `wire:"nullable"`
+}
@@ -0,0 +1,13 @@
+package IncrementalAlterConfigs
+
+type Response struct {
+	ThrottleTimeMs int32
+	Responses      []ResourceResponse
+}
+
+type ResourceResponse struct {
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+	ResourceType int8
+	ResourceName string
+}
@@ -6,19 +6,61 @@ import (
 	"github.com/mkocikowski/libkafka/api"
 )
 
+const (
+	SessionTimeoutMs   = 10000 // if no heartbeat this long then rebalance
+	RebalanceTimeoutMs = 5000  // wait this long for members to join
+)
+
+type Args struct {
+	GroupId      string
+	MemberId     string
+	ProtocolType string
+	Protocols    []Protocol
+	// Zero values mean the SessionTimeoutMs and RebalanceTimeoutMs
+	// defaults.
+	SessionTimeoutMs   int32
+	RebalanceTimeoutMs int32
+	// If GroupInstanceId is set the member is a static member (KIP-345)
+	// and the request is v5 (Kafka 2.3+).
+	GroupInstanceId string
+}
+
 func NewRequest(group, member, protocol string, protocols []Protocol) *api.Request {
-	return &api.Request{
+	return NewRequestWithArgs(&Args{
+		GroupId:      group,
+		MemberId:     member,
+		ProtocolType: protocol,
+		Protocols:    protocols,
+	})
+}
+
+// NewRequestWithArgs returns v2 request, or v5 (Kafka 2.3+) if
+// GroupInstanceId is set.
+func NewRequestWithArgs(args *Args) *api.Request {
+	r := Request{
+		GroupId:            args.GroupId,
+		SessionTimeoutMs:   args.SessionTimeoutMs,
+		RebalanceTimeoutMs: args.RebalanceTimeoutMs,
+		MemberId:           args.MemberId,
+		GroupInstanceId:    args.GroupInstanceId,
+		ProtocolType:       args.ProtocolType,
+		Protocols:          args.Protocols,
+	}
+	if r.SessionTimeoutMs == 0 {
+		r.SessionTimeoutMs = SessionTimeoutMs
+	}
+	if r.RebalanceTimeoutMs == 0 {
+		r.RebalanceTimeoutMs = RebalanceTimeoutMs
+	}
+	req := &api.Request{
 		ApiKey:     api.JoinGroup,
 		ApiVersion: 2,
-		Body: Request{
-			GroupId:            group,
-			SessionTimeoutMs:   10000, // if no heartbeat this long then rebalance
-			RebalanceTimeoutMs: 5000,  // wait this long for members to join
-			MemberId:           member,
-			ProtocolType:       protocol,
-			Protocols:          protocols,
-		},
+		Body:       r,
+	}
+	if args.GroupInstanceId != "" {
+		req.ApiVersion = 5
 	}
+	return req
 }
 
 type Request struct {
@@ -26,6 +68,7 @@ type Request struct {
 	SessionTimeoutMs   int32
 	RebalanceTimeoutMs int32
 	MemberId           string
+	GroupInstanceId    string `versions:"5+" wire:"nullable"`
 	ProtocolType       string
 	Protocols          []Protocol
 }
@@ -11,6 +11,7 @@ type Response struct {
 }
 
 type Member struct {
-	MemberId string
-	Metadata []byte
+	MemberId        string
+	GroupInstanceId string `versions:"5+" wire:"nullable"`
+	Metadata        []byte
 }
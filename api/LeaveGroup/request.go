@@ -0,0 +1,38 @@
+package LeaveGroup
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request for a single (dynamic) member. If
+// groupInstanceId is set the request is v3 (Kafka 2.3+) which identifies
+// static members (KIP-345) by their group instance id; member can then be
+// empty.
+func NewRequest(group, member, groupInstanceId string) *api.Request {
+	req := &api.Request{
+		ApiKey:     api.LeaveGroup,
+		ApiVersion: 1,
+		Body: Request{
+			GroupId:  group,
+			MemberId: member,
+			Members:  []Member{{MemberId: member, GroupInstanceId: groupInstanceId}},
+		},
+	}
+	if groupInstanceId != "" {
+		req.ApiVersion = 3
+	}
+	return req
+}
+
+// Version 3 replaced MemberId with a list of Members, so that multiple (static)
+// members can be removed in a single request.
+type Request struct {
+	GroupId  string
+	MemberId string   `versions:"0-2"`
+	Members  []Member `versions:"3+"`
+}
+
+type Member struct {
+	MemberId        string
+	GroupInstanceId string `wire:"nullable"`
+}
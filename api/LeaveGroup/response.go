@@ -0,0 +1,13 @@
+package LeaveGroup
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	Members        []MemberResponse `versions:"3+"`
+}
+
+type MemberResponse struct {
+	MemberId        string
+	GroupInstanceId string `wire:"nullable"`
+	ErrorCode       int16
+}
@@ -0,0 +1,18 @@
+package ListGroups
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request. ListGroups lists only groups coordinated by
+// the broker which receives the request, so to list all groups in the cluster
+// the request must be sent to every broker.
+func NewRequest() *api.Request {
+	return &api.Request{
+		ApiKey:     api.ListGroups,
+		ApiVersion: 1,
+		Body:       Request{},
+	}
+}
+
+type Request struct{}
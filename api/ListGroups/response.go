@@ -0,0 +1,12 @@
+package ListGroups
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	Groups         []Group
+}
+
+type Group struct {
+	GroupId      string
+	ProtocolType string // "consumer" for consumer groups, "" for groups used only to commit offsets
+}
@@ -4,6 +4,12 @@ import (
 	"github.com/mkocikowski/libkafka/api"
 )
 
+// Special timestamp values.
+const (
+	Latest   int64 = -1 // offset of the next message to be produced (log end offset)
+	Earliest int64 = -2 // offset of the first message in the log (log start offset)
+)
+
 // timestamp is milliseconds since epoch
 func NewRequest(topic string, partition int32, timestampMs int64) *api.Request {
 	p := []RequestPartition{{Partition: partition, Timestamp: timestampMs}}
@@ -19,6 +25,41 @@ func NewRequest(topic string, partition int32, timestampMs int64) *api.Request {
 	}
 }
 
+type PartitionArgs struct {
+	Topic       string
+	Partition   int32
+	TimestampMs int64
+}
+
+// NewMultiplePartitionsRequest lists offsets for multiple partitions, each
+// with its own timestamp. Partitions must be led by the broker which gets the
+// request, and each partition can be in the request only once.
+func NewMultiplePartitionsRequest(partitions []PartitionArgs) *api.Request {
+	var topics []RequestTopic
+	index := make(map[string]int)
+	for _, p := range partitions {
+		i, ok := index[p.Topic]
+		if !ok {
+			i = len(topics)
+			index[p.Topic] = i
+			topics = append(topics, RequestTopic{Topic: p.Topic})
+		}
+		topics[i].Partitions = append(topics[i].Partitions, RequestPartition{Partition: p.Partition, Timestamp: p.TimestampMs})
+	}
+	if topics == nil {
+		topics = []RequestTopic{}
+	}
+	return &api.Request{
+		ApiKey:     api.ListOffsets,
+		ApiVersion: 2,
+		Body: RequestBody{
+			ReplicaId:      -1,
+			IsolationLevel: 0,
+			Topics:         topics,
+		},
+	}
+}
+
 type RequestBody struct {
 	ReplicaId      int32
 	IsolationLevel int8
@@ -0,0 +1,29 @@
+package ListPartitionReassignments
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v0 request (Kafka 2.4+, KIP-455). It must be sent to the
+// controller. Nil topics (sent as null) lists all reassignments in progress.
+// The request uses the flexible encoding.
+func NewRequest(topics []Topic, timeoutMs int32) *api.Request {
+	return &api.Request{
+		ApiKey:     api.ListPartitionReassignments,
+		ApiVersion: 0,
+		Body: Request{
+			TimeoutMs: timeoutMs,
+			Topics:    topics,
+		},
+	}
+}
+
+type Request struct {
+	TimeoutMs int32
+	Topics    []Topic
+}
+
+type Topic struct {
+	Name             string
+	PartitionIndexes []int32
+}
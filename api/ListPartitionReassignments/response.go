@@ -0,0 +1,23 @@
+package ListPartitionReassignments
+
+type Response struct {
+	ThrottleTimeMs int32
+	ErrorCode      int16
+	ErrorMessage   string `wire:"nullable"`
+	Topics         []TopicResponse
+}
+
+// TopicResponse lists only partitions which are being reassigned.
+type TopicResponse struct {
+	Name       string
+	Partitions []PartitionResponse
+}
+
+type PartitionResponse struct {
+	PartitionIndex int32
+	// Replicas is the current replica set (which includes the adding and
+	// the removing replicas).
+	Replicas         []int32
+	AddingReplicas   []int32
+	RemovingReplicas []int32
+}
@@ -15,6 +15,14 @@ func NewRequest(topics []string) *api.Request {
 	}
 }
 
+// NewLeaderEpochsRequest returns v7 request (Kafka 2.1+). Unlike v5
+// responses, v7 responses have partition leader epochs (KIP-320).
+func NewLeaderEpochsRequest(topics []string) *api.Request {
+	req := NewRequest(topics)
+	req.ApiVersion = 7
+	return req
+}
+
 type Request struct {
 	Topics                 []string
 	AllowAutoTopicCreation bool
@@ -40,6 +40,7 @@ type PartitionMetadata struct {
 	ErrorCode       int16
 	Partition       int32
 	Leader          int32
+	LeaderEpoch     int32 `versions:"7+"` // -1 if unknown
 	Replicas        []int32
 	Isr             []int32
 	OfflineReplicas []int32
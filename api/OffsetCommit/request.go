@@ -1,6 +1,8 @@
 package OffsetCommit
 
 import (
+	"sort"
+
 	"github.com/mkocikowski/libkafka/api"
 )
 
@@ -58,11 +60,78 @@ func NewRequest(group, topic string, partition int32, offset, retentionMs int64)
 	}
 }
 
+type Offset struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Metadata  string
+}
+
+type MultipleTopicsArgs struct {
+	GroupId string
+	// GenerationId and MemberId of the group member making the commit.
+	// The coordinator rejects commits from members of older generations
+	// (ERR_ILLEGAL_GENERATION) and from members no longer in the group
+	// (ERR_UNKNOWN_MEMBER_ID), which fences "zombie" members after a
+	// rebalance. Commits made outside of group membership use -1 and "".
+	GenerationId int32
+	MemberId     string
+	// If GroupInstanceId is set (static member) the request is v7 (Kafka
+	// 2.3+), which has no retention time (RetentionTimeMs is ignored and
+	// the broker offsets.retention.minutes setting applies).
+	GroupInstanceId string
+	RetentionTimeMs int64 // -1 for broker default
+	Offsets         []Offset
+}
+
+// NewMultipleTopicsRequest commits offsets (and their metadata) for any
+// number of partitions of any number of topics. Topics and partitions in the
+// request are sorted.
+func NewMultipleTopicsRequest(args *MultipleTopicsArgs) *api.Request {
+	offsets := append([]Offset(nil), args.Offsets...)
+	sort.Slice(offsets, func(i, j int) bool {
+		if offsets[i].Topic != offsets[j].Topic {
+			return offsets[i].Topic < offsets[j].Topic
+		}
+		return offsets[i].Partition < offsets[j].Partition
+	})
+	topics := []Topic{}
+	for _, o := range offsets {
+		if n := len(topics); n == 0 || topics[n-1].Name != o.Topic {
+			topics = append(topics, Topic{Name: o.Topic})
+		}
+		t := &(topics[len(topics)-1])
+		t.Partitions = append(t.Partitions, Partition{
+			PartitionIndex:       o.Partition,
+			CommitedOffset:       o.Offset,
+			CommittedLeaderEpoch: -1,
+			CommitedMetadata:     o.Metadata,
+		})
+	}
+	req := &api.Request{
+		ApiKey:     api.OffsetCommit,
+		ApiVersion: 2,
+		Body: Request{
+			GroupId:         args.GroupId,
+			GenerationId:    args.GenerationId,
+			MemberId:        args.MemberId,
+			GroupInstanceId: args.GroupInstanceId,
+			RetentionTimeMs: args.RetentionTimeMs,
+			Topics:          topics,
+		},
+	}
+	if args.GroupInstanceId != "" {
+		req.ApiVersion = 7
+	}
+	return req
+}
+
 type Request struct {
 	GroupId         string
 	GenerationId    int32
 	MemberId        string
-	RetentionTimeMs int64
+	GroupInstanceId string `versions:"7+" wire:"nullable"`
+	RetentionTimeMs int64  `versions:"2-4"`
 	Topics          []Topic
 }
 
@@ -72,7 +141,8 @@ type Topic struct {
 }
 
 type Partition struct {
-	PartitionIndex   int32
-	CommitedOffset   int64
-	CommitedMetadata string
+	PartitionIndex       int32
+	CommitedOffset       int64
+	CommittedLeaderEpoch int32 `versions:"6+"`
+	CommitedMetadata     string
 }
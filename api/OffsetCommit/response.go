@@ -1,7 +1,8 @@
 package OffsetCommit
 
 type Response struct {
-	Topics []TopicResponse
+	ThrottleTimeMs int32 `versions:"3+"`
+	Topics         []TopicResponse
 }
 
 type TopicResponse struct {
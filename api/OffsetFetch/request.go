@@ -1,6 +1,8 @@
 package OffsetFetch
 
 import (
+	"sort"
+
 	"github.com/mkocikowski/libkafka/api"
 )
 
@@ -19,9 +21,43 @@ func NewRequest(group, topic string, partition int32) *api.Request {
 	}
 }
 
+// NewMultipleTopicsRequest fetches offsets for any number of partitions of any
+// number of topics (keyed by topic). Topics and partitions in the request are
+// sorted.
+func NewMultipleTopicsRequest(group string, partitions map[string][]int32) *api.Request {
+	topics := []Topic{}
+	for topic, p := range partitions {
+		indexes := append([]int32{}, p...)
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+		topics = append(topics, Topic{Name: topic, PartitionIndexes: indexes})
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return &api.Request{
+		ApiKey:     api.OffsetFetch,
+		ApiVersion: 3,
+		Body: Request{
+			GroupId: group,
+			Topics:  topics,
+		},
+	}
+}
+
+// NewAllTopicsRequest fetches all offsets committed by the group (null topics
+// array, v2+). Response has only partitions with committed offsets.
+func NewAllTopicsRequest(group string) *api.Request {
+	return &api.Request{
+		ApiKey:     api.OffsetFetch,
+		ApiVersion: 3,
+		Body: Request{
+			GroupId: group,
+			Topics:  nil,
+		},
+	}
+}
+
 type Request struct {
 	GroupId string
-	Topics  []Topic
+	Topics  []Topic // nil for all topics
 }
 
 type Topic struct {
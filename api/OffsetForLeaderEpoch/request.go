@@ -0,0 +1,41 @@
+package OffsetForLeaderEpoch
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v2 request (Kafka 2.1+, KIP-320) for the end offset of
+// leaderEpoch in the log of the partition leader. The leader responds with
+// ERR_FENCED_LEADER_EPOCH if currentLeaderEpoch is older than its own (and
+// with ERR_UNKNOWN_LEADER_EPOCH if it is newer); -1 skips the check.
+func NewRequest(topic string, partition, currentLeaderEpoch, leaderEpoch int32) *api.Request {
+	p := []Partition{{
+		Partition:          partition,
+		CurrentLeaderEpoch: currentLeaderEpoch,
+		LeaderEpoch:        leaderEpoch,
+	}}
+	return &api.Request{
+		ApiKey:     api.OffsetForLeaderEpoch,
+		ApiVersion: 2,
+		Body: Request{
+			ReplicaId: -1,
+			Topics:    []Topic{{Topic: topic, Partitions: p}},
+		},
+	}
+}
+
+type Request struct {
+	ReplicaId int32 `versions:"3+"`
+	Topics    []Topic
+}
+
+type Topic struct {
+	Topic      string
+	Partitions []Partition
+}
+
+type Partition struct {
+	Partition          int32
+	CurrentLeaderEpoch int32 `versions:"2+"`
+	LeaderEpoch        int32
+}
@@ -0,0 +1,37 @@
+package OffsetForLeaderEpoch
+
+// UndefinedOffset and UndefinedEpoch are returned when the leader has no
+// information about the requested epoch (for example when the log has
+// messages in the format older than v2).
+const (
+	UndefinedOffset int64 = -1
+	UndefinedEpoch  int32 = -1
+)
+
+type Response struct {
+	ThrottleTimeMs int32 `versions:"2+"`
+	Topics         []TopicResponse
+}
+
+// PartitionResponse returns the response for the first partition of the first
+// topic, or nil (for single partition requests).
+func (r *Response) PartitionResponse() *PartitionResponse {
+	defer func() { recover() }()
+	return &(r.Topics[0].Partitions[0])
+}
+
+type TopicResponse struct {
+	Topic      string
+	Partitions []PartitionResponse
+}
+
+type PartitionResponse struct {
+	ErrorCode int16
+	Partition int32
+	// LeaderEpoch is the largest epoch, not greater than the requested
+	// epoch, in the log of the leader.
+	LeaderEpoch int32 `versions:"1+"`
+	// EndOffset of LeaderEpoch: the start offset of the next epoch, or
+	// the log end offset if LeaderEpoch is the current epoch.
+	EndOffset int64
+}
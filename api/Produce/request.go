@@ -35,6 +35,58 @@ func NewRequest(args *Args, recordSet []byte) *api.Request {
 	}
 }
 
+// PartitionRecordSet is the record set to be produced to a single topic
+// partition in a multiple partitions produce request.
+type PartitionRecordSet struct {
+	Topic     string
+	Partition int32
+	RecordSet []byte
+}
+
+type MultiplePartitionsArgs struct {
+	ClientId   string
+	Acks       int16 // 0: no, 1: leader only, -1: all ISRs (as specified by min.insync.replicas)
+	TimeoutMs  int32
+	RecordSets []PartitionRecordSet
+}
+
+// NewMultiplePartitionsRequest constructs a Produce request for multiple topic
+// partitions. All partitions must be led by the broker to which the request is
+// sent. Record sets are grouped by topic, in the order in which the topics
+// first appear in args.RecordSets.
+func NewMultiplePartitionsRequest(args *MultiplePartitionsArgs) *api.Request {
+	var topics []TopicData
+	index := make(map[string]int)
+	for _, rs := range args.RecordSets {
+		i, ok := index[rs.Topic]
+		if !ok {
+			i = len(topics)
+			index[rs.Topic] = i
+			topics = append(topics, TopicData{Topic: rs.Topic, Data: []Data{}})
+		}
+		d := Data{
+			Partition: rs.Partition,
+			RecordSet: rs.RecordSet,
+		}
+		topics[i].Data = append(topics[i].Data, d)
+	}
+	if topics == nil {
+		topics = []TopicData{}
+	}
+	return &api.Request{
+		ApiKey:        api.Produce,
+		ApiVersion:    7,
+		CorrelationId: 0,
+		ClientId:      args.ClientId,
+		Body: Request{
+			TransactionalId: "",
+			Acks:            args.Acks,
+			TimeoutMs:       args.TimeoutMs,
+			TopicData:       topics,
+		},
+	}
+}
+
 type Request struct {
 	TransactionalId string // NULLABLE_STRING
 	Acks            int16  // 0: no, 1: leader only, -1: all ISRs (as specified by min.insync.replicas)
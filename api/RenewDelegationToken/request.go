@@ -0,0 +1,25 @@
+package RenewDelegationToken
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 1.1+) extending the token expiry time
+// to now + renewPeriodMs (but not past the token max lifetime). It must be
+// sent by the token owner or a renewer. RenewPeriodMs -1 uses the broker
+// default (delegation.token.expiry.time.ms).
+func NewRequest(hmac []byte, renewPeriodMs int64) *api.Request {
+	return &api.Request{
+		ApiKey:     api.RenewDelegationToken,
+		ApiVersion: 1,
+		Body: Request{
+			Hmac:          hmac,
+			RenewPeriodMs: renewPeriodMs,
+		},
+	}
+}
+
+type Request struct {
+	Hmac          []byte
+	RenewPeriodMs int64
+}
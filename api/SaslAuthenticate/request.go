@@ -0,0 +1,21 @@
+package SaslAuthenticate
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 2.2+) carrying a message of the SASL
+// mechanism authentication exchange.
+func NewRequest(authBytes []byte) *api.Request {
+	return &api.Request{
+		ApiKey:     api.SaslAuthenticate,
+		ApiVersion: 1,
+		Body: Request{
+			AuthBytes: authBytes,
+		},
+	}
+}
+
+type Request struct {
+	AuthBytes []byte
+}
@@ -0,0 +1,10 @@
+package SaslAuthenticate
+
+type Response struct {
+	ErrorCode    int16
+	ErrorMessage string `wire:"nullable"`
+	AuthBytes    []byte
+	// SessionLifetimeMs after which the broker closes the connection (0
+	// if the session does not expire).
+	SessionLifetimeMs int64 `versions:"1+"`
+}
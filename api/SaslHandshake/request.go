@@ -0,0 +1,21 @@
+package SaslHandshake
+
+import (
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// NewRequest returns v1 request (Kafka 1.0+). With v1 the authentication
+// exchange that follows is made with SaslAuthenticate requests.
+func NewRequest(mechanism string) *api.Request {
+	return &api.Request{
+		ApiKey:     api.SaslHandshake,
+		ApiVersion: 1,
+		Body: Request{
+			Mechanism: mechanism,
+		},
+	}
+}
+
+type Request struct {
+	Mechanism string
+}
@@ -0,0 +1,7 @@
+package SaslHandshake
+
+type Response struct {
+	ErrorCode int16
+	// Mechanisms enabled on the broker.
+	Mechanisms []string
+}
@@ -7,23 +7,35 @@ import (
 )
 
 func NewRequest(group, member string, generation int32, assignments []Assignment) *api.Request {
-	return &api.Request{
+	return NewStaticRequest(group, member, "", generation, assignments)
+}
+
+// NewStaticRequest returns v1 request, or v3 (Kafka 2.3+) if groupInstanceId
+// is set (static membership, KIP-345).
+func NewStaticRequest(group, member, groupInstanceId string, generation int32, assignments []Assignment) *api.Request {
+	req := &api.Request{
 		ApiKey:     api.SyncGroup,
 		ApiVersion: 1,
 		Body: Request{
-			GroupId:      group,
-			GenerationId: generation,
-			MemberId:     member,
-			Assignments:  assignments,
+			GroupId:         group,
+			GenerationId:    generation,
+			MemberId:        member,
+			GroupInstanceId: groupInstanceId,
+			Assignments:     assignments,
 		},
 	}
+	if groupInstanceId != "" {
+		req.ApiVersion = 3
+	}
+	return req
 }
 
 type Request struct {
-	GroupId      string
-	GenerationId int32
-	MemberId     string
-	Assignments  []Assignment
+	GroupId         string
+	GenerationId    int32
+	MemberId        string
+	GroupInstanceId string `versions:"3+" wire:"nullable"`
+	Assignments     []Assignment
 }
 
 type Assignment struct {
@@ -2,50 +2,59 @@
 package api
 
 const (
-	Produce                 int16 = 0 // 1_0:5 2_3:7
-	Fetch                         = 1 // 1_0:6
-	ListOffsets                   = 2 // 1_0:2
-	Metadata                      = 3 // 1_0:5
-	LeaderAndIsr                  = 4
-	StopReplica                   = 5
-	UpdateMetadata                = 6
-	ControlledShutdown            = 7
-	OffsetCommit                  = 8  // 1_0:3
-	OffsetFetch                   = 9  // 1_0:3
-	FindCoordinator               = 10 // 1_0:1
-	JoinGroup                     = 11 // 1_0:2
-	Heartbeat                     = 12 // 1_0:1
-	LeaveGroup                    = 13
-	SyncGroup                     = 14 // 1_0:1
-	DescribeGroups                = 15
-	ListGroups                    = 16
-	SaslHandshake                 = 17
-	ApiVersions                   = 18 // 1_0:1
-	CreateTopics                  = 19 // 1_0:2
-	DeleteTopics                  = 20
-	DeleteRecords                 = 21
-	InitProducerId                = 22
-	OffsetForLeaderEpoch          = 23
-	AddPartitionsToTxn            = 24
-	AddOffsetsToTxn               = 25
-	EndTxn                        = 26
-	WriteTxnMarkers               = 27
-	TxnOffsetCommit               = 28
-	DescribeAcls                  = 29
-	CreateAcls                    = 30
-	DeleteAcls                    = 31
-	DescribeConfigs               = 32
-	AlterConfigs                  = 33
-	AlterReplicaLogDirs           = 34
-	DescribeLogDirs               = 35
-	SaslAuthenticate              = 36
-	CreatePartitions              = 37
-	CreateDelegationToken         = 38
-	RenewDelegationToken          = 39
-	ExpireDelegationToken         = 40
-	DescribeDelegationToken       = 41
-	DeleteGroups                  = 42
-	ElectPreferredLeaders         = 43
+	Produce                      int16 = 0 // 1_0:5 2_3:7
+	Fetch                              = 1 // 1_0:6
+	ListOffsets                        = 2 // 1_0:2
+	Metadata                           = 3 // 1_0:5
+	LeaderAndIsr                       = 4
+	StopReplica                        = 5
+	UpdateMetadata                     = 6
+	ControlledShutdown                 = 7
+	OffsetCommit                       = 8  // 1_0:3
+	OffsetFetch                        = 9  // 1_0:3
+	FindCoordinator                    = 10 // 1_0:1
+	JoinGroup                          = 11 // 1_0:2
+	Heartbeat                          = 12 // 1_0:1
+	LeaveGroup                         = 13
+	SyncGroup                          = 14 // 1_0:1
+	DescribeGroups                     = 15
+	ListGroups                         = 16
+	SaslHandshake                      = 17
+	ApiVersions                        = 18 // 1_0:1
+	CreateTopics                       = 19 // 1_0:2
+	DeleteTopics                       = 20
+	DeleteRecords                      = 21
+	InitProducerId                     = 22
+	OffsetForLeaderEpoch               = 23
+	AddPartitionsToTxn                 = 24
+	AddOffsetsToTxn                    = 25
+	EndTxn                             = 26
+	WriteTxnMarkers                    = 27
+	TxnOffsetCommit                    = 28
+	DescribeAcls                       = 29
+	CreateAcls                         = 30
+	DeleteAcls                         = 31
+	DescribeConfigs                    = 32
+	AlterConfigs                       = 33
+	AlterReplicaLogDirs                = 34
+	DescribeLogDirs                    = 35
+	SaslAuthenticate                   = 36
+	CreatePartitions                   = 37
+	CreateDelegationToken              = 38
+	RenewDelegationToken               = 39
+	ExpireDelegationToken              = 40
+	DescribeDelegationToken            = 41
+	DeleteGroups                       = 42
+	ElectPreferredLeaders              = 43
+	ElectLeaders                       = 43 // ElectPreferredLeaders v1+ (KIP-460)
+	IncrementalAlterConfigs            = 44
+	AlterPartitionReassignments        = 45
+	ListPartitionReassignments         = 46
+	OffsetDelete                       = 47
+	DescribeClientQuotas               = 48
+	AlterClientQuotas                  = 49
+	DescribeUserScramCredentials       = 50
+	AlterUserScramCredentials          = 51
 )
 
 var Keys = map[int]string{
@@ -93,4 +102,75 @@ var Keys = map[int]string{
 	41: "DescribeDelegationToken",
 	42: "DeleteGroups",
 	43: "ElectPreferredLeaders",
+	44: "IncrementalAlterConfigs",
+	45: "AlterPartitionReassignments",
+	46: "ListPartitionReassignments",
+	47: "OffsetDelete",
+	48: "DescribeClientQuotas",
+	49: "AlterClientQuotas",
+	50: "DescribeUserScramCredentials",
+	51: "AlterUserScramCredentials",
+}
+
+// flexibleVersions are the first flexible (KIP-482) versions of api keys.
+// Api keys which are not here have no flexible versions.
+var flexibleVersions = map[int16]int16{
+	Produce:                      9,
+	Fetch:                        12,
+	ListOffsets:                  6,
+	Metadata:                     9,
+	LeaderAndIsr:                 4,
+	StopReplica:                  2,
+	UpdateMetadata:               6,
+	ControlledShutdown:           3,
+	OffsetCommit:                 8,
+	OffsetFetch:                  6,
+	FindCoordinator:              3,
+	JoinGroup:                    6,
+	Heartbeat:                    4,
+	LeaveGroup:                   4,
+	SyncGroup:                    4,
+	DescribeGroups:               5,
+	ListGroups:                   3,
+	ApiVersions:                  3,
+	CreateTopics:                 5,
+	DeleteTopics:                 4,
+	DeleteRecords:                2,
+	InitProducerId:               2,
+	OffsetForLeaderEpoch:         4,
+	AddPartitionsToTxn:           3,
+	AddOffsetsToTxn:              3,
+	EndTxn:                       3,
+	WriteTxnMarkers:              1,
+	TxnOffsetCommit:              3,
+	DescribeAcls:                 2,
+	CreateAcls:                   2,
+	DeleteAcls:                   2,
+	DescribeConfigs:              4,
+	AlterConfigs:                 2,
+	AlterReplicaLogDirs:          2,
+	DescribeLogDirs:              2,
+	SaslAuthenticate:             2,
+	CreatePartitions:             2,
+	CreateDelegationToken:        2,
+	RenewDelegationToken:         2,
+	ExpireDelegationToken:        2,
+	DescribeDelegationToken:      2,
+	DeleteGroups:                 2,
+	ElectLeaders:                 2,
+	IncrementalAlterConfigs:      1,
+	AlterPartitionReassignments:  0,
+	ListPartitionReassignments:   0,
+	DescribeClientQuotas:         1,
+	AlterClientQuotas:            1,
+	DescribeUserScramCredentials: 0,
+	AlterUserScramCredentials:    0,
+}
+
+// Flexible returns true if the api version uses flexible encoding (compact
+// strings and arrays, tagged fields, and v2 request and v1 response headers).
+// Flexible ApiVersions responses are not supported (they have v0 headers).
+func Flexible(apiKey, apiVersion int16) bool {
+	v, ok := flexibleVersions[apiKey]
+	return ok && apiVersion >= v
 }
@@ -20,9 +20,22 @@ type Request struct {
 	Body          interface{}
 }
 
+// Bytes marshals the request. Body fields which are not present in
+// r.ApiVersion (according to their "versions" struct tags) are skipped. If
+// the api version is flexible, the header is followed by (empty) tagged
+// fields, and the body uses the flexible encoding.
 func (r *Request) Bytes() []byte {
 	tmp := new(bytes.Buffer)
-	wire.Write(tmp, reflect.ValueOf(r))
+	if Flexible(r.ApiKey, r.ApiVersion) {
+		header := []interface{}{r.ApiKey, r.ApiVersion, r.CorrelationId, r.ClientId}
+		for _, v := range header {
+			wire.Write(tmp, reflect.ValueOf(v))
+		}
+		tmp.WriteByte(0) // no tagged fields
+		wire.WriteFlexible(tmp, reflect.ValueOf(r.Body), r.ApiVersion)
+	} else {
+		wire.WriteVersion(tmp, reflect.ValueOf(r), r.ApiVersion)
+	}
 	buf := new(bytes.Buffer)
 	binary.Write(buf, binary.BigEndian, int32(tmp.Len()))
 	tmp.WriteTo(buf)
@@ -43,6 +43,26 @@ func (r *Response) Unmarshal(v interface{}) error {
 	return wire.Read(bytes.NewReader(r.body[4:]), reflect.ValueOf(v))
 }
 
+// UnmarshalVersion is like Unmarshal but it skips fields of v which are not
+// present in the given api version (according to their "versions" struct
+// tags). Pass the version of the request to which this is the response.
+func (r *Response) UnmarshalVersion(v interface{}, version int16) error {
+	// [4:] skips bytes used for correlation id
+	return wire.ReadVersion(bytes.NewReader(r.body[4:]), reflect.ValueOf(v), version)
+}
+
+// UnmarshalFlexible is like UnmarshalVersion, for flexible api versions:
+// response header tagged fields are skipped, and v is read with the flexible
+// encoding.
+func (r *Response) UnmarshalFlexible(v interface{}, version int16) error {
+	// [4:] skips bytes used for correlation id
+	buf := bytes.NewReader(r.body[4:])
+	if err := wire.SkipTaggedFields(buf); err != nil {
+		return fmt.Errorf("error reading response header: %v", err)
+	}
+	return wire.ReadFlexible(buf, reflect.ValueOf(v), version)
+}
+
 func (r *Response) Bytes() []byte {
 	// [4:] skips bytes used for correlation id
 	return r.body[4:]
@@ -0,0 +1,286 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/CreateAcls"
+	"github.com/mkocikowski/libkafka/api/DeleteAcls"
+	"github.com/mkocikowski/libkafka/api/DescribeAcls"
+)
+
+// AclResourceType is the type of the resource an ACL applies to. Values are
+// the same as in the Kafka protocol. "Any" values are only for filters.
+type AclResourceType int8
+
+const (
+	ResourceUnknown AclResourceType = iota
+	ResourceAny
+	ResourceTopic
+	ResourceGroup
+	ResourceCluster
+	ResourceTransactionalId
+	ResourceDelegationToken
+)
+
+var aclResourceTypes = []string{"UNKNOWN", "ANY", "TOPIC", "GROUP", "CLUSTER", "TRANSACTIONAL_ID", "DELEGATION_TOKEN"}
+
+func (t AclResourceType) String() string {
+	if t >= 0 && int(t) < len(aclResourceTypes) {
+		return aclResourceTypes[t]
+	}
+	return fmt.Sprintf("AclResourceType(%d)", t)
+}
+
+// AclPatternType tells how the ACL resource name is matched against the
+// names of resources (Kafka 2.0+).
+type AclPatternType int8
+
+const (
+	PatternUnknown  AclPatternType = iota
+	PatternAny                     // filters only: any pattern type
+	PatternMatch                   // filters only: patterns which match the name
+	PatternLiteral                 // resource name is the name, or "*" for all
+	PatternPrefixed                // resource name is a prefix of names
+)
+
+var aclPatternTypes = []string{"UNKNOWN", "ANY", "MATCH", "LITERAL", "PREFIXED"}
+
+func (t AclPatternType) String() string {
+	if t >= 0 && int(t) < len(aclPatternTypes) {
+		return aclPatternTypes[t]
+	}
+	return fmt.Sprintf("AclPatternType(%d)", t)
+}
+
+// AclOperation which an ACL allows or denies.
+type AclOperation int8
+
+const (
+	OperationUnknown AclOperation = iota
+	OperationAny
+	OperationAll
+	OperationRead
+	OperationWrite
+	OperationCreate
+	OperationDelete
+	OperationAlter
+	OperationDescribe
+	OperationClusterAction
+	OperationDescribeConfigs
+	OperationAlterConfigs
+	OperationIdempotentWrite
+)
+
+var aclOperations = []string{"UNKNOWN", "ANY", "ALL", "READ", "WRITE", "CREATE", "DELETE", "ALTER", "DESCRIBE", "CLUSTER_ACTION", "DESCRIBE_CONFIGS", "ALTER_CONFIGS", "IDEMPOTENT_WRITE"}
+
+func (o AclOperation) String() string {
+	if o >= 0 && int(o) < len(aclOperations) {
+		return aclOperations[o]
+	}
+	return fmt.Sprintf("AclOperation(%d)", o)
+}
+
+// AclPermission is the permission type of an ACL.
+type AclPermission int8
+
+const (
+	PermissionUnknown AclPermission = iota
+	PermissionAny
+	PermissionDeny
+	PermissionAllow
+)
+
+var aclPermissions = []string{"UNKNOWN", "ANY", "DENY", "ALLOW"}
+
+func (p AclPermission) String() string {
+	if p >= 0 && int(p) < len(aclPermissions) {
+		return aclPermissions[p]
+	}
+	return fmt.Sprintf("AclPermission(%d)", p)
+}
+
+// AnyHost is the ACL host matching all hosts.
+const AnyHost = "*"
+
+// UserPrincipal returns principal ("User:name") for the user name. Use
+// UserPrincipal("*") for all users.
+func UserPrincipal(name string) string {
+	return "User:" + name
+}
+
+// Acl allows or denies Principal connecting from Host the Operation on the
+// resources matching the resource pattern (ResourceType, ResourceName, and
+// PatternType).
+type Acl struct {
+	ResourceType AclResourceType
+	ResourceName string
+	PatternType  AclPatternType
+	Principal    string // for example "User:alice"
+	Host         string // ip address or AnyHost
+	Operation    AclOperation
+	Permission   AclPermission
+}
+
+func (a Acl) String() string {
+	return fmt.Sprintf("%v:%v:%s %v %s from %s %v", a.ResourceType, a.PatternType, a.ResourceName, a.Permission, a.Principal, a.Host, a.Operation)
+}
+
+// AclFilter matches ACLs. Empty strings match any value. Use the "Any" values
+// (and PatternMatch) to match any resource type, pattern, operation, or
+// permission.
+type AclFilter struct {
+	ResourceType AclResourceType
+	ResourceName string
+	PatternType  AclPatternType
+	Principal    string
+	Host         string
+	Operation    AclOperation
+	Permission   AclPermission
+}
+
+// DescribeAcls returns ACLs matching the filter. An error code in the response
+// is returned as *libkafka.Error (for example ERR_SECURITY_DISABLED if the
+// brokers have no authorizer configured).
+func (c *Client) DescribeAcls(filter AclFilter) ([]Acl, error) {
+	req := DescribeAcls.NewRequest(&DescribeAcls.Request{
+		ResourceTypeFilter: int8(filter.ResourceType),
+		ResourceNameFilter: filter.ResourceName,
+		PatternTypeFilter:  int8(filter.PatternType),
+		PrincipalFilter:    filter.Principal,
+		HostFilter:         filter.Host,
+		Operation:          int8(filter.Operation),
+		PermissionType:     int8(filter.Permission),
+	})
+	resp := &DescribeAcls.Response{}
+	if err := c.Call(req, resp, nil); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+	}
+	acls := []Acl{}
+	for _, r := range resp.Resources {
+		for _, a := range r.Acls {
+			acls = append(acls, Acl{
+				ResourceType: AclResourceType(r.ResourceType),
+				ResourceName: r.ResourceName,
+				PatternType:  AclPatternType(r.PatternType),
+				Principal:    a.Principal,
+				Host:         a.Host,
+				Operation:    AclOperation(a.Operation),
+				Permission:   AclPermission(a.PermissionType),
+			})
+		}
+	}
+	return acls, nil
+}
+
+// AclResult of creating an ACL.
+type AclResult struct {
+	Acl          Acl
+	ErrorCode    int16
+	ErrorMessage string
+}
+
+func (r *AclResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+// CreateAcls creates ACLs. Results are in the order of acls. Creating an ACL
+// which already exists is not an error.
+func (c *Client) CreateAcls(acls []Acl) ([]*AclResult, error) {
+	creations := make([]CreateAcls.Creation, len(acls))
+	for i, a := range acls {
+		creations[i] = CreateAcls.Creation{
+			ResourceType:        int8(a.ResourceType),
+			ResourceName:        a.ResourceName,
+			ResourcePatternType: int8(a.PatternType),
+			Principal:           a.Principal,
+			Host:                a.Host,
+			Operation:           int8(a.Operation),
+			PermissionType:      int8(a.Permission),
+		}
+	}
+	resp := &CreateAcls.Response{}
+	if err := c.Call(CreateAcls.NewRequest(creations), resp, nil); err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(acls) {
+		return nil, fmt.Errorf("malformed response: %d results for %d acls", len(resp.Results), len(acls))
+	}
+	results := make([]*AclResult, len(acls))
+	for i, r := range resp.Results {
+		results[i] = &AclResult{Acl: acls[i], ErrorCode: r.ErrorCode, ErrorMessage: r.ErrorMessage}
+	}
+	return results, nil
+}
+
+// DeleteAclsResult lists ACLs matching a filter, which were deleted (unless
+// their ErrorCode is set). If the filter ErrorCode is set, no ACLs were
+// deleted.
+type DeleteAclsResult struct {
+	Filter       AclFilter
+	ErrorCode    int16
+	ErrorMessage string
+	Matching     []*AclResult
+}
+
+func (r *DeleteAclsResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+// DeleteAcls deletes ACLs matching filters. Results are in the order of
+// filters.
+func (c *Client) DeleteAcls(filters []AclFilter) ([]*DeleteAclsResult, error) {
+	f := make([]DeleteAcls.Filter, len(filters))
+	for i, filter := range filters {
+		f[i] = DeleteAcls.Filter{
+			ResourceTypeFilter: int8(filter.ResourceType),
+			ResourceNameFilter: filter.ResourceName,
+			PatternTypeFilter:  int8(filter.PatternType),
+			PrincipalFilter:    filter.Principal,
+			HostFilter:         filter.Host,
+			Operation:          int8(filter.Operation),
+			PermissionType:     int8(filter.Permission),
+		}
+	}
+	resp := &DeleteAcls.Response{}
+	if err := c.Call(DeleteAcls.NewRequest(f), resp, nil); err != nil {
+		return nil, err
+	}
+	if len(resp.FilterResults) != len(filters) {
+		return nil, fmt.Errorf("malformed response: %d results for %d filters", len(resp.FilterResults), len(filters))
+	}
+	results := make([]*DeleteAclsResult, len(filters))
+	for i, r := range resp.FilterResults {
+		results[i] = &DeleteAclsResult{
+			Filter:       filters[i],
+			ErrorCode:    r.ErrorCode,
+			ErrorMessage: r.ErrorMessage,
+			Matching:     []*AclResult{},
+		}
+		for _, m := range r.MatchingAcls {
+			results[i].Matching = append(results[i].Matching, &AclResult{
+				Acl: Acl{
+					ResourceType: AclResourceType(m.ResourceType),
+					ResourceName: m.ResourceName,
+					PatternType:  AclPatternType(m.PatternType),
+					Principal:    m.Principal,
+					Host:         m.Host,
+					Operation:    AclOperation(m.Operation),
+					Permission:   AclPermission(m.PermissionType),
+				},
+				ErrorCode:    m.ErrorCode,
+				ErrorMessage: m.ErrorMessage,
+			})
+		}
+	}
+	return results, nil
+}
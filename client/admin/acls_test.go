@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/CreateAcls"
+	"github.com/mkocikowski/libkafka/api/DeleteAcls"
+	"github.com/mkocikowski/libkafka/api/DescribeAcls"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+func TestUnitAclEnumStrings(t *testing.T) {
+	tests := map[fmt.Stringer]string{
+		ResourceTopic:            "TOPIC",
+		ResourceDelegationToken:  "DELEGATION_TOKEN",
+		PatternPrefixed:          "PREFIXED",
+		OperationIdempotentWrite: "IDEMPOTENT_WRITE",
+		PermissionAllow:          "ALLOW",
+		AclOperation(100):        "AclOperation(100)",
+	}
+	for v, want := range tests {
+		if s := v.String(); s != want {
+			t.Fatal(s, want)
+		}
+	}
+}
+
+// aclHandlers for fakeCluster keep ACLs in memory. describe and delete filters
+// match on principal only.
+func aclHandlers(secure bool) apiHandlers {
+	var acls []CreateAcls.Creation
+	return apiHandlers{
+		api.CreateAcls: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &CreateAcls.Request{}
+			req.Unmarshal(r)
+			resp := &CreateAcls.Response{}
+			for _, c := range r.Creations {
+				result := CreateAcls.Result{}
+				if c.ResourcePatternType == int8(PatternAny) {
+					result.ErrorCode = libkafka.ERR_INVALID_REQUEST
+					result.ErrorMessage = "invalid pattern type"
+				} else {
+					acls = append(acls, c)
+				}
+				resp.Results = append(resp.Results, result)
+			}
+			return resp
+		},
+		api.DescribeAcls: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &DescribeAcls.Request{}
+			req.Unmarshal(r)
+			if !secure {
+				return &DescribeAcls.Response{ErrorCode: libkafka.ERR_SECURITY_DISABLED, ErrorMessage: "No Authorizer is configured"}
+			}
+			resp := &DescribeAcls.Response{}
+			for _, a := range acls {
+				if r.PrincipalFilter != "" && r.PrincipalFilter != a.Principal {
+					continue
+				}
+				resp.Resources = append(resp.Resources, DescribeAcls.Resource{
+					ResourceType: a.ResourceType,
+					ResourceName: a.ResourceName,
+					PatternType:  a.ResourcePatternType,
+					Acls: []DescribeAcls.Acl{
+						{Principal: a.Principal, Host: a.Host, Operation: a.Operation, PermissionType: a.PermissionType},
+					},
+				})
+			}
+			return resp
+		},
+		api.DeleteAcls: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &DeleteAcls.Request{}
+			req.Unmarshal(r)
+			resp := &DeleteAcls.Response{}
+			for _, f := range r.Filters {
+				result := DeleteAcls.FilterResult{}
+				var kept []CreateAcls.Creation
+				for _, a := range acls {
+					if f.PrincipalFilter != "" && f.PrincipalFilter != a.Principal {
+						kept = append(kept, a)
+						continue
+					}
+					result.MatchingAcls = append(result.MatchingAcls, DeleteAcls.MatchingAcl{
+						ResourceType:   a.ResourceType,
+						ResourceName:   a.ResourceName,
+						PatternType:    a.ResourcePatternType,
+						Principal:      a.Principal,
+						Host:           a.Host,
+						Operation:      a.Operation,
+						PermissionType: a.PermissionType,
+					})
+				}
+				acls = kept
+				resp.FilterResults = append(resp.FilterResults, result)
+			}
+			return resp
+		},
+	}
+}
+
+func TestUnitClientAcls(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, aclHandlers(true))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	alice := Acl{
+		ResourceType: ResourceTopic,
+		ResourceName: "orders-",
+		PatternType:  PatternPrefixed,
+		Principal:    UserPrincipal("alice"),
+		Host:         AnyHost,
+		Operation:    OperationRead,
+		Permission:   PermissionAllow,
+	}
+	bob := Acl{
+		ResourceType: ResourceGroup,
+		ResourceName: "billing",
+		PatternType:  PatternLiteral,
+		Principal:    UserPrincipal("bob"),
+		Host:         "10.0.0.1",
+		Operation:    OperationDescribe,
+		Permission:   PermissionDeny,
+	}
+	invalid := bob
+	invalid.PatternType = PatternAny
+	results, err := c.CreateAcls([]Acl{alice, bob, invalid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Err() != nil || results[1].Err() != nil || results[2].Err() == nil {
+		t.Fatalf("%+v", results)
+	}
+	if results[2].Acl != invalid || results[2].ErrorMessage == "" {
+		t.Fatalf("%+v", results[2])
+	}
+	acls, err := c.DescribeAcls(AclFilter{
+		ResourceType: ResourceAny,
+		PatternType:  PatternAny,
+		Principal:    UserPrincipal("alice"),
+		Operation:    OperationAny,
+		Permission:   PermissionAny,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(acls, []Acl{alice}) {
+		t.Fatalf("%+v", acls)
+	}
+	// empty filter strings are sent as null
+	for _, req := range b.Requests() {
+		if req.ApiKey == api.DescribeAcls {
+			r := &DescribeAcls.Request{}
+			req.Unmarshal(r)
+			if r.ResourceNameFilter != "" || r.HostFilter != "" || r.PatternTypeFilter != int8(PatternAny) {
+				t.Fatalf("%+v", r)
+			}
+		}
+	}
+	filters := []AclFilter{
+		{ResourceType: ResourceAny, PatternType: PatternAny, Principal: UserPrincipal("bob"), Operation: OperationAny, Permission: PermissionAny},
+		{ResourceType: ResourceAny, PatternType: PatternAny, Principal: UserPrincipal("carol"), Operation: OperationAny, Permission: PermissionAny},
+	}
+	deleted, err := c.DeleteAcls(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].Filter != filters[0] || deleted[0].Err() != nil {
+		t.Fatalf("%+v", deleted)
+	}
+	if m := deleted[0].Matching; len(m) != 1 || m[0].Acl != bob || m[0].Err() != nil {
+		t.Fatalf("%+v", m)
+	}
+	if m := deleted[1].Matching; len(m) != 0 {
+		t.Fatalf("%+v", m)
+	}
+	if acls, _ = c.DescribeAcls(AclFilter{ResourceType: ResourceAny}); len(acls) != 1 {
+		t.Fatalf("%+v", acls)
+	}
+}
+
+func TestUnitClientDescribeAclsSecurityDisabled(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, aclHandlers(false))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	_, err := c.DescribeAcls(AclFilter{ResourceType: ResourceAny})
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_SECURITY_DISABLED || e.Message == "" {
+		t.Fatal(err)
+	}
+}
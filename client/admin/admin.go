@@ -0,0 +1,283 @@
+// Package admin implements cluster administration calls: creating and
+// deleting topics, adding partitions to topics, describing and altering topic
+// and broker configs, managing ACLs, client quotas, SCRAM credentials and
+// delegation tokens, reassigning partitions, electing partition leaders, and
+// describing and altering broker log dirs. Calls are sent to the cluster
+// controller (except for calls about the configs or the log dirs of a
+// specific broker, which are sent to that broker): the Client looks the
+// controller up (with a Metadata call) and keeps a connection to it, looking
+// it up again when it moves (on round trip errors, and when the broker
+// responds with ERR_NOT_CONTROLLER, in which case the call is retried once).
+// Kafka error codes are returned per topic (or resource, or partition), with
+// the error messages set by the broker; returned errors are for
+// request-response round trip errors, and for error codes which apply to the
+// whole request.
+package admin
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/CreatePartitions"
+	"github.com/mkocikowski/libkafka/api/CreateTopics"
+	"github.com/mkocikowski/libkafka/api/DeleteTopics"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// DefaultTimeoutMs is how long the controller waits for the operations to
+// complete if Client.TimeoutMs is not set. If the operation does not
+// complete in that time the topic result is ERR_REQUEST_TIMED_OUT (but the
+// operation continues in the background).
+const DefaultTimeoutMs = 30000
+
+// Client makes admin calls to the controller. Client methods are safe for
+// concurrent use (calls are serialized).
+type Client struct {
+	sync.Mutex
+	Bootstrap string // srv or host:port
+	TLS       *tls.Config
+	SASL      client.SASL
+	ClientId  string
+	// TimeoutMs sent in the requests. Default (0) is DefaultTimeoutMs.
+	// Keep it < libkafka.RequestTimeout.
+	TimeoutMs  int32
+	controller *client.BrokerClient
+}
+
+func (c *Client) timeoutMs() int32 {
+	if c.TimeoutMs > 0 {
+		return c.TimeoutMs
+	}
+	return DefaultTimeoutMs
+}
+
+func (c *Client) connect() error {
+	if c.controller != nil {
+		return nil
+	}
+	meta, err := client.CallMetadataSASL(c.Bootstrap, c.TLS, c.SASL, []string{})
+	if err != nil {
+		return fmt.Errorf("error getting controller id: %w", err)
+	}
+	if meta.ControllerId < 0 {
+		return fmt.Errorf("no controller: %w", &libkafka.Error{Code: libkafka.ERR_NOT_CONTROLLER})
+	}
+	c.controller = &client.BrokerClient{
+		Bootstrap: c.Bootstrap,
+		TLS:       c.TLS,
+		SASL:      c.SASL,
+		ClientId:  c.ClientId,
+		NodeId:    meta.ControllerId,
+	}
+	return nil
+}
+
+func (c *Client) disconnect() {
+	if c.controller != nil {
+		c.controller.Close()
+		c.controller = nil
+	}
+}
+
+// Close the connection to the controller.
+func (c *Client) Close() error { // implement io.Closer
+	c.Lock()
+	defer c.Unlock()
+	c.disconnect()
+	return nil
+}
+
+// Controller returns the broker which is the cluster controller (as of the
+// last call made by the client).
+func (c *Client) Controller() (*Metadata.Broker, error) {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	if b := c.controller.Broker(); b != nil {
+		return b, nil
+	}
+	return client.GetBrokerSASL(c.Bootstrap, c.TLS, c.SASL, c.controller.NodeId)
+}
+
+// Call makes a request to the controller and reads the response into
+// respStructPtr. notController, if not nil, is called after the response is
+// read, and if it returns true the controller is looked up again and the call
+// is retried once. On round trip error the connection to the controller is
+// closed (and the controller looked up again on next call).
+func (c *Client) Call(req *api.Request, respStructPtr interface{}, notController func() bool) error {
+	c.Lock()
+	defer c.Unlock()
+	for i := 0; ; i++ {
+		if err := c.connect(); err != nil {
+			return err
+		}
+		if err := c.controller.Call(req, respStructPtr); err != nil {
+			c.disconnect()
+			return fmt.Errorf("error calling controller: %w", err)
+		}
+		if notController == nil || !notController() {
+			return nil
+		}
+		c.disconnect()
+		if i > 0 {
+			return nil
+		}
+	}
+}
+
+// TopicResult of an admin operation.
+type TopicResult struct {
+	Topic        string
+	ErrorCode    int16
+	ErrorMessage string // not set for DeleteTopics results
+}
+
+// Err returns *libkafka.Error if ErrorCode is not ERR_NONE, and nil otherwise.
+func (r *TopicResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+func sortResults(results []*TopicResult) {
+	sort.Slice(results, func(i, j int) bool { return results[i].Topic < results[j].Topic })
+}
+
+func notController(results func() []*TopicResult) func() bool {
+	return func() bool {
+		for _, r := range results() {
+			if r.ErrorCode == libkafka.ERR_NOT_CONTROLLER {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NewTopic describes a topic to be created.
+type NewTopic struct {
+	Name string
+	// NumPartitions and ReplicationFactor must be -1 if Assignments are
+	// set.
+	NumPartitions     int32
+	ReplicationFactor int16
+	// Assignments of replicas to partitions: broker ids keyed by
+	// partition. Nil lets the controller assign replicas.
+	Assignments map[int32][]int32
+	// Configs (for example "retention.ms") overriding broker defaults.
+	Configs map[string]string
+}
+
+func (t *NewTopic) request() CreateTopics.Topic {
+	topic := CreateTopics.Topic{
+		Name:              t.Name,
+		NumPartitions:     t.NumPartitions,
+		ReplicationFactor: t.ReplicationFactor,
+	}
+	for p, brokers := range t.Assignments {
+		topic.Assignments = append(topic.Assignments, CreateTopics.Assignment{PartitionIndex: p, BrokerIds: brokers})
+	}
+	sort.Slice(topic.Assignments, func(i, j int) bool {
+		return topic.Assignments[i].PartitionIndex < topic.Assignments[j].PartitionIndex
+	})
+	for name, value := range t.Configs {
+		topic.Configs = append(topic.Configs, CreateTopics.Config{Name: name, Value: value})
+	}
+	sort.Slice(topic.Configs, func(i, j int) bool { return topic.Configs[i].Name < topic.Configs[j].Name })
+	return topic
+}
+
+func createTopicsResults(resp *CreateTopics.Response) []*TopicResult {
+	results := make([]*TopicResult, len(resp.Topics))
+	for i, t := range resp.Topics {
+		results[i] = &TopicResult{Topic: t.Name, ErrorCode: t.ErrorCode, ErrorMessage: t.ErrorMessage}
+	}
+	sortResults(results)
+	return results
+}
+
+// CreateTopics creates topics. With validateOnly the request is validated
+// (results have error codes such as ERR_TOPIC_ALREADY_EXISTS or
+// ERR_INVALID_REPLICATION_FACTOR) but topics are not created. Results are
+// sorted by topic.
+func (c *Client) CreateTopics(topics []NewTopic, validateOnly bool) ([]*TopicResult, error) {
+	t := make([]CreateTopics.Topic, len(topics))
+	for i := range topics {
+		t[i] = topics[i].request()
+	}
+	req := CreateTopics.NewMultipleTopicsRequest(t, c.timeoutMs(), validateOnly)
+	resp := &CreateTopics.Response{}
+	results := func() []*TopicResult { return createTopicsResults(resp) }
+	if err := c.Call(req, resp, notController(results)); err != nil {
+		return nil, err
+	}
+	return results(), nil
+}
+
+func deleteTopicsResults(resp *DeleteTopics.Response) []*TopicResult {
+	results := make([]*TopicResult, len(resp.Responses))
+	for i, t := range resp.Responses {
+		results[i] = &TopicResult{Topic: t.Name, ErrorCode: t.ErrorCode}
+	}
+	sortResults(results)
+	return results
+}
+
+// DeleteTopics deletes topics. Results are sorted by topic. Topics which do
+// not exist have ERR_UNKNOWN_TOPIC_OR_PARTITION.
+func (c *Client) DeleteTopics(topics []string) ([]*TopicResult, error) {
+	req := DeleteTopics.NewRequest(topics, c.timeoutMs())
+	resp := &DeleteTopics.Response{}
+	results := func() []*TopicResult { return deleteTopicsResults(resp) }
+	if err := c.Call(req, resp, notController(results)); err != nil {
+		return nil, err
+	}
+	return results(), nil
+}
+
+// NewPartitions describes partitions to be added to a topic.
+type NewPartitions struct {
+	Topic string
+	// Count is the new total number of partitions of the topic.
+	Count int32
+	// Assignments of replicas (broker ids) to each of the new partitions.
+	// Nil lets the controller assign replicas.
+	Assignments [][]int32
+}
+
+func createPartitionsResults(resp *CreatePartitions.Response) []*TopicResult {
+	results := make([]*TopicResult, len(resp.Results))
+	for i, t := range resp.Results {
+		results[i] = &TopicResult{Topic: t.Name, ErrorCode: t.ErrorCode, ErrorMessage: t.ErrorMessage}
+	}
+	sortResults(results)
+	return results
+}
+
+// CreatePartitions adds partitions to topics. With validateOnly the request
+// is validated but no partitions are created. Results are sorted by topic.
+// Requires Kafka 2.0+.
+func (c *Client) CreatePartitions(partitions []NewPartitions, validateOnly bool) ([]*TopicResult, error) {
+	topics := make([]CreatePartitions.Topic, len(partitions))
+	for i, p := range partitions {
+		topics[i] = CreatePartitions.Topic{Name: p.Topic, Count: p.Count}
+		for _, brokers := range p.Assignments {
+			topics[i].Assignments = append(topics[i].Assignments, CreatePartitions.Assignment{BrokerIds: brokers})
+		}
+	}
+	req := CreatePartitions.NewRequest(topics, c.timeoutMs(), validateOnly)
+	resp := &CreatePartitions.Response{}
+	results := func() []*TopicResult { return createPartitionsResults(resp) }
+	if err := c.Call(req, resp, notController(results)); err != nil {
+		return nil, err
+	}
+	return results(), nil
+}
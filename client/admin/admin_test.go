@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/CreatePartitions"
+	"github.com/mkocikowski/libkafka/api/CreateTopics"
+	"github.com/mkocikowski/libkafka/api/DeleteTopics"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// apiHandlers handle requests to fakeCluster brokers by api key. nodeId is the
+// id of the broker which got the request.
+type apiHandlers map[int16]func(nodeId int32, req *fakebroker.Request) interface{}
+
+// fakeCluster has two brokers. controller is the node id of the controller;
+// the other broker responds to topic admin requests with ERR_NOT_CONTROLLER.
+// Requests for other apis are handled by handlers.
+func fakeCluster(t *testing.T, controller *int32, handlers apiHandlers) (*fakebroker.Broker, *fakebroker.Broker) {
+	t.Helper()
+	var b1, b2 *fakebroker.Broker
+	handler := func(nodeId int32) fakebroker.Handler {
+		return func(req *fakebroker.Request) interface{} {
+			code := int16(libkafka.ERR_NONE)
+			if atomic.LoadInt32(controller) != nodeId {
+				code = libkafka.ERR_NOT_CONTROLLER
+			}
+			if h, ok := handlers[req.ApiKey]; ok {
+				return h(nodeId, req)
+			}
+			switch req.ApiKey {
+			case api.Metadata:
+				return &Metadata.Response{
+					Brokers: []Metadata.Broker{
+						{NodeId: 1, Host: b1.Host(), Port: b1.Port()},
+						{NodeId: 2, Host: b2.Host(), Port: b2.Port()},
+					},
+					ControllerId: atomic.LoadInt32(controller),
+				}
+			case api.CreateTopics:
+				r := &CreateTopics.Request{}
+				req.Unmarshal(r)
+				resp := &CreateTopics.Response{}
+				for _, t := range r.Topics {
+					tr := CreateTopics.TopicResponse{Name: t.Name, ErrorCode: code}
+					if t.Name == "exists" && code == libkafka.ERR_NONE {
+						tr.ErrorCode = libkafka.ERR_TOPIC_ALREADY_EXISTS
+						tr.ErrorMessage = "Topic 'exists' already exists."
+					}
+					resp.Topics = append(resp.Topics, tr)
+				}
+				return resp
+			case api.DeleteTopics:
+				r := &DeleteTopics.Request{}
+				req.Unmarshal(r)
+				resp := &DeleteTopics.Response{}
+				for _, t := range r.TopicNames {
+					resp.Responses = append(resp.Responses, DeleteTopics.TopicResponse{Name: t, ErrorCode: code})
+				}
+				return resp
+			case api.CreatePartitions:
+				r := &CreatePartitions.Request{}
+				req.Unmarshal(r)
+				resp := &CreatePartitions.Response{}
+				for _, t := range r.Topics {
+					resp.Results = append(resp.Results, CreatePartitions.Result{Name: t.Name, ErrorCode: code})
+				}
+				return resp
+			}
+			return nil
+		}
+	}
+	b1, _ = fakebroker.Start(1, handler(1))
+	b2, _ = fakebroker.Start(2, handler(2))
+	return b1, b2
+}
+
+func adminRequests(b *fakebroker.Broker, apiKey int16) []*fakebroker.Request {
+	var requests []*fakebroker.Request
+	for _, r := range b.Requests() {
+		if r.ApiKey == apiKey {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+func TestUnitClientCreateTopics(t *testing.T) {
+	controller := int32(2)
+	b1, b2 := fakeCluster(t, &controller, nil)
+	defer b1.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b1.Addr(), TimeoutMs: 5000}
+	defer c.Close()
+	topics := []NewTopic{
+		{
+			Name:              "foo",
+			NumPartitions:     -1,
+			ReplicationFactor: -1,
+			Assignments:       map[int32][]int32{1: {2, 1}, 0: {1, 2}},
+			Configs:           map[string]string{"retention.ms": "1000", "cleanup.policy": "compact"},
+		},
+		{Name: "exists", NumPartitions: 1, ReplicationFactor: 1},
+	}
+	results, err := c.CreateTopics(topics, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Topic != "exists" || results[1].Topic != "foo" {
+		t.Fatalf("%+v", results)
+	}
+	var e *libkafka.Error
+	if err := results[0].Err(); !errors.As(err, &e) || e.Code != libkafka.ERR_TOPIC_ALREADY_EXISTS || e.Message == "" {
+		t.Fatal(err)
+	}
+	if err := results[1].Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(adminRequests(b1, api.CreateTopics)); n != 0 {
+		t.Fatal(n)
+	}
+	requests := adminRequests(b2, api.CreateTopics)
+	if len(requests) != 1 {
+		t.Fatal(len(requests))
+	}
+	r := &CreateTopics.Request{}
+	requests[0].Unmarshal(r)
+	if !r.ValidateOnly || r.TimeoutMs != 5000 {
+		t.Fatalf("%+v", r)
+	}
+	foo := r.Topics[0]
+	wantAssignments := []CreateTopics.Assignment{{PartitionIndex: 0, BrokerIds: []int32{1, 2}}, {PartitionIndex: 1, BrokerIds: []int32{2, 1}}}
+	if !reflect.DeepEqual(foo.Assignments, wantAssignments) {
+		t.Fatalf("%+v", foo)
+	}
+	wantConfigs := []CreateTopics.Config{{Name: "cleanup.policy", Value: "compact"}, {Name: "retention.ms", Value: "1000"}}
+	if !reflect.DeepEqual(foo.Configs, wantConfigs) {
+		t.Fatalf("%+v", foo)
+	}
+	if exists := r.Topics[1]; len(exists.Assignments) != 0 || len(exists.Configs) != 0 {
+		t.Fatalf("%+v", exists)
+	}
+}
+
+func TestUnitClientControllerMoved(t *testing.T) {
+	controller := int32(2)
+	b1, b2 := fakeCluster(t, &controller, nil)
+	defer b1.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b1.Addr()}
+	defer c.Close()
+	if b, err := c.Controller(); err != nil || b.NodeId != 2 {
+		t.Fatal(b, err)
+	}
+	results, err := c.DeleteTopics([]string{"foo", "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Topic != "bar" || results[0].Err() != nil || results[1].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	// controller moves. first call goes to the old controller, is rejected
+	// and retried on the new one
+	atomic.StoreInt32(&controller, 1)
+	results, err = c.CreatePartitions([]NewPartitions{{Topic: "foo", Count: 3, Assignments: [][]int32{{1}}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	if n := len(adminRequests(b2, api.CreatePartitions)); n != 1 {
+		t.Fatal(n)
+	}
+	requests := adminRequests(b1, api.CreatePartitions)
+	if len(requests) != 1 {
+		t.Fatal(len(requests))
+	}
+	r := &CreatePartitions.Request{}
+	requests[0].Unmarshal(r)
+	if p := r.Topics[0]; p.Count != 3 || len(p.Assignments) != 1 || p.Assignments[0].BrokerIds[0] != 1 || r.TimeoutMs != DefaultTimeoutMs {
+		t.Fatalf("%+v", r)
+	}
+	// nil assignments are sent as null
+	if _, err := c.CreatePartitions([]NewPartitions{{Topic: "foo", Count: 4}}, false); err != nil {
+		t.Fatal(err)
+	}
+	requests = adminRequests(b1, api.CreatePartitions)
+	r = &CreatePartitions.Request{}
+	requests[len(requests)-1].Unmarshal(r)
+	if r.Topics[0].Assignments != nil {
+		t.Fatalf("%+v", r)
+	}
+	// controller moves to a broker which is not in the cluster metadata
+	atomic.StoreInt32(&controller, 3)
+	if _, err = c.DeleteTopics([]string{"foo"}); err == nil {
+		t.Fatal("expected error")
+	}
+}
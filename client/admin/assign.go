@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// rackAlternated returns brokers sorted so that consecutive brokers are on
+// different racks (as far as possible): the first broker of each rack, then
+// the second broker of each rack, and so on. Racks and brokers within racks
+// are sorted.
+func rackAlternated(brokers []Metadata.Broker) []Metadata.Broker {
+	racks := make(map[string][]Metadata.Broker)
+	var names []string
+	for _, b := range brokers {
+		if _, ok := racks[b.Rack]; !ok {
+			names = append(names, b.Rack)
+		}
+		racks[b.Rack] = append(racks[b.Rack], b)
+	}
+	sort.Strings(names)
+	for _, r := range names {
+		sort.Slice(racks[r], func(i, j int) bool { return racks[r][i].NodeId < racks[r][j].NodeId })
+	}
+	var alternated []Metadata.Broker
+	for i := 0; len(alternated) < len(brokers); i++ {
+		for _, r := range names {
+			if i < len(racks[r]) {
+				alternated = append(alternated, racks[r][i])
+			}
+		}
+	}
+	return alternated
+}
+
+// BalancedAssignment returns replica assignments (to be used as
+// ReassignPartitions targets) for all partitions of topics, with
+// replicationFactor replicas each, spread evenly over the brokers in meta
+// (which must have metadata for the topics). Leaders (first replicas) are
+// assigned round robin over the brokers, alternating between racks. Each
+// following replica goes to the broker with the fewest replicas (counting all
+// the leaders) among the brokers on racks which do not yet have a replica of
+// the partition (or among all brokers, once every rack has a replica), so
+// replicas of a partition are on as many racks as possible. Brokers without a
+// rack are treated as being on the same rack. The assignment does not depend
+// on the current assignment (most replicas may move).
+func BalancedAssignment(meta *Metadata.Response, topics []string, replicationFactor int) (map[client.TopicPartition][]int32, error) {
+	brokers := rackAlternated(meta.Brokers)
+	if replicationFactor < 1 || replicationFactor > len(brokers) {
+		return nil, fmt.Errorf("replication factor %d for %d brokers: %w", replicationFactor, len(brokers), &libkafka.Error{Code: libkafka.ERR_INVALID_REPLICATION_FACTOR})
+	}
+	var partitions []client.TopicPartition
+	for _, topic := range topics {
+		p := meta.Partitions(topic)
+		if len(p) == 0 {
+			return nil, fmt.Errorf("no metadata for topic %q: %w", topic, &libkafka.Error{Code: libkafka.ERR_UNKNOWN_TOPIC_OR_PARTITION})
+		}
+		for id := range p {
+			partitions = append(partitions, client.TopicPartition{Topic: topic, Partition: id})
+		}
+	}
+	client.SortTopicPartitions(partitions)
+	replicas := make(map[int32]int) // number of replicas assigned to broker
+	for i := range partitions {
+		replicas[brokers[i%len(brokers)].NodeId]++ // leaders
+	}
+	assignments := make(map[client.TopicPartition][]int32)
+	for i, p := range partitions {
+		leader := i % len(brokers)
+		assigned := []int32{brokers[leader].NodeId}
+		racks := map[string]bool{brokers[leader].Rack: true}
+		for len(assigned) < replicationFactor {
+			best := -1
+			for k := 1; k < len(brokers); k++ {
+				// candidates in rack alternated order, starting after the
+				// leader, so that ties are broken the same way as for
+				// leaders
+				j := (leader + k) % len(brokers)
+				b := brokers[j]
+				if contains(assigned, b.NodeId) {
+					continue
+				}
+				if best < 0 {
+					best = j
+					continue
+				}
+				newRack, bestNewRack := !racks[b.Rack], !racks[brokers[best].Rack]
+				if newRack != bestNewRack {
+					if newRack {
+						best = j
+					}
+					continue
+				}
+				if replicas[b.NodeId] < replicas[brokers[best].NodeId] {
+					best = j
+				}
+			}
+			assigned = append(assigned, brokers[best].NodeId)
+			racks[brokers[best].Rack] = true
+			replicas[brokers[best].NodeId]++
+		}
+		assignments[p] = assigned
+	}
+	return assignments, nil
+}
+
+func contains(ids []int32, id int32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/AlterConfigs"
+	"github.com/mkocikowski/libkafka/api/DescribeConfigs"
+	"github.com/mkocikowski/libkafka/api/IncrementalAlterConfigs"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// ConfigResource identifies a topic or a broker. Type is one of
+// DescribeConfigs.Resource* values.
+type ConfigResource struct {
+	Type int8
+	Name string
+}
+
+func TopicResource(topic string) ConfigResource {
+	return ConfigResource{Type: DescribeConfigs.ResourceTopic, Name: topic}
+}
+
+// BrokerResource for broker configs. Use BrokerDefaultsResource for configs
+// which are the defaults for all brokers in the cluster.
+func BrokerResource(nodeId int32) ConfigResource {
+	return ConfigResource{Type: DescribeConfigs.ResourceBroker, Name: strconv.Itoa(int(nodeId))}
+}
+
+// BrokerDefaultsResource for cluster wide dynamic broker configs.
+func BrokerDefaultsResource() ConfigResource {
+	return ConfigResource{Type: DescribeConfigs.ResourceBroker}
+}
+
+func (r ConfigResource) String() string {
+	switch r.Type {
+	case DescribeConfigs.ResourceTopic:
+		return "topic:" + r.Name
+	case DescribeConfigs.ResourceBroker:
+		return "broker:" + r.Name
+	case DescribeConfigs.ResourceBrokerLogger:
+		return "broker-logger:" + r.Name
+	}
+	return fmt.Sprintf("%d:%s", r.Type, r.Name)
+}
+
+// broker resource requests must be sent to the broker. returns -1 for
+// resources which can be handled by any broker.
+func (r ConfigResource) nodeId() int32 {
+	if r.Type != DescribeConfigs.ResourceBroker && r.Type != DescribeConfigs.ResourceBrokerLogger {
+		return -1
+	}
+	id, err := strconv.Atoi(r.Name)
+	if err != nil {
+		return -1
+	}
+	return int32(id)
+}
+
+func (r ConfigResource) less(other ConfigResource) bool {
+	if r.Type != other.Type {
+		return r.Type < other.Type
+	}
+	return r.Name < other.Name
+}
+
+func sortResources(resources []ConfigResource) {
+	sort.Slice(resources, func(i, j int) bool { return resources[i].less(resources[j]) })
+}
+
+// byNodeId groups resources by the node id of the broker which must handle
+// them (-1 for the controller).
+func byNodeId(resources []ConfigResource) map[int32][]ConfigResource {
+	groups := make(map[int32][]ConfigResource)
+	for _, r := range resources {
+		groups[r.nodeId()] = append(groups[r.nodeId()], r)
+	}
+	return groups
+}
+
+// callNode makes the call to the controller (nodeId -1) or to the broker.
+func (c *Client) callNode(nodeId int32, req *api.Request, resp interface{}) error {
+	if nodeId < 0 {
+		return c.Call(req, resp, nil)
+	}
+	b := &client.BrokerClient{Bootstrap: c.Bootstrap, TLS: c.TLS, SASL: c.SASL, ClientId: c.ClientId, NodeId: nodeId}
+	defer b.Close()
+	return b.Call(req, resp)
+}
+
+type ConfigSynonym struct {
+	Name   string
+	Value  string
+	Source int8
+}
+
+type ConfigEntry struct {
+	Name string
+	// Value is empty for sensitive configs.
+	Value     string
+	ReadOnly  bool
+	Sensitive bool
+	// Source is one of DescribeConfigs.Source* values.
+	Source int8
+	// Default is true if the config is not set anywhere (it has the Kafka
+	// default value).
+	Default bool
+	// Synonyms in order of precedence (the first one is the config
+	// itself).
+	Synonyms []ConfigSynonym
+}
+
+// ResourceConfigs are configs of a resource. If ErrorCode is not ERR_NONE
+// Configs are empty.
+type ResourceConfigs struct {
+	Resource     ConfigResource
+	ErrorCode    int16
+	ErrorMessage string
+	Configs      []*ConfigEntry // sorted by name
+}
+
+func (r *ResourceConfigs) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+// Config returns the config entry with the name, or nil.
+func (r *ResourceConfigs) Config(name string) *ConfigEntry {
+	i := sort.Search(len(r.Configs), func(i int) bool { return r.Configs[i].Name >= name })
+	if i < len(r.Configs) && r.Configs[i].Name == name {
+		return r.Configs[i]
+	}
+	return nil
+}
+
+func resourceConfigs(r *DescribeConfigs.Result) *ResourceConfigs {
+	rc := &ResourceConfigs{
+		Resource:     ConfigResource{Type: r.ResourceType, Name: r.ResourceName},
+		ErrorCode:    r.ErrorCode,
+		ErrorMessage: r.ErrorMessage,
+		Configs:      []*ConfigEntry{},
+	}
+	for _, c := range r.Configs {
+		e := &ConfigEntry{
+			Name:      c.Name,
+			Value:     c.Value,
+			ReadOnly:  c.ReadOnly,
+			Sensitive: c.IsSensitive,
+			Source:    c.ConfigSource,
+			Default:   c.ConfigSource == DescribeConfigs.SourceDefaultConfig,
+			Synonyms:  []ConfigSynonym{},
+		}
+		for _, s := range c.Synonyms {
+			e.Synonyms = append(e.Synonyms, ConfigSynonym{Name: s.Name, Value: s.Value, Source: s.Source})
+		}
+		rc.Configs = append(rc.Configs, e)
+	}
+	sort.Slice(rc.Configs, func(i, j int) bool { return rc.Configs[i].Name < rc.Configs[j].Name })
+	return rc
+}
+
+// DescribeConfigs of resources. Keys are the names of the configs to describe;
+// nil describes all configs. Broker resources are described by the brokers
+// themselves, and other resources by the controller (one request per broker).
+// Results are sorted by resource type and name. Returned error is the first
+// round trip error; results for resources described before the error are
+// returned along with it.
+func (c *Client) DescribeConfigs(resources []ConfigResource, keys []string) ([]*ResourceConfigs, error) {
+	var results []*ResourceConfigs
+	sortResults := func() {
+		sort.Slice(results, func(i, j int) bool { return results[i].Resource.less(results[j].Resource) })
+	}
+	for nodeId, group := range byNodeId(resources) {
+		r := make([]DescribeConfigs.Resource, len(group))
+		for i, g := range group {
+			r[i] = DescribeConfigs.Resource{ResourceType: g.Type, ResourceName: g.Name, ConfigurationKeys: keys}
+		}
+		resp := &DescribeConfigs.Response{}
+		if err := c.callNode(nodeId, DescribeConfigs.NewRequest(r, true), resp); err != nil {
+			sortResults()
+			return results, fmt.Errorf("error describing configs: %w", err)
+		}
+		for i := range resp.Results {
+			results = append(results, resourceConfigs(&resp.Results[i]))
+		}
+	}
+	sortResults()
+	return results, nil
+}
+
+// ResourceResult of altering resource configs.
+type ResourceResult struct {
+	Resource     ConfigResource
+	ErrorCode    int16
+	ErrorMessage string
+}
+
+func (r *ResourceResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+func sortResourceResults(results []*ResourceResult) {
+	sort.Slice(results, func(i, j int) bool { return results[i].Resource.less(results[j].Resource) })
+}
+
+// AlterConfigs replaces configs of resources. Configs which are not in the
+// map are reverted to their defaults, so unless you want to reset all
+// configs, use IncrementalAlterConfigs. With validateOnly the configs are
+// validated but not changed. Routing, results and errors are the same as for
+// DescribeConfigs.
+func (c *Client) AlterConfigs(configs map[ConfigResource]map[string]string, validateOnly bool) ([]*ResourceResult, error) {
+	resources := make([]ConfigResource, 0, len(configs))
+	for r := range configs {
+		resources = append(resources, r)
+	}
+	var results []*ResourceResult
+	for nodeId, group := range byNodeId(resources) {
+		sortResources(group)
+		r := make([]AlterConfigs.Resource, len(group))
+		for i, g := range group {
+			r[i] = AlterConfigs.Resource{ResourceType: g.Type, ResourceName: g.Name, Configs: []AlterConfigs.Config{}}
+			for name, value := range configs[g] {
+				r[i].Configs = append(r[i].Configs, AlterConfigs.Config{Name: name, Value: value})
+			}
+			sort.Slice(r[i].Configs, func(a, b int) bool { return r[i].Configs[a].Name < r[i].Configs[b].Name })
+		}
+		resp := &AlterConfigs.Response{}
+		if err := c.callNode(nodeId, AlterConfigs.NewRequest(r, validateOnly), resp); err != nil {
+			sortResourceResults(results)
+			return results, fmt.Errorf("error altering configs: %w", err)
+		}
+		for _, rr := range resp.Responses {
+			results = append(results, &ResourceResult{
+				Resource:     ConfigResource{Type: rr.ResourceType, Name: rr.ResourceName},
+				ErrorCode:    rr.ErrorCode,
+				ErrorMessage: rr.ErrorMessage,
+			})
+		}
+	}
+	sortResourceResults(results)
+	return results, nil
+}
+
+// AlterConfigOp is an incremental config change. Op is one of
+// IncrementalAlterConfigs.Op* values. Value is ignored for OpDelete. For
+// OpAppend and OpSubtract (list configs such as "cleanup.policy") Value is
+// a comma separated list.
+type AlterConfigOp struct {
+	Name  string
+	Op    int8
+	Value string
+}
+
+// IncrementalAlterConfigs changes only the specified configs of resources
+// (KIP-339, Kafka 2.3+). With validateOnly the changes are validated but not
+// made. Routing, results and errors are the same as for DescribeConfigs.
+func (c *Client) IncrementalAlterConfigs(ops map[ConfigResource][]AlterConfigOp, validateOnly bool) ([]*ResourceResult, error) {
+	resources := make([]ConfigResource, 0, len(ops))
+	for r := range ops {
+		resources = append(resources, r)
+	}
+	var results []*ResourceResult
+	for nodeId, group := range byNodeId(resources) {
+		sortResources(group)
+		r := make([]IncrementalAlterConfigs.Resource, len(group))
+		for i, g := range group {
+			r[i] = IncrementalAlterConfigs.Resource{ResourceType: g.Type, ResourceName: g.Name, Configs: []IncrementalAlterConfigs.Config{}}
+			for _, op := range ops[g] {
+				r[i].Configs = append(r[i].Configs, IncrementalAlterConfigs.Config{Name: op.Name, ConfigOperation: op.Op, Value: op.Value})
+			}
+		}
+		resp := &IncrementalAlterConfigs.Response{}
+		if err := c.callNode(nodeId, IncrementalAlterConfigs.NewRequest(r, validateOnly), resp); err != nil {
+			sortResourceResults(results)
+			return results, fmt.Errorf("error altering configs: %w", err)
+		}
+		for _, rr := range resp.Responses {
+			results = append(results, &ResourceResult{
+				Resource:     ConfigResource{Type: rr.ResourceType, Name: rr.ResourceName},
+				ErrorCode:    rr.ErrorCode,
+				ErrorMessage: rr.ErrorMessage,
+			})
+		}
+	}
+	sortResourceResults(results)
+	return results, nil
+}
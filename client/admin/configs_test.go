@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/AlterConfigs"
+	"github.com/mkocikowski/libkafka/api/DescribeConfigs"
+	"github.com/mkocikowski/libkafka/api/IncrementalAlterConfigs"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// configsHandlers for fakeCluster. topic "foo" has retention.ms set, topic
+// "bar" does not exist. each broker describes only its own configs.
+var configsHandlers = apiHandlers{
+	api.DescribeConfigs: func(nodeId int32, req *fakebroker.Request) interface{} {
+		r := &DescribeConfigs.Request{}
+		req.Unmarshal(r)
+		resp := &DescribeConfigs.Response{}
+		for _, res := range r.Resources {
+			result := DescribeConfigs.Result{ResourceType: res.ResourceType, ResourceName: res.ResourceName}
+			switch {
+			case res.ResourceName == "foo":
+				result.Configs = []DescribeConfigs.Config{
+					{Name: "retention.ms", Value: "1000", ConfigSource: DescribeConfigs.SourceDynamicTopicConfig, Synonyms: []DescribeConfigs.Synonym{
+						{Name: "retention.ms", Value: "1000", Source: DescribeConfigs.SourceDynamicTopicConfig},
+						{Name: "log.retention.hours", Value: "168", Source: DescribeConfigs.SourceDefaultConfig},
+					}},
+					{Name: "max.message.bytes", Value: "1048588", ConfigSource: DescribeConfigs.SourceDefaultConfig},
+				}
+			case res.ResourceType == DescribeConfigs.ResourceBroker && res.ResourceName == "2" && nodeId == 2:
+				result.Configs = []DescribeConfigs.Config{
+					{Name: "ssl.key.password", IsSensitive: true, ReadOnly: true, ConfigSource: DescribeConfigs.SourceStaticBrokerConfig},
+				}
+			case res.ResourceType == DescribeConfigs.ResourceTopic:
+				result.ErrorCode = libkafka.ERR_UNKNOWN_TOPIC_OR_PARTITION
+				result.ErrorMessage = "Topic bar does not exist"
+			default:
+				result.ErrorCode = libkafka.ERR_INVALID_REQUEST
+			}
+			resp.Results = append(resp.Results, result)
+		}
+		return resp
+	},
+	api.AlterConfigs: func(nodeId int32, req *fakebroker.Request) interface{} {
+		r := &AlterConfigs.Request{}
+		req.Unmarshal(r)
+		resp := &AlterConfigs.Response{}
+		for _, res := range r.Resources {
+			resp.Responses = append(resp.Responses, AlterConfigs.ResourceResponse{ResourceType: res.ResourceType, ResourceName: res.ResourceName})
+		}
+		return resp
+	},
+	api.IncrementalAlterConfigs: func(nodeId int32, req *fakebroker.Request) interface{} {
+		r := &IncrementalAlterConfigs.Request{}
+		req.Unmarshal(r)
+		resp := &IncrementalAlterConfigs.Response{}
+		for _, res := range r.Resources {
+			resp.Responses = append(resp.Responses, IncrementalAlterConfigs.ResourceResponse{ResourceType: res.ResourceType, ResourceName: res.ResourceName})
+		}
+		return resp
+	},
+}
+
+func TestUnitClientDescribeConfigs(t *testing.T) {
+	controller := int32(1)
+	b1, b2 := fakeCluster(t, &controller, configsHandlers)
+	defer b1.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b1.Addr()}
+	defer c.Close()
+	resources := []ConfigResource{BrokerResource(2), TopicResource("foo"), TopicResource("bar")}
+	results, err := c.DescribeConfigs(resources, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatal(len(results))
+	}
+	if r := results[0]; r.Resource != TopicResource("bar") || r.Err() == nil || r.ErrorMessage == "" {
+		t.Fatalf("%+v", r)
+	}
+	foo := results[1]
+	if foo.Resource != TopicResource("foo") || foo.Err() != nil || len(foo.Configs) != 2 {
+		t.Fatalf("%+v", foo)
+	}
+	if e := foo.Config("max.message.bytes"); e == nil || e.Value != "1048588" || !e.Default {
+		t.Fatalf("%+v", e)
+	}
+	retention := foo.Config("retention.ms")
+	if retention == nil || retention.Default || retention.Source != DescribeConfigs.SourceDynamicTopicConfig {
+		t.Fatalf("%+v", retention)
+	}
+	if len(retention.Synonyms) != 2 || retention.Synonyms[1].Name != "log.retention.hours" {
+		t.Fatalf("%+v", retention)
+	}
+	if foo.Config("nope") != nil {
+		t.Fatal("unexpected config")
+	}
+	broker := results[2]
+	if broker.Resource != BrokerResource(2) || broker.Err() != nil {
+		t.Fatalf("%+v", broker)
+	}
+	if e := broker.Config("ssl.key.password"); e == nil || !e.Sensitive || !e.ReadOnly || e.Value != "" {
+		t.Fatalf("%+v", e)
+	}
+	// synonyms requested, all keys (null)
+	for _, req := range b1.Requests() {
+		if req.ApiKey != api.DescribeConfigs {
+			continue
+		}
+		r := &DescribeConfigs.Request{}
+		req.Unmarshal(r)
+		if !r.IncludeSynonyms || len(r.Resources) != 2 || r.Resources[0].ConfigurationKeys != nil {
+			t.Fatalf("%+v", r)
+		}
+	}
+}
+
+func TestUnitClientAlterConfigs(t *testing.T) {
+	controller := int32(1)
+	b1, b2 := fakeCluster(t, &controller, configsHandlers)
+	defer b1.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b1.Addr()}
+	defer c.Close()
+	results, err := c.AlterConfigs(map[ConfigResource]map[string]string{
+		TopicResource("foo"): {"retention.ms": "2000", "max.message.bytes": "2000000"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Resource != TopicResource("foo") || results[0].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	ops := map[ConfigResource][]AlterConfigOp{
+		TopicResource("foo"): {
+			{Name: "retention.ms", Op: IncrementalAlterConfigs.OpDelete},
+			{Name: "cleanup.policy", Op: IncrementalAlterConfigs.OpAppend, Value: "compact"},
+		},
+		BrokerResource(2): {{Name: "log.cleaner.threads", Op: IncrementalAlterConfigs.OpSet, Value: "2"}},
+	}
+	results, err = c.IncrementalAlterConfigs(ops, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Resource != TopicResource("foo") || results[1].Resource != BrokerResource(2) {
+		t.Fatalf("%+v", results)
+	}
+	var requests []*IncrementalAlterConfigs.Request
+	for _, b := range []*fakebroker.Broker{b1, b2} {
+		for _, req := range b.Requests() {
+			if req.ApiKey == api.IncrementalAlterConfigs {
+				r := &IncrementalAlterConfigs.Request{}
+				req.Unmarshal(r)
+				requests = append(requests, r)
+			}
+		}
+	}
+	if len(requests) != 2 {
+		t.Fatal(len(requests))
+	}
+	want := []IncrementalAlterConfigs.Config{
+		{Name: "retention.ms", ConfigOperation: IncrementalAlterConfigs.OpDelete},
+		{Name: "cleanup.policy", ConfigOperation: IncrementalAlterConfigs.OpAppend, Value: "compact"},
+	}
+	if topic := requests[0].Resources[0]; topic.ResourceName != "foo" || !reflect.DeepEqual(topic.Configs, want) {
+		t.Fatalf("%+v", topic)
+	}
+	if broker := requests[1].Resources[0]; broker.ResourceName != "2" || broker.Configs[0].Value != "2" {
+		t.Fatalf("%+v", broker)
+	}
+}
@@ -0,0 +1,204 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/AlterReplicaLogDirs"
+	"github.com/mkocikowski/libkafka/api/DescribeLogDirs"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// ReplicaLogDir is a partition replica in a log dir.
+type ReplicaLogDir struct {
+	Topic     string
+	Partition int32
+	// Size of the replica log in bytes.
+	Size int64
+	// OffsetLag of the replica behind the partition high watermark (or,
+	// for future replicas, behind the current replica).
+	OffsetLag int64
+	// Future is true if the replica is being moved to the log dir.
+	Future bool
+}
+
+// LogDir of a broker. If ErrorCode is not ERR_NONE (for example
+// ERR_KAFKA_STORAGE_ERROR for log dirs which are offline), Replicas are empty.
+type LogDir struct {
+	Broker    int32
+	Path      string
+	ErrorCode int16
+	Replicas  []*ReplicaLogDir // sorted by topic and partition
+	Size      int64            // of all replicas, in bytes
+}
+
+func (d *LogDir) Err() error {
+	if d.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: d.ErrorCode}
+}
+
+// TopicSize is the size of all replicas of all partitions of a topic in the
+// cluster (as described in a LogDirsReport).
+type TopicSize struct {
+	Topic    string
+	Size     int64 // in bytes
+	Replicas int   // number of partition replicas (including future replicas)
+}
+
+// LogDirsReport describes the log dirs of brokers.
+type LogDirsReport struct {
+	LogDirs []*LogDir // sorted by broker and path
+}
+
+// Broker returns the log dirs of the broker.
+func (r *LogDirsReport) Broker(nodeId int32) []*LogDir {
+	var dirs []*LogDir
+	for _, d := range r.LogDirs {
+		if d.Broker == nodeId {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// TopicSizes aggregates replica sizes by topic across the log dirs (and
+// brokers) in the report. Future replicas are included (they take up disk
+// space). Topic sizes are sorted largest first.
+func (r *LogDirsReport) TopicSizes() []*TopicSize {
+	topics := make(map[string]*TopicSize)
+	for _, d := range r.LogDirs {
+		for _, replica := range d.Replicas {
+			t, ok := topics[replica.Topic]
+			if !ok {
+				t = &TopicSize{Topic: replica.Topic}
+				topics[replica.Topic] = t
+			}
+			t.Size += replica.Size
+			t.Replicas++
+		}
+	}
+	sizes := make([]*TopicSize, 0, len(topics))
+	for _, t := range topics {
+		sizes = append(sizes, t)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Size != sizes[j].Size {
+			return sizes[i].Size > sizes[j].Size
+		}
+		return sizes[i].Topic < sizes[j].Topic
+	})
+	return sizes
+}
+
+func logDirs(nodeId int32, resp *DescribeLogDirs.Response) []*LogDir {
+	dirs := make([]*LogDir, len(resp.Results))
+	for i, r := range resp.Results {
+		d := &LogDir{Broker: nodeId, Path: r.LogDir, ErrorCode: r.ErrorCode, Replicas: []*ReplicaLogDir{}}
+		for _, t := range r.Topics {
+			for _, p := range t.Partitions {
+				d.Replicas = append(d.Replicas, &ReplicaLogDir{
+					Topic:     t.Name,
+					Partition: p.PartitionIndex,
+					Size:      p.PartitionSize,
+					OffsetLag: p.OffsetLag,
+					Future:    p.IsFutureKey,
+				})
+				d.Size += p.PartitionSize
+			}
+		}
+		sort.Slice(d.Replicas, func(i, j int) bool {
+			if d.Replicas[i].Topic != d.Replicas[j].Topic {
+				return d.Replicas[i].Topic < d.Replicas[j].Topic
+			}
+			return d.Replicas[i].Partition < d.Replicas[j].Partition
+		})
+		dirs[i] = d
+	}
+	return dirs
+}
+
+// DescribeLogDirs describes the log dirs of brokers (nil for all brokers in
+// the cluster) and the replicas of partitions (nil for all partitions) in
+// them. Each broker describes its own log dirs (one request per broker).
+// Returned error is the first round trip error; the report of the log dirs
+// described before the error is returned along with it. Requires Kafka 2.0+.
+func (c *Client) DescribeLogDirs(brokers []int32, partitions []client.TopicPartition) (*LogDirsReport, error) {
+	if brokers == nil {
+		meta, err := client.CallMetadataSASL(c.Bootstrap, c.TLS, c.SASL, []string{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting brokers: %w", err)
+		}
+		for _, b := range meta.Brokers {
+			brokers = append(brokers, b.NodeId)
+		}
+	}
+	var topics []DescribeLogDirs.Topic // nil for all partitions
+	if partitions != nil {
+		names, groups := byTopic(partitions)
+		topics = make([]DescribeLogDirs.Topic, len(names))
+		for i, name := range names {
+			topics[i] = DescribeLogDirs.Topic{Topic: name, PartitionIndexes: groups[name]}
+		}
+	}
+	report := &LogDirsReport{LogDirs: []*LogDir{}}
+	sortLogDirs := func() {
+		sort.Slice(report.LogDirs, func(i, j int) bool {
+			if report.LogDirs[i].Broker != report.LogDirs[j].Broker {
+				return report.LogDirs[i].Broker < report.LogDirs[j].Broker
+			}
+			return report.LogDirs[i].Path < report.LogDirs[j].Path
+		})
+	}
+	for _, nodeId := range brokers {
+		resp := &DescribeLogDirs.Response{}
+		if err := c.callNode(nodeId, DescribeLogDirs.NewRequest(topics), resp); err != nil {
+			sortLogDirs()
+			return report, fmt.Errorf("error describing log dirs of broker %d: %w", nodeId, err)
+		}
+		report.LogDirs = append(report.LogDirs, logDirs(nodeId, resp)...)
+	}
+	sortLogDirs()
+	return report, nil
+}
+
+// AlterReplicaLogDirs moves replicas of partitions on the broker to log dirs
+// (absolute paths keyed by partition). The move happens in the background:
+// the broker creates a future replica in the target log dir, which replaces
+// the current replica once it catches up (follow the progress with
+// DescribeLogDirs). Results are sorted by topic and partition; partitions
+// for which the log dir does not exist have ERR_LOG_DIR_NOT_FOUND. Requires
+// Kafka 2.0+.
+func (c *Client) AlterReplicaLogDirs(broker int32, dirs map[client.TopicPartition]string) ([]*PartitionResult, error) {
+	byDir := make(map[string][]client.TopicPartition)
+	var paths []string
+	for p, path := range dirs {
+		if _, ok := byDir[path]; !ok {
+			paths = append(paths, path)
+		}
+		byDir[path] = append(byDir[path], p)
+	}
+	sort.Strings(paths)
+	d := make([]AlterReplicaLogDirs.Dir, len(paths))
+	for i, path := range paths {
+		d[i] = AlterReplicaLogDirs.Dir{Path: path, Topics: []AlterReplicaLogDirs.Topic{}}
+		topics, groups := byTopic(byDir[path])
+		for _, t := range topics {
+			d[i].Topics = append(d[i].Topics, AlterReplicaLogDirs.Topic{Name: t, Partitions: groups[t]})
+		}
+	}
+	resp := &AlterReplicaLogDirs.Response{}
+	if err := c.callNode(broker, AlterReplicaLogDirs.NewRequest(d), resp); err != nil {
+		return nil, fmt.Errorf("error altering log dirs of broker %d: %w", broker, err)
+	}
+	results := []*PartitionResult{}
+	for _, t := range resp.Results {
+		for _, p := range t.Partitions {
+			results = append(results, &PartitionResult{Topic: t.TopicName, Partition: p.PartitionIndex, ErrorCode: p.ErrorCode})
+		}
+	}
+	sortPartitionResults(results)
+	return results, nil
+}
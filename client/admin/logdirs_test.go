@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/AlterReplicaLogDirs"
+	"github.com/mkocikowski/libkafka/api/DescribeLogDirs"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// logDirsHandlers for fakeCluster. broker 1 has replicas of foo:0 and bar:0
+// in /data1 and an offline /data2. broker 2 has foo:0 in /data1 and is moving
+// it to /data2.
+func logDirsHandlers() apiHandlers {
+	results := map[int32][]DescribeLogDirs.Result{
+		1: {
+			{LogDir: "/data2", ErrorCode: libkafka.ERR_KAFKA_STORAGE_ERROR},
+			{LogDir: "/data1", Topics: []DescribeLogDirs.TopicResult{
+				{Name: "foo", Partitions: []DescribeLogDirs.Partition{{PartitionIndex: 0, PartitionSize: 100}}},
+				{Name: "bar", Partitions: []DescribeLogDirs.Partition{{PartitionIndex: 0, PartitionSize: 300}}},
+			}},
+		},
+		2: {
+			{LogDir: "/data1", Topics: []DescribeLogDirs.TopicResult{
+				{Name: "foo", Partitions: []DescribeLogDirs.Partition{{PartitionIndex: 0, PartitionSize: 100, OffsetLag: 5}}},
+			}},
+			{LogDir: "/data2", Topics: []DescribeLogDirs.TopicResult{
+				{Name: "foo", Partitions: []DescribeLogDirs.Partition{{PartitionIndex: 0, PartitionSize: 40, OffsetLag: 60, IsFutureKey: true}}},
+			}},
+		},
+	}
+	return apiHandlers{
+		api.DescribeLogDirs: func(nodeId int32, req *fakebroker.Request) interface{} {
+			return &DescribeLogDirs.Response{Results: results[nodeId]}
+		},
+		api.AlterReplicaLogDirs: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &AlterReplicaLogDirs.Request{}
+			req.Unmarshal(r)
+			resp := &AlterReplicaLogDirs.Response{}
+			for _, d := range r.Dirs {
+				for _, topic := range d.Topics {
+					tr := AlterReplicaLogDirs.TopicResult{TopicName: topic.Name}
+					for _, p := range topic.Partitions {
+						pr := AlterReplicaLogDirs.PartitionResult{PartitionIndex: p}
+						if d.Path != "/data1" && d.Path != "/data2" {
+							pr.ErrorCode = libkafka.ERR_LOG_DIR_NOT_FOUND
+						}
+						tr.Partitions = append(tr.Partitions, pr)
+					}
+					resp.Results = append(resp.Results, tr)
+				}
+			}
+			return resp
+		},
+	}
+}
+
+func TestUnitClientDescribeLogDirs(t *testing.T) {
+	controller := int32(1)
+	b1, b2 := fakeCluster(t, &controller, logDirsHandlers())
+	defer b1.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b1.Addr()}
+	defer c.Close()
+	report, err := c.DescribeLogDirs(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.LogDirs) != 4 {
+		t.Fatalf("%+v", report.LogDirs)
+	}
+	dirs := report.Broker(1)
+	if len(dirs) != 2 || dirs[0].Path != "/data1" || dirs[0].Size != 400 || dirs[1].Err() == nil {
+		t.Fatalf("%+v", dirs)
+	}
+	if r := dirs[0].Replicas; len(r) != 2 || r[0].Topic != "bar" || r[1].Topic != "foo" {
+		t.Fatalf("%+v", r)
+	}
+	dirs = report.Broker(2)
+	if future := dirs[1].Replicas[0]; !future.Future || future.OffsetLag != 60 {
+		t.Fatalf("%+v", future)
+	}
+	want := []*TopicSize{{Topic: "bar", Size: 300, Replicas: 1}, {Topic: "foo", Size: 240, Replicas: 3}}
+	if sizes := report.TopicSizes(); !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("%+v", sizes)
+	}
+	// only broker 2, only foo:0
+	report, err = c.DescribeLogDirs([]int32{2}, []client.TopicPartition{{Topic: "foo", Partition: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.LogDirs) != 2 || len(report.Broker(1)) != 0 {
+		t.Fatalf("%+v", report.LogDirs)
+	}
+	requests := b2.Requests()
+	r := &DescribeLogDirs.Request{}
+	requests[len(requests)-1].Unmarshal(r)
+	if !reflect.DeepEqual(r.Topics, []DescribeLogDirs.Topic{{Topic: "foo", PartitionIndexes: []int32{0}}}) {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestUnitClientAlterReplicaLogDirs(t *testing.T) {
+	controller := int32(1)
+	b1, b2 := fakeCluster(t, &controller, logDirsHandlers())
+	defer b1.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b1.Addr()}
+	defer c.Close()
+	results, err := c.AlterReplicaLogDirs(2, map[client.TopicPartition]string{
+		{Topic: "foo", Partition: 1}: "/data2",
+		{Topic: "foo", Partition: 0}: "/data2",
+		{Topic: "bar", Partition: 0}: "/nope",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Topic != "bar" || results[0].ErrorCode != libkafka.ERR_LOG_DIR_NOT_FOUND {
+		t.Fatalf("%+v", results)
+	}
+	if results[1].Err() != nil || results[2].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	for _, req := range b1.Requests() {
+		if req.ApiKey == api.AlterReplicaLogDirs {
+			t.Fatal("request sent to broker 1")
+		}
+	}
+	r := &AlterReplicaLogDirs.Request{}
+	requests := b2.Requests()
+	requests[len(requests)-1].Unmarshal(r)
+	if len(r.Dirs) != 2 || r.Dirs[0].Path != "/data2" || !reflect.DeepEqual(r.Dirs[0].Topics[0].Partitions, []int32{0, 1}) {
+		t.Fatalf("%+v", r)
+	}
+}
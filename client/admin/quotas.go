@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/AlterClientQuotas"
+	"github.com/mkocikowski/libkafka/api/DescribeClientQuotas"
+)
+
+// QuotaEntityType is the type of a quota entity component.
+type QuotaEntityType string
+
+const (
+	QuotaUser     QuotaEntityType = "user"
+	QuotaClientId QuotaEntityType = "client-id"
+	QuotaIp       QuotaEntityType = "ip"
+)
+
+// QuotaKey is the name of a quota.
+type QuotaKey string
+
+const (
+	// ProducerByteRate in bytes per second, per broker.
+	ProducerByteRate QuotaKey = "producer_byte_rate"
+	// ConsumerByteRate in bytes per second, per broker.
+	ConsumerByteRate QuotaKey = "consumer_byte_rate"
+	// RequestPercentage of the time of broker network and io threads.
+	RequestPercentage QuotaKey = "request_percentage"
+	// ConnectionCreationRate per second, per broker (ip entities only).
+	ConnectionCreationRate QuotaKey = "connection_creation_rate"
+)
+
+// QuotaEntityComponent of a quota entity. Empty Name is the default entity of
+// the type: for example the default user quota applies to all users without
+// their own quota.
+type QuotaEntityComponent struct {
+	Type QuotaEntityType
+	Name string
+}
+
+// QuotaEntity is what quotas apply to: a user, a client id, a user and a
+// client id, or an ip. Components are sorted by type.
+type QuotaEntity []QuotaEntityComponent
+
+func newQuotaEntity(components ...QuotaEntityComponent) QuotaEntity {
+	e := QuotaEntity(components)
+	sort.Slice(e, func(i, j int) bool { return e[i].Type < e[j].Type })
+	return e
+}
+
+// UserQuotaEntity for the user ("" for the default user).
+func UserQuotaEntity(user string) QuotaEntity {
+	return newQuotaEntity(QuotaEntityComponent{Type: QuotaUser, Name: user})
+}
+
+// ClientIdQuotaEntity for the client id ("" for the default client id).
+func ClientIdQuotaEntity(clientId string) QuotaEntity {
+	return newQuotaEntity(QuotaEntityComponent{Type: QuotaClientId, Name: clientId})
+}
+
+// UserClientIdQuotaEntity for the client id of the user.
+func UserClientIdQuotaEntity(user, clientId string) QuotaEntity {
+	return newQuotaEntity(
+		QuotaEntityComponent{Type: QuotaUser, Name: user},
+		QuotaEntityComponent{Type: QuotaClientId, Name: clientId},
+	)
+}
+
+// IpQuotaEntity for the ip address ("" for the default ip).
+func IpQuotaEntity(ip string) QuotaEntity {
+	return newQuotaEntity(QuotaEntityComponent{Type: QuotaIp, Name: ip})
+}
+
+// String in the form "client-id=foo,user=<default>".
+func (e QuotaEntity) String() string {
+	s := make([]string, len(e))
+	for i, c := range e {
+		name := c.Name
+		if name == "" {
+			name = "<default>"
+		}
+		s[i] = string(c.Type) + "=" + name
+	}
+	return strings.Join(s, ",")
+}
+
+// QuotaFilterComponent matches entities on the component type. Match is one
+// of DescribeClientQuotas.Match* values. Name is used only with MatchExact.
+type QuotaFilterComponent struct {
+	Type  QuotaEntityType
+	Match int8
+	Name  string
+}
+
+// ClientQuotas are the quotas of an entity.
+type ClientQuotas struct {
+	Entity QuotaEntity
+	Values map[QuotaKey]float64
+}
+
+// DescribeClientQuotas returns quotas of entities matching all components of
+// the filter. With strict, only entities with exactly the filter component
+// types match (otherwise entities with additional types match as well, so
+// for example a filter for user "alice" also matches user "alice" with any
+// client id). Results are sorted by entity. An error code for the whole
+// request is returned as *libkafka.Error. Requires Kafka 2.8+.
+func (c *Client) DescribeClientQuotas(filter []QuotaFilterComponent, strict bool) ([]*ClientQuotas, error) {
+	components := make([]DescribeClientQuotas.Component, len(filter))
+	for i, f := range filter {
+		components[i] = DescribeClientQuotas.Component{EntityType: string(f.Type), MatchType: f.Match}
+		if f.Match == DescribeClientQuotas.MatchExact {
+			components[i].Match = f.Name
+		}
+	}
+	resp := &DescribeClientQuotas.Response{}
+	if err := c.Call(DescribeClientQuotas.NewRequest(components, strict), resp, nil); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+	}
+	quotas := []*ClientQuotas{}
+	for _, entry := range resp.Entries {
+		q := &ClientQuotas{Values: make(map[QuotaKey]float64)}
+		for _, e := range entry.Entity {
+			q.Entity = append(q.Entity, QuotaEntityComponent{Type: QuotaEntityType(e.EntityType), Name: e.EntityName})
+		}
+		q.Entity = newQuotaEntity(q.Entity...)
+		for _, v := range entry.Values {
+			q.Values[QuotaKey(v.Key)] = v.Value
+		}
+		quotas = append(quotas, q)
+	}
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Entity.String() < quotas[j].Entity.String() })
+	return quotas, nil
+}
+
+// QuotaAlteration sets and removes quotas of an entity.
+type QuotaAlteration struct {
+	Entity QuotaEntity
+	Set    map[QuotaKey]float64
+	Remove []QuotaKey
+}
+
+// QuotaResult of altering the quotas of an entity.
+type QuotaResult struct {
+	Entity       QuotaEntity
+	ErrorCode    int16
+	ErrorMessage string
+}
+
+func (r *QuotaResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+// AlterClientQuotas sets and removes quotas of entities. With validateOnly
+// the changes are validated but not made. Results are sorted by entity.
+// Requires Kafka 2.8+.
+func (c *Client) AlterClientQuotas(alterations []QuotaAlteration, validateOnly bool) ([]*QuotaResult, error) {
+	entries := make([]AlterClientQuotas.Entry, len(alterations))
+	for i, a := range alterations {
+		entries[i] = AlterClientQuotas.Entry{Ops: []AlterClientQuotas.Op{}}
+		for _, e := range a.Entity {
+			entries[i].Entity = append(entries[i].Entity, AlterClientQuotas.Entity{EntityType: string(e.Type), EntityName: e.Name})
+		}
+		for key, value := range a.Set {
+			entries[i].Ops = append(entries[i].Ops, AlterClientQuotas.Op{Key: string(key), Value: value})
+		}
+		for _, key := range a.Remove {
+			entries[i].Ops = append(entries[i].Ops, AlterClientQuotas.Op{Key: string(key), Remove: true})
+		}
+		sort.SliceStable(entries[i].Ops, func(a, b int) bool { return entries[i].Ops[a].Key < entries[i].Ops[b].Key })
+	}
+	resp := &AlterClientQuotas.Response{}
+	if err := c.Call(AlterClientQuotas.NewRequest(entries, validateOnly), resp, nil); err != nil {
+		return nil, err
+	}
+	if len(resp.Entries) != len(alterations) {
+		return nil, fmt.Errorf("malformed response: %d results for %d alterations", len(resp.Entries), len(alterations))
+	}
+	results := make([]*QuotaResult, len(resp.Entries))
+	for i, r := range resp.Entries {
+		results[i] = &QuotaResult{ErrorCode: r.ErrorCode, ErrorMessage: r.ErrorMessage}
+		for _, e := range r.Entity {
+			results[i].Entity = append(results[i].Entity, QuotaEntityComponent{Type: QuotaEntityType(e.EntityType), Name: e.EntityName})
+		}
+		results[i].Entity = newQuotaEntity(results[i].Entity...)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Entity.String() < results[j].Entity.String() })
+	return results, nil
+}
@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/AlterClientQuotas"
+	"github.com/mkocikowski/libkafka/api/DescribeClientQuotas"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// quotasHandlers for fakeCluster keep quotas in memory. describe supports only
+// filters with one component, and ignores strict.
+func quotasHandlers(t *testing.T) apiHandlers {
+	type entry struct {
+		entity []DescribeClientQuotas.Entity
+		values map[string]float64
+	}
+	quotas := make(map[string]*entry)
+	return apiHandlers{
+		api.AlterClientQuotas: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &AlterClientQuotas.Request{}
+			if err := req.Unmarshal(r); err != nil {
+				t.Error(err)
+			}
+			resp := &AlterClientQuotas.Response{}
+			for _, e := range r.Entries {
+				result := AlterClientQuotas.EntryResponse{Entity: e.Entity}
+				key := ""
+				var entity []DescribeClientQuotas.Entity
+				for _, c := range e.Entity {
+					key += c.EntityType + "=" + c.EntityName + ";"
+					entity = append(entity, DescribeClientQuotas.Entity{EntityType: c.EntityType, EntityName: c.EntityName})
+				}
+				if quotas[key] == nil {
+					quotas[key] = &entry{entity: entity, values: make(map[string]float64)}
+				}
+				for _, op := range e.Ops {
+					switch {
+					case op.Key == "nope":
+						result.ErrorCode = libkafka.ERR_INVALID_REQUEST
+						result.ErrorMessage = "unknown quota key"
+					case op.Remove && !r.ValidateOnly:
+						delete(quotas[key].values, op.Key)
+					case !r.ValidateOnly:
+						quotas[key].values[op.Key] = op.Value
+					}
+				}
+				resp.Entries = append(resp.Entries, result)
+			}
+			return resp
+		},
+		api.DescribeClientQuotas: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &DescribeClientQuotas.Request{}
+			req.Unmarshal(r)
+			resp := &DescribeClientQuotas.Response{}
+			f := r.Components[0]
+			for _, q := range quotas {
+				if len(q.values) == 0 {
+					continue
+				}
+				match := false
+				for _, e := range q.entity {
+					if e.EntityType != f.EntityType {
+						continue
+					}
+					switch f.MatchType {
+					case DescribeClientQuotas.MatchAny:
+						match = true
+					case DescribeClientQuotas.MatchDefault:
+						match = e.EntityName == ""
+					case DescribeClientQuotas.MatchExact:
+						match = e.EntityName == f.Match
+					}
+				}
+				if !match {
+					continue
+				}
+				result := DescribeClientQuotas.Entry{Entity: q.entity}
+				for k, v := range q.values {
+					result.Values = append(result.Values, DescribeClientQuotas.Value{Key: k, Value: v})
+				}
+				resp.Entries = append(resp.Entries, result)
+			}
+			return resp
+		},
+	}
+}
+
+func TestUnitQuotaEntityString(t *testing.T) {
+	if s := UserClientIdQuotaEntity("alice", "").String(); s != "client-id=<default>,user=alice" {
+		t.Fatal(s)
+	}
+	if s := IpQuotaEntity("10.0.0.1").String(); s != "ip=10.0.0.1" {
+		t.Fatal(s)
+	}
+}
+
+func TestUnitClientQuotas(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, quotasHandlers(t))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	results, err := c.AlterClientQuotas([]QuotaAlteration{
+		{Entity: UserQuotaEntity("alice"), Set: map[QuotaKey]float64{ProducerByteRate: 1048576, ConsumerByteRate: 2097152}},
+		{Entity: UserQuotaEntity(""), Set: map[QuotaKey]float64{ProducerByteRate: 1024}},
+		{Entity: UserClientIdQuotaEntity("alice", "etl"), Set: map[QuotaKey]float64{RequestPercentage: 12.5}},
+		{Entity: ClientIdQuotaEntity("bob"), Set: map[QuotaKey]float64{"nope": 1}},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("%+v", results)
+	}
+	if r := results[0]; !reflect.DeepEqual(r.Entity, ClientIdQuotaEntity("bob")) || r.Err() == nil || r.ErrorMessage == "" {
+		t.Fatalf("%+v", r)
+	}
+	for _, r := range results[1:] {
+		if r.Err() != nil {
+			t.Fatalf("%+v", r)
+		}
+	}
+	// default entity is sent as null
+	r := &AlterClientQuotas.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(r)
+	if r.Entries[1].Entity[0].EntityName != "" || r.Entries[0].Ops[0].Key != string(ConsumerByteRate) {
+		t.Fatalf("%+v", r)
+	}
+	quotas, err := c.DescribeClientQuotas([]QuotaFilterComponent{{Type: QuotaUser, Match: DescribeClientQuotas.MatchExact, Name: "alice"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotas) != 2 {
+		t.Fatalf("%+v", quotas)
+	}
+	if q := quotas[0]; !reflect.DeepEqual(q.Entity, UserClientIdQuotaEntity("alice", "etl")) || q.Values[RequestPercentage] != 12.5 {
+		t.Fatalf("%+v", q)
+	}
+	if q := quotas[1]; q.Values[ProducerByteRate] != 1048576 || q.Values[ConsumerByteRate] != 2097152 {
+		t.Fatalf("%+v", q)
+	}
+	quotas, _ = c.DescribeClientQuotas([]QuotaFilterComponent{{Type: QuotaUser, Match: DescribeClientQuotas.MatchDefault}}, false)
+	if len(quotas) != 1 || quotas[0].Entity.String() != "user=<default>" || quotas[0].Values[ProducerByteRate] != 1024 {
+		t.Fatalf("%+v", quotas)
+	}
+	// remove
+	results, _ = c.AlterClientQuotas([]QuotaAlteration{{Entity: UserQuotaEntity(""), Remove: []QuotaKey{ProducerByteRate}}}, false)
+	if results[0].Err() != nil {
+		t.Fatalf("%+v", results[0])
+	}
+	quotas, _ = c.DescribeClientQuotas([]QuotaFilterComponent{{Type: QuotaUser, Match: DescribeClientQuotas.MatchAny}}, true)
+	if len(quotas) != 2 {
+		t.Fatalf("%+v", quotas)
+	}
+	for _, req := range b.Requests() {
+		if req.ApiKey == api.DescribeClientQuotas && req.ApiVersion != 1 {
+			t.Fatal(req.ApiVersion)
+		}
+	}
+}
+
+func TestUnitClientQuotasValidateOnly(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, quotasHandlers(t))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	alice := UserQuotaEntity("alice")
+	if _, err := c.AlterClientQuotas([]QuotaAlteration{{Entity: alice, Set: map[QuotaKey]float64{ProducerByteRate: 1024, ConsumerByteRate: 2048}}}, false); err != nil {
+		t.Fatal(err)
+	}
+	results, err := c.AlterClientQuotas([]QuotaAlteration{{Entity: alice, Set: map[QuotaKey]float64{ProducerByteRate: 1}, Remove: []QuotaKey{ConsumerByteRate}}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	r := &AlterClientQuotas.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(r)
+	if !r.ValidateOnly {
+		t.Fatalf("%+v", r)
+	}
+	quotas, _ := c.DescribeClientQuotas([]QuotaFilterComponent{{Type: QuotaUser, Match: DescribeClientQuotas.MatchExact, Name: "alice"}}, false)
+	if len(quotas) != 1 || quotas[0].Values[ProducerByteRate] != 1024 || quotas[0].Values[ConsumerByteRate] != 2048 {
+		t.Fatalf("%+v", quotas)
+	}
+}
@@ -0,0 +1,290 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/AlterPartitionReassignments"
+	"github.com/mkocikowski/libkafka/api/ElectLeaders"
+	"github.com/mkocikowski/libkafka/api/ElectPreferredLeaders"
+	"github.com/mkocikowski/libkafka/api/ListPartitionReassignments"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// DefaultPollInterval is how often ReassignPartitions checks the progress of
+// the reassignment if pollInterval is not set.
+const DefaultPollInterval = 10 * time.Second
+
+// ErrReassignmentInProgress is returned by ReassignPartitions when it stops
+// waiting for the reassignment to complete.
+var ErrReassignmentInProgress = errors.New("reassignment in progress")
+
+// PartitionResult of an admin operation on a partition.
+type PartitionResult struct {
+	Topic        string
+	Partition    int32
+	ErrorCode    int16
+	ErrorMessage string
+}
+
+func (r *PartitionResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+func sortPartitionResults(results []*PartitionResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Topic != results[j].Topic {
+			return results[i].Topic < results[j].Topic
+		}
+		return results[i].Partition < results[j].Partition
+	})
+}
+
+// byTopic groups partitions by topic, with topics and partitions sorted.
+func byTopic(partitions []client.TopicPartition) ([]string, map[string][]int32) {
+	var topics []string
+	groups := make(map[string][]int32)
+	for _, p := range partitions {
+		if _, ok := groups[p.Topic]; !ok {
+			topics = append(topics, p.Topic)
+		}
+		groups[p.Topic] = append(groups[p.Topic], p.Partition)
+	}
+	sort.Strings(topics)
+	for _, t := range topics {
+		sort.Slice(groups[t], func(i, j int) bool { return groups[t][i] < groups[t][j] })
+	}
+	return topics, groups
+}
+
+func notControllerCode(code *int16) func() bool {
+	return func() bool { return *code == libkafka.ERR_NOT_CONTROLLER }
+}
+
+// AlterPartitionReassignments submits reassignments of partitions to target
+// replicas (broker ids, the first one being the preferred leader). Nil
+// replicas cancel the reassignment of the partition in progress. It returns
+// once the controller has accepted the reassignments (use
+// ListPartitionReassignments to see their progress, or ReassignPartitions to
+// submit and wait). Results are sorted by topic and partition. An error code
+// for the whole request is returned as *libkafka.Error. Requires Kafka 2.4+.
+func (c *Client) AlterPartitionReassignments(targets map[client.TopicPartition][]int32) ([]*PartitionResult, error) {
+	partitions := make([]client.TopicPartition, 0, len(targets))
+	for p := range targets {
+		partitions = append(partitions, p)
+	}
+	topics, groups := byTopic(partitions)
+	t := make([]AlterPartitionReassignments.Topic, len(topics))
+	for i, topic := range topics {
+		t[i] = AlterPartitionReassignments.Topic{Name: topic, Partitions: []AlterPartitionReassignments.Partition{}}
+		for _, p := range groups[topic] {
+			t[i].Partitions = append(t[i].Partitions, AlterPartitionReassignments.Partition{
+				PartitionIndex: p,
+				Replicas:       targets[client.TopicPartition{Topic: topic, Partition: p}],
+			})
+		}
+	}
+	req := AlterPartitionReassignments.NewRequest(t, c.timeoutMs())
+	resp := &AlterPartitionReassignments.Response{}
+	if err := c.Call(req, resp, notControllerCode(&resp.ErrorCode)); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+	}
+	results := []*PartitionResult{}
+	for _, t := range resp.Responses {
+		for _, p := range t.Partitions {
+			results = append(results, &PartitionResult{
+				Topic:        t.Name,
+				Partition:    p.PartitionIndex,
+				ErrorCode:    p.ErrorCode,
+				ErrorMessage: p.ErrorMessage,
+			})
+		}
+	}
+	sortPartitionResults(results)
+	return results, nil
+}
+
+// PartitionReassignment in progress.
+type PartitionReassignment struct {
+	Topic     string
+	Partition int32
+	// Replicas is the current replica set, which includes the Adding and
+	// Removing replicas.
+	Replicas []int32
+	Adding   []int32
+	Removing []int32
+}
+
+// ListPartitionReassignments returns reassignments in progress of partitions
+// (nil partitions for all reassignments in progress), sorted by topic and
+// partition. An error code for the whole request is returned as
+// *libkafka.Error. Requires Kafka 2.4+.
+func (c *Client) ListPartitionReassignments(partitions []client.TopicPartition) ([]*PartitionReassignment, error) {
+	var t []ListPartitionReassignments.Topic // nil lists all
+	if partitions != nil {
+		topics, groups := byTopic(partitions)
+		t = make([]ListPartitionReassignments.Topic, len(topics))
+		for i, topic := range topics {
+			t[i] = ListPartitionReassignments.Topic{Name: topic, PartitionIndexes: groups[topic]}
+		}
+	}
+	req := ListPartitionReassignments.NewRequest(t, c.timeoutMs())
+	resp := &ListPartitionReassignments.Response{}
+	if err := c.Call(req, resp, notControllerCode(&resp.ErrorCode)); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+	}
+	reassignments := []*PartitionReassignment{}
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			reassignments = append(reassignments, &PartitionReassignment{
+				Topic:     t.Name,
+				Partition: p.PartitionIndex,
+				Replicas:  p.Replicas,
+				Adding:    p.AddingReplicas,
+				Removing:  p.RemovingReplicas,
+			})
+		}
+	}
+	sort.Slice(reassignments, func(i, j int) bool {
+		if reassignments[i].Topic != reassignments[j].Topic {
+			return reassignments[i].Topic < reassignments[j].Topic
+		}
+		return reassignments[i].Partition < reassignments[j].Partition
+	})
+	return reassignments, nil
+}
+
+// ReassignPartitions submits reassignments of partitions to target replicas
+// (same as AlterPartitionReassignments) and waits until the reassignments of
+// all partitions which were accepted complete, polling their progress with
+// ListPartitionReassignments every pollInterval (DefaultPollInterval if 0).
+// Progress, if not nil, is called after every poll with the reassignments
+// still in progress; if it returns false, ReassignPartitions stops waiting and
+// returns ErrReassignmentInProgress (the reassignments continue on the
+// cluster). Results of submitting the reassignments are returned also when
+// there is an error waiting for them to complete.
+func (c *Client) ReassignPartitions(targets map[client.TopicPartition][]int32, pollInterval time.Duration, progress func([]*PartitionReassignment) bool) ([]*PartitionResult, error) {
+	results, err := c.AlterPartitionReassignments(targets)
+	if err != nil {
+		return nil, fmt.Errorf("error submitting reassignments: %w", err)
+	}
+	var partitions []client.TopicPartition
+	for _, r := range results {
+		if r.ErrorCode == libkafka.ERR_NONE {
+			partitions = append(partitions, client.TopicPartition{Topic: r.Topic, Partition: r.Partition})
+		}
+	}
+	if len(partitions) == 0 {
+		return results, nil
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	for {
+		inProgress, err := c.ListPartitionReassignments(partitions)
+		if err != nil {
+			return results, fmt.Errorf("error listing reassignments: %w", err)
+		}
+		if len(inProgress) == 0 {
+			return results, nil
+		}
+		if progress != nil && !progress(inProgress) {
+			return results, ErrReassignmentInProgress
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// ElectLeaders runs leader elections of electionType (ElectLeaders.Preferred
+// or ElectLeaders.Unclean) for partitions (nil for all partitions). Partitions
+// whose leader is already the one which would be elected have
+// ERR_ELECTION_NOT_NEEDED. Results are sorted by topic and partition. An
+// error code for the whole request is returned as *libkafka.Error. Requires
+// Kafka 2.4+, except for preferred elections, which fall back to
+// ElectPreferredLeaders for Kafka 2.2 and 2.3.
+func (c *Client) ElectLeaders(electionType int8, partitions []client.TopicPartition) ([]*PartitionResult, error) {
+	var t []ElectLeaders.TopicPartitions // nil for all partitions
+	if partitions != nil {
+		topics, groups := byTopic(partitions)
+		t = make([]ElectLeaders.TopicPartitions, len(topics))
+		for i, topic := range topics {
+			t[i] = ElectLeaders.TopicPartitions{Topic: topic, Partitions: groups[topic]}
+		}
+	}
+	req := ElectLeaders.NewRequest(electionType, t, c.timeoutMs())
+	resp := &ElectLeaders.Response{}
+	err := c.Call(req, resp, notControllerCode(&resp.ErrorCode))
+	var e *libkafka.Error
+	if errors.As(err, &e) && e.Code == libkafka.ERR_UNSUPPORTED_VERSION && electionType == ElectLeaders.Preferred {
+		return c.electPreferredLeaders(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode}
+	}
+	results := []*PartitionResult{}
+	for _, r := range resp.ReplicaElectionResults {
+		for _, p := range r.PartitionResult {
+			results = append(results, &PartitionResult{
+				Topic:        r.Topic,
+				Partition:    p.PartitionId,
+				ErrorCode:    p.ErrorCode,
+				ErrorMessage: p.ErrorMessage,
+			})
+		}
+	}
+	sortPartitionResults(results)
+	return results, nil
+}
+
+func (c *Client) electPreferredLeaders(topics []ElectLeaders.TopicPartitions) ([]*PartitionResult, error) {
+	var t []ElectPreferredLeaders.TopicPartitions // nil for all partitions
+	if topics != nil {
+		t = make([]ElectPreferredLeaders.TopicPartitions, len(topics))
+		for i, topic := range topics {
+			t[i] = ElectPreferredLeaders.TopicPartitions{Topic: topic.Topic, PartitionId: topic.Partitions}
+		}
+	}
+	req := ElectPreferredLeaders.NewRequest(t, c.timeoutMs())
+	resp := &ElectPreferredLeaders.Response{}
+	notController := func() bool {
+		for _, r := range resp.ReplicaElectionResults {
+			for _, p := range r.PartitionResult {
+				if p.ErrorCode == libkafka.ERR_NOT_CONTROLLER {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if err := c.Call(req, resp, notController); err != nil {
+		return nil, err
+	}
+	results := []*PartitionResult{}
+	for _, r := range resp.ReplicaElectionResults {
+		for _, p := range r.PartitionResult {
+			results = append(results, &PartitionResult{
+				Topic:        r.Topic,
+				Partition:    p.PartitionId,
+				ErrorCode:    p.ErrorCode,
+				ErrorMessage: p.ErrorMessage,
+			})
+		}
+	}
+	sortPartitionResults(results)
+	return results, nil
+}
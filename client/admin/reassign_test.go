@@ -0,0 +1,326 @@
+package admin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/AlterPartitionReassignments"
+	"github.com/mkocikowski/libkafka/api/ApiVersions"
+	"github.com/mkocikowski/libkafka/api/ElectLeaders"
+	"github.com/mkocikowski/libkafka/api/ElectPreferredLeaders"
+	"github.com/mkocikowski/libkafka/api/ListPartitionReassignments"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// reassignHandlers for fakeCluster reassign partitions of topic "foo" (other
+// topics do not exist). each reassignment stays in progress for polls
+// ListPartitionReassignments calls.
+func reassignHandlers(polls int) apiHandlers {
+	inProgress := make(map[int32][]int32) // partition -> remaining polls, target
+	remaining := make(map[int32]int)
+	return apiHandlers{
+		api.AlterPartitionReassignments: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &AlterPartitionReassignments.Request{}
+			req.Unmarshal(r)
+			resp := &AlterPartitionReassignments.Response{}
+			for _, topic := range r.Topics {
+				tr := AlterPartitionReassignments.TopicResponse{Name: topic.Name}
+				for _, p := range topic.Partitions {
+					pr := AlterPartitionReassignments.PartitionResponse{PartitionIndex: p.PartitionIndex}
+					switch {
+					case topic.Name != "foo":
+						pr.ErrorCode = libkafka.ERR_UNKNOWN_TOPIC_OR_PARTITION
+						pr.ErrorMessage = "unknown topic"
+					case p.Replicas == nil && inProgress[p.PartitionIndex] == nil:
+						pr.ErrorCode = libkafka.ERR_NO_REASSIGNMENT_IN_PROGRESS
+					case p.Replicas == nil:
+						delete(inProgress, p.PartitionIndex)
+					default:
+						inProgress[p.PartitionIndex] = p.Replicas
+						remaining[p.PartitionIndex] = polls
+					}
+					tr.Partitions = append(tr.Partitions, pr)
+				}
+				resp.Responses = append(resp.Responses, tr)
+			}
+			return resp
+		},
+		api.ListPartitionReassignments: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &ListPartitionReassignments.Request{}
+			req.Unmarshal(r)
+			tr := ListPartitionReassignments.TopicResponse{Name: "foo"}
+			for p, target := range inProgress {
+				if remaining[p] == 0 {
+					delete(inProgress, p)
+					continue
+				}
+				remaining[p]--
+				tr.Partitions = append(tr.Partitions, ListPartitionReassignments.PartitionResponse{
+					PartitionIndex: p,
+					Replicas:       append([]int32{1}, target...),
+					AddingReplicas: target,
+				})
+			}
+			resp := &ListPartitionReassignments.Response{}
+			if len(tr.Partitions) > 0 {
+				resp.Topics = []ListPartitionReassignments.TopicResponse{tr}
+			}
+			return resp
+		},
+	}
+}
+
+func TestUnitClientReassignPartitions(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, reassignHandlers(2))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	targets := map[client.TopicPartition][]int32{
+		{Topic: "foo", Partition: 1}: {2, 3},
+		{Topic: "foo", Partition: 0}: {3, 2},
+		{Topic: "bar", Partition: 0}: {2, 3},
+	}
+	var polls [][]*PartitionReassignment
+	progress := func(p []*PartitionReassignment) bool {
+		polls = append(polls, p)
+		return true
+	}
+	results, err := c.ReassignPartitions(targets, 1, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Topic != "bar" || results[0].Err() == nil || results[0].ErrorMessage == "" {
+		t.Fatalf("%+v", results)
+	}
+	if results[1].Partition != 0 || results[1].Err() != nil || results[2].Partition != 1 || results[2].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	if len(polls) != 2 || len(polls[0]) != 2 {
+		t.Fatalf("%+v", polls)
+	}
+	if p := polls[0][0]; p.Partition != 0 || !reflect.DeepEqual(p.Adding, []int32{3, 2}) {
+		t.Fatalf("%+v", p)
+	}
+	// the request uses flexible encoding, and lists only accepted partitions
+	for _, req := range b.Requests() {
+		if req.ApiKey != api.ListPartitionReassignments {
+			continue
+		}
+		r := &ListPartitionReassignments.Request{}
+		if err := req.Unmarshal(r); err != nil {
+			t.Fatal(err)
+		}
+		want := []ListPartitionReassignments.Topic{{Name: "foo", PartitionIndexes: []int32{0, 1}}}
+		if !reflect.DeepEqual(r.Topics, want) || r.TimeoutMs != DefaultTimeoutMs {
+			t.Fatalf("%+v", r)
+		}
+	}
+}
+
+func TestUnitClientReassignPartitionsStop(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, reassignHandlers(10))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	targets := map[client.TopicPartition][]int32{{Topic: "foo", Partition: 0}: {2}}
+	_, err := c.ReassignPartitions(targets, 1, func([]*PartitionReassignment) bool { return false })
+	if err != ErrReassignmentInProgress {
+		t.Fatal(err)
+	}
+	all, err := c.ListPartitionReassignments(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Topic != "foo" {
+		t.Fatalf("%+v", all)
+	}
+	// cancel
+	results, err := c.AlterPartitionReassignments(map[client.TopicPartition][]int32{{Topic: "foo", Partition: 0}: nil})
+	if err != nil || results[0].Err() != nil {
+		t.Fatal(err, results)
+	}
+	results, _ = c.AlterPartitionReassignments(map[client.TopicPartition][]int32{{Topic: "foo", Partition: 0}: nil})
+	var e *libkafka.Error
+	if !errors.As(results[0].Err(), &e) || e.Code != libkafka.ERR_NO_REASSIGNMENT_IN_PROGRESS {
+		t.Fatalf("%+v", results[0])
+	}
+}
+
+// electionHandlers for fakeCluster elect leaders of any partitions, except
+// that ElectLeaders v1+ preferred election of partition 0 is not needed.
+var electionHandlers = apiHandlers{
+	api.ElectLeaders: func(nodeId int32, req *fakebroker.Request) interface{} {
+		if req.ApiVersion == 0 {
+			r := &ElectPreferredLeaders.Request{}
+			req.Unmarshal(r)
+			resp := &ElectPreferredLeaders.Response{}
+			for _, tp := range r.TopicPartitions {
+				result := ElectPreferredLeaders.ReplicaElectionResult{Topic: tp.Topic}
+				for _, p := range tp.PartitionId {
+					result.PartitionResult = append(result.PartitionResult, ElectPreferredLeaders.PartitionResult{PartitionId: p})
+				}
+				resp.ReplicaElectionResults = append(resp.ReplicaElectionResults, result)
+			}
+			return resp
+		}
+		r := &ElectLeaders.Request{}
+		req.Unmarshal(r)
+		resp := &ElectLeaders.Response{}
+		for _, tp := range r.TopicPartitions {
+			result := ElectLeaders.ReplicaElectionResult{Topic: tp.Topic}
+			for _, p := range tp.Partitions {
+				pr := ElectLeaders.PartitionResult{PartitionId: p}
+				if r.ElectionType == ElectLeaders.Preferred && p == 0 {
+					pr.ErrorCode = libkafka.ERR_ELECTION_NOT_NEEDED
+				}
+				result.PartitionResult = append(result.PartitionResult, pr)
+			}
+			resp.ReplicaElectionResults = append(resp.ReplicaElectionResults, result)
+		}
+		return resp
+	},
+}
+
+// fakeElectionCluster supports ElectLeaders versions up to
+// electLeadersVersion (v0 is ElectPreferredLeaders).
+func fakeElectionCluster(t *testing.T, electLeadersVersion int16) (*fakebroker.Broker, *fakebroker.Broker) {
+	t.Helper()
+	controller := int32(1)
+	b1, b2 := fakeCluster(t, &controller, electionHandlers)
+	b1.Versions = &ApiVersions.Response{}
+	for k := 0; k < len(api.Keys); k++ {
+		v := ApiVersions.ApiKeyVersion{ApiKey: int16(k), MaxVersion: 12}
+		if k == api.ElectLeaders {
+			v.MaxVersion = electLeadersVersion
+		}
+		b1.Versions.ApiKeys = append(b1.Versions.ApiKeys, v)
+	}
+	return b1, b2
+}
+
+func TestUnitClientElectLeaders(t *testing.T) {
+	b, b2 := fakeElectionCluster(t, 1)
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	partitions := []client.TopicPartition{{Topic: "foo", Partition: 1}, {Topic: "foo", Partition: 0}}
+	results, err := c.ElectLeaders(ElectLeaders.Preferred, partitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Partition != 0 || results[0].ErrorCode != libkafka.ERR_ELECTION_NOT_NEEDED || results[1].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	if results, _ = c.ElectLeaders(ElectLeaders.Unclean, partitions); results[0].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	r := &ElectLeaders.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(r)
+	if r.ElectionType != ElectLeaders.Unclean {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestUnitClientElectLeadersFallback(t *testing.T) {
+	b, b2 := fakeElectionCluster(t, 0)
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	partitions := []client.TopicPartition{{Topic: "foo", Partition: 0}}
+	results, err := c.ElectLeaders(ElectLeaders.Preferred, partitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Topic != "foo" || results[0].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	for _, req := range b.Requests() {
+		if req.ApiKey == api.ElectLeaders && req.ApiVersion != 0 {
+			t.Fatal(req.ApiVersion)
+		}
+	}
+	// unclean elections need v1
+	_, err = c.ElectLeaders(ElectLeaders.Unclean, partitions)
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_UNSUPPORTED_VERSION {
+		t.Fatal(err)
+	}
+}
+
+func TestUnitBalancedAssignment(t *testing.T) {
+	meta := &Metadata.Response{
+		Brokers: []Metadata.Broker{
+			{NodeId: 1, Rack: "a"}, {NodeId: 2, Rack: "a"},
+			{NodeId: 3, Rack: "b"}, {NodeId: 4, Rack: "b"},
+			{NodeId: 5, Rack: "c"}, {NodeId: 6, Rack: "c"},
+		},
+		TopicMetadata: []Metadata.TopicMetadata{
+			{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0}, {Partition: 1}, {Partition: 2}}},
+			{Topic: "bar", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0}, {Partition: 1}, {Partition: 2}}},
+		},
+	}
+	assignments, err := BalancedAssignment(meta, []string{"foo", "bar"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignments) != 6 {
+		t.Fatal(len(assignments))
+	}
+	rack := map[int32]string{1: "a", 2: "a", 3: "b", 4: "b", 5: "c", 6: "c"}
+	leaders := make(map[int32]int)
+	replicas := make(map[int32]int)
+	for p, a := range assignments {
+		if len(a) != 3 {
+			t.Fatal(p, a)
+		}
+		racks := make(map[string]bool)
+		for _, id := range a {
+			racks[rack[id]] = true
+			replicas[id]++
+		}
+		if len(racks) != 3 {
+			t.Fatal(p, a)
+		}
+		leaders[a[0]]++
+	}
+	for id := int32(1); id <= 6; id++ {
+		if leaders[id] != 1 || replicas[id] != 3 {
+			t.Fatal(id, leaders, replicas)
+		}
+	}
+	if _, err := BalancedAssignment(meta, []string{"foo"}, 7); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := BalancedAssignment(meta, []string{"baz"}, 1); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestUnitBalancedAssignmentNoRacks(t *testing.T) {
+	meta := &Metadata.Response{
+		Brokers: []Metadata.Broker{{NodeId: 3}, {NodeId: 1}, {NodeId: 2}},
+		TopicMetadata: []Metadata.TopicMetadata{
+			{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 1}, {Partition: 0}, {Partition: 2}}},
+		},
+	}
+	assignments, _ := BalancedAssignment(meta, []string{"foo"}, 2)
+	want := map[client.TopicPartition][]int32{
+		{Topic: "foo", Partition: 0}: {1, 2},
+		{Topic: "foo", Partition: 1}: {2, 3},
+		{Topic: "foo", Partition: 2}: {3, 1},
+	}
+	if !reflect.DeepEqual(assignments, want) {
+		t.Fatal(assignments)
+	}
+}
@@ -0,0 +1,187 @@
+package admin
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/AlterUserScramCredentials"
+	"github.com/mkocikowski/libkafka/api/DescribeUserScramCredentials"
+	"github.com/mkocikowski/libkafka/scram"
+)
+
+func scramMechanismType(m *scram.Mechanism) (int8, error) {
+	switch m {
+	case scram.SHA256:
+		return DescribeUserScramCredentials.ScramSha256, nil
+	case scram.SHA512:
+		return DescribeUserScramCredentials.ScramSha512, nil
+	}
+	return 0, fmt.Errorf("unsupported SCRAM mechanism %v", m)
+}
+
+func scramMechanism(t int8) *scram.Mechanism {
+	switch t {
+	case DescribeUserScramCredentials.ScramSha256:
+		return scram.SHA256
+	case DescribeUserScramCredentials.ScramSha512:
+		return scram.SHA512
+	}
+	return nil
+}
+
+// ScramCredentialInfo describes a SCRAM credential of a user (the salted
+// password is not returned by the broker).
+type ScramCredentialInfo struct {
+	Mechanism  *scram.Mechanism
+	Iterations int32
+}
+
+// UserScramCredentials of a user.
+type UserScramCredentials struct {
+	User         string
+	Credentials  []ScramCredentialInfo
+	ErrorCode    int16
+	ErrorMessage string
+}
+
+// Err is ERR_RESOURCE_NOT_FOUND for users without SCRAM credentials.
+func (u *UserScramCredentials) Err() error {
+	if u.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: u.ErrorCode, Message: u.ErrorMessage}
+}
+
+// DescribeUserScramCredentials returns SCRAM credentials of the users, or of
+// all users with credentials for nil users. For empty (not nil) users no
+// call is made and no results are returned. Results are sorted by user, and
+// credentials by mechanism name. An error code for the whole request is
+// returned as *libkafka.Error. Requires Kafka 2.7+.
+func (c *Client) DescribeUserScramCredentials(users []string) ([]*UserScramCredentials, error) {
+	if users != nil && len(users) == 0 {
+		return []*UserScramCredentials{}, nil
+	}
+	resp := &DescribeUserScramCredentials.Response{}
+	if err := c.Call(DescribeUserScramCredentials.NewRequest(users), resp, nil); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+	}
+	results := make([]*UserScramCredentials, len(resp.Results))
+	for i, r := range resp.Results {
+		results[i] = &UserScramCredentials{
+			User:         r.User,
+			Credentials:  []ScramCredentialInfo{},
+			ErrorCode:    r.ErrorCode,
+			ErrorMessage: r.ErrorMessage,
+		}
+		for _, info := range r.CredentialInfos {
+			m := scramMechanism(info.Mechanism)
+			if m == nil {
+				continue // mechanism unknown to this client
+			}
+			results[i].Credentials = append(results[i].Credentials, ScramCredentialInfo{Mechanism: m, Iterations: info.Iterations})
+		}
+		creds := results[i].Credentials
+		sort.Slice(creds, func(a, b int) bool { return creds[a].Mechanism.Name < creds[b].Mechanism.Name })
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].User < results[j].User })
+	return results, nil
+}
+
+// ScramUpsertion creates or replaces the SCRAM credential of the user for the
+// mechanism. The salted password is computed here, and the password is not
+// sent to the broker.
+type ScramUpsertion struct {
+	User      string
+	Mechanism *scram.Mechanism
+	Password  string
+	// Iterations must be between scram.MinIterations and
+	// scram.MaxIterations (0 for scram.MinIterations).
+	Iterations int
+	// Salt is random if nil.
+	Salt []byte
+}
+
+// ScramDeletion deletes the SCRAM credential of the user for the mechanism.
+type ScramDeletion struct {
+	User      string
+	Mechanism *scram.Mechanism
+}
+
+// UserScramResult is the result of altering the SCRAM credentials of a user.
+type UserScramResult struct {
+	User         string
+	ErrorCode    int16
+	ErrorMessage string
+}
+
+func (r *UserScramResult) Err() error {
+	if r.ErrorCode == libkafka.ERR_NONE {
+		return nil
+	}
+	return &libkafka.Error{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+func newUpsertion(u ScramUpsertion) (AlterUserScramCredentials.Upsertion, error) {
+	mechanism, err := scramMechanismType(u.Mechanism)
+	if err != nil {
+		return AlterUserScramCredentials.Upsertion{}, err
+	}
+	iterations := u.Iterations
+	if iterations == 0 {
+		iterations = scram.MinIterations
+	}
+	if iterations < scram.MinIterations || iterations > scram.MaxIterations {
+		return AlterUserScramCredentials.Upsertion{}, fmt.Errorf("invalid iterations %d for user %q (must be %d-%d)", iterations, u.User, scram.MinIterations, scram.MaxIterations)
+	}
+	salt := u.Salt
+	if salt == nil {
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return AlterUserScramCredentials.Upsertion{}, fmt.Errorf("error generating salt: %w", err)
+		}
+	}
+	return AlterUserScramCredentials.Upsertion{
+		Name:           u.User,
+		Mechanism:      mechanism,
+		Iterations:     int32(iterations),
+		Salt:           salt,
+		SaltedPassword: u.Mechanism.SaltedPassword(u.Password, salt, iterations),
+	}, nil
+}
+
+// AlterUserScramCredentials upserts and deletes SCRAM credentials. The same
+// user and mechanism can not be both upserted and deleted. Results are per
+// user (if any alteration of the user fails, none of them are made), sorted
+// by user. Requires Kafka 2.7+.
+func (c *Client) AlterUserScramCredentials(upsertions []ScramUpsertion, deletions []ScramDeletion) ([]*UserScramResult, error) {
+	u := make([]AlterUserScramCredentials.Upsertion, len(upsertions))
+	for i, upsertion := range upsertions {
+		var err error
+		if u[i], err = newUpsertion(upsertion); err != nil {
+			return nil, err
+		}
+	}
+	d := make([]AlterUserScramCredentials.Deletion, len(deletions))
+	for i, deletion := range deletions {
+		mechanism, err := scramMechanismType(deletion.Mechanism)
+		if err != nil {
+			return nil, err
+		}
+		d[i] = AlterUserScramCredentials.Deletion{Name: deletion.User, Mechanism: mechanism}
+	}
+	resp := &AlterUserScramCredentials.Response{}
+	if err := c.Call(AlterUserScramCredentials.NewRequest(d, u), resp, nil); err != nil {
+		return nil, err
+	}
+	results := make([]*UserScramResult, len(resp.Results))
+	for i, r := range resp.Results {
+		results[i] = &UserScramResult{User: r.User, ErrorCode: r.ErrorCode, ErrorMessage: r.ErrorMessage}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].User < results[j].User })
+	return results, nil
+}
@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/AlterUserScramCredentials"
+	"github.com/mkocikowski/libkafka/api/DescribeUserScramCredentials"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+	"github.com/mkocikowski/libkafka/scram"
+)
+
+// scramCredentialsHandlers for fakeCluster keep credentials in memory, by user
+// and mechanism.
+func scramCredentialsHandlers(t *testing.T) apiHandlers {
+	credentials := make(map[string]map[int8]AlterUserScramCredentials.Upsertion)
+	return apiHandlers{
+		api.AlterUserScramCredentials: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &AlterUserScramCredentials.Request{}
+			if err := req.Unmarshal(r); err != nil {
+				t.Error(err)
+			}
+			results := make(map[string]*AlterUserScramCredentials.Result)
+			for _, d := range r.Deletions {
+				results[d.Name] = &AlterUserScramCredentials.Result{User: d.Name}
+				if _, ok := credentials[d.Name][d.Mechanism]; !ok {
+					results[d.Name].ErrorCode = libkafka.ERR_RESOURCE_NOT_FOUND
+					continue
+				}
+				delete(credentials[d.Name], d.Mechanism)
+			}
+			for _, u := range r.Upsertions {
+				results[u.Name] = &AlterUserScramCredentials.Result{User: u.Name}
+				if credentials[u.Name] == nil {
+					credentials[u.Name] = make(map[int8]AlterUserScramCredentials.Upsertion)
+				}
+				credentials[u.Name][u.Mechanism] = u
+			}
+			resp := &AlterUserScramCredentials.Response{}
+			for _, result := range results {
+				resp.Results = append(resp.Results, *result)
+			}
+			return resp
+		},
+		api.DescribeUserScramCredentials: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &DescribeUserScramCredentials.Request{}
+			req.Unmarshal(r)
+			users := r.Users
+			if users == nil {
+				for name := range credentials {
+					users = append(users, DescribeUserScramCredentials.User{Name: name})
+				}
+			}
+			resp := &DescribeUserScramCredentials.Response{}
+			for _, user := range users {
+				result := DescribeUserScramCredentials.Result{User: user.Name}
+				for mechanism, u := range credentials[user.Name] {
+					result.CredentialInfos = append(result.CredentialInfos, DescribeUserScramCredentials.CredentialInfo{Mechanism: mechanism, Iterations: u.Iterations})
+				}
+				if len(result.CredentialInfos) == 0 {
+					if r.Users == nil {
+						continue
+					}
+					result.ErrorCode = libkafka.ERR_RESOURCE_NOT_FOUND
+					result.ErrorMessage = "attempt to describe a user credential that does not exist"
+				}
+				resp.Results = append(resp.Results, result)
+			}
+			return resp
+		},
+	}
+}
+
+func TestUnitUserScramCredentials(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, scramCredentialsHandlers(t))
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	if _, err := c.AlterUserScramCredentials([]ScramUpsertion{{User: "alice", Mechanism: scram.SHA256, Password: "secret", Iterations: 1000}}, nil); err == nil {
+		t.Fatal("expected invalid iterations error")
+	}
+	results, err := c.AlterUserScramCredentials([]ScramUpsertion{
+		{User: "bob", Mechanism: scram.SHA512, Password: "hunter2", Iterations: 8192},
+		{User: "alice", Mechanism: scram.SHA256, Password: "secret", Salt: []byte("salt")},
+		{User: "alice", Mechanism: scram.SHA512, Password: "secret"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].User != "alice" || results[0].Err() != nil || results[1].Err() != nil {
+		t.Fatalf("%+v", results)
+	}
+	r := &AlterUserScramCredentials.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(r)
+	u := r.Upsertions[1]
+	if u.Mechanism != DescribeUserScramCredentials.ScramSha256 || u.Iterations != scram.MinIterations || !bytes.Equal(u.SaltedPassword, scram.SHA256.SaltedPassword("secret", []byte("salt"), 4096)) {
+		t.Fatalf("%+v", u)
+	}
+	if u := r.Upsertions[2]; len(u.Salt) != 32 || len(u.SaltedPassword) != 64 || bytes.Equal(u.Salt, r.Upsertions[0].Salt) {
+		t.Fatalf("%+v", u)
+	}
+	users, err := c.DescribeUserScramCredentials(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].User != "alice" || len(users[0].Credentials) != 2 || users[1].Credentials[0].Iterations != 8192 {
+		t.Fatalf("%+v", users)
+	}
+	if creds := users[0].Credentials; creds[0].Mechanism != scram.SHA256 || creds[1].Mechanism != scram.SHA512 {
+		t.Fatalf("%+v", creds)
+	}
+	results, err = c.AlterUserScramCredentials(nil, []ScramDeletion{{User: "alice", Mechanism: scram.SHA512}, {User: "carol", Mechanism: scram.SHA256}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *libkafka.Error
+	if len(results) != 2 || results[0].Err() != nil || !errors.As(results[1].Err(), &e) || e.Code != libkafka.ERR_RESOURCE_NOT_FOUND {
+		t.Fatalf("%+v", results)
+	}
+	users, _ = c.DescribeUserScramCredentials([]string{"alice", "carol"})
+	if len(users) != 2 || len(users[0].Credentials) != 1 || users[0].Credentials[0].Mechanism != scram.SHA256 || users[1].Err() == nil {
+		t.Fatalf("%+v", users)
+	}
+	n := len(b.Requests())
+	if users, err = c.DescribeUserScramCredentials([]string{}); err != nil || users == nil || len(users) != 0 {
+		t.Fatalf("%+v %v", users, err)
+	}
+	if len(b.Requests()) != n {
+		t.Fatal("request sent for no users")
+	}
+	for _, req := range b.Requests() {
+		if req.ApiKey == api.DescribeUserScramCredentials && req.ApiVersion != 0 {
+			t.Fatal(req.ApiVersion)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/CreateDelegationToken"
+	"github.com/mkocikowski/libkafka/api/DescribeDelegationToken"
+	"github.com/mkocikowski/libkafka/api/ExpireDelegationToken"
+	"github.com/mkocikowski/libkafka/api/RenewDelegationToken"
+	"github.com/mkocikowski/libkafka/scram"
+)
+
+// DelegationToken is a shared secret (HMAC) with which clients authenticate
+// as the token owner, without the owner credentials (KIP-48). Tokens are
+// authenticated with SCRAM, see the Client SASL field and Credentials.
+type DelegationToken struct {
+	TokenId string
+	Hmac    []byte
+	// Owner and Renewers are principals ("User:name").
+	Owner    string
+	Renewers []string
+	Issued   time.Time
+	// Expires unless renewed before. Tokens can be renewed until
+	// MaxLifetime.
+	Expires     time.Time
+	MaxLifetime time.Time
+}
+
+// Credentials for authenticating with the token. Mechanism must be one of the
+// SCRAM mechanisms enabled on the broker.
+func (t *DelegationToken) Credentials(mechanism *scram.Mechanism) *scram.Credentials {
+	return &scram.Credentials{
+		Mechanism: mechanism,
+		User:      t.TokenId,
+		Password:  base64.StdEncoding.EncodeToString(t.Hmac),
+		TokenAuth: true,
+	}
+}
+
+func splitPrincipal(principal string) (string, string, error) {
+	i := strings.Index(principal, ":")
+	if i < 1 {
+		return "", "", fmt.Errorf("invalid principal %q (want Type:name)", principal)
+	}
+	return principal[:i], principal[i+1:], nil
+}
+
+func millis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+// durationMs returns d in milliseconds, or -1 for d <= 0.
+func durationMs(d time.Duration) int64 {
+	if d <= 0 {
+		return -1
+	}
+	return d.Milliseconds()
+}
+
+// CreateDelegationToken for the principal of the connection (which can not be
+// authenticated with a delegation token). Renewers are principals
+// ("User:name") which, in addition to the owner, can renew and expire the
+// token. maxLifetime <= 0 uses the broker default. Errors are returned as
+// *libkafka.Error. Requires Kafka 1.1+ with delegation tokens enabled.
+func (c *Client) CreateDelegationToken(renewers []string, maxLifetime time.Duration) (*DelegationToken, error) {
+	r := make([]CreateDelegationToken.Renewer, len(renewers))
+	for i, p := range renewers {
+		typ, name, err := splitPrincipal(p)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = CreateDelegationToken.Renewer{PrincipalType: typ, PrincipalName: name}
+	}
+	resp := &CreateDelegationToken.Response{}
+	if err := c.Call(CreateDelegationToken.NewRequest(r, durationMs(maxLifetime)), resp, nil); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode}
+	}
+	return &DelegationToken{
+		TokenId:     resp.TokenId,
+		Hmac:        resp.Hmac,
+		Owner:       resp.PrincipalType + ":" + resp.PrincipalName,
+		Renewers:    renewers,
+		Issued:      millis(resp.IssueTimestampMs),
+		Expires:     millis(resp.ExpiryTimestampMs),
+		MaxLifetime: millis(resp.MaxTimestampMs),
+	}, nil
+}
+
+// RenewDelegationToken extends the token expiry time by period from now (but
+// not past the token max lifetime) and returns the new expiry time. period <=
+// 0 uses the broker default. Errors are returned as *libkafka.Error.
+func (c *Client) RenewDelegationToken(hmac []byte, period time.Duration) (time.Time, error) {
+	resp := &RenewDelegationToken.Response{}
+	if err := c.Call(RenewDelegationToken.NewRequest(hmac, durationMs(period)), resp, nil); err != nil {
+		return time.Time{}, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return time.Time{}, &libkafka.Error{Code: resp.ErrorCode}
+	}
+	return millis(resp.ExpiryTimestampMs), nil
+}
+
+// ExpireDelegationToken sets the token expiry time to period from now, and
+// returns it. period <= 0 expires the token immediately (after which it can
+// not be used or renewed). Errors are returned as *libkafka.Error.
+func (c *Client) ExpireDelegationToken(hmac []byte, period time.Duration) (time.Time, error) {
+	resp := &ExpireDelegationToken.Response{}
+	if err := c.Call(ExpireDelegationToken.NewRequest(hmac, durationMs(period)), resp, nil); err != nil {
+		return time.Time{}, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return time.Time{}, &libkafka.Error{Code: resp.ErrorCode}
+	}
+	return millis(resp.ExpiryTimestampMs), nil
+}
+
+// DescribeDelegationTokens returns tokens owned by the owners (principals,
+// "User:name"), or all tokens for nil owners. Only tokens which the principal
+// of the connection owns, can renew, or is allowed to describe are returned.
+// Errors are returned as *libkafka.Error.
+func (c *Client) DescribeDelegationTokens(owners []string) ([]*DelegationToken, error) {
+	var o []DescribeDelegationToken.Owner
+	for _, p := range owners {
+		typ, name, err := splitPrincipal(p)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, DescribeDelegationToken.Owner{PrincipalType: typ, PrincipalName: name})
+	}
+	resp := &DescribeDelegationToken.Response{}
+	if err := c.Call(DescribeDelegationToken.NewRequest(o), resp, nil); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode}
+	}
+	tokens := make([]*DelegationToken, len(resp.Tokens))
+	for i, t := range resp.Tokens {
+		tokens[i] = &DelegationToken{
+			TokenId:     t.TokenId,
+			Hmac:        t.Hmac,
+			Owner:       t.PrincipalType + ":" + t.PrincipalName,
+			Renewers:    []string{},
+			Issued:      millis(t.IssueTimestampMs),
+			Expires:     millis(t.ExpiryTimestampMs),
+			MaxLifetime: millis(t.MaxTimestampMs),
+		}
+		for _, r := range t.Renewers {
+			tokens[i].Renewers = append(tokens[i].Renewers, r.PrincipalType+":"+r.PrincipalName)
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/CreateDelegationToken"
+	"github.com/mkocikowski/libkafka/api/DescribeDelegationToken"
+	"github.com/mkocikowski/libkafka/api/ExpireDelegationToken"
+	"github.com/mkocikowski/libkafka/api/RenewDelegationToken"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+	"github.com/mkocikowski/libkafka/scram"
+)
+
+// tokensHandlers for fakeCluster issue tokens owned by User:alice, at time 1000
+// with max lifetime 10000 and default expiry period 500.
+func tokensHandlers() apiHandlers {
+	tokens := make(map[string]*DescribeDelegationToken.Token)
+	return apiHandlers{
+		api.CreateDelegationToken: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &CreateDelegationToken.Request{}
+			req.Unmarshal(r)
+			token := &DescribeDelegationToken.Token{
+				PrincipalType:     "User",
+				PrincipalName:     "alice",
+				IssueTimestampMs:  1000,
+				ExpiryTimestampMs: 1500,
+				MaxTimestampMs:    11000,
+				TokenId:           "token1",
+				Hmac:              []byte("hmac1"),
+			}
+			if r.MaxLifetimeMs > 0 {
+				token.MaxTimestampMs = 1000 + r.MaxLifetimeMs
+			}
+			for _, renewer := range r.Renewers {
+				token.Renewers = append(token.Renewers, DescribeDelegationToken.Owner(renewer))
+			}
+			tokens[string(token.Hmac)] = token
+			return &CreateDelegationToken.Response{
+				PrincipalType:     token.PrincipalType,
+				PrincipalName:     token.PrincipalName,
+				IssueTimestampMs:  token.IssueTimestampMs,
+				ExpiryTimestampMs: token.ExpiryTimestampMs,
+				MaxTimestampMs:    token.MaxTimestampMs,
+				TokenId:           token.TokenId,
+				Hmac:              token.Hmac,
+			}
+		},
+		api.RenewDelegationToken: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &RenewDelegationToken.Request{}
+			req.Unmarshal(r)
+			token := tokens[string(r.Hmac)]
+			if token == nil {
+				return &RenewDelegationToken.Response{ErrorCode: libkafka.ERR_DELEGATION_TOKEN_NOT_FOUND}
+			}
+			token.ExpiryTimestampMs = 2000 + r.RenewPeriodMs
+			if token.ExpiryTimestampMs > token.MaxTimestampMs {
+				token.ExpiryTimestampMs = token.MaxTimestampMs
+			}
+			return &RenewDelegationToken.Response{ExpiryTimestampMs: token.ExpiryTimestampMs}
+		},
+		api.ExpireDelegationToken: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &ExpireDelegationToken.Request{}
+			req.Unmarshal(r)
+			token := tokens[string(r.Hmac)]
+			if token == nil {
+				return &ExpireDelegationToken.Response{ErrorCode: libkafka.ERR_DELEGATION_TOKEN_NOT_FOUND}
+			}
+			if r.ExpiryTimePeriodMs < 0 {
+				delete(tokens, string(r.Hmac))
+				return &ExpireDelegationToken.Response{ExpiryTimestampMs: 2000}
+			}
+			token.ExpiryTimestampMs = 2000 + r.ExpiryTimePeriodMs
+			return &ExpireDelegationToken.Response{ExpiryTimestampMs: token.ExpiryTimestampMs}
+		},
+		api.DescribeDelegationToken: func(nodeId int32, req *fakebroker.Request) interface{} {
+			r := &DescribeDelegationToken.Request{}
+			req.Unmarshal(r)
+			resp := &DescribeDelegationToken.Response{}
+			for _, token := range tokens {
+				if r.Owners == nil || r.Owners[0].PrincipalName == token.PrincipalName {
+					resp.Tokens = append(resp.Tokens, *token)
+				}
+			}
+			return resp
+		},
+	}
+}
+
+func TestUnitDelegationTokens(t *testing.T) {
+	controller := int32(1)
+	b, b2 := fakeCluster(t, &controller, tokensHandlers())
+	defer b.Close()
+	defer b2.Close()
+	c := &Client{Bootstrap: b.Addr()}
+	defer c.Close()
+	if _, err := c.CreateDelegationToken([]string{"bob"}, 0); err == nil {
+		t.Fatal("expected invalid principal error")
+	}
+	token, err := c.CreateDelegationToken([]string{UserPrincipal("bob")}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.TokenId != "token1" || token.Owner != "User:alice" || !token.Issued.Equal(time.Unix(1, 0)) || !token.MaxLifetime.Equal(time.Unix(3601, 0)) {
+		t.Fatalf("%+v", token)
+	}
+	creds := token.Credentials(scram.SHA512)
+	if creds.User != "token1" || creds.Password != "aG1hYzE=" || !creds.TokenAuth {
+		t.Fatalf("%+v", creds)
+	}
+	expires, err := c.RenewDelegationToken(token.Hmac, time.Second)
+	if err != nil || !expires.Equal(time.Unix(3, 0)) {
+		t.Fatal(expires, err)
+	}
+	r := &RenewDelegationToken.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(r)
+	if r.RenewPeriodMs != 1000 {
+		t.Fatalf("%+v", r)
+	}
+	tokens, err := c.DescribeDelegationTokens([]string{UserPrincipal("alice")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || !reflect.DeepEqual(tokens[0].Renewers, []string{"User:bob"}) || !tokens[0].Expires.Equal(expires) {
+		t.Fatalf("%+v", tokens)
+	}
+	if tokens, _ = c.DescribeDelegationTokens([]string{UserPrincipal("carol")}); len(tokens) != 0 {
+		t.Fatalf("%+v", tokens)
+	}
+	if _, err := c.ExpireDelegationToken(token.Hmac, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.RenewDelegationToken(token.Hmac, 0)
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_DELEGATION_TOKEN_NOT_FOUND {
+		t.Fatal(err)
+	}
+	r = &RenewDelegationToken.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(r)
+	if r.RenewPeriodMs != -1 {
+		t.Fatalf("%+v", r)
+	}
+	// nil owners sent as null for all tokens
+	c.DescribeDelegationTokens(nil)
+	d := &DescribeDelegationToken.Request{}
+	b.Requests()[len(b.Requests())-1].Unmarshal(d)
+	if d.Owners != nil {
+		t.Fatalf("%+v", d)
+	}
+}
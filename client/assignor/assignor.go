@@ -0,0 +1,260 @@
+// Package assignor implements partition assignment strategies for groups with
+// the "consumer" protocol type. The strategies (range, round robin, sticky,
+// and cooperative sticky) make the same assignments as their Java client
+// counterparts, so libkafka consumers can be in the same group as Java (and
+// librdkafka) consumers, and either can be elected group leader. Where the
+// Java implementation depends on hash map iteration order (this happens only
+// when breaking ties between equivalent assignments) members are processed
+// ordered by member id, and partitions ordered by topic and partition.
+// Assignments are encoded with topics sorted by name and partitions in
+// ascending order.
+package assignor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/ConsumerProtocol"
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// Assignor is the partition assignment strategy of a consumer group. It is
+// modeled on the Java client ConsumerPartitionAssignor.
+type Assignor interface {
+	// Name of the strategy. Must be the same as the name used by the
+	// Java client for the same strategy.
+	Name() string
+	// Cooperative is true if the strategy supports cooperative (KIP-429)
+	// rebalancing.
+	Cooperative() bool
+	// UserData for the member subscription. Assigned are the partitions
+	// assigned to the member in the generation generationId (-1 if the
+	// member has not been in the group).
+	UserData(assigned []client.TopicPartition, generationId int32) []byte
+	// Assign is called on the group leader. Members come from the
+	// JoinGroup response: their metadata are encoded subscriptions.
+	// Metadata must have all topics that members are subscribed to;
+	// topics with errors in metadata, or missing from it, are not
+	// assigned. Returns assignment for every member.
+	Assign(members []JoinGroup.Member, meta *Metadata.Response) ([]SyncGroup.Assignment, error)
+}
+
+// member of the group as seen by the group leader
+type member struct {
+	id           string
+	instanceId   string // for static members (KIP-345), or ""
+	subscription *ConsumerProtocol.Subscription
+	topics       map[string]bool
+}
+
+// members sort by group instance id (members with instance id first), and
+// then by member id, same as MemberInfo in the java client
+func less(a, b *member) bool {
+	switch {
+	case a.instanceId != "" && b.instanceId != "":
+		return a.instanceId < b.instanceId
+	case a.instanceId != "":
+		return true
+	case b.instanceId != "":
+		return false
+	}
+	return a.id < b.id
+}
+
+func decodeMembers(members []JoinGroup.Member) ([]*member, error) {
+	out := make([]*member, len(members))
+	for i, m := range members {
+		s, err := ConsumerProtocol.UnmarshalSubscription(m.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding member %s subscription: %w", m.MemberId, err)
+		}
+		out[i] = &member{
+			id:           m.MemberId,
+			instanceId:   m.GroupInstanceId,
+			subscription: s,
+			topics:       make(map[string]bool),
+		}
+		for _, t := range s.Topics {
+			out[i].topics[t] = true
+		}
+	}
+	return out, nil
+}
+
+// SubscribedTopics returns the sorted list of all topics that members are
+// subscribed to. This is what group leader needs to get metadata for.
+func SubscribedTopics(members []JoinGroup.Member) ([]string, error) {
+	decoded, err := decodeMembers(members)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	topics := []string{}
+	for _, m := range decoded {
+		for _, t := range m.subscription.Topics {
+			if !seen[t] {
+				seen[t] = true
+				topics = append(topics, t)
+			}
+		}
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// partition counts of subscribed topics that are in metadata (without errors)
+func partitionsPerTopic(members []*member, meta *Metadata.Response) map[string]int {
+	subscribed := make(map[string]bool)
+	for _, m := range members {
+		for t := range m.topics {
+			subscribed[t] = true
+		}
+	}
+	counts := make(map[string]int)
+	for _, t := range meta.TopicMetadata {
+		if subscribed[t.Topic] && t.ErrorCode == libkafka.ERR_NONE {
+			counts[t.Topic] = len(t.PartitionMetadata)
+		}
+	}
+	return counts
+}
+
+func sortedTopics(partitionsPerTopic map[string]int) []string {
+	topics := make([]string, 0, len(partitionsPerTopic))
+	for t := range partitionsPerTopic {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// EncodePartitions groups partitions by topic (topics sorted by name,
+// partitions in ascending order).
+func EncodePartitions(partitions []client.TopicPartition) []ConsumerProtocol.TopicPartitions {
+	sorted := append([]client.TopicPartition(nil), partitions...)
+	client.SortTopicPartitions(sorted)
+	out := []ConsumerProtocol.TopicPartitions{}
+	for _, tp := range sorted {
+		if n := len(out); n == 0 || out[n-1].Topic != tp.Topic {
+			out = append(out, ConsumerProtocol.TopicPartitions{Topic: tp.Topic, Partitions: []int32{}})
+		}
+		t := &(out[len(out)-1])
+		t.Partitions = append(t.Partitions, tp.Partition)
+	}
+	return out
+}
+
+// DecodePartitions is the inverse of EncodePartitions. Order is preserved.
+func DecodePartitions(topics []ConsumerProtocol.TopicPartitions) []client.TopicPartition {
+	var out []client.TopicPartition
+	for _, t := range topics {
+		for _, p := range t.Partitions {
+			out = append(out, client.TopicPartition{Topic: t.Topic, Partition: p})
+		}
+	}
+	return out
+}
+
+// encode assignments for all members. assignment version is the same as the
+// member subscription version (but no newer than supported here).
+func encodeAssignments(members []*member, assignments map[string][]client.TopicPartition) ([]SyncGroup.Assignment, error) {
+	out := make([]SyncGroup.Assignment, len(members))
+	for i, m := range members {
+		a := ConsumerProtocol.NewAssignment(EncodePartitions(assignments[m.id]))
+		if v := m.subscription.Version; v < a.Version {
+			a.Version = v
+		}
+		b, err := a.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = SyncGroup.Assignment{MemberId: m.id, Assignment: b}
+	}
+	return out, nil
+}
+
+// assignFunc implements an assignment strategy. it returns assignments for
+// members, keyed by member id.
+type assignFunc func(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error)
+
+func assign(f assignFunc, members []JoinGroup.Member, meta *Metadata.Response) ([]SyncGroup.Assignment, error) {
+	decoded, err := decodeMembers(members)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := f(partitionsPerTopic(decoded, meta), decoded)
+	if err != nil {
+		return nil, err
+	}
+	return encodeAssignments(decoded, assignments)
+}
+
+// GroupProtocol implements the group.Protocol interface for the "consumer"
+// protocol type (use ConsumerProtocol.ProtocolType as the group member
+// ProtocolType) with given Assignor. The Bootstrap and TLS values are used
+// by the group leader to get topic metadata.
+type GroupProtocol struct {
+	sync.Mutex
+	Assignor  Assignor
+	Topics    []string // subscribed topics
+	RackId    string
+	Bootstrap string // srv or host:port
+	TLS       *tls.Config
+	SASL      client.SASL
+	// partitions assigned to the member in the last generation (the
+	// Assignor may need them for its subscription user data)
+	assigned []client.TopicPartition
+}
+
+func (p *GroupProtocol) Name() string {
+	return p.Assignor.Name()
+}
+
+func (p *GroupProtocol) Cooperative() bool {
+	return p.Assignor.Cooperative()
+}
+
+// Metadata returns the encoded subscription.
+func (p *GroupProtocol) Metadata(owned []client.TopicPartition, generationId int32) ([]byte, error) {
+	p.Lock()
+	assigned := p.assigned
+	p.Unlock()
+	s := ConsumerProtocol.NewSubscription(p.Topics)
+	s.UserData = p.Assignor.UserData(assigned, generationId)
+	s.OwnedPartitions = EncodePartitions(owned)
+	s.GenerationId = generationId
+	s.RackId = p.RackId
+	return s.Marshal()
+}
+
+// Assign gets metadata for all subscribed topics and calls the Assignor.
+func (p *GroupProtocol) Assign(members []JoinGroup.Member) ([]SyncGroup.Assignment, error) {
+	topics, err := SubscribedTopics(members)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := client.CallMetadataSASL(p.Bootstrap, p.TLS, p.SASL, topics)
+	if err != nil {
+		return nil, fmt.Errorf("error getting metadata for subscribed topics: %w", err)
+	}
+	return p.Assignor.Assign(members, meta)
+}
+
+// Partitions decodes the assignment.
+func (p *GroupProtocol) Partitions(assignment []byte) ([]client.TopicPartition, error) {
+	a, err := ConsumerProtocol.UnmarshalAssignment(assignment)
+	if err != nil {
+		return nil, err
+	}
+	partitions := DecodePartitions(a.AssignedPartitions)
+	p.Lock()
+	p.assigned = partitions
+	p.Unlock()
+	return partitions, nil
+}
@@ -0,0 +1,186 @@
+package assignor
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkocikowski/libkafka/api/ConsumerProtocol"
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/client/group"
+)
+
+var _ group.Protocol = &GroupProtocol{}
+
+// parse "topic:partition" strings
+func tps(s ...string) []client.TopicPartition {
+	var partitions []client.TopicPartition
+	for _, x := range s {
+		i := strings.LastIndex(x, ":")
+		var p int32
+		fmt.Sscan(x[i+1:], &p)
+		partitions = append(partitions, client.TopicPartition{Topic: x[:i], Partition: p})
+	}
+	return partitions
+}
+
+func testMetadata(partitionsPerTopic map[string]int) *Metadata.Response {
+	meta := &Metadata.Response{}
+	for t, n := range partitionsPerTopic {
+		m := Metadata.TopicMetadata{Topic: t}
+		for p := 0; p < n; p++ {
+			m.PartitionMetadata = append(m.PartitionMetadata, Metadata.PartitionMetadata{Partition: int32(p)})
+		}
+		meta.TopicMetadata = append(meta.TopicMetadata, m)
+	}
+	return meta
+}
+
+// group member state, as seen by the test
+type testMember struct {
+	id         string
+	topics     []string
+	assigned   []client.TopicPartition
+	generation int32
+}
+
+func (m *testMember) joinGroupMember(t *testing.T, a Assignor) JoinGroup.Member {
+	t.Helper()
+	p := &GroupProtocol{Assignor: a, Topics: m.topics, assigned: m.assigned}
+	var owned []client.TopicPartition
+	if a.Cooperative() {
+		owned = m.assigned
+	}
+	b, err := p.Metadata(owned, m.generation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return JoinGroup.Member{MemberId: m.id, Metadata: b}
+}
+
+// rebalance runs the assignor for members and updates their assignments and
+// generations. returns assignments keyed by member id.
+func rebalance(t *testing.T, a Assignor, members []*testMember, partitionsPerTopic map[string]int, generation int32) map[string][]client.TopicPartition {
+	t.Helper()
+	var joined []JoinGroup.Member
+	for _, m := range members {
+		joined = append(joined, m.joinGroupMember(t, a))
+	}
+	assignments, err := a.Assign(joined, testMetadata(partitionsPerTopic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignments) != len(members) {
+		t.Fatal(len(assignments))
+	}
+	out := make(map[string][]client.TopicPartition)
+	for i, m := range members {
+		if assignments[i].MemberId != m.id {
+			t.Fatal(assignments[i].MemberId, m.id)
+		}
+		p := &GroupProtocol{Assignor: a}
+		partitions, err := p.Partitions(assignments[i].Assignment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.assigned = partitions
+		m.generation = generation
+		out[m.id] = partitions
+	}
+	return out
+}
+
+func assertAssignments(t *testing.T, got map[string][]client.TopicPartition, want map[string][]string) {
+	t.Helper()
+	for id, w := range want {
+		if !reflect.DeepEqual(got[id], tps(w...)) {
+			t.Fatalf("%s: got %v, want %v", id, got[id], w)
+		}
+	}
+}
+
+func TestUnitEncodeDecodePartitions(t *testing.T) {
+	partitions := tps("foo:1", "bar:0", "foo:0")
+	encoded := EncodePartitions(partitions)
+	want := []ConsumerProtocol.TopicPartitions{
+		{Topic: "bar", Partitions: []int32{0}},
+		{Topic: "foo", Partitions: []int32{0, 1}},
+	}
+	if !reflect.DeepEqual(encoded, want) {
+		t.Fatal(encoded)
+	}
+	if decoded := DecodePartitions(encoded); !reflect.DeepEqual(decoded, tps("bar:0", "foo:0", "foo:1")) {
+		t.Fatal(decoded)
+	}
+	if encoded := EncodePartitions(nil); encoded == nil || len(encoded) != 0 {
+		t.Fatal(encoded)
+	}
+}
+
+func TestUnitSubscribedTopics(t *testing.T) {
+	a := &RangeAssignor{}
+	members := []JoinGroup.Member{
+		(&testMember{id: "a", topics: []string{"foo", "bar"}}).joinGroupMember(t, a),
+		(&testMember{id: "b", topics: []string{"baz", "foo"}}).joinGroupMember(t, a),
+	}
+	topics, err := SubscribedTopics(members)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(topics, []string{"bar", "baz", "foo"}) {
+		t.Fatal(topics)
+	}
+	if _, err := SubscribedTopics([]JoinGroup.Member{{MemberId: "c", Metadata: []byte{0}}}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestUnitGroupProtocolMetadata(t *testing.T) {
+	p := &GroupProtocol{
+		Assignor: &CooperativeStickyAssignor{},
+		Topics:   []string{"foo"},
+		RackId:   "rack-1",
+	}
+	if p.Name() != "cooperative-sticky" || !p.Cooperative() {
+		t.Fatal(p.Name(), p.Cooperative())
+	}
+	b, err := p.Metadata(tps("foo:0"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ConsumerProtocol.UnmarshalSubscription(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.GenerationId != 3 || s.RackId != "rack-1" || !reflect.DeepEqual(s.Topics, []string{"foo"}) {
+		t.Fatal(s)
+	}
+	if owned := DecodePartitions(s.OwnedPartitions); !reflect.DeepEqual(owned, tps("foo:0")) {
+		t.Fatal(owned)
+	}
+	if d := cooperativeMemberData(s); !d.hasGen || d.generation != 3 {
+		t.Fatal(d)
+	}
+}
+
+func TestUnitAssignTopicErrors(t *testing.T) {
+	a := &RoundRobinAssignor{}
+	members := []JoinGroup.Member{(&testMember{id: "a", topics: []string{"foo", "bar"}}).joinGroupMember(t, a)}
+	meta := testMetadata(map[string]int{"foo": 1, "bar": 1})
+	for i := range meta.TopicMetadata {
+		if meta.TopicMetadata[i].Topic == "bar" {
+			meta.TopicMetadata[i].ErrorCode = 3
+		}
+	}
+	assignments, err := a.Assign(members, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partitions, _ := (&GroupProtocol{}).Partitions(assignments[0].Assignment)
+	if !reflect.DeepEqual(partitions, tps("foo:0")) {
+		t.Fatal(partitions)
+	}
+}
@@ -0,0 +1,67 @@
+package assignor
+
+import (
+	"sort"
+
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// RangeAssignor assigns partitions on a per topic basis. For each topic the
+// members subscribed to it are sorted, and the partitions (in numeric order)
+// are divided in ranges as equal as possible; first members get one extra
+// partition if the partitions don't divide evenly. Same as Java
+// RangeAssignor (without rack awareness).
+type RangeAssignor struct{}
+
+func (*RangeAssignor) Name() string {
+	return "range"
+}
+
+func (*RangeAssignor) Cooperative() bool {
+	return false
+}
+
+func (*RangeAssignor) UserData([]client.TopicPartition, int32) []byte {
+	return nil
+}
+
+func (*RangeAssignor) Assign(members []JoinGroup.Member, meta *Metadata.Response) ([]SyncGroup.Assignment, error) {
+	return assign(assignRange, members, meta)
+}
+
+func assignRange(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error) {
+	assignments := make(map[string][]client.TopicPartition)
+	for _, topic := range sortedTopics(partitionsPerTopic) {
+		var subscribed []*member
+		for _, m := range members {
+			if m.topics[topic] {
+				subscribed = append(subscribed, m)
+			}
+		}
+		sort.Slice(subscribed, func(i, j int) bool { return less(subscribed[i], subscribed[j]) })
+		n := partitionsPerTopic[topic]
+		perMember := n / len(subscribed)
+		extra := n % len(subscribed)
+		for i, m := range subscribed {
+			start := perMember*i + min(i, extra)
+			length := perMember
+			if i+1 <= extra {
+				length++
+			}
+			for p := start; p < start+length; p++ {
+				assignments[m.id] = append(assignments[m.id], client.TopicPartition{Topic: topic, Partition: int32(p)})
+			}
+		}
+	}
+	return assignments, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,79 @@
+package assignor
+
+import (
+	"testing"
+)
+
+func TestUnitRangeAssignor(t *testing.T) {
+	tests := []struct {
+		members    []*testMember
+		partitions map[string]int
+		want       map[string][]string
+	}{
+		{ // two members, two topics, partitions don't divide evenly
+			members: []*testMember{
+				{id: "c1", topics: []string{"t1", "t2"}},
+				{id: "c0", topics: []string{"t1", "t2"}},
+			},
+			partitions: map[string]int{"t1": 3, "t2": 3},
+			want: map[string][]string{
+				"c0": {"t1:0", "t1:1", "t2:0", "t2:1"},
+				"c1": {"t1:2", "t2:2"},
+			},
+		},
+		{ // members subscribed to different topics
+			members: []*testMember{
+				{id: "c0", topics: []string{"t1"}},
+				{id: "c1", topics: []string{"t1", "t2"}},
+				{id: "c2", topics: []string{"t1"}},
+			},
+			partitions: map[string]int{"t1": 3, "t2": 2},
+			want: map[string][]string{
+				"c0": {"t1:0"},
+				"c1": {"t1:1", "t2:0", "t2:1"},
+				"c2": {"t1:2"},
+			},
+		},
+		{ // more members than partitions
+			members: []*testMember{
+				{id: "c0", topics: []string{"t1"}},
+				{id: "c1", topics: []string{"t1"}},
+			},
+			partitions: map[string]int{"t1": 1},
+			want: map[string][]string{
+				"c0": {"t1:0"},
+				"c1": nil,
+			},
+		},
+		{ // topic not in metadata
+			members: []*testMember{
+				{id: "c0", topics: []string{"t1", "t3"}},
+			},
+			partitions: map[string]int{"t1": 2},
+			want: map[string][]string{
+				"c0": {"t1:0", "t1:1"},
+			},
+		},
+	}
+	for i, test := range tests {
+		got := rebalance(t, &RangeAssignor{}, test.members, test.partitions, 1)
+		if len(got) != len(test.members) {
+			t.Fatal(i, got)
+		}
+		assertAssignments(t, got, test.want)
+	}
+}
+
+func TestUnitRangeAssignorStaticMembers(t *testing.T) {
+	members := []*member{
+		{id: "a", topics: map[string]bool{"t1": true}},
+		{id: "b", instanceId: "z", topics: map[string]bool{"t1": true}},
+		{id: "c", instanceId: "y", topics: map[string]bool{"t1": true}},
+	}
+	got, _ := assignRange(map[string]int{"t1": 3}, members)
+	assertAssignments(t, got, map[string][]string{
+		"c": {"t1:0"},
+		"b": {"t1:1"},
+		"a": {"t1:2"},
+	})
+}
@@ -0,0 +1,52 @@
+package assignor
+
+import (
+	"sort"
+
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// RoundRobinAssignor lists all partitions of all subscribed topics (sorted by
+// topic and partition) and assigns them one by one to sorted members in
+// round robin fashion, skipping members not subscribed to the partition's
+// topic. Same as Java RoundRobinAssignor.
+type RoundRobinAssignor struct{}
+
+func (*RoundRobinAssignor) Name() string {
+	return "roundrobin"
+}
+
+func (*RoundRobinAssignor) Cooperative() bool {
+	return false
+}
+
+func (*RoundRobinAssignor) UserData([]client.TopicPartition, int32) []byte {
+	return nil
+}
+
+func (*RoundRobinAssignor) Assign(members []JoinGroup.Member, meta *Metadata.Response) ([]SyncGroup.Assignment, error) {
+	return assign(assignRoundRobin, members, meta)
+}
+
+func assignRoundRobin(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error) {
+	assignments := make(map[string][]client.TopicPartition)
+	sorted := append([]*member(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	next := 0 // position of the round robin "iterator"
+	for _, topic := range sortedTopics(partitionsPerTopic) {
+		for p := 0; p < partitionsPerTopic[topic]; p++ {
+			// every topic in partitionsPerTopic has at least one
+			// subscribed member so this terminates
+			for !sorted[next%len(sorted)].topics[topic] {
+				next++
+			}
+			m := sorted[next%len(sorted)]
+			next++
+			assignments[m.id] = append(assignments[m.id], client.TopicPartition{Topic: topic, Partition: int32(p)})
+		}
+	}
+	return assignments, nil
+}
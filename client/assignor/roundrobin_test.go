@@ -0,0 +1,45 @@
+package assignor
+
+import (
+	"testing"
+)
+
+func TestUnitRoundRobinAssignor(t *testing.T) {
+	tests := []struct {
+		members    []*testMember
+		partitions map[string]int
+		want       map[string][]string
+	}{
+		{
+			members: []*testMember{
+				{id: "c1", topics: []string{"t1", "t2"}},
+				{id: "c0", topics: []string{"t1", "t2"}},
+			},
+			partitions: map[string]int{"t1": 3, "t2": 3},
+			want: map[string][]string{
+				"c0": {"t1:0", "t1:2", "t2:1"},
+				"c1": {"t1:1", "t2:0", "t2:2"},
+			},
+		},
+		{ // members subscribed to different topics
+			members: []*testMember{
+				{id: "c0", topics: []string{"t1"}},
+				{id: "c1", topics: []string{"t1", "t2"}},
+				{id: "c2", topics: []string{"t1", "t2", "t3"}},
+			},
+			partitions: map[string]int{"t1": 1, "t2": 2, "t3": 3},
+			want: map[string][]string{
+				"c0": {"t1:0"},
+				"c1": {"t2:0"},
+				"c2": {"t2:1", "t3:0", "t3:1", "t3:2"},
+			},
+		},
+	}
+	for i, test := range tests {
+		got := rebalance(t, &RoundRobinAssignor{}, test.members, test.partitions, 1)
+		if len(got) != len(test.members) {
+			t.Fatal(i, got)
+		}
+		assertAssignments(t, got, test.want)
+	}
+}
@@ -0,0 +1,970 @@
+package assignor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/mkocikowski/libkafka/api/ConsumerProtocol"
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/wire"
+)
+
+// StickyAssignor makes assignments that are as balanced as possible (the
+// numbers of partitions assigned to members differ by at most one, when
+// subscriptions allow it) while preserving as many existing assignments as
+// possible. Rebalancing is eager: members revoke all partitions before
+// rejoining, and send their previous assignment (and its generation) in the
+// subscription user data. Same as Java StickyAssignor.
+type StickyAssignor struct{}
+
+func (*StickyAssignor) Name() string {
+	return "sticky"
+}
+
+func (*StickyAssignor) Cooperative() bool {
+	return false
+}
+
+// sticky assignor user data. v0 has only the previous assignment.
+type stickyUserData struct {
+	PreviousAssignment []ConsumerProtocol.TopicPartitions
+	Generation         int32 `versions:"1+"`
+}
+
+// UserData encodes the previous assignment and its generation (v1 of sticky
+// assignor user data). Nil if the member has not been in the group.
+func (*StickyAssignor) UserData(assigned []client.TopicPartition, generationId int32) []byte {
+	if generationId < 0 {
+		return nil
+	}
+	d := &stickyUserData{
+		PreviousAssignment: EncodePartitions(assigned),
+		Generation:         generationId,
+	}
+	buf := new(bytes.Buffer)
+	wire.WriteVersion(buf, reflect.ValueOf(d), 1)
+	return buf.Bytes()
+}
+
+// memberData is the previous assignment of a member and its generation
+type memberData struct {
+	partitions []client.TopicPartition
+	generation int32
+	hasGen     bool // false if generation is not known
+}
+
+func stickyMemberData(s *ConsumerProtocol.Subscription) memberData {
+	if len(s.UserData) == 0 {
+		return memberData{}
+	}
+	d := &stickyUserData{}
+	if err := wire.ReadVersion(bytes.NewReader(s.UserData), reflect.ValueOf(d), 1); err == nil {
+		return memberData{DecodePartitions(d.PreviousAssignment), d.Generation, true}
+	}
+	d = &stickyUserData{}
+	if err := wire.ReadVersion(bytes.NewReader(s.UserData), reflect.ValueOf(d), 0); err == nil {
+		return memberData{partitions: DecodePartitions(d.PreviousAssignment)}
+	}
+	// ignore previous assignment that can not be parsed
+	return memberData{generation: defaultGeneration, hasGen: true}
+}
+
+func (*StickyAssignor) Assign(members []JoinGroup.Member, meta *Metadata.Response) ([]SyncGroup.Assignment, error) {
+	f := func(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error) {
+		s := &sticky{memberData: stickyMemberData}
+		return s.assign(partitionsPerTopic, members)
+	}
+	return assign(f, members, meta)
+}
+
+// CooperativeStickyAssignor makes the same assignments as the
+// StickyAssignor, but follows the cooperative rebalancing protocol (KIP-429):
+// members keep their partitions while rebalancing, and report them in the
+// subscription. A partition that moves from one member to another is first
+// removed from the assignment of its current owner; only in the following
+// rebalance (which the owner triggers after revoking it) is it assigned to its
+// new owner. Same as Java CooperativeStickyAssignor.
+type CooperativeStickyAssignor struct{}
+
+func (*CooperativeStickyAssignor) Name() string {
+	return "cooperative-sticky"
+}
+
+func (*CooperativeStickyAssignor) Cooperative() bool {
+	return true
+}
+
+// UserData encodes the generation. Subscriptions v2 and newer have the
+// generation in a field of their own, but older members read it from here.
+func (*CooperativeStickyAssignor) UserData(assigned []client.TopicPartition, generationId int32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(generationId))
+	return b
+}
+
+func cooperativeMemberData(s *ConsumerProtocol.Subscription) memberData {
+	owned := DecodePartitions(s.OwnedPartitions)
+	if s.Version >= 2 && s.GenerationId >= 0 {
+		return memberData{owned, s.GenerationId, true}
+	}
+	if s.UserData == nil {
+		return memberData{partitions: owned}
+	}
+	if len(s.UserData) < 4 {
+		return memberData{owned, defaultGeneration, true}
+	}
+	return memberData{owned, int32(binary.BigEndian.Uint32(s.UserData)), true}
+}
+
+func (*CooperativeStickyAssignor) Assign(members []JoinGroup.Member, meta *Metadata.Response) ([]SyncGroup.Assignment, error) {
+	f := func(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error) {
+		s := &sticky{memberData: cooperativeMemberData}
+		assignments, err := s.assign(partitionsPerTopic, members)
+		if err != nil {
+			return nil, err
+		}
+		transferring := s.transferring
+		if transferring == nil {
+			transferring = partitionsTransferringOwnership(s.owned, assignments)
+		}
+		// partitions changing owner must first be revoked by the
+		// current owner, so they are not assigned in this generation
+		for p, c := range transferring {
+			assignments[c] = remove(assignments[c], p)
+		}
+		return assignments, nil
+	}
+	return assign(f, members, meta)
+}
+
+// partitions that are assigned to a member that does not own them, and that
+// are owned by some other member
+func partitionsTransferringOwnership(owned, assignments map[string][]client.TopicPartition) map[client.TopicPartition]string {
+	added := make(map[client.TopicPartition]string)
+	revoked := make(map[client.TopicPartition]bool)
+	for c, assigned := range assignments {
+		ownedSet := toSet(owned[c])
+		for _, p := range assigned {
+			if !ownedSet[p] {
+				added[p] = c
+			}
+		}
+		assignedSet := toSet(assigned)
+		for _, p := range owned[c] {
+			if !assignedSet[p] {
+				revoked[p] = true
+			}
+		}
+	}
+	for p := range added {
+		if !revoked[p] {
+			delete(added, p)
+		}
+	}
+	return added
+}
+
+const defaultGeneration = -1
+
+// sticky implements the assignment algorithm shared by sticky and cooperative
+// sticky assignors (Java AbstractStickyAssignor). if all members are
+// subscribed to the same topics (the common case) the "constrained"
+// algorithm is used; otherwise the "general" one.
+type sticky struct {
+	memberData func(*ConsumerProtocol.Subscription) memberData
+	// partitions owned by members, as used in the assignment (duplicates,
+	// stale generations, and partitions of topics that no longer exist
+	// removed)
+	owned         map[string][]client.TopicPartition
+	maxGeneration int32
+	// set by the constrained algorithm only
+	transferring map[client.TopicPartition]string
+}
+
+func (s *sticky) assign(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error) {
+	sorted := append([]*member(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+	multipleOwners := make(map[client.TopicPartition]bool)
+	if s.allSubscriptionsEqual(partitionsPerTopic, sorted, multipleOwners) {
+		s.transferring = make(map[client.TopicPartition]string)
+		return s.constrainedAssign(partitionsPerTopic, sorted, multipleOwners)
+	}
+	return s.generalAssign(partitionsPerTopic, sorted)
+}
+
+// allSubscriptionsEqual returns true if all members are subscribed to the
+// same topics. it also fills in s.owned with owned partitions of members of
+// the highest generation, and multipleOwners with partitions claimed by more
+// than one such member.
+func (s *sticky) allSubscriptionsEqual(partitionsPerTopic map[string]int, members []*member, multipleOwners map[client.TopicPartition]bool) bool {
+	s.owned = make(map[string][]client.TopicPartition)
+	s.maxGeneration = defaultGeneration
+	equal := true
+	var subscribed map[string]bool
+	var highestGeneration []string
+	previousOwner := make(map[client.TopicPartition]string)
+	for _, m := range members {
+		if subscribed == nil {
+			subscribed = m.topics
+		} else if equal && !reflect.DeepEqual(subscribed, m.topics) {
+			equal = false
+		}
+		d := s.memberData(m.subscription)
+		s.owned[m.id] = []client.TopicPartition{}
+		// owned partitions are valid only if member is of the highest
+		// generation seen, or if its generation is not known but no
+		// generation is known so far
+		if !(d.hasGen && d.generation >= s.maxGeneration || !d.hasGen && s.maxGeneration == defaultGeneration) {
+			continue
+		}
+		if d.hasGen && d.generation > s.maxGeneration {
+			// all previously owned partitions are invalid
+			previousOwner = make(map[client.TopicPartition]string)
+			for p := range multipleOwners {
+				delete(multipleOwners, p)
+			}
+			for _, c := range highestGeneration {
+				s.owned[c] = []client.TopicPartition{}
+			}
+			highestGeneration = nil
+			s.maxGeneration = d.generation
+		}
+		highestGeneration = append(highestGeneration, m.id)
+		for _, p := range d.partitions {
+			if n, ok := partitionsPerTopic[p.Topic]; !ok || int(p.Partition) >= n {
+				continue // topic no longer exists or is not subscribed
+			}
+			if _, ok := previousOwner[p]; ok {
+				multipleOwners[p] = true
+				continue
+			}
+			previousOwner[p] = m.id
+			s.owned[m.id] = append(s.owned[m.id], p)
+		}
+	}
+	return equal
+}
+
+func allPartitions(partitionsPerTopic map[string]int) []client.TopicPartition {
+	var partitions []client.TopicPartition
+	for _, t := range sortedTopics(partitionsPerTopic) {
+		for p := 0; p < partitionsPerTopic[t]; p++ {
+			partitions = append(partitions, client.TopicPartition{Topic: t, Partition: int32(p)})
+		}
+	}
+	return partitions
+}
+
+// constrainedAssign is used when all members are subscribed to the same
+// topics. members keep up to their quota of owned partitions and the rest is
+// assigned round robin to members under quota.
+func (s *sticky) constrainedAssign(partitionsPerTopic map[string]int, members []*member, multipleOwners map[client.TopicPartition]bool) (map[string][]client.TopicPartition, error) {
+	revoked := make(map[client.TopicPartition]bool)
+	var underMinQuota []string
+	var exactlyMinQuota []string
+	numMembers := len(members)
+	total := 0
+	for _, n := range partitionsPerTopic {
+		total += n
+	}
+	minQuota := total / numMembers
+	maxQuota := minQuota
+	if total%numMembers != 0 {
+		maxQuota++
+	}
+	// number of members which get maxQuota partitions
+	expectedOverMinQuota := total % numMembers
+	currentOverMinQuota := 0
+	assignment := make(map[string][]client.TopicPartition)
+	assigned := make(map[client.TopicPartition]bool)
+	for _, m := range members {
+		var owned []client.TopicPartition
+		for _, p := range s.owned[m.id] {
+			if multipleOwners[p] {
+				revoked[p] = true
+				continue
+			}
+			owned = append(owned, p)
+		}
+		var keep []client.TopicPartition
+		switch {
+		case len(owned) < minQuota:
+			keep = owned
+			underMinQuota = append(underMinQuota, m.id)
+		case len(owned) >= maxQuota && currentOverMinQuota < expectedOverMinQuota:
+			currentOverMinQuota++
+			if currentOverMinQuota == expectedOverMinQuota {
+				exactlyMinQuota = nil
+			}
+			keep = owned[:maxQuota]
+		default:
+			keep = owned[:minQuota]
+			if currentOverMinQuota < expectedOverMinQuota {
+				exactlyMinQuota = append(exactlyMinQuota, m.id)
+			}
+		}
+		for _, p := range owned[len(keep):] {
+			revoked[p] = true
+		}
+		assignment[m.id] = append([]client.TopicPartition{}, keep...)
+		for _, p := range keep {
+			assigned[p] = true
+		}
+	}
+	var unassigned []client.TopicPartition
+	for _, p := range allPartitions(partitionsPerTopic) {
+		if !assigned[p] {
+			unassigned = append(unassigned, p)
+		}
+	}
+	sort.Strings(underMinQuota)
+	sort.Strings(exactlyMinQuota)
+	// round robin over members under min quota. when all are at min
+	// quota, give one more partition to members at min quota until
+	// expected number of them is at max quota
+	next := 0
+	for _, p := range unassigned {
+		var c string
+		switch {
+		case next < len(underMinQuota):
+			c = underMinQuota[next]
+			next++
+		case len(underMinQuota) == 0 && len(exactlyMinQuota) == 0:
+			return nil, fmt.Errorf("no more unfilled members to assign %v to", p)
+		case len(underMinQuota) == 0:
+			c = exactlyMinQuota[0]
+			exactlyMinQuota = exactlyMinQuota[1:]
+		default:
+			next = 0
+			c = underMinQuota[next]
+			next++
+		}
+		assignment[c] = append(assignment[c], p)
+		if revoked[p] || multipleOwners[p] {
+			s.transferring[p] = c
+		}
+		switch len(assignment[c]) {
+		case minQuota:
+			next--
+			underMinQuota = append(underMinQuota[:next], underMinQuota[next+1:]...)
+			exactlyMinQuota = append(exactlyMinQuota, c)
+		case maxQuota:
+			currentOverMinQuota++
+		}
+	}
+	if len(underMinQuota) > 0 {
+		if currentOverMinQuota != expectedOverMinQuota {
+			return nil, fmt.Errorf("expected %d members with more than min quota partitions, got %d", expectedOverMinQuota, currentOverMinQuota)
+		}
+		for _, c := range underMinQuota {
+			if n := len(assignment[c]); n != minQuota {
+				return nil, fmt.Errorf("member %s has %d partitions, expected %d", c, n, minQuota)
+			}
+		}
+	}
+	return assignment, nil
+}
+
+type consumerGeneration struct {
+	consumer   string
+	generation int32
+}
+
+// previousAssignments returns, for each partition, its most recent owner of
+// a generation older than the max generation.
+func (s *sticky) previousAssignments(members []*member) map[client.TopicPartition]consumerGeneration {
+	prev := make(map[client.TopicPartition]consumerGeneration)
+	update := func(partitions []client.TopicPartition, consumer string, generation int32) {
+		for _, p := range partitions {
+			if g, ok := prev[p]; !ok || generation > g.generation {
+				prev[p] = consumerGeneration{consumer, generation}
+			}
+		}
+	}
+	for _, m := range members {
+		d := s.memberData(m.subscription)
+		switch {
+		case d.hasGen && d.generation < s.maxGeneration:
+			update(d.partitions, m.id, d.generation)
+		case !d.hasGen && s.maxGeneration > defaultGeneration:
+			update(d.partitions, m.id, defaultGeneration)
+		}
+	}
+	return prev
+}
+
+// generalAssign is used when members are subscribed to different topics.
+func (s *sticky) generalAssign(partitionsPerTopic map[string]int, members []*member) (map[string][]client.TopicPartition, error) {
+	g := &general{
+		currentAssignment:        make(map[string][]client.TopicPartition),
+		prevAssignment:           s.previousAssignments(members),
+		consumerPartitions:       make(map[string][]client.TopicPartition),
+		consumerPartitionsSet:    make(map[string]map[client.TopicPartition]bool),
+		partitionConsumers:       make(map[client.TopicPartition][]string),
+		currentPartitionConsumer: make(map[client.TopicPartition]string),
+		movements:                newPartitionMovements(),
+	}
+	fresh := true
+	for c, owned := range s.owned {
+		g.currentAssignment[c] = append([]client.TopicPartition(nil), owned...)
+		if len(owned) > 0 {
+			fresh = false
+		}
+	}
+	for _, p := range allPartitions(partitionsPerTopic) {
+		g.partitionConsumers[p] = []string{}
+	}
+	subscriptions := make(map[string]*member)
+	for _, m := range members {
+		subscriptions[m.id] = m
+		g.consumerPartitions[m.id] = []client.TopicPartition{}
+		g.consumerPartitionsSet[m.id] = make(map[client.TopicPartition]bool)
+		for _, t := range m.subscription.Topics {
+			n, ok := partitionsPerTopic[t]
+			if !ok {
+				continue
+			}
+			for i := 0; i < n; i++ {
+				p := client.TopicPartition{Topic: t, Partition: int32(i)}
+				if g.consumerPartitionsSet[m.id][p] {
+					continue // topic listed more than once
+				}
+				g.consumerPartitions[m.id] = append(g.consumerPartitions[m.id], p)
+				g.consumerPartitionsSet[m.id][p] = true
+				g.partitionConsumers[p] = append(g.partitionConsumers[p], m.id)
+			}
+		}
+		if _, ok := g.currentAssignment[m.id]; !ok {
+			g.currentAssignment[m.id] = []client.TopicPartition{}
+		}
+	}
+	for c, partitions := range g.currentAssignment {
+		for _, p := range partitions {
+			g.currentPartitionConsumer[p] = c
+		}
+	}
+	sortedPartitions := g.sortPartitions(fresh)
+	unassigned := make(map[client.TopicPartition]bool)
+	for _, p := range sortedPartitions {
+		unassigned[p] = true
+	}
+	revocationRequired := false
+	for _, c := range sortedKeys(g.currentAssignment) {
+		partitions := g.currentAssignment[c]
+		m, ok := subscriptions[c]
+		if !ok {
+			for _, p := range partitions {
+				delete(g.currentPartitionConsumer, p)
+			}
+			delete(g.currentAssignment, c)
+			continue
+		}
+		var keep []client.TopicPartition
+		for _, p := range partitions {
+			switch {
+			case g.partitionConsumers[p] == nil:
+				// partition no longer exists
+				delete(g.currentPartitionConsumer, p)
+			case !m.topics[p.Topic]:
+				// member no longer subscribed to the topic
+				revocationRequired = true
+			default:
+				keep = append(keep, p)
+				delete(unassigned, p)
+			}
+		}
+		g.currentAssignment[c] = append([]client.TopicPartition{}, keep...)
+	}
+	var unassignedPartitions []client.TopicPartition
+	for _, p := range sortedPartitions {
+		if unassigned[p] {
+			unassignedPartitions = append(unassignedPartitions, p)
+		}
+	}
+	g.sorted = &consumerSet{assignment: g.currentAssignment}
+	for c := range g.currentAssignment {
+		g.sorted.add(c)
+	}
+	g.balance(sortedPartitions, unassignedPartitions, revocationRequired)
+	return g.currentAssignment, nil
+}
+
+// state of the general sticky assignment algorithm
+type general struct {
+	currentAssignment map[string][]client.TopicPartition
+	prevAssignment    map[client.TopicPartition]consumerGeneration
+	// all partitions that can be assigned to each member
+	consumerPartitions    map[string][]client.TopicPartition
+	consumerPartitionsSet map[string]map[client.TopicPartition]bool
+	// all members that each partition can be assigned to
+	partitionConsumers       map[client.TopicPartition][]string
+	currentPartitionConsumer map[client.TopicPartition]string
+	// members, sorted by number of assigned partitions, then member id
+	sorted    *consumerSet
+	movements *partitionMovements
+}
+
+func sortedKeys(m map[string][]client.TopicPartition) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortPartitions returns the order in which partitions are considered for
+// reassignment.
+func (g *general) sortPartitions(fresh bool) []client.TopicPartition {
+	var sorted []client.TopicPartition
+	if !fresh && g.subscriptionsIdentical() {
+		// list partitions round robin, from members with most assigned
+		// partitions to those with least, starting with partitions
+		// which had a different previous owner
+		assignments := make(map[string][]client.TopicPartition)
+		for c, partitions := range g.currentAssignment {
+			for _, p := range partitions {
+				if g.partitionConsumers[p] != nil {
+					assignments[c] = append(assignments[c], p)
+				}
+			}
+			if assignments[c] == nil {
+				assignments[c] = []client.TopicPartition{}
+			}
+		}
+		consumers := &consumerSet{assignment: assignments}
+		for c := range assignments {
+			consumers.add(c)
+		}
+		listed := make(map[client.TopicPartition]bool)
+		for consumers.len() > 0 {
+			c := consumers.last()
+			consumers.remove(c)
+			remaining := assignments[c]
+			i := 0 // partition to list
+			for j, p := range remaining {
+				if _, ok := g.prevAssignment[p]; ok {
+					i = j
+					break
+				}
+			}
+			if len(remaining) > 0 {
+				sorted = append(sorted, remaining[i])
+				listed[remaining[i]] = true
+				assignments[c] = append(remaining[:i:i], remaining[i+1:]...)
+				consumers.add(c)
+			}
+		}
+		for _, p := range g.partitionsByPotentialConsumers() {
+			if !listed[p] {
+				sorted = append(sorted, p)
+			}
+		}
+		return sorted
+	}
+	return g.partitionsByPotentialConsumers()
+}
+
+// partitions sorted by number of members they can be assigned to, then by
+// topic and partition
+func (g *general) partitionsByPotentialConsumers() []client.TopicPartition {
+	partitions := make([]client.TopicPartition, 0, len(g.partitionConsumers))
+	for p := range g.partitionConsumers {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		a, b := partitions[i], partitions[j]
+		if na, nb := len(g.partitionConsumers[a]), len(g.partitionConsumers[b]); na != nb {
+			return na < nb
+		}
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+	return partitions
+}
+
+// true if all partitions can be assigned to the same members and all members
+// can be assigned the same partitions
+func (g *general) subscriptionsIdentical() bool {
+	var first map[string]bool
+	for _, consumers := range g.partitionConsumers {
+		set := make(map[string]bool)
+		for _, c := range consumers {
+			set[c] = true
+		}
+		if first == nil {
+			first = set
+		} else if !reflect.DeepEqual(first, set) {
+			return false
+		}
+	}
+	var firstPartitions map[client.TopicPartition]bool
+	for c := range g.consumerPartitions {
+		if firstPartitions == nil {
+			firstPartitions = g.consumerPartitionsSet[c]
+		} else if !reflect.DeepEqual(firstPartitions, g.consumerPartitionsSet[c]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *general) balance(sortedPartitions, unassigned []client.TopicPartition, revocationRequired bool) {
+	initializing := g.sorted.len() == 0 || len(g.currentAssignment[g.sorted.last()]) == 0
+	for _, p := range unassigned {
+		if len(g.partitionConsumers[p]) == 0 {
+			continue // no member can be assigned the partition
+		}
+		g.assignPartition(p)
+	}
+	// narrow down reassignment to partitions and members which can
+	// actually be reassigned
+	fixedPartitions := make(map[client.TopicPartition]bool)
+	for p := range g.partitionConsumers {
+		if !g.partitionCanParticipate(p) {
+			fixedPartitions[p] = true
+		}
+	}
+	sortedPartitions = without(sortedPartitions, fixedPartitions)
+	unassigned = without(unassigned, fixedPartitions)
+	fixedAssignments := make(map[string][]client.TopicPartition)
+	for c := range g.consumerPartitions {
+		if !g.consumerCanParticipate(c) {
+			g.sorted.remove(c)
+			fixedAssignments[c] = g.currentAssignment[c]
+			delete(g.currentAssignment, c)
+		}
+	}
+	preBalanceAssignment := deepCopy(g.currentAssignment)
+	preBalancePartitionConsumer := make(map[client.TopicPartition]string, len(g.currentPartitionConsumer))
+	for p, c := range g.currentPartitionConsumer {
+		preBalancePartitionConsumer[p] = c
+	}
+	// if nothing has to be revoked because of subscription changes, first
+	// try to balance by moving only newly added partitions
+	if !revocationRequired {
+		g.performReassignments(unassigned)
+	}
+	reassigned := g.performReassignments(sortedPartitions)
+	// if assignments were changed, make sure the result is more balanced
+	// than before; otherwise revert
+	if !initializing && reassigned && balanceScore(g.currentAssignment) >= balanceScore(preBalanceAssignment) {
+		g.currentAssignment = preBalanceAssignment
+		g.currentPartitionConsumer = preBalancePartitionConsumer
+	}
+	for c, partitions := range fixedAssignments {
+		g.currentAssignment[c] = partitions
+	}
+}
+
+func without(partitions []client.TopicPartition, exclude map[client.TopicPartition]bool) []client.TopicPartition {
+	var out []client.TopicPartition
+	for _, p := range partitions {
+		if !exclude[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func deepCopy(assignment map[string][]client.TopicPartition) map[string][]client.TopicPartition {
+	out := make(map[string][]client.TopicPartition, len(assignment))
+	for c, partitions := range assignment {
+		out[c] = append([]client.TopicPartition{}, partitions...)
+	}
+	return out
+}
+
+// sum of differences in numbers of assigned partitions between all pairs of
+// members. lower is more balanced.
+func balanceScore(assignment map[string][]client.TopicPartition) int {
+	var sizes []int
+	for _, partitions := range assignment {
+		sizes = append(sizes, len(partitions))
+	}
+	score := 0
+	for i := range sizes {
+		for j := i + 1; j < len(sizes); j++ {
+			d := sizes[i] - sizes[j]
+			if d < 0 {
+				d = -d
+			}
+			score += d
+		}
+	}
+	return score
+}
+
+// assign partition to the member with fewest partitions which can take it
+func (g *general) assignPartition(p client.TopicPartition) {
+	for _, c := range g.sorted.list() {
+		if g.consumerPartitionsSet[c][p] {
+			g.sorted.remove(c)
+			g.currentAssignment[c] = append(g.currentAssignment[c], p)
+			g.currentPartitionConsumer[p] = c
+			g.sorted.add(c)
+			return
+		}
+	}
+}
+
+func (g *general) partitionCanParticipate(p client.TopicPartition) bool {
+	return len(g.partitionConsumers[p]) >= 2
+}
+
+func (g *general) consumerCanParticipate(c string) bool {
+	current := g.currentAssignment[c]
+	if len(current) < len(g.consumerPartitions[c]) {
+		// member does not have all the partitions it could have
+		return true
+	}
+	for _, p := range current {
+		if g.partitionCanParticipate(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBalanced is true if the numbers of assigned partitions differ by at most
+// one, or if no member can get a partition from a member with more
+// partitions.
+func (g *general) isBalanced() bool {
+	if g.sorted.len() == 0 {
+		return true
+	}
+	min := len(g.currentAssignment[g.sorted.first()])
+	max := len(g.currentAssignment[g.sorted.last()])
+	if min >= max-1 {
+		return true
+	}
+	for _, c := range g.sorted.list() {
+		count := len(g.currentAssignment[c])
+		if count == len(g.consumerPartitions[c]) {
+			continue // member has all partitions it can get
+		}
+		assigned := toSet(g.currentAssignment[c])
+		for _, p := range g.consumerPartitions[c] {
+			if assigned[p] {
+				continue
+			}
+			other, ok := g.currentPartitionConsumer[p]
+			if ok && count < len(g.currentAssignment[other]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// performReassignments moves partitions between members until the assignment
+// is balanced, or until no move improves it. returns true if any partitions
+// were moved.
+func (g *general) performReassignments(partitions []client.TopicPartition) bool {
+	reassigned := false
+	for modified := true; modified; {
+		modified = false
+		for _, p := range partitions {
+			if g.isBalanced() {
+				break
+			}
+			c := g.currentPartitionConsumer[p]
+			if prev, ok := g.prevAssignment[p]; ok {
+				if prevAssigned, ok := g.currentAssignment[prev.consumer]; ok && len(g.currentAssignment[c]) > len(prevAssigned)+1 {
+					// move partition back to its previous owner
+					g.reassignPartitionTo(p, prev.consumer)
+					reassigned = true
+					modified = true
+					continue
+				}
+			}
+			// check if a better suited member exists for the partition
+			for _, other := range g.partitionConsumers[p] {
+				if len(g.currentAssignment[c]) > len(g.currentAssignment[other])+1 {
+					g.reassignPartition(p)
+					reassigned = true
+					modified = true
+					break
+				}
+			}
+		}
+	}
+	return reassigned
+}
+
+// reassign partition to the member with fewest partitions which can take it
+func (g *general) reassignPartition(p client.TopicPartition) {
+	for _, c := range g.sorted.list() {
+		if g.consumerPartitionsSet[c][p] {
+			g.reassignPartitionTo(p, c)
+			return
+		}
+	}
+}
+
+func (g *general) reassignPartitionTo(p client.TopicPartition, newConsumer string) {
+	c := g.currentPartitionConsumer[p]
+	// to preserve stickiness move a different partition of the same
+	// topic, if one was moved in the opposite direction before
+	p = g.movements.partitionToBeMoved(p, c, newConsumer)
+	g.processPartitionMovement(p, newConsumer)
+}
+
+func (g *general) processPartitionMovement(p client.TopicPartition, newConsumer string) {
+	oldConsumer := g.currentPartitionConsumer[p]
+	g.sorted.remove(oldConsumer)
+	g.sorted.remove(newConsumer)
+	g.movements.move(p, oldConsumer, newConsumer)
+	g.currentAssignment[oldConsumer] = remove(g.currentAssignment[oldConsumer], p)
+	g.currentAssignment[newConsumer] = append(g.currentAssignment[newConsumer], p)
+	g.currentPartitionConsumer[p] = newConsumer
+	g.sorted.add(newConsumer)
+	g.sorted.add(oldConsumer)
+}
+
+func remove(partitions []client.TopicPartition, p client.TopicPartition) []client.TopicPartition {
+	for i, x := range partitions {
+		if x == p {
+			return append(partitions[:i:i], partitions[i+1:]...)
+		}
+	}
+	return partitions
+}
+
+func toSet(partitions []client.TopicPartition) map[client.TopicPartition]bool {
+	set := make(map[client.TopicPartition]bool, len(partitions))
+	for _, p := range partitions {
+		set[p] = true
+	}
+	return set
+}
+
+// consumerSet is a set of member ids, sorted by number of partitions assigned
+// to members, and then by member id. members must be removed from the set
+// before their assignment changes, and added back after.
+type consumerSet struct {
+	assignment map[string][]client.TopicPartition
+	ids        []string
+}
+
+func (s *consumerSet) less(a, b string) bool {
+	if na, nb := len(s.assignment[a]), len(s.assignment[b]); na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+func (s *consumerSet) add(id string) {
+	i := sort.Search(len(s.ids), func(i int) bool { return !s.less(s.ids[i], id) })
+	if i < len(s.ids) && s.ids[i] == id {
+		return
+	}
+	s.ids = append(s.ids, "")
+	copy(s.ids[i+1:], s.ids[i:])
+	s.ids[i] = id
+}
+
+func (s *consumerSet) remove(id string) {
+	for i, x := range s.ids {
+		if x == id {
+			s.ids = append(s.ids[:i], s.ids[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *consumerSet) len() int      { return len(s.ids) }
+func (s *consumerSet) first() string { return s.ids[0] }
+func (s *consumerSet) last() string  { return s.ids[len(s.ids)-1] }
+
+// list returns a copy, so the set can be modified while iterating
+func (s *consumerSet) list() []string {
+	return append([]string(nil), s.ids...)
+}
+
+type consumerPair struct {
+	src string
+	dst string
+}
+
+// partitionMovements keeps track of partitions moved between members during
+// the reassignment, so that if partitions of a topic move in both directions
+// between two members, the original assignment is restored instead.
+type partitionMovements struct {
+	byTopic map[string]map[consumerPair]map[client.TopicPartition]bool
+	moves   map[client.TopicPartition]consumerPair
+}
+
+func newPartitionMovements() *partitionMovements {
+	return &partitionMovements{
+		byTopic: make(map[string]map[consumerPair]map[client.TopicPartition]bool),
+		moves:   make(map[client.TopicPartition]consumerPair),
+	}
+}
+
+func (m *partitionMovements) removeRecord(p client.TopicPartition) consumerPair {
+	pair := m.moves[p]
+	delete(m.moves, p)
+	pairs := m.byTopic[p.Topic]
+	delete(pairs[pair], p)
+	if len(pairs[pair]) == 0 {
+		delete(pairs, pair)
+	}
+	if len(pairs) == 0 {
+		delete(m.byTopic, p.Topic)
+	}
+	return pair
+}
+
+func (m *partitionMovements) addRecord(p client.TopicPartition, pair consumerPair) {
+	m.moves[p] = pair
+	if m.byTopic[p.Topic] == nil {
+		m.byTopic[p.Topic] = make(map[consumerPair]map[client.TopicPartition]bool)
+	}
+	if m.byTopic[p.Topic][pair] == nil {
+		m.byTopic[p.Topic][pair] = make(map[client.TopicPartition]bool)
+	}
+	m.byTopic[p.Topic][pair][p] = true
+}
+
+func (m *partitionMovements) move(p client.TopicPartition, oldConsumer, newConsumer string) {
+	if _, ok := m.moves[p]; !ok {
+		m.addRecord(p, consumerPair{oldConsumer, newConsumer})
+		return
+	}
+	// partition moved before
+	existing := m.removeRecord(p)
+	if existing.src != newConsumer {
+		// not moving back to its original member
+		m.addRecord(p, consumerPair{existing.src, newConsumer})
+	}
+}
+
+func (m *partitionMovements) partitionToBeMoved(p client.TopicPartition, oldConsumer, newConsumer string) client.TopicPartition {
+	pairs, ok := m.byTopic[p.Topic]
+	if !ok {
+		return p
+	}
+	if pair, ok := m.moves[p]; ok {
+		oldConsumer = pair.src
+	}
+	reverse, ok := pairs[consumerPair{newConsumer, oldConsumer}]
+	if !ok {
+		return p
+	}
+	// java takes any partition from the set. take the lowest one
+	var candidates []client.TopicPartition
+	for x := range reverse {
+		candidates = append(candidates, x)
+	}
+	client.SortTopicPartitions(candidates)
+	return candidates[0]
+}
@@ -0,0 +1,302 @@
+package assignor
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka/api/ConsumerProtocol"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+func TestUnitStickyUserData(t *testing.T) {
+	a := &StickyAssignor{}
+	if b := a.UserData(nil, -1); b != nil {
+		t.Fatal(b)
+	}
+	s := &ConsumerProtocol.Subscription{UserData: a.UserData(tps("foo:1", "foo:0"), 5)}
+	d := stickyMemberData(s)
+	if !d.hasGen || d.generation != 5 || !reflect.DeepEqual(d.partitions, tps("foo:0", "foo:1")) {
+		t.Fatal(d)
+	}
+	// v0 has no generation
+	s.UserData = s.UserData[:len(s.UserData)-4]
+	d = stickyMemberData(s)
+	if d.hasGen || !reflect.DeepEqual(d.partitions, tps("foo:0", "foo:1")) {
+		t.Fatal(d)
+	}
+	s.UserData = []byte{1}
+	if d = stickyMemberData(s); !d.hasGen || d.generation != -1 || d.partitions != nil {
+		t.Fatal(d)
+	}
+}
+
+func TestUnitCooperativeStickyUserData(t *testing.T) {
+	a := &CooperativeStickyAssignor{}
+	// subscription v1 (no generation field) of a java 2.x consumer
+	s := &ConsumerProtocol.Subscription{
+		Version:         1,
+		UserData:        a.UserData(nil, 4),
+		OwnedPartitions: EncodePartitions(tps("foo:0")),
+		GenerationId:    -1,
+	}
+	d := cooperativeMemberData(s)
+	if !d.hasGen || d.generation != 4 || !reflect.DeepEqual(d.partitions, tps("foo:0")) {
+		t.Fatal(d)
+	}
+	s.UserData = nil
+	if d = cooperativeMemberData(s); d.hasGen {
+		t.Fatal(d)
+	}
+}
+
+func TestUnitStickyAssignorAddRemoveMembers(t *testing.T) {
+	a := &StickyAssignor{}
+	partitions := map[string]int{"t": 3}
+	c1 := &testMember{id: "c1", topics: []string{"t"}, generation: -1}
+	got := rebalance(t, a, []*testMember{c1}, partitions, 1)
+	assertAssignments(t, got, map[string][]string{"c1": {"t:0", "t:1", "t:2"}})
+	c2 := &testMember{id: "c2", topics: []string{"t"}, generation: -1}
+	got = rebalance(t, a, []*testMember{c1, c2}, partitions, 2)
+	assertAssignments(t, got, map[string][]string{"c1": {"t:0", "t:1"}, "c2": {"t:2"}})
+	got = rebalance(t, a, []*testMember{c2}, partitions, 3)
+	assertAssignments(t, got, map[string][]string{"c2": {"t:0", "t:1", "t:2"}})
+}
+
+func TestUnitCooperativeStickyAssignorAddMember(t *testing.T) {
+	a := &CooperativeStickyAssignor{}
+	partitions := map[string]int{"t": 3}
+	c1 := &testMember{id: "c1", topics: []string{"t"}, generation: -1}
+	rebalance(t, a, []*testMember{c1}, partitions, 1)
+	c2 := &testMember{id: "c2", topics: []string{"t"}, generation: -1}
+	// t:2 is revoked from c1 but not yet assigned to c2
+	got := rebalance(t, a, []*testMember{c1, c2}, partitions, 2)
+	assertAssignments(t, got, map[string][]string{"c1": {"t:0", "t:1"}, "c2": nil})
+	got = rebalance(t, a, []*testMember{c1, c2}, partitions, 3)
+	assertAssignments(t, got, map[string][]string{"c1": {"t:0", "t:1"}, "c2": {"t:2"}})
+}
+
+func TestUnitStickyAssignorGeneral(t *testing.T) {
+	a := &StickyAssignor{}
+	// c0 can only take t1, which forces the general algorithm
+	members := []*testMember{
+		{id: "c0", topics: []string{"t1"}, generation: -1},
+		{id: "c1", topics: []string{"t1", "t2"}, generation: -1},
+		{id: "c2", topics: []string{"t1", "t2"}, generation: -1},
+	}
+	partitions := map[string]int{"t1": 2, "t2": 4}
+	got := rebalance(t, a, members, partitions, 1)
+	verifyAssignment(t, members, partitions, got)
+	if len(got["c0"]) != 2 || len(got["c1"]) != 2 || len(got["c2"]) != 2 {
+		t.Fatal(got)
+	}
+	// c2 leaves: its partitions go to c1, others stay put
+	before := got
+	got = rebalance(t, a, members[:2], partitions, 2)
+	verifyAssignment(t, members[:2], partitions, got)
+	for _, id := range []string{"c0", "c1"} {
+		if kept := intersection(before[id], got[id]); len(kept) != len(before[id]) {
+			t.Fatal(id, before[id], got[id])
+		}
+	}
+}
+
+func intersection(a, b []client.TopicPartition) []client.TopicPartition {
+	set := toSet(b)
+	var out []client.TopicPartition
+	for _, p := range a {
+		if set[p] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// verifyAssignment checks that every partition is assigned exactly once, to
+// a member subscribed to its topic, and that the assignment is balanced: a
+// member with at least two more partitions than another member has no
+// partitions that the other member could take.
+func verifyAssignment(t *testing.T, members []*testMember, partitionsPerTopic map[string]int, assignments map[string][]client.TopicPartition) {
+	t.Helper()
+	subscribed := make(map[string]map[string]bool)
+	for _, m := range members {
+		subscribed[m.id] = make(map[string]bool)
+		for _, topic := range m.topics {
+			subscribed[m.id][topic] = true
+		}
+	}
+	owner := make(map[client.TopicPartition]string)
+	for id, partitions := range assignments {
+		for _, p := range partitions {
+			if other, ok := owner[p]; ok {
+				t.Fatalf("%v assigned to %s and %s", p, id, other)
+			}
+			if !subscribed[id][p.Topic] {
+				t.Fatalf("%v assigned to %s which is not subscribed", p, id)
+			}
+			owner[p] = id
+		}
+	}
+	for topic, n := range partitionsPerTopic {
+		for i := 0; i < n; i++ {
+			p := client.TopicPartition{Topic: topic, Partition: int32(i)}
+			if _, ok := owner[p]; ok {
+				continue
+			}
+			for _, m := range members {
+				if subscribed[m.id][topic] {
+					t.Fatalf("%v not assigned", p)
+				}
+			}
+		}
+	}
+	for _, a := range members {
+		for _, b := range members {
+			if len(assignments[a.id]) < len(assignments[b.id])+2 {
+				continue
+			}
+			for _, p := range assignments[a.id] {
+				if subscribed[b.id][p.Topic] {
+					t.Fatalf("unbalanced: %s has %v, %s has %v", a.id, assignments[a.id], b.id, assignments[b.id])
+				}
+			}
+		}
+	}
+}
+
+// random group changes: members join and leave, and change subscriptions,
+// and topics get more partitions. with "equal" all members are subscribed to
+// all topics.
+func randomRebalances(t *testing.T, a Assignor, seed int64, equal bool, check func(before map[string][]client.TopicPartition, members []*testMember, partitions map[string]int, after map[string][]client.TopicPartition)) {
+	r := rand.New(rand.NewSource(seed))
+	topics := []string{"t0", "t1", "t2", "t3", "t4"}
+	partitions := make(map[string]int)
+	for _, topic := range topics {
+		partitions[topic] = 1 + r.Intn(10)
+	}
+	subscription := func() []string {
+		if equal {
+			return topics
+		}
+		var s []string
+		for _, topic := range topics {
+			if r.Intn(2) == 0 {
+				s = append(s, topic)
+			}
+		}
+		if s == nil {
+			s = topics[:1]
+		}
+		return s
+	}
+	var members []*testMember
+	for i := 0; i < 1+r.Intn(8); i++ {
+		members = append(members, &testMember{id: fmt.Sprintf("c%02d", i), topics: subscription(), generation: -1})
+	}
+	next := len(members)
+	for i := 0; i < 20; i++ {
+		before := make(map[string][]client.TopicPartition)
+		generation := int32(0)
+		for _, m := range members {
+			before[m.id] = m.assigned
+			if m.generation > generation {
+				generation = m.generation
+			}
+		}
+		after := rebalance(t, a, members, partitions, generation+1)
+		check(before, members, partitions, after)
+		switch r.Intn(4) {
+		case 0:
+			members = append(members, &testMember{id: fmt.Sprintf("c%02d", next), topics: subscription(), generation: -1})
+			next++
+		case 1:
+			if len(members) > 1 {
+				i := r.Intn(len(members))
+				members = append(members[:i], members[i+1:]...)
+			}
+		case 2:
+			if !equal {
+				m := members[r.Intn(len(members))]
+				m.topics = subscription()
+			}
+		case 3:
+			partitions[topics[r.Intn(len(topics))]]++
+		}
+	}
+}
+
+func TestUnitStickyAssignorPropertyBalance(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		randomRebalances(t, &StickyAssignor{}, seed, false, func(_ map[string][]client.TopicPartition, members []*testMember, partitions map[string]int, after map[string][]client.TopicPartition) {
+			verifyAssignment(t, members, partitions, after)
+		})
+	}
+}
+
+// with equal subscriptions, members which do not have to give up partitions
+// (to keep the assignment balanced) keep all of them, and members which do
+// keep as many as they can
+func TestUnitStickyAssignorPropertyStickiness(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		randomRebalances(t, &StickyAssignor{}, seed, true, func(before map[string][]client.TopicPartition, members []*testMember, partitions map[string]int, after map[string][]client.TopicPartition) {
+			verifyAssignment(t, members, partitions, after)
+			for _, m := range members {
+				kept := intersection(before[m.id], after[m.id])
+				if want := min(len(before[m.id]), len(after[m.id])); len(kept) != want {
+					t.Fatalf("seed %d: %s had %v, got %v", seed, m.id, before[m.id], after[m.id])
+				}
+			}
+		})
+	}
+}
+
+// a partition is never assigned to a member while some other member owns it,
+// and once revocations are done the assignment is balanced
+func TestUnitCooperativeStickyAssignorProperty(t *testing.T) {
+	for _, equal := range []bool{true, false} {
+		for seed := int64(0); seed < 50; seed++ {
+			a := &CooperativeStickyAssignor{}
+			randomRebalances(t, a, seed, equal, func(before map[string][]client.TopicPartition, members []*testMember, partitions map[string]int, after map[string][]client.TopicPartition) {
+				assertNoDirectTransfers(t, members, before, after)
+				// members that had partitions revoked rejoin, until
+				// there is nothing more to revoke
+				for i := 0; revokes(members, before, after); i++ {
+					if i == 10 {
+						t.Fatalf("seed %d: no stable assignment", seed)
+					}
+					before = after
+					after = rebalance(t, a, members, partitions, members[0].generation+1)
+					assertNoDirectTransfers(t, members, before, after)
+				}
+				verifyAssignment(t, members, partitions, after)
+			})
+		}
+	}
+}
+
+func revokes(members []*testMember, before, after map[string][]client.TopicPartition) bool {
+	for _, m := range members {
+		if len(intersection(before[m.id], after[m.id])) < len(before[m.id]) {
+			return true
+		}
+	}
+	return false
+}
+
+func assertNoDirectTransfers(t *testing.T, members []*testMember, before, after map[string][]client.TopicPartition) {
+	t.Helper()
+	owner := make(map[client.TopicPartition]string)
+	for _, m := range members {
+		for _, p := range before[m.id] {
+			owner[p] = m.id
+		}
+	}
+	for id, partitions := range after {
+		for _, p := range partitions {
+			if o, ok := owner[p]; ok && o != id {
+				t.Fatalf("%v moved from %s to %s in one rebalance", p, o, id)
+			}
+		}
+	}
+}
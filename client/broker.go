@@ -0,0 +1,311 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/api/ListOffsets"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/Produce"
+)
+
+var (
+	ErrBrokerDoesNotExist = errors.New("broker does not exist")
+)
+
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+}
+
+func (tp TopicPartition) String() string {
+	return fmt.Sprintf("%s:%d", tp.Topic, tp.Partition)
+}
+
+// SortTopicPartitions sorts by topic and then by partition.
+func SortTopicPartitions(partitions []TopicPartition) {
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+}
+
+func GetBroker(bootstrap string, tlsConfig *tls.Config, nodeId int32) (*Metadata.Broker, error) {
+	return GetBrokerSASL(bootstrap, tlsConfig, nil, nodeId)
+}
+
+// GetBrokerSASL is GetBroker on connections authenticated with sasl.
+func GetBrokerSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, nodeId int32) (*Metadata.Broker, error) {
+	meta, err := CallMetadataSASL(bootstrap, tlsConfig, sasl, []string{})
+	if err != nil {
+		return nil, err
+	}
+	if b := meta.Broker(nodeId); b != nil {
+		return b, nil
+	}
+	return nil, ErrBrokerDoesNotExist
+}
+
+// GetPartitions makes a Metadata call for the topics and returns all their
+// partitions, sorted. Error is returned if metadata for any of the topics has
+// an error code (for example the topic does not exist).
+func GetPartitions(bootstrap string, tlsConfig *tls.Config, topics []string) ([]TopicPartition, error) {
+	return GetPartitionsSASL(bootstrap, tlsConfig, nil, topics)
+}
+
+// GetPartitionsSASL is GetPartitions on connections authenticated with sasl.
+func GetPartitionsSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, topics []string) ([]TopicPartition, error) {
+	meta, err := CallMetadataSASL(bootstrap, tlsConfig, sasl, topics)
+	if err != nil {
+		return nil, fmt.Errorf("error getting topic metadata: %w", err)
+	}
+	var partitions []TopicPartition
+	for _, t := range meta.TopicMetadata {
+		if t.ErrorCode != libkafka.ERR_NONE {
+			return nil, fmt.Errorf("error getting metadata for topic %q: %w", t.Topic, &libkafka.Error{Code: t.ErrorCode})
+		}
+		for _, p := range t.PartitionMetadata {
+			partitions = append(partitions, TopicPartition{Topic: t.Topic, Partition: p.Partition})
+		}
+	}
+	SortTopicPartitions(partitions)
+	return partitions, nil
+}
+
+// NoLeader is the key under which GroupByLeader returns partitions that do not
+// exist or that currently have no leader. It is the same value that Kafka uses
+// in metadata responses for partitions with no leader.
+const NoLeader int32 = -1
+
+// GroupByLeader makes a single Metadata call for all topics and groups the
+// topic partitions by the node id of their leaders. Partitions that do not
+// exist or that have no leader are grouped under NoLeader. The metadata
+// response is returned so that the caller can look up broker addresses.
+func GroupByLeader(bootstrap string, tlsConfig *tls.Config, partitions []TopicPartition) (map[int32][]TopicPartition, *Metadata.Response, error) {
+	return GroupByLeaderSASL(bootstrap, tlsConfig, nil, partitions)
+}
+
+// GroupByLeaderSASL is GroupByLeader on connections authenticated with sasl.
+func GroupByLeaderSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, partitions []TopicPartition) (map[int32][]TopicPartition, *Metadata.Response, error) {
+	var topics []string
+	seen := make(map[string]bool)
+	for _, tp := range partitions {
+		if !seen[tp.Topic] {
+			seen[tp.Topic] = true
+			topics = append(topics, tp.Topic)
+		}
+	}
+	if topics == nil {
+		topics = []string{} // nil would mean "all topics"
+	}
+	meta, err := CallMetadataSASL(bootstrap, tlsConfig, sasl, topics)
+	if err != nil {
+		return nil, nil, err
+	}
+	return groupByLeader(meta, partitions), meta, nil
+}
+
+func groupByLeader(meta *Metadata.Response, partitions []TopicPartition) map[int32][]TopicPartition {
+	leaders := make(map[string]map[int32]*Metadata.Broker)
+	groups := make(map[int32][]TopicPartition)
+	for _, tp := range partitions {
+		if _, ok := leaders[tp.Topic]; !ok {
+			leaders[tp.Topic] = meta.Leaders(tp.Topic)
+		}
+		id := NoLeader
+		if b := leaders[tp.Topic][tp.Partition]; b != nil {
+			id = b.NodeId
+		}
+		groups[id] = append(groups[id], tp)
+	}
+	return groups
+}
+
+// GetOffsets lists offsets of partitions for each of the timestamps (in
+// milliseconds since epoch, or ListOffsets.Earliest or ListOffsets.Latest).
+// Requests are batched: for each timestamp one request is sent to each
+// partition leader. Results are in the order of timestamps. Every partition is
+// in every result: partitions with no leader have ERR_LEADER_NOT_AVAILABLE
+// error code (and offset -1). If a call to a broker fails, partitions led by
+// it are missing from the results, and the (first) error is returned along
+// with the results.
+func GetOffsets(bootstrap string, tlsConfig *tls.Config, partitions []TopicPartition, timestampsMs ...int64) ([]map[TopicPartition]*ListOffsets.PartitionResponse, error) {
+	return GetOffsetsSASL(bootstrap, tlsConfig, nil, partitions, timestampsMs...)
+}
+
+// GetOffsetsSASL is GetOffsets on connections authenticated with sasl.
+func GetOffsetsSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, partitions []TopicPartition, timestampsMs ...int64) ([]map[TopicPartition]*ListOffsets.PartitionResponse, error) {
+	byLeader, meta, err := GroupByLeaderSASL(bootstrap, tlsConfig, sasl, partitions)
+	if err != nil {
+		return nil, fmt.Errorf("error getting partition leaders: %w", err)
+	}
+	results := make([]map[TopicPartition]*ListOffsets.PartitionResponse, len(timestampsMs))
+	for i := range results {
+		results[i] = make(map[TopicPartition]*ListOffsets.PartitionResponse)
+		for _, tp := range byLeader[NoLeader] {
+			results[i][tp] = &ListOffsets.PartitionResponse{
+				Partition: tp.Partition,
+				ErrorCode: libkafka.ERR_LEADER_NOT_AVAILABLE,
+				Timestamp: -1,
+				Offset:    -1,
+			}
+		}
+	}
+	var firstErr error
+	for nodeId, led := range byLeader {
+		if nodeId == NoLeader {
+			continue
+		}
+		b := meta.Broker(nodeId)
+		if b == nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("leader %d: %w", nodeId, ErrBrokerDoesNotExist)
+			}
+			continue
+		}
+		if err := listOffsets(b, tlsConfig, sasl, led, timestampsMs, results); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return results, firstErr
+}
+
+func listOffsets(broker *Metadata.Broker, tlsConfig *tls.Config, sasl SASL, partitions []TopicPartition, timestampsMs []int64, results []map[TopicPartition]*ListOffsets.PartitionResponse) error {
+	conn, err := dial(broker.Addr(), tlsConfig, sasl)
+	if err != nil {
+		return fmt.Errorf("error connecting to broker %d (TLS: %v): %w", broker.NodeId, tlsConfig != nil, err)
+	}
+	defer conn.Close()
+	for i, ts := range timestampsMs {
+		args := make([]ListOffsets.PartitionArgs, len(partitions))
+		for j, tp := range partitions {
+			args[j] = ListOffsets.PartitionArgs{Topic: tp.Topic, Partition: tp.Partition, TimestampMs: ts}
+		}
+		resp := &ListOffsets.Response{}
+		if err := call(conn, ListOffsets.NewMultiplePartitionsRequest(args), resp); err != nil {
+			return fmt.Errorf("error listing offsets on broker %d: %w", broker.NodeId, err)
+		}
+		for _, t := range resp.Responses {
+			for j := range t.Partitions {
+				p := &(t.Partitions[j])
+				results[i][TopicPartition{Topic: t.Topic, Partition: p.Partition}] = p
+			}
+		}
+	}
+	return nil
+}
+
+// BrokerClient maintains a connection to a single broker, identified by its
+// node id. It is used for calls which combine multiple topic partitions led
+// by the same broker into a single request (such as multi partition Fetch and
+// Produce). The client uses the Bootstrap value to look up the address of the
+// broker. This happens on the first API call. Connection handling and error
+// semantics are the same as for the PartitionClient: on request-response
+// round trip error the connection is closed (and re-opened on next call),
+// error codes in Kafka responses are not interpreted. All BrokerClient calls
+// are safe for concurrent use.
+type BrokerClient struct {
+	sync.Mutex
+	Bootstrap string // srv or host:port
+	TLS       *tls.Config
+	SASL      SASL
+	ClientId  string
+	NodeId    int32
+	// ConnMaxIdle and HonorThrottle have the same meaning as in the
+	// PartitionClient.
+	ConnMaxIdle   time.Duration
+	HonorThrottle bool
+	broker        *Metadata.Broker
+	brokerConn
+}
+
+func (c *BrokerClient) connect() (err error) {
+	// no mutex here. connect() is called only from Call(), and that is
+	// where the mutex is acquired
+	if c.reuse(c.ConnMaxIdle) {
+		return nil
+	}
+	c.broker, err = GetBrokerSASL(c.Bootstrap, c.TLS, c.SASL, c.NodeId)
+	if err != nil {
+		return fmt.Errorf("error getting broker %d: %w", c.NodeId, err)
+	}
+	return c.open(c.broker.Addr(), c.TLS, c.SASL)
+}
+
+// Close the connection to the broker. Nop if no active connection. If there is
+// a request in progress blocks until the request completes.
+func (c *BrokerClient) Close() error { // implement io.Closer
+	c.Lock()
+	defer c.Unlock()
+	c.close()
+	return nil
+}
+
+// Broker returns the last resolved broker, even if connection has since been
+// closed (as happens on error).
+func (c *BrokerClient) Broker() *Metadata.Broker {
+	c.Lock()
+	defer c.Unlock()
+	return c.broker
+}
+
+// Conn returns the connection that the client has to the broker. Same caveats
+// as for PartitionClient.Conn apply.
+func (c *BrokerClient) Conn() net.Conn {
+	c.Lock()
+	defer c.Unlock()
+	return c.conn
+}
+
+// ThrottleStats returns statistics of broker throttling of the client.
+func (c *BrokerClient) ThrottleStats() ThrottleStats {
+	c.Lock()
+	defer c.Unlock()
+	return c.throttle.stats
+}
+
+// Call makes a request (connecting to the broker if necessary) and reads the
+// response. If there is error making the request or reading the response, it
+// disconnects. If the broker does not support the request api version, no
+// request is made and ERR_UNSUPPORTED_VERSION is returned. Response is not
+// interpreted. Same as GroupClient.Call, this is
+// intended for users who want to make their "own" requests.
+func (c *BrokerClient) Call(req *api.Request, respStructPtr interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+	if err := c.connect(); err != nil {
+		return fmt.Errorf("error connecting to broker %d (TLS: %v): %w", c.NodeId, c.TLS != nil, err)
+	}
+	if err := c.roundTrip(req, respStructPtr, c.HonorThrottle); err != nil {
+		return fmt.Errorf("error making call to broker %d (TLS: %v): %w", c.NodeId, c.TLS != nil, err)
+	}
+	return nil
+}
+
+func (c *BrokerClient) Fetch(args *Fetch.MultiplePartitionsArgs) (*Fetch.Response, error) {
+	req := Fetch.NewMultiplePartitionsRequest(args)
+	resp := &Fetch.Response{}
+	return resp, c.Call(req, resp)
+}
+
+func (c *BrokerClient) ListOffsets(partitions []ListOffsets.PartitionArgs) (*ListOffsets.Response, error) {
+	req := ListOffsets.NewMultiplePartitionsRequest(partitions)
+	resp := &ListOffsets.Response{}
+	return resp, c.Call(req, resp)
+}
+
+func (c *BrokerClient) Produce(args *Produce.MultiplePartitionsArgs) (*Produce.Response, error) {
+	req := Produce.NewMultiplePartitionsRequest(args)
+	resp := &Produce.Response{}
+	return resp, c.Call(req, resp)
+}
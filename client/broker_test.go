@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/mkocikowski/libkafka/api/Metadata"
+)
+
+func TestUnitGroupByLeader(t *testing.T) {
+	meta := &Metadata.Response{
+		Brokers: []Metadata.Broker{{NodeId: 1}, {NodeId: 2}},
+		TopicMetadata: []Metadata.TopicMetadata{
+			{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{
+				{Partition: 0, Leader: 1},
+				{Partition: 1, Leader: 2},
+				{Partition: 2, Leader: -1},
+			}},
+			{Topic: "bar", PartitionMetadata: []Metadata.PartitionMetadata{
+				{Partition: 0, Leader: 2},
+			}},
+		},
+	}
+	partitions := []TopicPartition{
+		{"foo", 0}, {"foo", 1}, {"foo", 2}, {"bar", 0}, {"bar", 1}, {"baz", 0},
+	}
+	groups := groupByLeader(meta, partitions)
+	if n := len(groups[1]); n != 1 {
+		t.Fatal(groups)
+	}
+	if g := groups[2]; len(g) != 2 || g[0] != (TopicPartition{"foo", 1}) || g[1] != (TopicPartition{"bar", 0}) {
+		t.Fatal(groups)
+	}
+	// no leader, partition does not exist, topic does not exist
+	if n := len(groups[NoLeader]); n != 3 {
+		t.Fatal(groups)
+	}
+}
+
+func TestUnitSortTopicPartitions(t *testing.T) {
+	partitions := []TopicPartition{{"foo", 1}, {"bar", 2}, {"foo", 0}}
+	SortTopicPartitions(partitions)
+	if partitions[0] != (TopicPartition{"bar", 2}) || partitions[2] != (TopicPartition{"foo", 1}) {
+		t.Fatal(partitions)
+	}
+}
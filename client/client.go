@@ -3,7 +3,8 @@
 // (producers and consumers are built on top of that) and the GroupClient which
 // maintains a connection to the group manager (for group membership and for
 // offset management).  Clients are synchronous and all code executes in the
-// calling goroutine.
+// calling goroutine. Connections are authenticated with SCRAM (with user
+// credentials, or with delegation tokens) when the client SASL is set.
 package client
 
 import (
@@ -88,15 +89,35 @@ func randomBroker(name string) (string, error) {
 	return addrs[0], nil
 }
 
-func connectToRandomBroker(bootstrap string, tlsConfig *tls.Config) (net.Conn, error) {
+func connectToRandomBroker(bootstrap string, tlsConfig *tls.Config, sasl SASL) (net.Conn, error) {
 	host, err := randomBroker(bootstrap)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get random broker: %w", err)
 	}
+	return dial(host, tlsConfig, sasl)
+}
+
+// dial connects to the broker, and authenticates the connection if sasl is
+// not nil.
+func dial(addr string, tlsConfig *tls.Config, sasl SASL) (conn net.Conn, err error) {
 	if tlsConfig != nil {
-		return tls.DialWithDialer(&net.Dialer{Timeout: libkafka.DialTimeout}, "tcp", host, tlsConfig)
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: libkafka.DialTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, libkafka.DialTimeout)
 	}
-	return net.DialTimeout("tcp", host, libkafka.DialTimeout)
+	if err != nil || sasl == nil {
+		return conn, err
+	}
+	creds, err := sasl()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error getting SASL credentials: %w", err)
+	}
+	if err := authenticate(conn, creds); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error authenticating with %s: %w", creds.Mechanism.Name, err)
+	}
+	return conn, nil
 }
 
 func call(conn net.Conn, req *api.Request, v interface{}) error {
@@ -116,7 +137,12 @@ func call(conn net.Conn, req *api.Request, v interface{}) error {
 	if err != nil {
 		return fmt.Errorf("error reading %T response: %w", req.Body, err)
 	}
-	if err := resp.Unmarshal(v); err != nil {
+	if api.Flexible(req.ApiKey, req.ApiVersion) {
+		err = resp.UnmarshalFlexible(v, req.ApiVersion)
+	} else {
+		err = resp.UnmarshalVersion(v, req.ApiVersion)
+	}
+	if err != nil {
 		return fmt.Errorf("error unmarshaling %T response: %w", req.Body, err)
 	}
 	return nil
@@ -127,14 +153,18 @@ func call(conn net.Conn, req *api.Request, v interface{}) error {
 // srv record, that record gets resolved and that resolved value is cached.
 // that cached value is cleared on call error (for example: srv record pointed
 // to a host that used to be a kafka broker but no longer is).
-func connectToRandomBrokerAndCall(bootstrap string, tlsConfig *tls.Config, req *api.Request, v interface{}) (err error) {
+func connectToRandomBrokerAndCall(bootstrap string, tlsConfig *tls.Config, req *api.Request, v interface{}) error {
+	return connectToRandomBrokerAndCallSASL(bootstrap, tlsConfig, nil, req, v)
+}
+
+func connectToRandomBrokerAndCallSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, req *api.Request, v interface{}) (err error) {
 	defer func() {
 		if err != nil {
 			forgetSrv(bootstrap)
 		}
 	}()
 	var conn net.Conn
-	if conn, err = connectToRandomBroker(bootstrap, tlsConfig); err != nil {
+	if conn, err = connectToRandomBroker(bootstrap, tlsConfig, sasl); err != nil {
 		return fmt.Errorf("error connecting to random broker (TLS: %v): %w", tlsConfig != nil, err)
 	}
 	defer conn.Close()
@@ -144,6 +174,20 @@ func connectToRandomBrokerAndCall(bootstrap string, tlsConfig *tls.Config, req *
 	return nil
 }
 
+// connectAndCall is for one-off calls to a specific broker (such as calls to
+// a group coordinator, or to every broker in the cluster).
+func connectAndCall(addr string, tlsConfig *tls.Config, sasl SASL, req *api.Request, v interface{}) error {
+	conn, err := dial(addr, tlsConfig, sasl)
+	if err != nil {
+		return fmt.Errorf("error connecting to broker %s (TLS: %v): %w", addr, tlsConfig != nil, err)
+	}
+	defer conn.Close()
+	if err := call(conn, req, v); err != nil {
+		return fmt.Errorf("error making call to broker %s (TLS: %v): %w", addr, tlsConfig != nil, err)
+	}
+	return nil
+}
+
 func CallApiVersions(bootstrap string, tlsConfig *tls.Config) (*ApiVersions.Response, error) {
 	req := ApiVersions.NewRequest()
 	resp := &ApiVersions.Response{}
@@ -156,12 +200,34 @@ func apiVersions(conn net.Conn) (*ApiVersions.Response, error) {
 	return resp, call(conn, req, resp)
 }
 
+// checkVersion returns ERR_UNSUPPORTED_VERSION if the broker (as described by
+// its ApiVersions response) does not support the request api version.
+func checkVersion(versions *ApiVersions.Response, req *api.Request) error {
+	if versions.Supports(req.ApiKey, req.ApiVersion) {
+		return nil
+	}
+	min, max := versions.Version(req.ApiKey)
+	return &libkafka.Error{
+		Code:    libkafka.ERR_UNSUPPORTED_VERSION,
+		Message: fmt.Sprintf("%s v%d (broker supports v%d-v%d)", api.Keys[int(req.ApiKey)], req.ApiVersion, min, max),
+	}
+}
+
 func CallMetadata(bootstrap string, tlsConfig *tls.Config, topics []string) (*Metadata.Response, error) {
+	return CallMetadataSASL(bootstrap, tlsConfig, nil, topics)
+}
+
+// CallMetadataSASL is CallMetadata on connections authenticated with sasl.
+func CallMetadataSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, topics []string) (*Metadata.Response, error) {
 	req := Metadata.NewRequest(topics)
 	resp := &Metadata.Response{}
-	return resp, connectToRandomBrokerAndCall(bootstrap, tlsConfig, req, resp)
+	return resp, connectToRandomBrokerAndCallSASL(bootstrap, tlsConfig, sasl, req, resp)
 }
 
+// CallCreateTopic creates a single topic with broker default configs. The
+// request goes to a random broker, which in older Kafka versions must be the
+// controller. See client/admin for creating multiple topics with configs and
+// replica assignments.
 func CallCreateTopic(bootstrap string, tlsConfig *tls.Config, topic string, numPartitions int32, replicationFactor int16) (*CreateTopics.Response, error) {
 	req := CreateTopics.NewRequest(topic, numPartitions, replicationFactor, []CreateTopics.Config{})
 	resp := &CreateTopics.Response{}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/ApiVersions"
+)
+
+// brokerConn is the connection handling shared by the PartitionClient and the
+// BrokerClient: a persistent connection to a single broker, closed on round
+// trip errors and re-opened when it exceeds libkafka.ConnectionTTL or the
+// client ConnMaxIdle, along with the broker api versions and the throttle
+// state. It has no mutex of its own: clients hold theirs when calling it.
+type brokerConn struct {
+	throttle     throttle
+	versions     *ApiVersions.Response
+	conn         net.Conn
+	connOpened   time.Time
+	connLastUsed time.Time
+}
+
+// reuse returns true if there is an open connection which can be used. if the
+// connection exceeded ConnectionTTL or maxIdle it is closed.
+func (c *brokerConn) reuse(maxIdle time.Duration) bool {
+	if c.conn == nil {
+		return false
+	}
+	switch {
+	case libkafka.ConnectionTTL > 0 && time.Since(c.connOpened) > libkafka.ConnectionTTL:
+		// connection exceeded TTL
+		c.close()
+	case maxIdle > 0 && time.Since(c.connLastUsed) > maxIdle:
+		// connection exceeded MaxIdle
+		c.close()
+	default:
+		// ConnTTL and ConnMaxIdle do not apply. Leave connection open
+		return true
+	}
+	return false
+}
+
+// open connection to the broker and get its api versions.
+func (c *brokerConn) open(addr string, tlsConfig *tls.Config, sasl SASL) (err error) {
+	conn, err := dial(addr, tlsConfig, sasl)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.connOpened = time.Now().UTC()
+	c.connLastUsed = c.connOpened
+	c.versions, err = apiVersions(c.conn)
+	if err != nil {
+		c.close()
+		return fmt.Errorf("error getting api versions from broker: %w", err)
+	}
+	if code := c.versions.ErrorCode; code != libkafka.ERR_NONE {
+		c.close()
+		return fmt.Errorf("error response for api versions call from broker: %w", libkafka.Error{Code: code})
+	}
+	return nil
+}
+
+func (c *brokerConn) close() {
+	if c.conn == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("recovered in brokerConn.close: %v", r)
+		}
+	}()
+	c.conn.Close()
+	c.conn = nil
+}
+
+// roundTrip makes the call on the open connection. if the broker does not
+// support the request api version no request is made and
+// ERR_UNSUPPORTED_VERSION is returned. on round trip error the connection is
+// closed.
+func (c *brokerConn) roundTrip(req *api.Request, v interface{}, honorThrottle bool) error {
+	// TODO: remove
+	if req.ApiKey == api.Produce && c.versions.ApiKeys[api.Produce].MaxVersion == 5 {
+		req.ApiVersion = 5 // downgrade to be able to produce to kafka 1.0
+	}
+	if err := checkVersion(c.versions, req); err != nil {
+		return err
+	}
+	c.throttle.wait()
+	err := call(c.conn, req, v)
+	if err != nil {
+		c.close()
+	} else {
+		c.throttle.update(req, v, honorThrottle)
+	}
+	c.connLastUsed = time.Now().UTC()
+	return err
+}
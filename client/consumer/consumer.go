@@ -0,0 +1,332 @@
+// Package consumer implements a single partition consumer on top of
+// fetcher.PartitionFetcher. Unlike the fetcher, the consumer manages offsets:
+// it advances its offset past the batches it returns, resets the offset when
+// it is out of range, handles corrupted batches, and (optionally) commits
+// offsets for a group. Use the fetcher directly if you need full control over
+// error handling and offsets.
+package consumer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/batch"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/client/fetcher"
+)
+
+// ResetPolicy tells the consumer what to do when its offset is out of range
+// (the partition leader responds with ERR_OFFSET_OUT_OF_RANGE), or when there
+// is no offset committed for the group.
+type ResetPolicy int
+
+const (
+	// ResetNone makes Consume return the error.
+	ResetNone ResetPolicy = iota
+	// ResetEarliest moves the offset to the log start offset.
+	ResetEarliest
+	// ResetLatest moves the offset to the log end offset.
+	ResetLatest
+)
+
+// CorruptedPolicy tells the consumer what to do when a fetched batch can not
+// be unmarshaled (for example because its crc does not match).
+type CorruptedPolicy int
+
+const (
+	// CorruptedReport makes Consume stop at the corrupted batch and return
+	// the batches before it along with CorruptedBatchError. The offset is
+	// not advanced past the corrupted batch, so the next call to Consume
+	// fetches it again: to skip it call SetOffset.
+	CorruptedReport CorruptedPolicy = iota
+	// CorruptedSkip makes Consume skip the corrupted batch and continue
+	// with the batches after it. Skipped batches are listed in the
+	// Response.
+	CorruptedSkip
+)
+
+// ErrNoOffset is returned by Consume when there is no offset committed for
+// the group and Reset is ResetNone.
+var ErrNoOffset = errors.New("no offset committed for the group")
+
+// CorruptedBatchError is returned by Consume when Corrupted is
+// CorruptedReport and a fetched batch can not be unmarshaled.
+type CorruptedBatchError struct {
+	// Offset of the corrupted batch (read from the batch header, which may
+	// itself be corrupted).
+	BaseOffset int64
+	Err        error
+}
+
+func (e *CorruptedBatchError) Error() string {
+	return fmt.Sprintf("corrupted batch at offset %d: %v", e.BaseOffset, e.Err)
+}
+
+func (e *CorruptedBatchError) Unwrap() error {
+	return e.Err
+}
+
+// SkippedBatch is a corrupted batch skipped by the consumer.
+type SkippedBatch struct {
+	BaseOffset int64
+	LastOffset int64
+	Err        error
+}
+
+type Response struct {
+	*fetcher.Response
+	// Batches successfully unmarshaled from the fetched record set. The
+	// first batch may start before the offset that was fetched (Kafka
+	// returns whole batches).
+	Batches []*batch.Batch
+	// Skipped corrupted batches (only with CorruptedSkip policy).
+	Skipped []*SkippedBatch
+	// Reset is true if the offset was out of range and was reset before
+	// the fetch.
+	Reset bool
+}
+
+// GroupMember commits offsets as a member of a group, with its member id and
+// generation (see group.Member.CommitOffsets, which implements it).
+type GroupMember interface {
+	CommitOffsets(offsets map[client.TopicPartition]client.OffsetMetadata) (map[client.TopicPartition]int16, error)
+}
+
+// PartitionConsumer consumes a single partition. Call Consume repeatedly to
+// get batches: each call fetches from the current offset (see
+// PartitionFetcher.Offset) and advances it to one past the last offset of
+// the last returned batch. Offset can be set explicitly with SetOffset and
+// Seek. If Group is set, on the first call to Consume the offset is set to
+// the offset committed for the group (overriding offset set with SetOffset or
+// Seek), and offsets are committed with Commit, and, if AutoCommitInterval is
+// set, by Consume. Do not call Fetch directly: it does not advance the
+// offset. Safe for concurrent use, but calls to Consume are serialized.
+type PartitionConsumer struct {
+	fetcher.PartitionFetcher
+	// Group to which offsets are committed and from which the initial
+	// offset is fetched. Nil means offsets are not committed. The consumer
+	// does not join the group: this is up to the user (see
+	// client/group).
+	Group *client.GroupClient
+	// Member, if set, is the member of the Group to which the partition
+	// is assigned. Offsets are then committed as the member, so that the
+	// coordinator rejects commits made after the partition was assigned
+	// to another member in a rebalance. Group must be set too.
+	Member GroupMember
+	// Reset policy applied when the offset is out of range, and when there
+	// is no offset committed for the Group.
+	Reset ResetPolicy
+	// Corrupted batches policy.
+	Corrupted CorruptedPolicy
+	// AutoCommitInterval, if greater than 0, makes Consume commit the
+	// offset to the Group if the time since the last commit is greater than
+	// the interval. Default (0) is to commit only when Commit is called.
+	AutoCommitInterval time.Duration
+	//
+	mu         sync.Mutex
+	started    bool
+	committed  int64
+	lastCommit time.Time
+}
+
+func (c *PartitionConsumer) reset() error {
+	switch c.Reset {
+	case ResetEarliest:
+		return c.Seek(fetcher.MessageOldest)
+	case ResetLatest:
+		return c.Seek(fetcher.MessageNewest)
+	}
+	return fmt.Errorf("no reset policy: %w", &libkafka.Error{Code: libkafka.ERR_OFFSET_OUT_OF_RANGE})
+}
+
+func (c *PartitionConsumer) start() error {
+	c.committed = -1
+	if c.Group == nil {
+		return nil
+	}
+	offset, err := c.Group.FetchOffset(c.Topic, c.Partition)
+	if err != nil {
+		return fmt.Errorf("error fetching committed offset: %w", err)
+	}
+	if offset >= 0 {
+		c.committed = offset
+		c.SetOffset(offset)
+		return nil
+	}
+	if c.Reset == ResetNone {
+		return ErrNoOffset
+	}
+	return c.reset()
+}
+
+// header reads base offset and last offset delta from batch bytes without
+// verifying the batch (it is used for batches which failed to unmarshal).
+func header(b []byte) (baseOffset int64, lastOffsetDelta int32, err error) {
+	r := bytes.NewReader(b)
+	if err = binary.Read(r, binary.BigEndian, &baseOffset); err != nil {
+		return
+	}
+	// batch length (4), partition leader epoch (4), magic (1), crc (4),
+	// attributes (2)
+	if _, err = r.Seek(15, 1); err != nil {
+		return
+	}
+	err = binary.Read(r, binary.BigEndian, &lastOffsetDelta)
+	return
+}
+
+// parse batches from the record set, and advance the offset past them (with
+// the leader epoch of the last batch, so that the fetcher can detect log
+// truncation). on CorruptedReport stops at the corrupted batch and returns the
+// error.
+func (c *PartitionConsumer) parse(resp *Response) error {
+	offset, epoch := c.Offset(), c.OffsetEpoch()
+	defer func() { c.SetOffsetEpoch(offset, epoch) }()
+	for _, b := range resp.RecordSet.Batches() {
+		parsed, err := batch.Unmarshal(b)
+		if err == nil {
+			resp.Batches = append(resp.Batches, parsed)
+			if last := parsed.LastOffset(); last >= offset {
+				offset = last + 1
+				epoch = parsed.PartitionLeaderEpoch
+			}
+			continue
+		}
+		baseOffset, lastOffsetDelta, headerErr := header(b)
+		if c.Corrupted == CorruptedReport || headerErr != nil {
+			return &CorruptedBatchError{BaseOffset: baseOffset, Err: err}
+		}
+		skipped := &SkippedBatch{
+			BaseOffset: baseOffset,
+			LastOffset: baseOffset + int64(lastOffsetDelta),
+			Err:        err,
+		}
+		resp.Skipped = append(resp.Skipped, skipped)
+		if skipped.LastOffset >= offset {
+			offset = skipped.LastOffset + 1
+			epoch = -1
+		}
+	}
+	return nil
+}
+
+// Consume fetches batches from the current offset and advances the offset
+// past them. If the offset is out of range, it is reset according to the
+// Reset policy and the fetch is retried once. Error codes other than
+// ERR_OFFSET_OUT_OF_RANGE are not handled: the response is returned and the
+// user should check its ErrorCode. If ValidateLeaderEpochs is set and the
+// partition log has been truncated below the offset (for example after an
+// unclean leader election) fetcher.TruncationError is returned, and the
+// offset is not changed. When the Corrupted policy is CorruptedReport and a
+// batch is corrupted, the response with the batches preceding the corrupted
+// one is returned along with CorruptedBatchError.
+// When auto commit fails the response is returned along with the error (the
+// offset has been advanced).
+func (c *PartitionConsumer) Consume() (*Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		if err := c.start(); err != nil {
+			return nil, err
+		}
+		c.started = true
+		c.lastCommit = time.Now()
+	}
+	fetched, err := c.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	resp := &Response{Response: fetched}
+	if fetched.ErrorCode == libkafka.ERR_OFFSET_OUT_OF_RANGE {
+		if err := c.reset(); err != nil {
+			return nil, err
+		}
+		if fetched, err = c.Fetch(); err != nil {
+			return nil, err
+		}
+		resp = &Response{Response: fetched, Reset: true}
+	}
+	if fetched.ErrorCode != libkafka.ERR_NONE {
+		return resp, nil
+	}
+	if err := c.parse(resp); err != nil {
+		return resp, err
+	}
+	if c.AutoCommitInterval > 0 && time.Since(c.lastCommit) > c.AutoCommitInterval {
+		if err := c.commit(); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (c *PartitionConsumer) commit() error {
+	offset := c.Offset()
+	if c.Group == nil || offset == c.committed {
+		return nil
+	}
+	if err := c.commitOffset(offset); err != nil {
+		return fmt.Errorf("error committing offset %d: %w", offset, err)
+	}
+	c.committed = offset
+	c.lastCommit = time.Now()
+	return nil
+}
+
+func (c *PartitionConsumer) commitOffset(offset int64) error {
+	if c.Member == nil {
+		return c.Group.CommitOffset(c.Topic, c.Partition, offset, -1)
+	}
+	tp := client.TopicPartition{Topic: c.Topic, Partition: c.Partition}
+	codes, err := c.Member.CommitOffsets(map[client.TopicPartition]client.OffsetMetadata{tp: {Offset: offset}})
+	if err != nil {
+		return err
+	}
+	if code := codes[tp]; code != libkafka.ERR_NONE {
+		return &libkafka.Error{Code: code}
+	}
+	return nil
+}
+
+// Commit the current offset to the Group. Nothing is committed if the Group is
+// nil or if the offset has not changed since the last commit.
+func (c *PartitionConsumer) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.commit()
+}
+
+// Committed returns the last offset committed (or fetched from the Group when
+// the consumer started) or -1.
+func (c *PartitionConsumer) Committed() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		return -1
+	}
+	return c.committed
+}
+
+// Close commits the offset (if AutoCommitInterval is set) and closes the
+// connections to the partition leader and to the Group coordinator. The
+// consumer can be used again after Close (connections are re-established).
+func (c *PartitionConsumer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.started && c.AutoCommitInterval > 0 {
+		err = c.commit()
+	}
+	if c.Group != nil {
+		c.Group.Close()
+	}
+	if closeErr := c.PartitionFetcher.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
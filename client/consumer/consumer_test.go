@@ -0,0 +1,301 @@
+package consumer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/api/FindCoordinator"
+	"github.com/mkocikowski/libkafka/api/ListOffsets"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetCommit"
+	"github.com/mkocikowski/libkafka/api/OffsetFetch"
+	"github.com/mkocikowski/libkafka/batch"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/client/fetcher"
+	"github.com/mkocikowski/libkafka/client/group"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// log with batches at offsets 0-1, 2-3 (corrupted), and 4
+func testLog(t *testing.T) []batch.RecordSet {
+	now := time.Now()
+	var log []batch.RecordSet
+	for i, values := range [][]string{{"a", "b"}, {"c", "d"}, {"e"}} {
+		b, err := batch.NewBuilder(now).AddStrings(values...).Build(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.BaseOffset = int64(2 * i)
+		rs := b.Marshal()
+		if i == 1 {
+			rs[len(rs)-1] ^= 0xff
+		}
+		log = append(log, rs)
+	}
+	return log
+}
+
+const logEndOffset = 5
+
+func startBroker(t *testing.T, committed int64) *fakebroker.Broker {
+	log := testLog(t)
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0, Leader: 1}}},
+				},
+			}
+		case api.Fetch:
+			r := &Fetch.Request{}
+			req.Unmarshal(r)
+			offset := r.Topics[0].Partitions[0].FetchOffset
+			p := Fetch.PartitionResponse{Partition: 0, HighWatermark: logEndOffset, RecordSet: []byte{}}
+			if offset < 0 || offset > logEndOffset {
+				p.ErrorCode = libkafka.ERR_OFFSET_OUT_OF_RANGE
+			}
+			for i, last := range []int64{1, 3, 4} {
+				if offset >= 0 && offset <= last {
+					p.RecordSet = append(p.RecordSet, log[i]...)
+				}
+			}
+			return &Fetch.Response{TopicResponses: []Fetch.TopicResponse{
+				{Topic: "foo", PartitionResponses: []Fetch.PartitionResponse{p}},
+			}}
+		case api.ListOffsets:
+			r := &ListOffsets.RequestBody{}
+			req.Unmarshal(r)
+			p := ListOffsets.PartitionResponse{Partition: 0}
+			if r.Topics[0].Partitions[0].Timestamp == ListOffsets.Latest {
+				p.Offset = logEndOffset
+			}
+			return &ListOffsets.Response{Responses: []ListOffsets.TopicResponse{
+				{Topic: "foo", Partitions: []ListOffsets.PartitionResponse{p}},
+			}}
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.OffsetFetch:
+			return &OffsetFetch.Response{Topics: []OffsetFetch.TopicResponse{
+				{Name: "foo", Partitions: []OffsetFetch.PartitionResponse{{PartitionIndex: 0, CommitedOffset: committed}}},
+			}}
+		case api.OffsetCommit:
+			return &OffsetCommit.Response{Topics: []OffsetCommit.TopicResponse{
+				{Name: "foo", Partitions: []OffsetCommit.PartitionResponse{{PartitionIndex: 0}}},
+			}}
+		}
+		return nil
+	})
+	return b
+}
+
+func commits(t *testing.T, b *fakebroker.Broker) []int64 {
+	var offsets []int64
+	for _, req := range b.Requests() {
+		if req.ApiKey != api.OffsetCommit {
+			continue
+		}
+		r := &OffsetCommit.Request{}
+		if err := req.Unmarshal(r); err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, r.Topics[0].Partitions[0].CommitedOffset)
+	}
+	return offsets
+}
+
+func TestUnitPartitionConsumerSkipAndCommit(t *testing.T) {
+	b := startBroker(t, -1)
+	defer b.Close()
+	c := &PartitionConsumer{
+		PartitionFetcher: fetcher.PartitionFetcher{
+			PartitionClient: client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		},
+		Group:              &client.GroupClient{Bootstrap: b.Addr(), GroupId: "test"},
+		Reset:              ResetEarliest,
+		Corrupted:          CorruptedSkip,
+		AutoCommitInterval: time.Nanosecond,
+	}
+	defer c.Close()
+	c.SetOffset(3) // overridden by reset, because nothing committed
+	resp, err := c.Consume()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.Batches); n != 2 || resp.Batches[1].BaseOffset != 4 {
+		t.Fatalf("%+v", resp)
+	}
+	if n := len(resp.Skipped); n != 1 {
+		t.Fatalf("%+v", resp)
+	}
+	if s := resp.Skipped[0]; s.BaseOffset != 2 || s.LastOffset != 3 || !errors.Is(s.Err, batch.CorruptedBatchError) {
+		t.Fatalf("%+v", s)
+	}
+	if c.Offset() != logEndOffset || c.Committed() != logEndOffset {
+		t.Fatal(c.Offset(), c.Committed())
+	}
+	if e := c.OffsetEpoch(); e != resp.Batches[1].PartitionLeaderEpoch {
+		t.Fatal(e)
+	}
+	if offsets := commits(t, b); len(offsets) != 1 || offsets[0] != logEndOffset {
+		t.Fatal(offsets)
+	}
+	// nothing new, offset does not change and nothing is committed
+	if resp, err = c.Consume(); err != nil || len(resp.Batches) != 0 {
+		t.Fatal(resp, err)
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if offsets := commits(t, b); len(offsets) != 1 {
+		t.Fatal(offsets)
+	}
+}
+
+func TestUnitPartitionConsumerCommitted(t *testing.T) {
+	b := startBroker(t, 4)
+	defer b.Close()
+	c := &PartitionConsumer{
+		PartitionFetcher: fetcher.PartitionFetcher{
+			PartitionClient: client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		},
+		Group: &client.GroupClient{Bootstrap: b.Addr(), GroupId: "test"},
+	}
+	defer c.Close()
+	resp, err := c.Consume()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.Batches); n != 1 || resp.Batches[0].BaseOffset != 4 {
+		t.Fatalf("%+v", resp)
+	}
+	if c.Committed() != 4 {
+		t.Fatal(c.Committed())
+	}
+	// no auto commit
+	if offsets := commits(t, b); len(offsets) != 0 {
+		t.Fatal(offsets)
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if offsets := commits(t, b); len(offsets) != 1 || offsets[0] != logEndOffset {
+		t.Fatal(offsets)
+	}
+}
+
+var _ GroupMember = (*group.Member)(nil)
+
+// testMember commits offsets as member "m1" of generation 3.
+type testMember struct {
+	*client.GroupClient
+}
+
+func (m *testMember) CommitOffsets(offsets map[client.TopicPartition]client.OffsetMetadata) (map[client.TopicPartition]int16, error) {
+	return m.GroupClient.CommitOffsets(&client.CommitOffsetsRequest{
+		MemberId:     "m1",
+		GenerationId: 3,
+		RetentionMs:  -1,
+		Offsets:      offsets,
+	})
+}
+
+func TestUnitPartitionConsumerCommitAsMember(t *testing.T) {
+	b := startBroker(t, 4)
+	defer b.Close()
+	g := &client.GroupClient{Bootstrap: b.Addr(), GroupId: "test"}
+	c := &PartitionConsumer{
+		PartitionFetcher: fetcher.PartitionFetcher{
+			PartitionClient: client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		},
+		Group:              g,
+		Member:             &testMember{g},
+		AutoCommitInterval: time.Nanosecond,
+	}
+	defer c.Close()
+	if _, err := c.Consume(); err != nil {
+		t.Fatal(err)
+	}
+	if offsets := commits(t, b); len(offsets) != 1 || offsets[0] != logEndOffset {
+		t.Fatal(offsets)
+	}
+	for _, req := range b.Requests() {
+		if req.ApiKey != api.OffsetCommit {
+			continue
+		}
+		r := &OffsetCommit.Request{}
+		req.Unmarshal(r)
+		if r.MemberId != "m1" || r.GenerationId != 3 {
+			t.Fatalf("%+v", r)
+		}
+	}
+}
+
+func TestUnitPartitionConsumerNoCommittedOffset(t *testing.T) {
+	b := startBroker(t, -1)
+	defer b.Close()
+	c := &PartitionConsumer{
+		PartitionFetcher: fetcher.PartitionFetcher{
+			PartitionClient: client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		},
+		Group: &client.GroupClient{Bootstrap: b.Addr(), GroupId: "test"},
+	}
+	defer c.Close()
+	if _, err := c.Consume(); err != ErrNoOffset {
+		t.Fatal(err)
+	}
+}
+
+func TestUnitPartitionConsumerReportAndReset(t *testing.T) {
+	b := startBroker(t, -1)
+	defer b.Close()
+	c := &PartitionConsumer{
+		PartitionFetcher: fetcher.PartitionFetcher{
+			PartitionClient: client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		},
+		Reset: ResetLatest,
+	}
+	defer c.Close()
+	c.SetOffset(1)
+	resp, err := c.Consume()
+	var e *CorruptedBatchError
+	if !errors.As(err, &e) || e.BaseOffset != 2 || !errors.Is(err, batch.CorruptedBatchError) {
+		t.Fatal(err)
+	}
+	// batch containing offset 1 is returned, and offset stops at the
+	// corrupted batch
+	if n := len(resp.Batches); n != 1 || resp.Batches[0].BaseOffset != 0 {
+		t.Fatalf("%+v", resp)
+	}
+	if c.Offset() != 2 {
+		t.Fatal(c.Offset())
+	}
+	if _, err = c.Consume(); !errors.As(err, &e) {
+		t.Fatal(err)
+	}
+	// out of range offset is reset to latest
+	c.SetOffset(100)
+	resp, err = c.Consume()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Reset || resp.ErrorCode != libkafka.ERR_NONE || len(resp.Batches) != 0 {
+		t.Fatalf("%+v", resp)
+	}
+	if c.Offset() != logEndOffset {
+		t.Fatal(c.Offset())
+	}
+	// no reset policy
+	c.Reset = ResetNone
+	c.SetOffset(100)
+	var k *libkafka.Error
+	if _, err = c.Consume(); !errors.As(err, &k) || k.Code != libkafka.ERR_OFFSET_OUT_OF_RANGE {
+		t.Fatal(err)
+	}
+}
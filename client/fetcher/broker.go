@@ -0,0 +1,405 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/batch"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// split multi partition response into per partition responses. unlike
+// parseResponse, any number of topic and partition responses is valid.
+func parseMultiplePartitionsResponse(r *Fetch.Response) []*Response {
+	var responses []*Response
+	for i := range r.TopicResponses {
+		t := &(r.TopicResponses[i])
+		for j := range t.PartitionResponses {
+			p := &(t.PartitionResponses[j])
+			responses = append(responses, &Response{
+				Topic:          t.Topic,
+				Partition:      p.Partition,
+				ThrottleTimeMs: r.ThrottleTimeMs,
+				ErrorCode:      p.ErrorCode,
+				LogStartOffset: p.LogStartOffset,
+				HighWatermark:  p.HighWatermark,
+				RecordSet:      batch.RecordSet(p.RecordSet),
+				// not present in response versions < 11
+				PreferredReadReplica: -1,
+			})
+		}
+	}
+	return responses
+}
+
+// BrokerFetcher fetches from multiple topic partitions led by the same broker
+// with a single Fetch request. Like the PartitionFetcher, it does no offset
+// management: offsets are set with SetOffset and are not advanced on fetch.
+// All partitions must be led by the broker identified by NodeId; partitions
+// which are not (for example because leadership changed) will come back with
+// ERR_NOT_LEADER_FOR_PARTITION in their responses. Use client.GroupByLeader to
+// group partitions, or use the MultiFetcher which does it for you.
+type BrokerFetcher struct {
+	sync.Mutex
+	client.BrokerClient
+	offsets map[client.TopicPartition]int64
+	// Same meaning as in PartitionFetcher. MaxBytes applies to the whole
+	// response.
+	MinBytes      int32
+	MaxBytes      int32
+	MaxWaitTimeMs int32
+	// PartitionMaxBytes is the max number of bytes returned for any one
+	// partition. If 0, MaxBytes is used.
+	PartitionMaxBytes int32
+	// If Session is not nil, fetches are made within an incremental fetch
+	// session (KIP-227; requires Kafka 1.1+). Responses then include only
+	// partitions for which something changed (see Session).
+	Session *Session
+}
+
+// SetOffset adds the topic partition to the fetcher (if it is not there
+// already) and sets the offset from which it will be fetched.
+func (c *BrokerFetcher) SetOffset(topic string, partition int32, offset int64) {
+	c.Lock()
+	defer c.Unlock()
+	if c.offsets == nil {
+		c.offsets = make(map[client.TopicPartition]int64)
+	}
+	c.offsets[client.TopicPartition{Topic: topic, Partition: partition}] = offset
+}
+
+// Offset returns the fetch offset for the topic partition, or -1 if the topic
+// partition has not been added to the fetcher.
+func (c *BrokerFetcher) Offset(topic string, partition int32) int64 {
+	c.Lock()
+	defer c.Unlock()
+	if o, ok := c.offsets[client.TopicPartition{Topic: topic, Partition: partition}]; ok {
+		return o
+	}
+	return -1
+}
+
+// Remove the topic partition from the fetcher. Nop if it is not there.
+func (c *BrokerFetcher) Remove(topic string, partition int32) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.offsets, client.TopicPartition{Topic: topic, Partition: partition})
+}
+
+// Partitions returns sorted topic partitions that have been added to the
+// fetcher.
+func (c *BrokerFetcher) Partitions() []client.TopicPartition {
+	c.Lock()
+	defer c.Unlock()
+	return c.partitions()
+}
+
+func (c *BrokerFetcher) partitions() []client.TopicPartition {
+	partitions := make([]client.TopicPartition, 0, len(c.offsets))
+	for tp := range c.offsets {
+		partitions = append(partitions, tp)
+	}
+	client.SortTopicPartitions(partitions)
+	return partitions
+}
+
+// Fetch all the partitions that have been added to the fetcher with a single
+// Fetch request and return one Response per partition. Each partition response
+// has its own ErrorCode, and these must be checked individually. Error is
+// returned only if the request-response round trip could not be completed (in
+// which case the connection to the broker is closed). If no partitions have
+// been added, no request is made and no responses are returned. When fetching
+// within a fetch Session only partitions for which something changed are
+// returned, and session level error codes (other than those from which the
+// session recovers by itself) are returned as errors wrapping *libkafka.Error.
+func (c *BrokerFetcher) Fetch() ([]*Response, error) {
+	c.Lock()
+	defer c.Unlock()
+	partitionMaxBytes := c.PartitionMaxBytes
+	if partitionMaxBytes == 0 {
+		partitionMaxBytes = c.MaxBytes
+	}
+	args := &Fetch.MultiplePartitionsArgs{
+		ClientId:      c.ClientId,
+		MinBytes:      c.MinBytes,
+		MaxBytes:      c.MaxBytes,
+		MaxWaitTimeMs: c.MaxWaitTimeMs,
+	}
+	for _, tp := range c.partitions() {
+		args.Partitions = append(args.Partitions, Fetch.PartitionArgs{
+			Topic:     tp.Topic,
+			Partition: tp.Partition,
+			Offset:    c.offsets[tp],
+			MaxBytes:  partitionMaxBytes,
+		})
+	}
+	if len(args.Partitions) == 0 {
+		return nil, nil
+	}
+	var resp *Fetch.Response
+	var err error
+	if c.Session != nil {
+		resp, err = c.fetchInSession(args)
+	} else {
+		resp, err = c.BrokerClient.Fetch(args)
+	}
+	if err != nil {
+		if broker := c.Broker(); broker != nil {
+			err = fmt.Errorf("error calling %+v: %w", broker, err)
+		}
+		return nil, err
+	}
+	broker := c.Broker()
+	responses := parseMultiplePartitionsResponse(resp)
+	for _, r := range responses {
+		r.Broker = broker
+	}
+	return responses, nil
+}
+
+// make the fetch call within the fetch session. if the broker rejects the
+// session (because it doesn't know it or because of wrong epoch) reset the
+// session and retry, once, with a full request.
+func (c *BrokerFetcher) fetchInSession(args *Fetch.MultiplePartitionsArgs) (*Fetch.Response, error) {
+	for retried := false; ; retried = true {
+		req := c.Session.request(args)
+		resp := &Fetch.Response{}
+		if err := c.BrokerClient.Call(req, resp); err != nil {
+			c.Session.Reset()
+			return nil, err
+		}
+		err := c.Session.update(resp)
+		if err == nil {
+			return resp, nil
+		}
+		if !isSessionError(resp.ErrorCode) || retried {
+			return nil, fmt.Errorf("error response for fetch session: %w", err)
+		}
+	}
+}
+
+// partition responses with these error codes indicate that metadata needs to
+// be refreshed and partitions re-grouped by leader
+func isStaleLeader(code int16) bool {
+	switch code {
+	case libkafka.ERR_UNKNOWN_TOPIC_OR_PARTITION,
+		libkafka.ERR_LEADER_NOT_AVAILABLE,
+		libkafka.ERR_NOT_LEADER_FOR_PARTITION,
+		libkafka.ERR_KAFKA_STORAGE_ERROR:
+		return true
+	}
+	return false
+}
+
+// MultiFetcher fetches from many topic partitions, making one Fetch request
+// per partition leader (it maintains a BrokerFetcher for every leader). The
+// partitions are grouped by leader on first Fetch, and then again whenever a
+// broker call fails or any partition response has an error code indicating
+// that the leader has changed. Like the other fetchers it does not advance
+// offsets. Brokers are called one after the other, in the calling goroutine,
+// so a single Fetch call can take up to MaxWaitTimeMs times the number of
+// brokers. If this is a problem, group partitions yourself with
+// client.GroupByLeader and run a BrokerFetcher per broker in its own
+// goroutine.
+type MultiFetcher struct {
+	sync.Mutex
+	Bootstrap   string // srv or host:port
+	TLS         *tls.Config
+	SASL        client.SASL
+	ClientId    string
+	ConnMaxIdle time.Duration
+	// HonorThrottle is passed on to the per-broker fetchers; see
+	// client.PartitionClient for details.
+	HonorThrottle bool
+	// Same meaning as in the BrokerFetcher. They apply to every one of the
+	// per-broker requests.
+	MinBytes          int32
+	MaxBytes          int32
+	MaxWaitTimeMs     int32
+	PartitionMaxBytes int32
+	// If Sessions is true, each of the per-broker fetchers uses an
+	// incremental fetch session. Set it before the first Fetch.
+	Sessions bool
+	offsets  map[client.TopicPartition]int64
+	leaders  map[client.TopicPartition]int32
+	fetchers map[int32]*BrokerFetcher
+	stale    bool
+}
+
+// SetOffset adds the topic partition to the fetcher (if it is not there
+// already) and sets the offset from which it will be fetched.
+func (c *MultiFetcher) SetOffset(topic string, partition int32, offset int64) {
+	c.Lock()
+	defer c.Unlock()
+	tp := client.TopicPartition{Topic: topic, Partition: partition}
+	if c.offsets == nil {
+		c.offsets = make(map[client.TopicPartition]int64)
+	}
+	c.offsets[tp] = offset
+	if id, ok := c.leaders[tp]; ok {
+		c.fetchers[id].SetOffset(topic, partition, offset)
+		return
+	}
+	c.stale = true
+}
+
+// Offset returns the fetch offset for the topic partition, or -1 if the topic
+// partition has not been added to the fetcher.
+func (c *MultiFetcher) Offset(topic string, partition int32) int64 {
+	c.Lock()
+	defer c.Unlock()
+	if o, ok := c.offsets[client.TopicPartition{Topic: topic, Partition: partition}]; ok {
+		return o
+	}
+	return -1
+}
+
+// Remove the topic partition from the fetcher. Nop if it is not there.
+func (c *MultiFetcher) Remove(topic string, partition int32) {
+	c.Lock()
+	defer c.Unlock()
+	tp := client.TopicPartition{Topic: topic, Partition: partition}
+	delete(c.offsets, tp)
+	if id, ok := c.leaders[tp]; ok {
+		c.fetchers[id].Remove(topic, partition)
+		delete(c.leaders, tp)
+	}
+}
+
+// Leaders returns the node id of the current leader for every partition, as of
+// the last time the partitions were grouped.
+func (c *MultiFetcher) Leaders() map[client.TopicPartition]int32 {
+	c.Lock()
+	defer c.Unlock()
+	leaders := make(map[client.TopicPartition]int32, len(c.leaders))
+	for tp, id := range c.leaders {
+		leaders[tp] = id
+	}
+	return leaders
+}
+
+// Close connections to all brokers.
+func (c *MultiFetcher) Close() error { // implement io.Closer
+	c.Lock()
+	defer c.Unlock()
+	for _, f := range c.fetchers {
+		f.Close()
+	}
+	return nil
+}
+
+func (c *MultiFetcher) regroup() error {
+	partitions := make([]client.TopicPartition, 0, len(c.offsets))
+	for tp := range c.offsets {
+		partitions = append(partitions, tp)
+	}
+	groups, _, err := client.GroupByLeaderSASL(c.Bootstrap, c.TLS, c.SASL, partitions)
+	if err != nil {
+		return fmt.Errorf("error grouping partitions by leader: %w", err)
+	}
+	fetchers := make(map[int32]*BrokerFetcher)
+	c.leaders = make(map[client.TopicPartition]int32)
+	for id, group := range groups {
+		if id == client.NoLeader {
+			continue
+		}
+		f := c.fetchers[id]
+		if f == nil {
+			f = &BrokerFetcher{
+				BrokerClient: client.BrokerClient{
+					Bootstrap:     c.Bootstrap,
+					TLS:           c.TLS,
+					SASL:          c.SASL,
+					ClientId:      c.ClientId,
+					NodeId:        id,
+					ConnMaxIdle:   c.ConnMaxIdle,
+					HonorThrottle: c.HonorThrottle,
+				},
+			}
+			if c.Sessions {
+				f.Session = &Session{}
+			}
+		}
+		f.offsets = make(map[client.TopicPartition]int64)
+		for _, tp := range group {
+			f.offsets[tp] = c.offsets[tp]
+			c.leaders[tp] = id
+		}
+		fetchers[id] = f
+	}
+	for id, f := range c.fetchers {
+		if _, ok := fetchers[id]; !ok {
+			f.Close() // broker no longer leads any of the partitions
+		}
+	}
+	c.fetchers = fetchers
+	c.stale = false
+	return nil
+}
+
+// Fetch all partitions, making one request per partition leader, and return
+// one Response per partition. Partition errors are independent of one another:
+// check ErrorCode of each Response. Partitions for which there is currently no
+// leader get a Response with ErrorCode set to ERR_LEADER_NOT_AVAILABLE (and no
+// Broker). If calls to some brokers fail, responses from the other brokers are
+// still returned, together with an error describing the failure. Error with no
+// responses is returned only when partitions could not be grouped by leader.
+func (c *MultiFetcher) Fetch() ([]*Response, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.stale || c.fetchers == nil {
+		if err := c.regroup(); err != nil {
+			return nil, err
+		}
+	}
+	ids := make([]int, 0, len(c.fetchers))
+	for id := range c.fetchers {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	var responses []*Response
+	var errs []error
+	for _, id := range ids {
+		f := c.fetchers[int32(id)]
+		f.MinBytes = c.MinBytes
+		f.MaxBytes = c.MaxBytes
+		f.MaxWaitTimeMs = c.MaxWaitTimeMs
+		f.PartitionMaxBytes = c.PartitionMaxBytes
+		resp, err := f.Fetch()
+		if err != nil {
+			errs = append(errs, err)
+			c.stale = true
+			continue
+		}
+		for _, r := range resp {
+			if isStaleLeader(r.ErrorCode) {
+				c.stale = true
+			}
+		}
+		responses = append(responses, resp...)
+	}
+	var orphans []client.TopicPartition
+	for tp := range c.offsets {
+		if _, ok := c.leaders[tp]; !ok {
+			orphans = append(orphans, tp)
+		}
+	}
+	client.SortTopicPartitions(orphans)
+	for _, tp := range orphans {
+		responses = append(responses, &Response{
+			Topic:     tp.Topic,
+			Partition: tp.Partition,
+			ErrorCode: libkafka.ERR_LEADER_NOT_AVAILABLE,
+		})
+		c.stale = true
+	}
+	if len(errs) > 0 {
+		return responses, fmt.Errorf("error fetching from %d of %d brokers: %w", len(errs), len(ids), errs[0])
+	}
+	return responses, nil
+}
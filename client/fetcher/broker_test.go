@@ -0,0 +1,212 @@
+package fetcher
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/client/producer"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+func TestUnitParseMultiplePartitionsResponse(t *testing.T) {
+	r := &Fetch.Response{
+		ThrottleTimeMs: 10,
+		TopicResponses: []Fetch.TopicResponse{
+			{Topic: "foo", PartitionResponses: []Fetch.PartitionResponse{
+				{Partition: 0, HighWatermark: 5},
+				{Partition: 1, ErrorCode: libkafka.ERR_NOT_LEADER_FOR_PARTITION},
+			}},
+			{Topic: "bar", PartitionResponses: []Fetch.PartitionResponse{
+				{Partition: 2, RecordSet: []byte{1, 2, 3}},
+			}},
+		},
+	}
+	responses := parseMultiplePartitionsResponse(r)
+	if n := len(responses); n != 3 {
+		t.Fatal(n)
+	}
+	if r := responses[0]; r.Topic != "foo" || r.Partition != 0 || r.HighWatermark != 5 || r.ThrottleTimeMs != 10 {
+		t.Fatalf("%+v", r)
+	}
+	if r := responses[1]; r.ErrorCode != libkafka.ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("%+v", r)
+	}
+	if r := responses[2]; r.Topic != "bar" || len(r.RecordSet) != 3 {
+		t.Fatalf("%+v", r)
+	}
+}
+
+// fetch handler which responds to every requested partition. partitions of
+// topic "moved" respond with NOT_LEADER_FOR_PARTITION.
+func fetchHandler(req *fakebroker.Request) interface{} {
+	r := &Fetch.Request{}
+	if err := req.Unmarshal(r); err != nil {
+		return nil
+	}
+	resp := &Fetch.Response{}
+	for _, t := range r.Topics {
+		tr := Fetch.TopicResponse{Topic: t.Topic}
+		for _, p := range t.Partitions {
+			pr := Fetch.PartitionResponse{
+				Partition:     p.Partition,
+				HighWatermark: p.FetchOffset + 1,
+				RecordSet:     []byte{},
+			}
+			if t.Topic == "moved" {
+				pr.ErrorCode = libkafka.ERR_NOT_LEADER_FOR_PARTITION
+			}
+			tr.PartitionResponses = append(tr.PartitionResponses, pr)
+		}
+		resp.TopicResponses = append(resp.TopicResponses, tr)
+	}
+	return resp
+}
+
+func TestUnitMultiFetcher(t *testing.T) {
+	b1, _ := fakebroker.Start(1, fetchHandler)
+	defer b1.Close()
+	b2, _ := fakebroker.Start(2, fetchHandler)
+	defer b2.Close()
+	meta := &Metadata.Response{
+		Brokers: []Metadata.Broker{
+			{NodeId: 1, Host: b1.Host(), Port: b1.Port()},
+			{NodeId: 2, Host: b2.Host(), Port: b2.Port()},
+		},
+		TopicMetadata: []Metadata.TopicMetadata{
+			{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{
+				{Partition: 0, Leader: 1},
+				{Partition: 1, Leader: 2},
+				{Partition: 2, Leader: -1},
+			}},
+			{Topic: "moved", PartitionMetadata: []Metadata.PartitionMetadata{
+				{Partition: 0, Leader: 2},
+			}},
+		},
+	}
+	bootstrap, _ := fakebroker.Start(0, func(req *fakebroker.Request) interface{} {
+		if req.ApiKey != api.Metadata {
+			return nil
+		}
+		return meta
+	})
+	defer bootstrap.Close()
+	c := &MultiFetcher{Bootstrap: bootstrap.Addr(), MaxBytes: 1 << 20}
+	defer c.Close()
+	c.SetOffset("foo", 0, 10)
+	c.SetOffset("foo", 1, 20)
+	c.SetOffset("foo", 2, 30)
+	c.SetOffset("moved", 0, 40)
+	responses, err := c.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(responses); n != 4 {
+		t.Fatal(n)
+	}
+	got := make(map[string]*Response)
+	for _, r := range responses {
+		got[client.TopicPartition{Topic: r.Topic, Partition: r.Partition}.String()] = r
+	}
+	if r := got["foo:0"]; r.HighWatermark != 11 || r.Broker.NodeId != 1 {
+		t.Fatalf("%+v", r)
+	}
+	if r := got["foo:1"]; r.HighWatermark != 21 || r.Broker.NodeId != 2 {
+		t.Fatalf("%+v", r)
+	}
+	if r := got["foo:2"]; r.ErrorCode != libkafka.ERR_LEADER_NOT_AVAILABLE || r.Broker != nil {
+		t.Fatalf("%+v", r)
+	}
+	if r := got["moved:0"]; r.ErrorCode != libkafka.ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("%+v", r)
+	}
+	// one request per broker
+	if n := len(b1.Requests()); n != 1 {
+		t.Fatal(n)
+	}
+	if n := len(b2.Requests()); n != 1 {
+		t.Fatal(n)
+	}
+	r := &Fetch.Request{}
+	b2.Requests()[0].Unmarshal(r)
+	if n := len(r.Topics); n != 2 {
+		t.Fatalf("%+v", r)
+	}
+	// partition moves to broker 1. errors in previous fetch cause regroup
+	meta.TopicMetadata[1].PartitionMetadata[0].Leader = 1
+	if _, err := c.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if id := c.Leaders()[client.TopicPartition{Topic: "moved", Partition: 0}]; id != 1 {
+		t.Fatal(id)
+	}
+	// broker 2 goes away. responses from broker 1 are still returned
+	b2.Close()
+	responses, err = c.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 brokers") {
+		t.Fatal(err)
+	}
+	if n := len(responses); n != 3 {
+		t.Fatal(n)
+	}
+}
+
+func TestIntegrationMultiFetcher(t *testing.T) {
+	bootstrap := "localhost:9092"
+	topic := fmt.Sprintf("test-%x", rand.Uint32())
+	if _, err := client.CallCreateTopic(bootstrap, nil, topic, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	for partition := int32(0); partition < 3; partition++ {
+		p := &producer.PartitionProducer{
+			PartitionClient: client.PartitionClient{
+				Bootstrap: bootstrap,
+				Topic:     topic,
+				Partition: partition,
+			},
+			Acks:      1,
+			TimeoutMs: 1000,
+		}
+		if _, err := p.ProduceStrings(time.Now(), "foo", "bar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &MultiFetcher{
+		Bootstrap:     bootstrap,
+		MinBytes:      1,
+		MaxBytes:      10 << 20,
+		MaxWaitTimeMs: 1000,
+	}
+	for partition := int32(0); partition < 3; partition++ {
+		c.SetOffset(topic, partition, 0)
+	}
+	c.SetOffset(topic, 3, 0) // does not exist
+	responses, err := c.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(responses); n != 4 {
+		t.Fatal(n)
+	}
+	for _, r := range responses {
+		if r.Partition == 3 {
+			if r.ErrorCode != libkafka.ERR_LEADER_NOT_AVAILABLE {
+				t.Fatalf("%+v", r)
+			}
+			continue
+		}
+		if r.ErrorCode != libkafka.ERR_NONE || r.HighWatermark != 2 {
+			t.Fatalf("%+v", r)
+		}
+		if n := len(r.RecordSet.Batches()); n != 1 {
+			t.Fatalf("%+v", r)
+		}
+	}
+}
@@ -1,14 +1,16 @@
-// Package fetcher implements a single partition Kafka fetcher. A "fetcher", in
-// my nomenclature, is different from a "consumer" in that it does no offset
-// management of its own: it doesn't even advance the offset on successfully
-// reading a fetch response. The reason for this is that there are many nuanced
-// error scenarios (example: fetch response successful; 3rd out of 5 returned
-// batches is corrupted) and so it makes sense to push the error handling logic
-// (and the logic responsible for advancing and storing offsets) to a higher
-// level library or even to the user.
+// Package fetcher implements single partition and multi partition Kafka
+// fetchers. A "fetcher", in my nomenclature, is different from a "consumer" in
+// that it does no offset management of its own: it doesn't even advance the
+// offset on successfully reading a fetch response. The reason for this is
+// that there are many nuanced error scenarios (example: fetch response
+// successful; 3rd out of 5 returned batches is corrupted) and so it makes
+// sense to push the error handling logic (and the logic responsible for
+// advancing and storing offsets) to a higher level library (see
+// client/consumer) or even to the user.
 package fetcher
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/mkocikowski/libkafka"
 	"github.com/mkocikowski/libkafka/api/Fetch"
 	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetForLeaderEpoch"
 	"github.com/mkocikowski/libkafka/batch"
 	"github.com/mkocikowski/libkafka/client"
 )
@@ -30,13 +33,14 @@ func parseResponse(r *Fetch.Response) (*Response, error) {
 	}
 	partitionResponse := &(topicResponse.PartitionResponses[0])
 	return &Response{
-		Topic:          topicResponse.Topic,
-		Partition:      partitionResponse.Partition,
-		ThrottleTimeMs: r.ThrottleTimeMs,
-		ErrorCode:      partitionResponse.ErrorCode,
-		LogStartOffset: partitionResponse.LogStartOffset,
-		HighWatermark:  partitionResponse.HighWatermark,
-		RecordSet:      batch.RecordSet(partitionResponse.RecordSet),
+		Topic:                topicResponse.Topic,
+		Partition:            partitionResponse.Partition,
+		ThrottleTimeMs:       r.ThrottleTimeMs,
+		ErrorCode:            partitionResponse.ErrorCode,
+		LogStartOffset:       partitionResponse.LogStartOffset,
+		HighWatermark:        partitionResponse.HighWatermark,
+		RecordSet:            batch.RecordSet(partitionResponse.RecordSet),
+		PreferredReadReplica: partitionResponse.PreferredReadReplica,
 	}, nil
 }
 
@@ -48,7 +52,10 @@ type Response struct {
 	ErrorCode      int16
 	LogStartOffset int64
 	HighWatermark  int64
-	RecordSet      batch.RecordSet `json:"-"`
+	// If the fetcher has RackId set, and the leader has a replica in the
+	// same rack, this is its node id. Otherwise -1.
+	PreferredReadReplica int32
+	RecordSet            batch.RecordSet `json:"-"`
 }
 
 type PartitionFetcher struct {
@@ -65,8 +72,61 @@ type PartitionFetcher struct {
 	// the fetch request if there isn't sufficient data to immediately
 	// satisfy the requirement given by MinBytes. Keep it < libkafka.RequestTimeout.
 	MaxWaitTimeMs int32
+	// RackId of the fetcher (KIP-392, Kafka 2.4+). If set, and the
+	// partition leader has a replica in the same rack (brokers configured
+	// with broker.rack and replica.selector.class), fetches are made from
+	// that replica instead of from the leader. Any error fetching from the
+	// replica (connection error or error code in the response) makes the
+	// fetcher fall back to the leader, and fetch from the leader without
+	// the RackId for PreferredReplicaMaxAge.
+	RackId        string
+	replica       *client.BrokerClient
+	replicaSince  time.Time
+	replicaFailed time.Time
+	// If ValidateLeaderEpochs is set (Kafka 2.1+, KIP-320) fetch requests
+	// have the current leader epoch of the partition, and the offset is
+	// validated against the leader log (see SetOffsetEpoch). The leader
+	// epoch is looked up with a Metadata call on the first fetch and after
+	// leader changes.
+	ValidateLeaderEpochs bool
+	// leader epochs (KIP-320). leaderEpoch is the current leader epoch of
+	// the partition, looked up (and the offset validated) when not set.
+	// offsetEpoch is the leader epoch of the record before the offset.
+	leaderEpoch    int32
+	leaderEpochSet bool
+	noLeaderEpochs bool // leader older than Kafka 2.1
+	offsetEpoch    int32
+	offsetEpochSet bool
 }
 
+// TruncationError is returned by PartitionFetcher.Fetch when the log of the
+// partition leader has been truncated below the fetcher offset (KIP-320),
+// typically after an unclean leader election: records from DivergentOffset
+// up to Offset, which had been fetched before, are not in the leader log (and
+// different records may be written at these offsets). The fetcher keeps
+// returning the error until its offset is set.
+type TruncationError struct {
+	Offset int64
+	// OffsetEpoch is the leader epoch of the record before Offset.
+	OffsetEpoch int32
+	// DivergentOffset is the end offset, in the leader log, of LeaderEpoch:
+	// the largest epoch not greater than OffsetEpoch.
+	DivergentOffset int64
+	LeaderEpoch     int32
+}
+
+func (e *TruncationError) Error() string {
+	return fmt.Sprintf("log truncated: offset %d (leader epoch %d) is past end offset %d of leader epoch %d", e.Offset, e.OffsetEpoch, e.DivergentOffset, e.LeaderEpoch)
+}
+
+// PreferredReplicaMaxAge is how long a PartitionFetcher fetches from the
+// preferred read replica before going back to the leader to check if the
+// preferred replica changed. It is also how long, after an error fetching from
+// the preferred replica, the fetcher fetches only from the leader. The default
+// is the same as metadata.max.age.ms in the Java client. Not safe to change
+// concurrently with fetches.
+var PreferredReplicaMaxAge = 5 * time.Minute
+
 var (
 	MessageNewest = time.Unix(0, -1e6)
 	MessageOldest = time.Unix(0, -2e6)
@@ -88,6 +148,7 @@ func (c *PartitionFetcher) Seek(target time.Time) error {
 		return &libkafka.Error{Code: p.ErrorCode}
 	}
 	c.offset = p.Offset
+	c.offsetEpochSet = false
 	return nil
 }
 
@@ -97,39 +158,232 @@ func (c *PartitionFetcher) Offset() int64 {
 	return c.offset
 }
 
+// SetOffset sets the offset, with unknown leader epoch (the offset is not
+// validated, see SetOffsetEpoch).
 func (c *PartitionFetcher) SetOffset(offset int64) {
 	c.Lock()
 	c.offset = offset
+	c.offsetEpochSet = false
 	c.Unlock()
 }
 
+// SetOffsetEpoch sets the offset, and the leader epoch of the record before it
+// (batch.Batch.PartitionLeaderEpoch of the last fetched batch, or -1 if
+// unknown). When ValidateLeaderEpochs is set and the leader epoch of the
+// offset is known, Fetch validates the offset, with an OffsetForLeaderEpoch call, every time it looks up the
+// current leader epoch of the partition (on the first fetch, and after fetch
+// errors such as ERR_FENCED_LEADER_EPOCH after a leader change), and returns
+// TruncationError if the leader log has been truncated below the offset.
+func (c *PartitionFetcher) SetOffsetEpoch(offset int64, leaderEpoch int32) {
+	c.Lock()
+	c.offset = offset
+	c.offsetEpoch = leaderEpoch
+	c.offsetEpochSet = leaderEpoch >= 0
+	c.Unlock()
+}
+
+// OffsetEpoch returns the leader epoch of the record before the offset, or -1
+// if unknown.
+func (c *PartitionFetcher) OffsetEpoch() int32 {
+	c.Lock()
+	defer c.Unlock()
+	if !c.offsetEpochSet {
+		return -1
+	}
+	return c.offsetEpoch
+}
+
+// validate looks up the current leader epoch of the partition, if it is not
+// set, and validates the offset.
+func (c *PartitionFetcher) validate() error {
+	if !c.ValidateLeaderEpochs || c.noLeaderEpochs || c.leaderEpochSet {
+		return nil
+	}
+	epoch, err := c.PartitionClient.LeaderEpoch()
+	var e *libkafka.Error
+	if errors.As(err, &e) && e.Code == libkafka.ERR_UNSUPPORTED_VERSION {
+		c.noLeaderEpochs = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting leader epoch: %w", err)
+	}
+	if !c.offsetEpochSet || epoch < 0 {
+		c.leaderEpoch, c.leaderEpochSet = epoch, true
+		return nil
+	}
+	resp, err := c.PartitionClient.OffsetForLeaderEpoch(epoch, c.offsetEpoch)
+	if err != nil {
+		return fmt.Errorf("error validating offset: %w", err)
+	}
+	p := resp.PartitionResponse()
+	if p == nil {
+		return fmt.Errorf("unexpected offset for leader epoch response: %+v", resp)
+	}
+	if p.ErrorCode != libkafka.ERR_NONE {
+		return fmt.Errorf("error validating offset: %w", &libkafka.Error{Code: p.ErrorCode})
+	}
+	if p.EndOffset != OffsetForLeaderEpoch.UndefinedOffset && p.EndOffset < c.offset {
+		return &TruncationError{
+			Offset:          c.offset,
+			OffsetEpoch:     c.offsetEpoch,
+			DivergentOffset: p.EndOffset,
+			LeaderEpoch:     p.LeaderEpoch,
+		}
+	}
+	c.leaderEpoch, c.leaderEpochSet = epoch, true
+	return nil
+}
+
 func fetch(c *client.PartitionClient, args *Fetch.Args) (*Response, error) {
 	resp, err := c.Fetch(args)
 	if err != nil {
 		return nil, err
 	}
-	return parseResponse(resp)
+	r, err := parseResponse(resp)
+	if err == nil && args.RackId == "" {
+		r.PreferredReadReplica = -1 // not present in response versions < 11
+	}
+	return r, err
 }
 
+// Close connections to the partition leader and to the preferred read replica.
+func (c *PartitionFetcher) Close() error { // implement io.Closer
+	c.Lock()
+	defer c.Unlock()
+	c.dropReplica()
+	return c.PartitionClient.Close()
+}
+
+func (c *PartitionFetcher) dropReplica() {
+	if c.replica != nil {
+		c.replica.Close()
+		c.replica = nil
+	}
+}
+
+// fetch from the preferred read replica. any error, including error code in
+// the partition response, is returned as error.
+func (c *PartitionFetcher) fetchReplica(args *Fetch.Args) (*Response, error) {
+	req := Fetch.NewRequest(args)
+	resp := &Fetch.Response{}
+	if err := c.replica.Call(req, resp); err != nil {
+		return nil, err
+	}
+	r, err := parseResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if r.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: r.ErrorCode}
+	}
+	r.Broker = c.replica.Broker()
+	return r, nil
+}
+
+// Fetch from the partition leader or, if RackId is set, from the preferred
+// read replica. Offset is not advanced. If ValidateLeaderEpochs is set fetch
+// requests have the current leader epoch of the partition. When the leader
+// responds with ERR_FENCED_LEADER_EPOCH or ERR_UNKNOWN_LEADER_EPOCH (the
+// leader changed, or the fetcher or the leader has stale metadata) the
+// fetcher reconnects, looks the leader epoch up again, validates the offset
+// (see SetOffsetEpoch), and retries the fetch once. TruncationError is
+// returned if the leader log has been truncated below the offset.
 func (c *PartitionFetcher) Fetch() (*Response, error) {
 	c.Lock()
 	defer c.Unlock()
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	resp, err := c.fetch()
+	if err == nil && (resp.ErrorCode == libkafka.ERR_FENCED_LEADER_EPOCH || resp.ErrorCode == libkafka.ERR_UNKNOWN_LEADER_EPOCH) {
+		c.PartitionClient.Close()
+		c.leaderEpochSet = false
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		resp, err = c.fetch()
+	}
+	if err != nil || leaderChanged(resp.ErrorCode) {
+		c.leaderEpochSet = false
+	}
+	return resp, err
+}
+
+// leaderChanged is true for fetch error codes after which the leader epoch of
+// the partition must be looked up again.
+func leaderChanged(code int16) bool {
+	switch code {
+	case libkafka.ERR_FENCED_LEADER_EPOCH, libkafka.ERR_UNKNOWN_LEADER_EPOCH, libkafka.ERR_NOT_LEADER_FOR_PARTITION:
+		return true
+	}
+	return false
+}
+
+func (c *PartitionFetcher) fetch() (*Response, error) {
 	args := &Fetch.Args{
-		ClientId:      c.ClientId,
-		Topic:         c.Topic,
-		Partition:     c.Partition,
-		Offset:        c.offset,
-		MinBytes:      c.MinBytes,
-		MaxBytes:      c.MaxBytes,
-		MaxWaitTimeMs: c.MaxWaitTimeMs,
+		ClientId:           c.ClientId,
+		Topic:              c.Topic,
+		Partition:          c.Partition,
+		Offset:             c.offset,
+		MinBytes:           c.MinBytes,
+		MaxBytes:           c.MaxBytes,
+		MaxWaitTimeMs:      c.MaxWaitTimeMs,
+		LeaderEpochs:       c.ValidateLeaderEpochs && !c.noLeaderEpochs,
+		CurrentLeaderEpoch: c.leaderEpoch,
+	}
+	if c.RackId != "" && time.Since(c.replicaFailed) > PreferredReplicaMaxAge {
+		args.RackId = c.RackId
+	}
+	if c.replica != nil && time.Since(c.replicaSince) > PreferredReplicaMaxAge {
+		c.dropReplica() // go back to the leader to check preferred replica
+	}
+	if c.replica != nil && args.RackId != "" {
+		resp, err := c.fetchReplica(args)
+		if err == nil {
+			return resp, nil
+		}
+		c.dropReplica()
+		c.replicaFailed = time.Now()
+		args.RackId = ""
 	}
 	resp, err := fetch(&(c.PartitionClient), args)
+	var e *libkafka.Error
+	if args.RackId != "" && errors.As(err, &e) && e.Code == libkafka.ERR_UNSUPPORTED_VERSION {
+		// leader does not support rack aware fetching (v11)
+		c.replicaFailed = time.Now()
+		args.RackId = ""
+		resp, err = fetch(&(c.PartitionClient), args)
+	}
 	if err != nil {
 		if leader := c.Leader(); leader != nil {
 			err = fmt.Errorf("error calling %+v: %w", leader, err)
 		}
 		return nil, err
 	}
-	resp.Broker = c.Leader()
+	leader := c.Leader()
+	resp.Broker = leader
+	if args.RackId == "" || resp.ErrorCode != libkafka.ERR_NONE {
+		return resp, nil
+	}
+	if id := resp.PreferredReadReplica; id >= 0 && leader != nil && id != leader.NodeId {
+		// leader redirects to a replica in the same rack. fetch from it
+		// right away: the leader response has no records.
+		c.replica = &client.BrokerClient{
+			Bootstrap:     c.Bootstrap,
+			TLS:           c.TLS,
+			SASL:          c.SASL,
+			ClientId:      c.ClientId,
+			NodeId:        id,
+			ConnMaxIdle:   c.ConnMaxIdle,
+			HonorThrottle: c.HonorThrottle,
+		}
+		c.replicaSince = time.Now()
+		if r, err := c.fetchReplica(args); err == nil {
+			return r, nil
+		}
+		c.dropReplica()
+		c.replicaFailed = time.Now()
+	}
 	return resp, nil
 }
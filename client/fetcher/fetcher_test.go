@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -8,9 +9,15 @@ import (
 	"time"
 
 	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/ApiVersions"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetForLeaderEpoch"
 	"github.com/mkocikowski/libkafka/batch"
 	"github.com/mkocikowski/libkafka/client"
 	"github.com/mkocikowski/libkafka/client/producer"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
 )
 
 func init() {
@@ -162,3 +169,229 @@ func TestIntergationPartitionFetcherEmptyPartition(t *testing.T) {
 		log.Fatalf("%+v", resp)
 	}
 }
+
+func TestUnitPartitionFetcherPreferredReadReplica(t *testing.T) {
+	var leader, replica *fakebroker.Broker
+	metadata := func() *Metadata.Response {
+		return &Metadata.Response{
+			Brokers: []Metadata.Broker{
+				{NodeId: 1, Host: leader.Host(), Port: leader.Port(), Rack: "b"},
+				{NodeId: 2, Host: replica.Host(), Port: replica.Port(), Rack: "a"},
+			},
+			TopicMetadata: []Metadata.TopicMetadata{
+				{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{
+					{Partition: 0, Leader: 1, Replicas: []int32{1, 2}},
+				}},
+			},
+		}
+	}
+	leader, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return metadata()
+		case api.Fetch:
+			r := &Fetch.Request{}
+			req.Unmarshal(r)
+			resp := fetchHandler(req).(*Fetch.Response)
+			p := &(resp.TopicResponses[0].PartitionResponses[0])
+			p.PreferredReadReplica = -1
+			if r.RackId == "a" {
+				p.PreferredReadReplica = 2
+				p.HighWatermark = -1 // no data when redirecting
+			}
+			return resp
+		}
+		return nil
+	})
+	defer leader.Close()
+	replica, _ = fakebroker.Start(2, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return metadata()
+		case api.Fetch:
+			return fetchHandler(req)
+		}
+		return nil
+	})
+	defer replica.Close()
+	c := &PartitionFetcher{
+		PartitionClient: client.PartitionClient{
+			Bootstrap: leader.Addr(),
+			Topic:     "foo",
+			Partition: 0,
+		},
+		RackId: "a",
+	}
+	defer c.Close()
+	c.SetOffset(10)
+	// leader redirects, and fetcher fetches from replica right away
+	resp, err := c.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Broker.NodeId != 2 || resp.HighWatermark != 11 {
+		t.Fatalf("%+v", resp)
+	}
+	resp, _ = c.Fetch()
+	if resp.Broker.NodeId != 2 {
+		t.Fatalf("%+v", resp)
+	}
+	var fetches int
+	for _, r := range leader.Requests() {
+		if r.ApiKey == api.Fetch {
+			fetches++
+		}
+	}
+	if fetches != 1 { // only the first fetch went to the leader
+		t.Fatal(fetches)
+	}
+	// replica goes away. fall back to the leader without rack id
+	replica.Close()
+	resp, err = c.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Broker.NodeId != 1 || resp.HighWatermark != 11 || resp.PreferredReadReplica != -1 {
+		t.Fatalf("%+v", resp)
+	}
+	requests := leader.Requests()
+	if r := requests[len(requests)-1]; r.ApiVersion != 6 {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestUnitPartitionFetcherRackIdNotSupported(t *testing.T) {
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0, Leader: 1}}},
+				},
+			}
+		case api.Fetch:
+			return fetchHandler(req)
+		}
+		return nil
+	})
+	defer b.Close()
+	b.Versions = &ApiVersions.Response{ApiKeys: []ApiVersions.ApiKeyVersion{
+		{ApiKey: api.Fetch, MinVersion: 0, MaxVersion: 6},
+	}}
+	c := &PartitionFetcher{
+		PartitionClient: client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		RackId:          "a",
+	}
+	resp, err := c.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE || resp.Broker.NodeId != 1 {
+		t.Fatalf("%+v", resp)
+	}
+}
+
+func TestUnitPartitionFetcherTruncation(t *testing.T) {
+	// current leader epoch, and end offsets of leader epochs in the log
+	currentEpoch := int32(1)
+	epochEnd := map[int32]int64{0: 3, 1: 20}
+	outOfRange := false
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0, Leader: 1, LeaderEpoch: currentEpoch}}},
+				},
+			}
+		case api.OffsetForLeaderEpoch:
+			r := &OffsetForLeaderEpoch.Request{}
+			req.Unmarshal(r)
+			p := r.Topics[0].Partitions[0]
+			resp := OffsetForLeaderEpoch.PartitionResponse{LeaderEpoch: p.LeaderEpoch, EndOffset: epochEnd[p.LeaderEpoch]}
+			if p.CurrentLeaderEpoch != currentEpoch {
+				resp.ErrorCode = libkafka.ERR_FENCED_LEADER_EPOCH
+			}
+			return &OffsetForLeaderEpoch.Response{Topics: []OffsetForLeaderEpoch.TopicResponse{
+				{Topic: "foo", Partitions: []OffsetForLeaderEpoch.PartitionResponse{resp}},
+			}}
+		case api.Fetch:
+			r := &Fetch.Request{}
+			req.Unmarshal(r)
+			resp := fetchHandler(req).(*Fetch.Response)
+			if e := r.Topics[0].Partitions[0].CurrentLeaderEpoch; e != currentEpoch {
+				resp.TopicResponses[0].PartitionResponses[0].ErrorCode = libkafka.ERR_FENCED_LEADER_EPOCH
+			} else if outOfRange {
+				resp.TopicResponses[0].PartitionResponses[0].ErrorCode = libkafka.ERR_OFFSET_OUT_OF_RANGE
+			}
+			return resp
+		}
+		return nil
+	})
+	defer b.Close()
+	c := &PartitionFetcher{
+		PartitionClient:      client.PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0},
+		ValidateLeaderEpochs: true,
+	}
+	defer c.Close()
+	c.SetOffsetEpoch(10, 1)
+	resp, err := c.Fetch()
+	if err != nil || resp.ErrorCode != libkafka.ERR_NONE {
+		t.Fatal(resp, err)
+	}
+	// errors other than leader changes do not make the fetcher look the
+	// leader epoch up again
+	n := len(b.Requests())
+	outOfRange = true
+	if resp, err = c.Fetch(); err != nil || resp.ErrorCode != libkafka.ERR_OFFSET_OUT_OF_RANGE {
+		t.Fatal(resp, err)
+	}
+	outOfRange = false
+	if resp, err = c.Fetch(); err != nil || resp.ErrorCode != libkafka.ERR_NONE {
+		t.Fatal(resp, err)
+	}
+	if len(b.Requests()) != n+2 {
+		t.Fatal(b.Requests()[n:])
+	}
+	// unclean leader election: new leader has epoch 1 records only up to
+	// offset 5
+	currentEpoch, epochEnd[1], epochEnd[2] = 2, 5, 30
+	for i := 0; i < 2; i++ {
+		_, err = c.Fetch()
+		var e *TruncationError
+		if !errors.As(err, &e) {
+			t.Fatal(err)
+		}
+		if e.Offset != 10 || e.OffsetEpoch != 1 || e.DivergentOffset != 5 || e.LeaderEpoch != 1 {
+			t.Fatalf("%+v", e)
+		}
+	}
+	if c.Offset() != 10 || c.OffsetEpoch() != 1 {
+		t.Fatal(c.Offset(), c.OffsetEpoch())
+	}
+	c.SetOffsetEpoch(5, 1)
+	if resp, err = c.Fetch(); err != nil || resp.ErrorCode != libkafka.ERR_NONE {
+		t.Fatal(resp, err)
+	}
+	requests := b.Requests()
+	r := &Fetch.Request{}
+	requests[len(requests)-1].Unmarshal(r)
+	if requests[len(requests)-1].ApiVersion != 9 || r.Topics[0].Partitions[0].CurrentLeaderEpoch != 2 {
+		t.Fatalf("%+v", r)
+	}
+	// leader change without truncation: fenced, revalidated, and retried
+	currentEpoch = 3
+	if resp, err = c.Fetch(); err != nil || resp.ErrorCode != libkafka.ERR_NONE {
+		t.Fatal(resp, err)
+	}
+	// offset with unknown epoch is not validated
+	currentEpoch, epochEnd[3] = 4, 1
+	c.SetOffset(100)
+	if resp, err = c.Fetch(); err != nil || resp.ErrorCode != libkafka.ERR_NONE || c.OffsetEpoch() != -1 {
+		t.Fatal(resp, err)
+	}
+}
@@ -0,0 +1,118 @@
+package fetcher
+
+import (
+	"math"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// Session holds the client side state of an incremental fetch session
+// (KIP-227) with a single broker. The first request in a session is "full"
+// (lists all partitions) and the broker responds with a session id. The
+// following requests are "incremental": they list only the partitions which
+// were added or whose fetch offset or max bytes changed, and the partitions
+// which were removed (as "forgotten"). Responses to incremental requests
+// include only partitions for which there is new data, a changed high
+// watermark or log start offset, or an error. If the broker does not know the
+// session (FETCH_SESSION_ID_NOT_FOUND, for example because it evicted it from
+// its cache or restarted) or the epoch is out of sync
+// (INVALID_FETCH_SESSION_EPOCH) the session is reset and the next request is
+// full again. Session is not safe for concurrent use; it is used by the
+// BrokerFetcher (under its mutex).
+type Session struct {
+	Id    int32
+	Epoch int32
+	// state of the partitions in the session as known to the broker
+	sent map[client.TopicPartition]Fetch.PartitionArgs
+	// state that will be known to the broker if the pending request is
+	// successful
+	pending map[client.TopicPartition]Fetch.PartitionArgs
+}
+
+// Reset the session so that the next request is a full request which creates
+// a new session.
+func (s *Session) Reset() {
+	s.Id = 0
+	s.Epoch = 0
+	s.sent = nil
+	s.pending = nil
+}
+
+// Full returns true if the next request will be a full request.
+func (s *Session) Full() bool {
+	return s.Id == 0
+}
+
+// request builds the request for the next fetch in the session.
+func (s *Session) request(args *Fetch.MultiplePartitionsArgs) *api.Request {
+	s.pending = make(map[client.TopicPartition]Fetch.PartitionArgs, len(args.Partitions))
+	for _, p := range args.Partitions {
+		s.pending[client.TopicPartition{Topic: p.Topic, Partition: p.Partition}] = p
+	}
+	if s.Full() {
+		return Fetch.NewSessionRequest(args, 0, 0, nil)
+	}
+	incremental := *args
+	incremental.Partitions = nil
+	for _, p := range args.Partitions {
+		tp := client.TopicPartition{Topic: p.Topic, Partition: p.Partition}
+		if prev, ok := s.sent[tp]; !ok || prev != p {
+			incremental.Partitions = append(incremental.Partitions, p)
+		}
+	}
+	var removed []client.TopicPartition
+	for tp := range s.sent {
+		if _, ok := s.pending[tp]; !ok {
+			removed = append(removed, tp)
+		}
+	}
+	client.SortTopicPartitions(removed)
+	var forgotten []Fetch.ForgottenTopic
+	for _, tp := range removed {
+		if n := len(forgotten); n == 0 || forgotten[n-1].Topic != tp.Topic {
+			forgotten = append(forgotten, Fetch.ForgottenTopic{Topic: tp.Topic})
+		}
+		f := &(forgotten[len(forgotten)-1])
+		f.Partitions = append(f.Partitions, tp.Partition)
+	}
+	return Fetch.NewSessionRequest(&incremental, s.Id, s.Epoch, forgotten)
+}
+
+// update the session state from response to the last request. returns the
+// top level (session) error, if any, in which case the session is reset.
+func (s *Session) update(r *Fetch.Response) error {
+	if r.ErrorCode != libkafka.ERR_NONE {
+		s.Reset()
+		return &libkafka.Error{Code: r.ErrorCode}
+	}
+	if s.Full() {
+		// broker may decline to create a session (for example if its
+		// session cache is full) in which case it responds with id 0
+		// and the next request is full again
+		s.Id = r.SessionId
+		s.Epoch = 0
+	}
+	if s.Id != 0 {
+		s.Epoch = nextEpoch(s.Epoch)
+	}
+	s.sent = s.pending
+	s.pending = nil
+	return nil
+}
+
+func nextEpoch(epoch int32) int32 {
+	if epoch == math.MaxInt32 {
+		return 1 // epoch 0 is reserved for full requests
+	}
+	return epoch + 1
+}
+
+// session level errors from which the fetcher recovers by resetting the
+// session and immediately retrying with a full request
+func isSessionError(code int16) bool {
+	return code == libkafka.ERR_FETCH_SESSION_ID_NOT_FOUND ||
+		code == libkafka.ERR_INVALID_FETCH_SESSION_EPOCH
+}
@@ -0,0 +1,177 @@
+package fetcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/ApiVersions"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+func TestUnitSessionRequest(t *testing.T) {
+	s := &Session{}
+	args := &Fetch.MultiplePartitionsArgs{
+		Partitions: []Fetch.PartitionArgs{
+			{Topic: "foo", Partition: 0, Offset: 1},
+			{Topic: "foo", Partition: 1, Offset: 2},
+			{Topic: "bar", Partition: 0, Offset: 3},
+		},
+	}
+	req := s.request(args)
+	body := req.Body.(Fetch.Request)
+	if req.ApiVersion != 7 || body.SessionId != 0 || body.SessionEpoch != 0 || len(body.Topics) != 2 {
+		t.Fatalf("%+v", req)
+	}
+	if err := s.update(&Fetch.Response{SessionId: 123}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Id != 123 || s.Epoch != 1 {
+		t.Fatalf("%+v", s)
+	}
+	// foo:1 offset changes, bar:0 is removed
+	args.Partitions = args.Partitions[:2]
+	args.Partitions[1].Offset = 5
+	body = s.request(args).Body.(Fetch.Request)
+	if body.SessionId != 123 || body.SessionEpoch != 1 {
+		t.Fatalf("%+v", body)
+	}
+	if len(body.Topics) != 1 || len(body.Topics[0].Partitions) != 1 || body.Topics[0].Partitions[0].FetchOffset != 5 {
+		t.Fatalf("%+v", body)
+	}
+	if f := body.ForgottenTopics; len(f) != 1 || f[0].Topic != "bar" || len(f[0].Partitions) != 1 {
+		t.Fatalf("%+v", body)
+	}
+	if err := s.update(&Fetch.Response{SessionId: 123}); err != nil {
+		t.Fatal(err)
+	}
+	// nothing changed
+	body = s.request(args).Body.(Fetch.Request)
+	if body.SessionEpoch != 2 || len(body.Topics) != 0 || len(body.ForgottenTopics) != 0 {
+		t.Fatalf("%+v", body)
+	}
+	// session error resets the session
+	err := s.update(&Fetch.Response{ErrorCode: libkafka.ERR_INVALID_FETCH_SESSION_EPOCH})
+	if !s.Full() || err == nil {
+		t.Fatalf("%+v %v", s, err)
+	}
+	// broker declines to create session
+	s.request(args)
+	s.update(&Fetch.Response{SessionId: 0})
+	if !s.Full() || s.Epoch != 0 {
+		t.Fatalf("%+v", s)
+	}
+}
+
+// fake broker which keeps one fetch session, and "forgets" it on demand
+type sessionBroker struct {
+	*fakebroker.Broker
+	id     int32
+	epoch  int32
+	forget bool
+}
+
+func (b *sessionBroker) handle(req *fakebroker.Request) interface{} {
+	switch req.ApiKey {
+	case api.Metadata:
+		return &Metadata.Response{
+			Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+		}
+	case api.Fetch:
+		r := &Fetch.Request{}
+		req.Unmarshal(r)
+		if b.forget {
+			b.forget = false
+			b.id = 0
+		}
+		resp := fetchHandler(req).(*Fetch.Response)
+		switch {
+		case r.SessionId == 0 && r.SessionEpoch == 0:
+			b.id++
+			b.epoch = 1
+			resp.SessionId = b.id
+		case r.SessionId != b.id:
+			return &Fetch.Response{ErrorCode: libkafka.ERR_FETCH_SESSION_ID_NOT_FOUND}
+		case r.SessionEpoch != b.epoch:
+			return &Fetch.Response{ErrorCode: libkafka.ERR_INVALID_FETCH_SESSION_EPOCH}
+		default:
+			b.epoch++
+			resp.SessionId = b.id
+		}
+		return resp
+	}
+	return nil
+}
+
+func TestUnitBrokerFetcherSession(t *testing.T) {
+	b := &sessionBroker{}
+	b.Broker, _ = fakebroker.Start(1, b.handle)
+	defer b.Close()
+	c := &BrokerFetcher{
+		BrokerClient: client.BrokerClient{Bootstrap: b.Addr(), NodeId: 1},
+		Session:      &Session{},
+	}
+	c.SetOffset("foo", 0, 10)
+	c.SetOffset("foo", 1, 20)
+	responses, err := c.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || c.Session.Id != 1 || c.Session.Epoch != 1 {
+		t.Fatalf("%+v %+v", responses, c.Session)
+	}
+	// only the changed partition is sent (and so returned by the fake)
+	c.SetOffset("foo", 1, 21)
+	if responses, err = c.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 1 || responses[0].HighWatermark != 22 || c.Session.Epoch != 2 {
+		t.Fatalf("%+v %+v", responses, c.Session)
+	}
+	// broker forgets the session. fetcher recovers with a full request
+	b.forget = true
+	if responses, err = c.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || c.Session.Id != 1 || c.Session.Epoch != 1 {
+		t.Fatalf("%+v %+v", responses, c.Session)
+	}
+	// epoch out of sync
+	b.epoch = 100
+	if responses, err = c.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || c.Session.Id != 2 {
+		t.Fatalf("%+v %+v", responses, c.Session)
+	}
+	// full requests are v7, others are rejected by the fake
+	for _, r := range b.Requests() {
+		if r.ApiKey == api.Fetch && r.ApiVersion != 7 {
+			t.Fatal(r.ApiVersion)
+		}
+	}
+}
+
+func TestUnitBrokerFetcherSessionUnsupported(t *testing.T) {
+	b := &sessionBroker{}
+	b.Broker, _ = fakebroker.Start(1, b.handle)
+	defer b.Close()
+	b.Versions = &ApiVersions.Response{ApiKeys: []ApiVersions.ApiKeyVersion{
+		{ApiKey: api.Fetch, MinVersion: 0, MaxVersion: 6},
+	}}
+	c := &BrokerFetcher{
+		BrokerClient: client.BrokerClient{Bootstrap: b.Addr(), NodeId: 1},
+		Session:      &Session{},
+	}
+	c.SetOffset("foo", 0, 10)
+	_, err := c.Fetch()
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_UNSUPPORTED_VERSION {
+		t.Fatal(err)
+	}
+	t.Log(err)
+}
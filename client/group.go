@@ -12,19 +12,32 @@ import (
 	"github.com/mkocikowski/libkafka/api/FindCoordinator"
 	"github.com/mkocikowski/libkafka/api/Heartbeat"
 	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/LeaveGroup"
 	"github.com/mkocikowski/libkafka/api/OffsetCommit"
 	"github.com/mkocikowski/libkafka/api/OffsetFetch"
 	"github.com/mkocikowski/libkafka/api/SyncGroup"
 )
 
 func CallFindCoordinator(bootstrap string, tlsConfig *tls.Config, groupId string) (*FindCoordinator.Response, error) {
+	return CallFindCoordinatorSASL(bootstrap, tlsConfig, nil, groupId)
+}
+
+// CallFindCoordinatorSASL is CallFindCoordinator on connections authenticated
+// with sasl.
+func CallFindCoordinatorSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, groupId string) (*FindCoordinator.Response, error) {
 	req := FindCoordinator.NewRequest(groupId)
 	resp := &FindCoordinator.Response{}
-	return resp, connectToRandomBrokerAndCall(bootstrap, tlsConfig, req, resp)
+	return resp, connectToRandomBrokerAndCallSASL(bootstrap, tlsConfig, sasl, req, resp)
 }
 
 func GetGroupCoordinator(bootstrap string, tlsConfig *tls.Config, groupId string) (string, error) {
-	resp, err := CallFindCoordinator(bootstrap, tlsConfig, groupId)
+	return GetGroupCoordinatorSASL(bootstrap, tlsConfig, nil, groupId)
+}
+
+// GetGroupCoordinatorSASL is GetGroupCoordinator on connections authenticated
+// with sasl.
+func GetGroupCoordinatorSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, groupId string) (string, error) {
+	resp, err := CallFindCoordinatorSASL(bootstrap, tlsConfig, sasl, groupId)
 	if err != nil {
 		return "", fmt.Errorf("error making FindCoordinator call: %w", err)
 	}
@@ -40,32 +53,28 @@ type GroupClient struct {
 	sync.Mutex
 	Bootstrap string
 	TLS       *tls.Config
+	SASL      SASL
 	GroupId   string
-	conn      net.Conn
+	// GroupInstanceId makes the member a static member (KIP-345). If it
+	// is set join, sync, heartbeat, and leave requests identify the member
+	// by it, and use the api versions which support it (Kafka 2.3+).
+	GroupInstanceId string
+	conn            net.Conn
 }
 
 func (c *GroupClient) connect() error {
 	if c.conn != nil {
 		return nil
 	}
-	addr, err := GetGroupCoordinator(c.Bootstrap, c.TLS, c.GroupId)
+	addr, err := GetGroupCoordinatorSASL(c.Bootstrap, c.TLS, c.SASL, c.GroupId)
 	if err != nil {
 		return err
 	}
-	if c.TLS != nil {
-		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: libkafka.DialTimeout}, "tcp", addr, c.TLS)
-		if err != nil {
-			return err
-		}
-		c.conn = conn
-	}
-	if c.TLS == nil {
-		conn, err := net.DialTimeout("tcp", addr, libkafka.DialTimeout)
-		if err != nil {
-			return err
-		}
-		c.conn = conn
+	conn, err := dial(addr, c.TLS, c.SASL)
+	if err != nil {
+		return err
 	}
+	c.conn = conn
 	return nil
 }
 
@@ -115,14 +124,14 @@ func (c *GroupClient) Call(req *api.Request, respStructPtr interface{}) error {
 	return err
 }
 
-func (c *GroupClient) callJoin(memberId, protoType string, protocols []JoinGroup.Protocol) (*JoinGroup.Response, error) {
-	req := JoinGroup.NewRequest(c.GroupId, memberId, protoType, protocols)
+func (c *GroupClient) callJoin(args *JoinGroup.Args) (*JoinGroup.Response, error) {
+	req := JoinGroup.NewRequestWithArgs(args)
 	resp := &JoinGroup.Response{}
 	return resp, c.Call(req, resp)
 }
 
 func (c *GroupClient) callSync(memberId string, generationId int32, assignments []SyncGroup.Assignment) (*SyncGroup.Response, error) {
-	req := SyncGroup.NewRequest(c.GroupId, memberId, generationId, assignments)
+	req := SyncGroup.NewStaticRequest(c.GroupId, memberId, c.GroupInstanceId, generationId, assignments)
 	//log.Printf("%+v", req)
 	resp := &SyncGroup.Response{}
 	return resp, c.Call(req, resp)
@@ -133,6 +142,12 @@ type JoinGroupRequest struct {
 	ProtocolType string
 	ProtocolName string
 	Metadata     []byte
+	// If zero, JoinGroup.SessionTimeoutMs and JoinGroup.RebalanceTimeoutMs
+	// are used. Session timeout must be within the range set by the
+	// group.min.session.timeout.ms and group.max.session.timeout.ms broker
+	// settings.
+	SessionTimeoutMs   int32
+	RebalanceTimeoutMs int32
 	//group.initial.rebalance.delay.ms
 }
 
@@ -141,7 +156,15 @@ func (c *GroupClient) Join(req *JoinGroupRequest) (*JoinGroup.Response, error) {
 		Name:     req.ProtocolName,
 		Metadata: req.Metadata,
 	}
-	return c.callJoin(req.MemberId, req.ProtocolType, []JoinGroup.Protocol{p})
+	return c.callJoin(&JoinGroup.Args{
+		GroupId:            c.GroupId,
+		MemberId:           req.MemberId,
+		ProtocolType:       req.ProtocolType,
+		Protocols:          []JoinGroup.Protocol{p},
+		SessionTimeoutMs:   req.SessionTimeoutMs,
+		RebalanceTimeoutMs: req.RebalanceTimeoutMs,
+		GroupInstanceId:    c.GroupInstanceId,
+	})
 }
 
 type SyncGroupRequest struct {
@@ -155,11 +178,23 @@ func (c *GroupClient) Sync(req *SyncGroupRequest) (*SyncGroup.Response, error) {
 }
 
 func (c *GroupClient) Heartbeat(memberId string, generationId int32) (*Heartbeat.Response, error) {
-	req := Heartbeat.NewRequest(c.GroupId, memberId, generationId)
+	req := Heartbeat.NewStaticRequest(c.GroupId, memberId, c.GroupInstanceId, generationId)
 	resp := &Heartbeat.Response{}
 	return resp, c.Call(req, resp)
 }
 
+// Leave the group, so that the coordinator rebalances it right away, instead
+// of waiting for the member session to time out. If GroupInstanceId is set
+// the static member is removed from the group (memberId can be empty);
+// static members which intend to rejoin (after a restart) should not leave.
+// Response error code (and, for static members, the member error code) is
+// not interpreted.
+func (c *GroupClient) Leave(memberId string) (*LeaveGroup.Response, error) {
+	req := LeaveGroup.NewRequest(c.GroupId, memberId, c.GroupInstanceId)
+	resp := &LeaveGroup.Response{}
+	return resp, c.Call(req, resp)
+}
+
 func parseOffsetFetchResponse(r *OffsetFetch.Response) (int64, error) {
 	if r.ErrorCode != libkafka.ERR_NONE {
 		return -1, &libkafka.Error{Code: r.ErrorCode}
@@ -189,57 +224,189 @@ func (c *GroupClient) FetchOffset(topic string, partition int32) (int64, error)
 	return parseOffsetFetchResponse(resp)
 }
 
-func parseOffsetCommitResponse(r *OffsetCommit.Response) error {
-	if n := len(r.Topics); n != 1 {
-		return fmt.Errorf("unexpected number of topic responses: %d", n)
-	}
-	t := r.Topics[0]
-	if n := len(t.Partitions); n != 1 {
-		return fmt.Errorf("unexpected number of topic partition responses: %d", n)
+// CommittedOffset is the offset committed by a group for a partition, with
+// the metadata committed with it. Offset is -1 if there is no offset
+// committed for the partition (or the partition does not exist).
+type CommittedOffset struct {
+	Offset    int64
+	Metadata  string
+	ErrorCode int16
+}
+
+func parseOffsetFetchResponseMultiple(r *OffsetFetch.Response) (map[TopicPartition]*CommittedOffset, error) {
+	if r.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: r.ErrorCode}
 	}
-	p := t.Partitions[0]
-	if p.ErrorCode != libkafka.ERR_NONE {
-		return &libkafka.Error{Code: p.ErrorCode}
+	offsets := make(map[TopicPartition]*CommittedOffset)
+	for _, t := range r.Topics {
+		for _, p := range t.Partitions {
+			offsets[TopicPartition{Topic: t.Name, Partition: p.PartitionIndex}] = &CommittedOffset{
+				Offset:    p.CommitedOffset,
+				Metadata:  p.Metadata,
+				ErrorCode: p.ErrorCode,
+			}
+		}
 	}
-	return nil
+	return offsets, nil
 }
 
-func (c *GroupClient) CommitOffset(topic string, partition int32, offset, retentionMs int64) error {
-	req := OffsetCommit.NewRequest(c.GroupId, topic, partition, offset, retentionMs)
-	resp := &OffsetCommit.Response{}
+// FetchOffsets fetches committed offsets for any number of partitions of any
+// number of topics in a single request. Result has all requested partitions
+// (error is returned if the response does not), each with its own error code.
+// Error is also returned for round trip errors and for group level error codes
+// (such as ERR_COORDINATOR_LOAD_IN_PROGRESS or ERR_NOT_COORDINATOR).
+func (c *GroupClient) FetchOffsets(partitions []TopicPartition) (map[TopicPartition]*CommittedOffset, error) {
+	byTopic := make(map[string][]int32)
+	for _, tp := range partitions {
+		byTopic[tp.Topic] = append(byTopic[tp.Topic], tp.Partition)
+	}
+	req := OffsetFetch.NewMultipleTopicsRequest(c.GroupId, byTopic)
+	resp := &OffsetFetch.Response{}
 	if err := c.Call(req, resp); err != nil {
-		return fmt.Errorf("error making commit offset call: %w", err)
+		return nil, fmt.Errorf("error making fetch offsets call: %w", err)
+	}
+	offsets, err := parseOffsetFetchResponseMultiple(resp)
+	if err != nil {
+		return nil, err
 	}
-	return parseOffsetCommitResponse(resp)
+	for _, tp := range partitions {
+		if _, ok := offsets[tp]; !ok {
+			return nil, fmt.Errorf("malformed response: no result for %v", tp)
+		}
+	}
+	return offsets, nil
+}
+
+// FetchAllOffsets fetches all offsets committed by the group (OffsetFetch v2+,
+// Kafka 0.10.2+). Only partitions with committed offsets are returned. Errors
+// are the same as for FetchOffsets.
+func (c *GroupClient) FetchAllOffsets() (map[TopicPartition]*CommittedOffset, error) {
+	resp := &OffsetFetch.Response{}
+	if err := c.Call(OffsetFetch.NewAllTopicsRequest(c.GroupId), resp); err != nil {
+		return nil, fmt.Errorf("error making fetch offsets call: %w", err)
+	}
+	return parseOffsetFetchResponseMultiple(resp)
+}
+
+// FetchTopicsOffsets fetches offsets committed by the group for all
+// partitions of the topics. It looks up the partitions of the topics with a
+// Metadata call (error is returned if any of the topics does not exist) and
+// fetches their offsets with FetchOffsets, so partitions with no committed
+// offset are returned with offset -1.
+func (c *GroupClient) FetchTopicsOffsets(topics []string) (map[TopicPartition]*CommittedOffset, error) {
+	partitions, err := GetPartitionsSASL(c.Bootstrap, c.TLS, c.SASL, topics)
+	if err != nil {
+		return nil, err
+	}
+	return c.FetchOffsets(partitions)
+}
+
+// CommitOffset commits offset for a single partition, outside of group
+// membership. It is a thin wrapper over CommitOffsets: a partition error code
+// is returned as *libkafka.Error.
+func (c *GroupClient) CommitOffset(topic string, partition int32, offset, retentionMs int64) error {
+	return c.CommitMultiplePartitionsOffsets(topic, map[int32]int64{partition: offset}, retentionMs)
 }
 
 // CommitMultiplePartitionsOffsets commits offsets for multiple partitions of a
-// specific topic at once. Accepts topic, and a map of partition -> offset
-// alongside with the time to retain the offsets (in ms)
+// specific topic at once, outside of group membership. Accepts topic, and a
+// map of partition -> offset alongside with the time to retain the offsets
+// (in ms). It is a thin wrapper over CommitOffsets: if any of the partitions
+// failed, its error code is returned as *libkafka.Error.
 func (c *GroupClient) CommitMultiplePartitionsOffsets(topic string, offsets map[int32]int64, retentionMs int64) error {
-	req := OffsetCommit.NewMultiplePartitionsRequest(c.GroupId, topic, offsets, retentionMs)
-	resp := &OffsetCommit.Response{}
-	if err := c.Call(req, resp); err != nil {
-		return fmt.Errorf("error of committing offset for multiple partitions: %w", err)
+	req := &CommitOffsetsRequest{
+		GenerationId: -1,
+		RetentionMs:  retentionMs,
+		Offsets:      make(map[TopicPartition]OffsetMetadata),
+	}
+	for p, o := range offsets {
+		req.Offsets[TopicPartition{Topic: topic, Partition: p}] = OffsetMetadata{Offset: o}
+	}
+	codes, err := c.CommitOffsets(req)
+	if err != nil {
+		return err
+	}
+	partitions := make([]TopicPartition, 0, len(codes))
+	for tp := range codes {
+		partitions = append(partitions, tp)
 	}
-	return parseCommitMultiplePartitionsOffsetsResponse(resp)
+	SortTopicPartitions(partitions)
+	for _, tp := range partitions {
+		if code := codes[tp]; code != libkafka.ERR_NONE {
+			return &libkafka.Error{Code: code}
+		}
+	}
+	return nil
+}
+
+// OffsetMetadata is the offset to commit for a partition, along with an
+// arbitrary metadata string, which is stored with the offset (and returned
+// when the offset is fetched). Size of the metadata is limited by the
+// offset.metadata.max.bytes broker setting (4KB by default).
+type OffsetMetadata struct {
+	Offset   int64
+	Metadata string
+}
+
+type CommitOffsetsRequest struct {
+	// MemberId and GenerationId of the committing group member. Commits
+	// from members which are no longer in the group, or which are of an
+	// older generation, are rejected by the coordinator, so that members
+	// which lost their partitions in a rebalance can't overwrite offsets
+	// committed by the new owners. Commits made outside of group
+	// membership use empty MemberId and GenerationId -1.
+	MemberId     string
+	GenerationId int32
+	// RetentionMs is how long to keep the offsets; -1 for broker default.
+	// Ignored if GroupInstanceId is set.
+	RetentionMs int64
+	Offsets     map[TopicPartition]OffsetMetadata
 }
 
-// parseCommitMultiplePartitionsOffsetsResponse reads the response of flushing
-// offsets of multiple partitions and returns error if the response malformed OR
-// at least one of them failed
-func parseCommitMultiplePartitionsOffsetsResponse(resp *OffsetCommit.Response) error {
-	if n := len(resp.Topics); n != 1 {
-		return fmt.Errorf("malformed response: unexpected number of topic responses: %d", n)
+// CommitOffsets commits offsets for any number of partitions of any number of
+// topics in a single request. If the client GroupInstanceId is set, it is sent
+// with the request (OffsetCommit v7, Kafka 2.3+), and static members fenced by
+// other members with the same GroupInstanceId get ERR_FENCED_INSTANCE_ID.
+// Returns error codes for all partitions in the response (ERR_NONE for
+// partitions committed successfully). Error is returned for round trip errors
+// and if the response does not have all partitions. Notable partition error
+// codes are ERR_ILLEGAL_GENERATION and ERR_UNKNOWN_MEMBER_ID (the member must
+// rejoin the group), ERR_REBALANCE_IN_PROGRESS, ERR_OFFSET_METADATA_TOO_LARGE,
+// and ERR_UNKNOWN_TOPIC_OR_PARTITION.
+func (c *GroupClient) CommitOffsets(req *CommitOffsetsRequest) (map[TopicPartition]int16, error) {
+	args := &OffsetCommit.MultipleTopicsArgs{
+		GroupId:         c.GroupId,
+		GenerationId:    req.GenerationId,
+		MemberId:        req.MemberId,
+		GroupInstanceId: c.GroupInstanceId,
+		RetentionTimeMs: req.RetentionMs,
+	}
+	for tp, o := range req.Offsets {
+		args.Offsets = append(args.Offsets, OffsetCommit.Offset{
+			Topic:     tp.Topic,
+			Partition: tp.Partition,
+			Offset:    o.Offset,
+			Metadata:  o.Metadata,
+		})
+	}
+	resp := &OffsetCommit.Response{}
+	if err := c.Call(OffsetCommit.NewMultipleTopicsRequest(args), resp); err != nil {
+		return nil, fmt.Errorf("error making commit offsets call: %w", err)
 	}
-	t := resp.Topics[0]
-	if len(t.Partitions) < 1 {
-		return fmt.Errorf("malformed response: empty list of partition responses")
+	return parseCommitOffsetsResponse(resp, req.Offsets)
+}
+
+func parseCommitOffsetsResponse(resp *OffsetCommit.Response, offsets map[TopicPartition]OffsetMetadata) (map[TopicPartition]int16, error) {
+	codes := make(map[TopicPartition]int16)
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			codes[TopicPartition{Topic: t.Name, Partition: p.PartitionIndex}] = p.ErrorCode
+		}
 	}
-	for _, p := range t.Partitions {
-		if p.ErrorCode != libkafka.ERR_NONE {
-			return &libkafka.Error{Code: p.ErrorCode}
+	for tp := range offsets {
+		if _, ok := codes[tp]; !ok {
+			return nil, fmt.Errorf("malformed response: no result for %v", tp)
 		}
 	}
-	return nil
+	return codes, nil
 }
@@ -0,0 +1,569 @@
+// Package group implements the client side of the Kafka group membership
+// protocol. A Member joins a group, takes part in the assignment of
+// partitions (as the group leader, or as a follower), keeps its membership
+// alive with heartbeats, and rejoins the group when it rebalances. The
+// encoding of member metadata and of assignments, and the assignment of
+// partitions itself, are delegated to the Protocol. Member does not fetch or
+// commit offsets: it only tells the user which partitions are assigned to it
+// and when they are revoked.
+package group
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// Protocol implements a group protocol (for example the "range" assignment
+// strategy of the "consumer" protocol type).
+type Protocol interface {
+	// Name of the protocol sent in the JoinGroup request.
+	Name() string
+	// Cooperative protocols rebalance incrementally (KIP-429): members
+	// keep their partitions when rejoining the group, and revoke only
+	// those that the new assignment takes away from them. Otherwise
+	// (eager rebalancing) members revoke all partitions before rejoining.
+	Cooperative() bool
+	// Metadata sent in the JoinGroup request. For the consumer protocol
+	// this is the encoded subscription. Owned are the partitions the
+	// member has when rejoining (always empty with eager rebalancing) and
+	// generationId is the generation in which they were assigned (-1 if
+	// the member has not been in the group).
+	Metadata(owned []client.TopicPartition, generationId int32) ([]byte, error)
+	// Assign is called on the member elected group leader. It gets the
+	// metadata of all group members, and returns their assignments, which
+	// are sent to the coordinator in the SyncGroup request.
+	Assign(members []JoinGroup.Member) ([]SyncGroup.Assignment, error)
+	// Partitions decodes the assignment received in SyncGroup response.
+	Partitions(assignment []byte) ([]client.TopicPartition, error)
+}
+
+var (
+	ErrRunning   = errors.New("member already running")
+	ErrNotMember = errors.New("not a member of the group")
+)
+
+// fatal errors end Member.Run. these are errors from the Protocol and errors
+// codes in Kafka responses from which the member can not recover.
+type fatal struct {
+	error
+}
+
+func (e fatal) Unwrap() error {
+	return e.error
+}
+
+// Member of a group. Membership is handled by Run, which joins the group,
+// syncs, and then heartbeats every HeartbeatInterval until Close is called.
+// When the group is rebalancing (coordinator responds with
+// ERR_REBALANCE_IN_PROGRESS) or the member has been removed from the group
+// (ERR_ILLEGAL_GENERATION, ERR_UNKNOWN_MEMBER_ID) the member revokes all its
+// partitions (calling OnRevoked) and rejoins. With a cooperative Protocol the
+// member keeps its partitions while rebalancing (unless it was removed from
+// the group) and after the rebalance revokes only the partitions which were
+// not assigned to it again, in which case it rejoins right away, so that
+// these partitions can be assigned to other members (KIP-429). After each
+// successful join and sync it calls OnAssigned. When the coordinator moves
+// (ERR_NOT_COORDINATOR, ERR_COORDINATOR_NOT_AVAILABLE) the member looks the
+// coordinator up again. Request-response round trip errors (and coordinator
+// errors) are retried every RetryBackoff for up to the session timeout (after
+// which the coordinator will have removed the member from the group anyway);
+// then Run returns the error. Other error codes end Run right away. Member
+// methods are safe for concurrent use, but a member can be Run only once.
+//
+// When Run returns because of Close, the member leaves the group, so that the
+// group rebalances right away instead of after the session timeout. Static
+// members (with GroupInstanceId set, KIP-345) do not leave: when a static
+// member is restarted within the session timeout it gets its assignment back
+// without a rebalance. This is what makes rolling restarts cheap. A static
+// member which is fenced by another member with the same GroupInstanceId
+// (ERR_FENCED_INSTANCE_ID) ends Run with the error.
+type Member struct {
+	sync.Mutex
+	client.GroupClient
+	ProtocolType string // for example "consumer"
+	Protocol     Protocol
+	// SessionTimeout after which the coordinator removes the member
+	// from the group if there are no heartbeats. Static members should
+	// set it long enough for a restart. Default (0) is
+	// JoinGroup.SessionTimeoutMs.
+	SessionTimeout time.Duration
+	// RebalanceTimeout is how long the coordinator waits for members to
+	// rejoin when the group rebalances. Default (0) is
+	// JoinGroup.RebalanceTimeoutMs.
+	RebalanceTimeout time.Duration
+	// HeartbeatInterval should be well under the session timeout set in
+	// the JoinGroup request. The default (0) is one third of that.
+	HeartbeatInterval time.Duration
+	// RetryBackoff is how long to wait before retrying after a round
+	// trip error or after a coordinator error. Default (0) is 1 second.
+	RetryBackoff time.Duration
+	// OnAssigned is called with the (complete) assignment of each new
+	// generation. Callbacks are called from the goroutine that called
+	// Run. Heartbeats are sent from their own goroutine, so the member
+	// stays in the group while callbacks run, but it can not rejoin
+	// until they return: when the group rebalances they must complete
+	// well within the rebalance timeout.
+	OnAssigned func(generationId int32, partitions []client.TopicPartition)
+	// OnRevoked is called with the revoked partitions (if there are
+	// any): all assigned partitions before rejoining the group (eager
+	// protocols), when the member has been removed from the group, and
+	// when Run returns; only the partitions that were not assigned again
+	// after the rebalance (cooperative protocols).
+	OnRevoked func(partitions []client.TopicPartition)
+	//
+	memberId     string
+	generationId int32
+	leader       bool
+	joined       bool
+	assignment   []client.TopicPartition
+	running      bool
+	stop         chan struct{}
+	done         chan struct{} // closed when Run returns
+	heartbeats   *heartbeats
+}
+
+// heartbeats are sent from their own goroutine, for one generation of the
+// group, until stop is closed or until there is an error.
+type heartbeats struct {
+	stop chan struct{}
+	done chan struct{}
+	ok   bool  // at least one heartbeat succeeded
+	err  error // why heartbeats ended; set before done is closed
+}
+
+// MemberId assigned by the coordinator. Empty before the member joins the
+// group for the first time.
+func (m *Member) MemberId() string {
+	m.Lock()
+	defer m.Unlock()
+	return m.memberId
+}
+
+// GenerationId of the group at the time of the last successful join. -1 if
+// the member has not joined the group.
+func (m *Member) GenerationId() int32 {
+	m.Lock()
+	defer m.Unlock()
+	if !m.joined {
+		return -1
+	}
+	return m.generationId
+}
+
+// Leader is true if the member was elected group leader in the current
+// generation.
+func (m *Member) Leader() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.joined && m.leader
+}
+
+// Assignment returns partitions assigned to the member in the current
+// generation, or nil if the member is not in the group.
+func (m *Member) Assignment() []client.TopicPartition {
+	m.Lock()
+	defer m.Unlock()
+	return append([]client.TopicPartition(nil), m.assignment...)
+}
+
+// CommitOffsets commits offsets as the member, with its member id and the
+// generation in which it was last assigned partitions. This way commits made
+// after the member lost its partitions in a rebalance (a "zombie" member) are
+// rejected by the coordinator (with ERR_ILLEGAL_GENERATION or
+// ERR_UNKNOWN_MEMBER_ID partition error codes) instead of overwriting offsets
+// committed by the new owners of the partitions. Returns ErrNotMember if the
+// member has not joined the group. See GroupClient.CommitOffsets.
+func (m *Member) CommitOffsets(offsets map[client.TopicPartition]client.OffsetMetadata) (map[client.TopicPartition]int16, error) {
+	m.Lock()
+	memberId, generationId := m.memberId, m.generationId
+	m.Unlock()
+	if memberId == "" {
+		return nil, ErrNotMember
+	}
+	return m.GroupClient.CommitOffsets(&client.CommitOffsetsRequest{
+		MemberId:     memberId,
+		GenerationId: generationId,
+		RetentionMs:  -1,
+		Offsets:      offsets,
+	})
+}
+
+func (m *Member) stopChan() chan struct{} {
+	m.Lock()
+	defer m.Unlock()
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+	}
+	return m.stop
+}
+
+// Close makes Run return, waits for it to revoke assigned partitions and to
+// leave the group, and then closes the connection to the coordinator. Close
+// must not be called from the OnAssigned and OnRevoked callbacks. Member can
+// not be used after Close.
+func (m *Member) Close() error { // implement io.Closer
+	stop := m.stopChan()
+	m.Lock()
+	select {
+	case <-stop:
+	default:
+		close(stop)
+	}
+	done := m.done
+	m.Unlock()
+	if done != nil {
+		<-done
+	}
+	return m.GroupClient.Close()
+}
+
+func (m *Member) sessionTimeout() time.Duration {
+	if m.SessionTimeout > 0 {
+		return m.SessionTimeout
+	}
+	return JoinGroup.SessionTimeoutMs * time.Millisecond
+}
+
+func (m *Member) rebalanceTimeout() time.Duration {
+	if m.RebalanceTimeout > 0 {
+		return m.RebalanceTimeout
+	}
+	return JoinGroup.RebalanceTimeoutMs * time.Millisecond
+}
+
+func (m *Member) heartbeatInterval() time.Duration {
+	if m.HeartbeatInterval > 0 {
+		return m.HeartbeatInterval
+	}
+	return m.sessionTimeout() / 3
+}
+
+func (m *Member) retryBackoff() time.Duration {
+	if m.RetryBackoff > 0 {
+		return m.RetryBackoff
+	}
+	return time.Second
+}
+
+// Run the group membership protocol. Blocks until Close is called (in which
+// case it returns nil) or until there is an error from which the member can
+// not recover.
+func (m *Member) Run() error {
+	if m.Protocol == nil {
+		return fmt.Errorf("no protocol")
+	}
+	stop := m.stopChan()
+	m.Lock()
+	if m.running {
+		m.Unlock()
+		return ErrRunning
+	}
+	m.running = true
+	done := make(chan struct{})
+	m.done = done
+	m.Unlock()
+	defer close(done)
+	defer m.stopHeartbeats()
+	defer m.revoke()
+	var failing time.Time // first of consecutive round trip or coordinator errors
+	for {
+		select {
+		case <-stop:
+			m.leave()
+			return nil
+		default:
+		}
+		var stepErr error
+		if m.isJoined() {
+			h := m.runningHeartbeats()
+			if h == nil {
+				// heartbeat error was retried
+				h = m.startHeartbeats(0)
+			}
+			select {
+			case <-stop:
+				m.leave()
+				return nil
+			case <-h.done:
+			}
+			m.stopHeartbeats()
+			if h.ok {
+				failing = time.Time{}
+			}
+			stepErr = h.err
+		} else {
+			m.stopHeartbeats()
+			if !m.Protocol.Cooperative() {
+				m.revoke()
+			}
+			stepErr = m.join()
+		}
+		wait, retry, err := m.handle(stepErr)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !retry:
+			failing = time.Time{}
+		case failing.IsZero():
+			failing = time.Now()
+		case time.Since(failing) > m.sessionTimeout():
+			return fmt.Errorf("member failing for longer than session timeout: %w", stepErr)
+		}
+		select {
+		case <-stop:
+			m.leave()
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// handle the result of a join or heartbeat. returns how long to wait before
+// the next step and whether the step failed and is being retried, or error
+// if the member can not recover.
+func (m *Member) handle(err error) (wait time.Duration, retry bool, _ error) {
+	if err == nil {
+		return 0, false, nil
+	}
+	var f fatal
+	if errors.As(err, &f) {
+		return 0, false, f.error
+	}
+	var e *libkafka.Error
+	if !errors.As(err, &e) {
+		return m.retryBackoff(), true, nil // round trip error
+	}
+	switch e.Code {
+	case libkafka.ERR_REBALANCE_IN_PROGRESS:
+		m.setUnjoined(false)
+		return 0, false, nil
+	case libkafka.ERR_MEMBER_ID_REQUIRED:
+		return 0, false, nil // rejoin with member id set by join
+	case libkafka.ERR_ILLEGAL_GENERATION, libkafka.ERR_UNKNOWN_MEMBER_ID:
+		m.setUnjoined(true)
+		m.revoke() // partitions are lost, even with cooperative protocols
+		return 0, false, nil
+	case libkafka.ERR_NOT_COORDINATOR, libkafka.ERR_COORDINATOR_NOT_AVAILABLE:
+		m.GroupClient.Close() // next call looks up the coordinator
+		return m.retryBackoff(), true, nil
+	case libkafka.ERR_COORDINATOR_LOAD_IN_PROGRESS:
+		return m.retryBackoff(), true, nil
+	}
+	return 0, false, err
+}
+
+func (m *Member) isJoined() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.joined
+}
+
+// mark member as needing to rejoin the group. if resetMemberId then it will
+// join as a new member.
+func (m *Member) setUnjoined(resetMemberId bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.joined = false
+	m.leader = false
+	if resetMemberId {
+		m.memberId = ""
+		m.generationId = -1
+	}
+}
+
+// revoke all assigned partitions, calling OnRevoked if there were any.
+func (m *Member) revoke() {
+	m.Lock()
+	revoked := m.assignment
+	m.assignment = nil
+	m.joined = false
+	m.Unlock()
+	if len(revoked) > 0 && m.OnRevoked != nil {
+		m.OnRevoked(revoked)
+	}
+}
+
+// join the group and sync. if the member is elected leader it computes the
+// assignments for all members.
+func (m *Member) join() error {
+	m.Lock()
+	owned := append([]client.TopicPartition(nil), m.assignment...)
+	generationId := m.generationId
+	if m.memberId == "" {
+		generationId = -1
+	}
+	m.Unlock()
+	metadata, err := m.Protocol.Metadata(owned, generationId)
+	if err != nil {
+		return fatal{fmt.Errorf("error getting protocol metadata: %w", err)}
+	}
+	req := &client.JoinGroupRequest{
+		MemberId:           m.MemberId(),
+		ProtocolType:       m.ProtocolType,
+		ProtocolName:       m.Protocol.Name(),
+		Metadata:           metadata,
+		SessionTimeoutMs:   int32(m.sessionTimeout() / time.Millisecond),
+		RebalanceTimeoutMs: int32(m.rebalanceTimeout() / time.Millisecond),
+	}
+	resp, err := m.GroupClient.Join(req)
+	if err != nil {
+		return fmt.Errorf("error making join group call: %w", err)
+	}
+	if resp.ErrorCode == libkafka.ERR_MEMBER_ID_REQUIRED {
+		// KIP-394: coordinator assigned the member id, and expects the
+		// member to join again with it
+		m.Lock()
+		m.memberId = resp.MemberId
+		m.Unlock()
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return &libkafka.Error{Code: resp.ErrorCode}
+	}
+	m.Lock()
+	m.memberId = resp.MemberId
+	m.generationId = resp.GenerationId
+	m.leader = resp.Leader == resp.MemberId
+	m.Unlock()
+	assignments := []SyncGroup.Assignment{}
+	if resp.Leader == resp.MemberId {
+		assignments, err = m.Protocol.Assign(resp.Members)
+		if err != nil {
+			return fatal{fmt.Errorf("error assigning partitions: %w", err)}
+		}
+	}
+	syncReq := &client.SyncGroupRequest{
+		MemberId:     resp.MemberId,
+		GenerationId: resp.GenerationId,
+		Assignments:  assignments,
+	}
+	syncResp, err := m.GroupClient.Sync(syncReq)
+	if err != nil {
+		return fmt.Errorf("error making sync group call: %w", err)
+	}
+	if syncResp.ErrorCode != libkafka.ERR_NONE {
+		return &libkafka.Error{Code: syncResp.ErrorCode}
+	}
+	partitions, err := m.Protocol.Partitions(syncResp.Assignment)
+	if err != nil {
+		return fatal{fmt.Errorf("error decoding assignment: %w", err)}
+	}
+	client.SortTopicPartitions(partitions)
+	m.Lock()
+	revoked := difference(m.assignment, partitions)
+	m.assignment = partitions
+	m.joined = true
+	m.Unlock()
+	m.startHeartbeats(m.heartbeatInterval())
+	if len(revoked) > 0 && m.OnRevoked != nil {
+		m.OnRevoked(revoked)
+	}
+	if m.OnAssigned != nil {
+		m.OnAssigned(resp.GenerationId, append([]client.TopicPartition(nil), partitions...))
+	}
+	if len(revoked) > 0 {
+		// revoked partitions can be assigned to other members only in
+		// the next rebalance, so trigger it
+		m.setUnjoined(false)
+	}
+	return nil
+}
+
+// leave the group (after revoking all partitions, so that they can be
+// committed in the current generation). static members don't leave. errors
+// are ignored: if leaving fails the coordinator removes the member after the
+// session timeout.
+func (m *Member) leave() {
+	m.revoke()
+	m.stopHeartbeats()
+	m.Lock()
+	memberId := m.memberId
+	m.memberId = ""
+	m.generationId = -1
+	m.Unlock()
+	if memberId == "" || m.GroupInstanceId != "" {
+		return
+	}
+	m.GroupClient.Leave(memberId)
+}
+
+// difference returns partitions in a which are not in b.
+func difference(a, b []client.TopicPartition) []client.TopicPartition {
+	in := make(map[client.TopicPartition]bool, len(b))
+	for _, tp := range b {
+		in[tp] = true
+	}
+	var out []client.TopicPartition
+	for _, tp := range a {
+		if !in[tp] {
+			out = append(out, tp)
+		}
+	}
+	return out
+}
+
+// startHeartbeats for the current generation, stopping heartbeats for the
+// previous one. The first heartbeat is sent after delay.
+func (m *Member) startHeartbeats(delay time.Duration) *heartbeats {
+	m.stopHeartbeats()
+	h := &heartbeats{stop: make(chan struct{}), done: make(chan struct{})}
+	m.Lock()
+	memberId, generationId := m.memberId, m.generationId
+	m.heartbeats = h
+	m.Unlock()
+	go func() {
+		defer close(h.done)
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-time.After(delay):
+			}
+			if h.err = m.heartbeat(memberId, generationId); h.err != nil {
+				return
+			}
+			h.ok = true
+			delay = m.heartbeatInterval()
+		}
+	}()
+	return h
+}
+
+// runningHeartbeats returns heartbeats started for the current generation,
+// or nil if there are none.
+func (m *Member) runningHeartbeats() *heartbeats {
+	m.Lock()
+	defer m.Unlock()
+	return m.heartbeats
+}
+
+// stopHeartbeats and wait for the heartbeat goroutine to return.
+func (m *Member) stopHeartbeats() {
+	m.Lock()
+	h := m.heartbeats
+	m.heartbeats = nil
+	m.Unlock()
+	if h == nil {
+		return
+	}
+	close(h.stop)
+	<-h.done
+}
+
+func (m *Member) heartbeat(memberId string, generationId int32) error {
+	resp, err := m.GroupClient.Heartbeat(memberId, generationId)
+	if err != nil {
+		return fmt.Errorf("error making heartbeat call: %w", err)
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return &libkafka.Error{Code: resp.ErrorCode}
+	}
+	return nil
+}
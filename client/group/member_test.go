@@ -0,0 +1,501 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/FindCoordinator"
+	"github.com/mkocikowski/libkafka/api/Heartbeat"
+	"github.com/mkocikowski/libkafka/api/JoinGroup"
+	"github.com/mkocikowski/libkafka/api/LeaveGroup"
+	"github.com/mkocikowski/libkafka/api/OffsetCommit"
+	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// testProtocol assigns all partitions to the group leader. assignments are
+// encoded as "topic:partition,topic:partition". metadata is the owned
+// partitions, encoded the same way, followed by "@" and generation id.
+type testProtocol struct {
+	partitions  []client.TopicPartition
+	cooperative bool
+}
+
+func (p *testProtocol) Name() string { return "test" }
+
+func (p *testProtocol) Cooperative() bool { return p.cooperative }
+
+func encode(partitions []client.TopicPartition) string {
+	var s []string
+	for _, tp := range partitions {
+		s = append(s, tp.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (p *testProtocol) Metadata(owned []client.TopicPartition, generationId int32) ([]byte, error) {
+	return []byte(fmt.Sprintf("%s@%d", encode(owned), generationId)), nil
+}
+
+func (p *testProtocol) Assign(members []JoinGroup.Member) ([]SyncGroup.Assignment, error) {
+	var assignments []SyncGroup.Assignment
+	for i, m := range members {
+		a := SyncGroup.Assignment{MemberId: m.MemberId, Assignment: []byte{}}
+		if i == 0 {
+			a.Assignment = []byte(encode(p.partitions))
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, nil
+}
+
+func (p *testProtocol) Partitions(assignment []byte) ([]client.TopicPartition, error) {
+	var partitions []client.TopicPartition
+	for _, s := range strings.Split(string(assignment), ",") {
+		if s == "" {
+			continue
+		}
+		i := strings.LastIndex(s, ":")
+		n, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, client.TopicPartition{Topic: s[:i], Partition: int32(n)})
+	}
+	return partitions, nil
+}
+
+// coordinator is a fake group coordinator for a group with a single member.
+// heartbeat error codes are scripted. so can be assignments (overriding the
+// ones sent by the leader).
+type coordinator struct {
+	sync.Mutex
+	*fakebroker.Broker
+	heartbeats  []int16  // error codes returned by consecutive heartbeats
+	assignments []string // returned by consecutive syncs
+	generation  int32
+	joins       []string // member ids in join requests
+	metadata    []string // member metadata in join requests
+	requests    []*JoinGroup.Request
+	leaves      []*LeaveGroup.Request
+	commits     []*OffsetCommit.Request
+	finds       int
+}
+
+func (c *coordinator) handle(req *fakebroker.Request) interface{} {
+	c.Lock()
+	defer c.Unlock()
+	switch req.ApiKey {
+	case api.FindCoordinator:
+		c.finds++
+		return &FindCoordinator.Response{NodeId: 1, Host: c.Host(), Port: c.Port()}
+	case api.JoinGroup:
+		r := &JoinGroup.Request{}
+		req.Unmarshal(r)
+		c.joins = append(c.joins, r.MemberId)
+		c.requests = append(c.requests, r)
+		c.metadata = append(c.metadata, string(r.Protocols[0].Metadata))
+		c.generation++
+		memberId := r.MemberId
+		if memberId == "" {
+			memberId = fmt.Sprintf("member-%d", c.generation)
+		}
+		return &JoinGroup.Response{
+			GenerationId: c.generation,
+			ProtocolName: r.Protocols[0].Name,
+			Leader:       memberId,
+			MemberId:     memberId,
+			Members: []JoinGroup.Member{{
+				MemberId:        memberId,
+				GroupInstanceId: r.GroupInstanceId,
+				Metadata:        r.Protocols[0].Metadata,
+			}},
+		}
+	case api.SyncGroup:
+		r := &SyncGroup.Request{}
+		req.Unmarshal(r)
+		if r.GenerationId != c.generation {
+			return &SyncGroup.Response{ErrorCode: libkafka.ERR_ILLEGAL_GENERATION}
+		}
+		if len(c.assignments) > 0 {
+			a := c.assignments[0]
+			c.assignments = c.assignments[1:]
+			return &SyncGroup.Response{Assignment: []byte(a)}
+		}
+		return &SyncGroup.Response{Assignment: r.Assignments[0].Assignment}
+	case api.LeaveGroup:
+		r := &LeaveGroup.Request{}
+		req.Unmarshal(r)
+		c.leaves = append(c.leaves, r)
+		return &LeaveGroup.Response{}
+	case api.OffsetCommit:
+		r := &OffsetCommit.Request{}
+		req.Unmarshal(r)
+		c.commits = append(c.commits, r)
+		return &OffsetCommit.Response{Topics: []OffsetCommit.TopicResponse{{
+			Name:       r.Topics[0].Name,
+			Partitions: []OffsetCommit.PartitionResponse{{PartitionIndex: r.Topics[0].Partitions[0].PartitionIndex}},
+		}}}
+	case api.Heartbeat:
+		resp := &Heartbeat.Response{}
+		if len(c.heartbeats) > 0 {
+			resp.ErrorCode = c.heartbeats[0]
+			c.heartbeats = c.heartbeats[1:]
+		}
+		return resp
+	}
+	return nil
+}
+
+type event struct {
+	assigned   bool
+	generation int32
+	partitions []client.TopicPartition
+}
+
+func TestUnitMemberRun(t *testing.T) {
+	c := &coordinator{
+		heartbeats: []int16{
+			libkafka.ERR_NONE,
+			libkafka.ERR_REBALANCE_IN_PROGRESS, // rejoin with same member id
+			libkafka.ERR_NONE,
+			libkafka.ERR_NOT_COORDINATOR, // find coordinator again
+			libkafka.ERR_NONE,
+			libkafka.ERR_UNKNOWN_MEMBER_ID, // rejoin as new member
+		},
+	}
+	c.Broker, _ = fakebroker.Start(1, c.handle)
+	defer c.Close()
+	partitions := []client.TopicPartition{{Topic: "foo", Partition: 1}, {Topic: "foo", Partition: 0}}
+	events := make(chan event, 10)
+	m := &Member{
+		GroupClient:       client.GroupClient{Bootstrap: c.Addr(), GroupId: "test"},
+		ProtocolType:      "consumer",
+		Protocol:          &testProtocol{partitions: partitions},
+		HeartbeatInterval: 10 * time.Millisecond,
+		RetryBackoff:      10 * time.Millisecond,
+		OnAssigned: func(generation int32, partitions []client.TopicPartition) {
+			events <- event{true, generation, partitions}
+		},
+		OnRevoked: func(partitions []client.TopicPartition) {
+			events <- event{false, 0, partitions}
+		},
+	}
+	done := make(chan error)
+	go func() { done <- m.Run() }()
+	sorted := []client.TopicPartition{{Topic: "foo", Partition: 0}, {Topic: "foo", Partition: 1}}
+	want := []event{
+		{true, 1, sorted},
+		{false, 0, sorted},
+		{true, 2, sorted},
+		{false, 0, sorted},
+		{true, 3, sorted},
+	}
+	for i, w := range want {
+		select {
+		case e := <-events:
+			if !reflect.DeepEqual(e, w) {
+				t.Fatal(i, e, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event", i)
+		}
+	}
+	if m.GenerationId() != 3 || !m.Leader() || m.MemberId() != "member-3" {
+		t.Fatal(m.GenerationId(), m.Leader(), m.MemberId())
+	}
+	if err := m.Run(); err != ErrRunning {
+		t.Fatal(err)
+	}
+	m.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; !reflect.DeepEqual(e, event{false, 0, sorted}) {
+		t.Fatal(e)
+	}
+	if m.Assignment() != nil {
+		t.Fatal(m.Assignment())
+	}
+	c.Lock()
+	defer c.Unlock()
+	if !reflect.DeepEqual(c.joins, []string{"", "member-1", ""}) {
+		t.Fatal(c.joins)
+	}
+	if c.finds < 2 {
+		t.Fatal(c.finds)
+	}
+	if !reflect.DeepEqual(c.metadata, []string{"@-1", "@1", "@-1"}) {
+		t.Fatal(c.metadata)
+	}
+	if r := c.requests[0]; r.SessionTimeoutMs != JoinGroup.SessionTimeoutMs || r.GroupInstanceId != "" {
+		t.Fatalf("%+v", r)
+	}
+	if len(c.leaves) != 1 || c.leaves[0].MemberId != "member-3" {
+		t.Fatal(c.leaves)
+	}
+}
+
+func TestUnitMemberRunStatic(t *testing.T) {
+	c := &coordinator{}
+	c.Broker, _ = fakebroker.Start(1, c.handle)
+	defer c.Close()
+	assigned := make(chan int32, 10)
+	m := &Member{
+		GroupClient: client.GroupClient{
+			Bootstrap:       c.Addr(),
+			GroupId:         "test",
+			GroupInstanceId: "instance-1",
+		},
+		Protocol:          &testProtocol{partitions: []client.TopicPartition{{Topic: "foo", Partition: 0}}},
+		SessionTimeout:    time.Minute,
+		RebalanceTimeout:  30 * time.Second,
+		HeartbeatInterval: 10 * time.Millisecond,
+		OnAssigned: func(generation int32, partitions []client.TopicPartition) {
+			assigned <- generation
+		},
+	}
+	done := make(chan error)
+	go func() { done <- m.Run() }()
+	select {
+	case <-assigned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for assignment")
+	}
+	m.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	c.Lock()
+	defer c.Unlock()
+	r := c.requests[0]
+	if r.GroupInstanceId != "instance-1" || r.SessionTimeoutMs != 60000 || r.RebalanceTimeoutMs != 30000 {
+		t.Fatalf("%+v", r)
+	}
+	for _, req := range c.Requests() {
+		want := map[int16]int16{api.JoinGroup: 5, api.SyncGroup: 3, api.Heartbeat: 3}[req.ApiKey]
+		if want != 0 && req.ApiVersion != want {
+			t.Fatal(req.ApiKey, req.ApiVersion)
+		}
+	}
+	// static members don't leave the group when closed
+	if len(c.leaves) != 0 {
+		t.Fatal(c.leaves)
+	}
+}
+
+func TestUnitMemberCommitOffsets(t *testing.T) {
+	c := &coordinator{}
+	c.Broker, _ = fakebroker.Start(1, c.handle)
+	defer c.Close()
+	foo := client.TopicPartition{Topic: "foo", Partition: 0}
+	assigned := make(chan struct{}, 1)
+	m := &Member{
+		GroupClient:       client.GroupClient{Bootstrap: c.Addr(), GroupId: "test"},
+		Protocol:          &testProtocol{partitions: []client.TopicPartition{foo}},
+		HeartbeatInterval: 10 * time.Millisecond,
+		OnAssigned: func(int32, []client.TopicPartition) {
+			assigned <- struct{}{}
+		},
+	}
+	offsets := map[client.TopicPartition]client.OffsetMetadata{foo: {Offset: 1}}
+	if _, err := m.CommitOffsets(offsets); err != ErrNotMember {
+		t.Fatal(err)
+	}
+	go m.Run()
+	defer m.Close()
+	select {
+	case <-assigned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for assignment")
+	}
+	codes, err := m.CommitOffsets(offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes[foo] != libkafka.ERR_NONE {
+		t.Fatal(codes)
+	}
+	c.Lock()
+	defer c.Unlock()
+	if r := c.commits[0]; r.MemberId != "member-1" || r.GenerationId != 1 {
+		t.Fatalf("%+v", r)
+	}
+}
+
+func TestUnitMemberHeartbeatsDuringCallbacks(t *testing.T) {
+	c := &coordinator{}
+	c.Broker, _ = fakebroker.Start(1, c.handle)
+	defer c.Close()
+	heartbeats := func() int {
+		n := 0
+		for _, req := range c.Requests() {
+			if req.ApiKey == api.Heartbeat {
+				n++
+			}
+		}
+		return n
+	}
+	m := &Member{
+		GroupClient:       client.GroupClient{Bootstrap: c.Addr(), GroupId: "test"},
+		Protocol:          &testProtocol{partitions: []client.TopicPartition{{Topic: "foo", Partition: 0}}},
+		HeartbeatInterval: 10 * time.Millisecond,
+	}
+	blocked := make(chan error, 1)
+	m.OnAssigned = func(int32, []client.TopicPartition) {
+		deadline := time.Now().Add(5 * time.Second)
+		for heartbeats() < 3 {
+			if time.Now().After(deadline) {
+				blocked <- fmt.Errorf("no heartbeats while OnAssigned runs")
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		blocked <- nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- m.Run() }()
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+	c.Lock()
+	leaves := len(c.leaves) // Close waits for Run to leave the group
+	c.Unlock()
+	if leaves != 1 {
+		t.Fatal(leaves)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnitMemberRunMemberIdRequired(t *testing.T) {
+	var b *fakebroker.Broker
+	var joins []string
+	var mu sync.Mutex
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.JoinGroup:
+			r := &JoinGroup.Request{}
+			req.Unmarshal(r)
+			mu.Lock()
+			joins = append(joins, r.MemberId)
+			mu.Unlock()
+			if r.MemberId == "" {
+				return &JoinGroup.Response{ErrorCode: libkafka.ERR_MEMBER_ID_REQUIRED, MemberId: "member-1"}
+			}
+			return &JoinGroup.Response{GenerationId: 1, Leader: "member-1", MemberId: "member-1"}
+		case api.SyncGroup:
+			return &SyncGroup.Response{Assignment: []byte{}}
+		case api.Heartbeat:
+			return &Heartbeat.Response{}
+		case api.LeaveGroup:
+			return &LeaveGroup.Response{}
+		}
+		return nil
+	})
+	defer b.Close()
+	assigned := make(chan struct{}, 1)
+	m := &Member{
+		GroupClient: client.GroupClient{Bootstrap: b.Addr(), GroupId: "test"},
+		Protocol:    &testProtocol{},
+		OnAssigned: func(int32, []client.TopicPartition) {
+			assigned <- struct{}{}
+		},
+	}
+	go m.Run()
+	defer m.Close()
+	select {
+	case <-assigned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for assignment")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(joins, []string{"", "member-1"}) {
+		t.Fatal(joins)
+	}
+}
+
+func TestUnitMemberRunCooperative(t *testing.T) {
+	c := &coordinator{
+		heartbeats:  []int16{libkafka.ERR_REBALANCE_IN_PROGRESS},
+		assignments: []string{"foo:0,foo:1", "foo:1", "foo:1"},
+	}
+	c.Broker, _ = fakebroker.Start(1, c.handle)
+	defer c.Close()
+	events := make(chan event, 10)
+	m := &Member{
+		GroupClient:       client.GroupClient{Bootstrap: c.Addr(), GroupId: "test"},
+		Protocol:          &testProtocol{cooperative: true},
+		HeartbeatInterval: 10 * time.Millisecond,
+		OnAssigned: func(generation int32, partitions []client.TopicPartition) {
+			events <- event{true, generation, partitions}
+		},
+		OnRevoked: func(partitions []client.TopicPartition) {
+			events <- event{false, 0, partitions}
+		},
+	}
+	go m.Run()
+	foo0 := client.TopicPartition{Topic: "foo", Partition: 0}
+	foo1 := client.TopicPartition{Topic: "foo", Partition: 1}
+	want := []event{
+		{true, 1, []client.TopicPartition{foo0, foo1}},
+		// no revocation on rebalance. only the partition that was
+		// taken away is revoked, and member rejoins right away
+		{false, 0, []client.TopicPartition{foo0}},
+		{true, 2, []client.TopicPartition{foo1}},
+		{true, 3, []client.TopicPartition{foo1}},
+	}
+	for i, w := range want {
+		select {
+		case e := <-events:
+			if !reflect.DeepEqual(e, w) {
+				t.Fatal(i, e, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for event", i)
+		}
+	}
+	m.Close()
+	c.Lock()
+	defer c.Unlock()
+	if !reflect.DeepEqual(c.metadata, []string{"@-1", "foo:0,foo:1@1", "foo:1@2"}) {
+		t.Fatal(c.metadata)
+	}
+}
+
+func TestUnitMemberRunFatal(t *testing.T) {
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.JoinGroup:
+			return &JoinGroup.Response{ErrorCode: libkafka.ERR_INVALID_SESSION_TIMEOUT}
+		}
+		return nil
+	})
+	defer b.Close()
+	m := &Member{
+		GroupClient: client.GroupClient{Bootstrap: b.Addr(), GroupId: "test"},
+		Protocol:    &testProtocol{},
+	}
+	err := m.Run()
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_INVALID_SESSION_TIMEOUT {
+		t.Fatal(err)
+	}
+}
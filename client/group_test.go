@@ -1,13 +1,21 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/FindCoordinator"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetCommit"
+	"github.com/mkocikowski/libkafka/api/OffsetFetch"
 	"github.com/mkocikowski/libkafka/api/SyncGroup"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
 )
 
 func TestIntegrationGroupClientJoin(t *testing.T) {
@@ -210,3 +218,183 @@ func TestIntegrationGroupOffsetsTLS(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUnitGroupClientCommitOffsets(t *testing.T) {
+	var b *fakebroker.Broker
+	var requests []*OffsetCommit.Request
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.OffsetCommit:
+			r := &OffsetCommit.Request{}
+			req.Unmarshal(r)
+			requests = append(requests, r)
+			resp := &OffsetCommit.Response{}
+			for _, t := range r.Topics {
+				tr := OffsetCommit.TopicResponse{Name: t.Name}
+				for _, p := range t.Partitions {
+					var code int16
+					if t.Name == "bar" {
+						code = libkafka.ERR_ILLEGAL_GENERATION
+					}
+					tr.Partitions = append(tr.Partitions, OffsetCommit.PartitionResponse{PartitionIndex: p.PartitionIndex, ErrorCode: code})
+				}
+				resp.Topics = append(resp.Topics, tr)
+			}
+			return resp
+		}
+		return nil
+	})
+	defer b.Close()
+	c := &GroupClient{Bootstrap: b.Addr(), GroupId: "test"}
+	req := &CommitOffsetsRequest{
+		MemberId:     "m1",
+		GenerationId: 3,
+		RetentionMs:  -1,
+		Offsets: map[TopicPartition]OffsetMetadata{
+			{"foo", 1}: {Offset: 10, Metadata: "a"},
+			{"foo", 0}: {Offset: 20},
+			{"bar", 0}: {Offset: 30},
+		},
+	}
+	codes, err := c.CommitOffsets(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[TopicPartition]int16{
+		{"foo", 0}: libkafka.ERR_NONE,
+		{"foo", 1}: libkafka.ERR_NONE,
+		{"bar", 0}: libkafka.ERR_ILLEGAL_GENERATION,
+	}
+	if !reflect.DeepEqual(codes, want) {
+		t.Fatal(codes)
+	}
+	r := requests[0]
+	if r.MemberId != "m1" || r.GenerationId != 3 || r.RetentionTimeMs != -1 || len(r.Topics) != 2 {
+		t.Fatalf("%+v", r)
+	}
+	foo := r.Topics[1]
+	if foo.Name != "foo" || foo.Partitions[1].CommitedOffset != 10 || foo.Partitions[1].CommitedMetadata != "a" {
+		t.Fatalf("%+v", foo)
+	}
+	// CommitMultiplePartitionsOffsets wraps CommitOffsets
+	err = c.CommitMultiplePartitionsOffsets("bar", map[int32]int64{0: 1, 1: 2}, 1000)
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_ILLEGAL_GENERATION {
+		t.Fatal(err)
+	}
+	if r := requests[1]; r.MemberId != "" || r.GenerationId != -1 || r.RetentionTimeMs != 1000 || len(r.Topics[0].Partitions) != 2 {
+		t.Fatalf("%+v", r)
+	}
+	// static member
+	c.GroupInstanceId = "instance-1"
+	if _, err := c.CommitOffsets(req); err != nil {
+		t.Fatal(err)
+	}
+	if r := requests[2]; r.GroupInstanceId != "instance-1" {
+		t.Fatalf("%+v", r)
+	}
+	var versions []int16
+	for _, r := range b.Requests() {
+		if r.ApiKey == api.OffsetCommit {
+			versions = append(versions, r.ApiVersion)
+		}
+	}
+	if !reflect.DeepEqual(versions, []int16{2, 2, 7}) {
+		t.Fatal(versions)
+	}
+}
+
+func TestUnitGroupClientFetchOffsets(t *testing.T) {
+	committed := map[string]map[int32]int64{
+		"foo": {0: 10, 1: 11},
+		"bar": {0: 20},
+	}
+	var b *fakebroker.Broker
+	var requests []*OffsetFetch.Request
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.Metadata:
+			return &Metadata.Response{TopicMetadata: []Metadata.TopicMetadata{{
+				Topic:             "bar",
+				PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 1}, {Partition: 0}},
+			}}}
+		case api.OffsetFetch:
+			r := &OffsetFetch.Request{}
+			req.Unmarshal(r)
+			requests = append(requests, r)
+			resp := &OffsetFetch.Response{}
+			topics := r.Topics
+			if topics == nil { // all topics
+				for name, offsets := range committed {
+					t := OffsetFetch.Topic{Name: name}
+					for p := range offsets {
+						t.PartitionIndexes = append(t.PartitionIndexes, p)
+					}
+					topics = append(topics, t)
+				}
+			}
+			for _, t := range topics {
+				tr := OffsetFetch.TopicResponse{Name: t.Name}
+				for _, p := range t.PartitionIndexes {
+					offset, ok := committed[t.Name][p]
+					if !ok {
+						offset = -1
+					}
+					tr.Partitions = append(tr.Partitions, OffsetFetch.PartitionResponse{
+						PartitionIndex: p,
+						CommitedOffset: offset,
+						Metadata:       fmt.Sprintf("%s-%d", t.Name, p),
+					})
+				}
+				resp.Topics = append(resp.Topics, tr)
+			}
+			return resp
+		}
+		return nil
+	})
+	defer b.Close()
+	c := &GroupClient{Bootstrap: b.Addr(), GroupId: "test"}
+	offsets, err := c.FetchOffsets([]TopicPartition{{"foo", 1}, {"foo", 2}, {"bar", 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[TopicPartition]*CommittedOffset{
+		{"foo", 1}: {Offset: 11, Metadata: "foo-1"},
+		{"foo", 2}: {Offset: -1, Metadata: "foo-2"},
+		{"bar", 0}: {Offset: 20, Metadata: "bar-0"},
+	}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Fatal(offsets)
+	}
+	if r := requests[0]; len(r.Topics) != 2 || r.Topics[1].Name != "foo" || !reflect.DeepEqual(r.Topics[1].PartitionIndexes, []int32{1, 2}) {
+		t.Fatalf("%+v", r)
+	}
+	offsets, err = c.FetchAllOffsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) != 3 || offsets[TopicPartition{"foo", 0}].Offset != 10 {
+		t.Fatal(offsets)
+	}
+	if r := requests[1]; r.Topics != nil {
+		t.Fatalf("%+v", r)
+	}
+	offsets, err = c.FetchTopicsOffsets([]string{"bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[TopicPartition]*CommittedOffset{
+		{"bar", 0}: {Offset: 20, Metadata: "bar-0"},
+		{"bar", 1}: {Offset: -1, Metadata: "bar-1"},
+	}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Fatal(offsets)
+	}
+	if r := requests[2]; len(r.Topics) != 1 || !reflect.DeepEqual(r.Topics[0].PartitionIndexes, []int32{0, 1}) {
+		t.Fatalf("%+v", r)
+	}
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/ConsumerProtocol"
+	"github.com/mkocikowski/libkafka/api/DeleteGroups"
+	"github.com/mkocikowski/libkafka/api/DescribeGroups"
+	"github.com/mkocikowski/libkafka/api/ListGroups"
+)
+
+// GetGroups lists groups on all brokers in the cluster (each broker lists
+// only the groups it coordinates, so the call is made to every broker).
+// Groups are sorted by group id. If the call to any of the brokers fails
+// (round trip error or error code in the response) the groups listed by the
+// other brokers are returned along with the (first) error.
+func GetGroups(bootstrap string, tlsConfig *tls.Config) ([]ListGroups.Group, error) {
+	return GetGroupsSASL(bootstrap, tlsConfig, nil)
+}
+
+// GetGroupsSASL is GetGroups on connections authenticated with sasl.
+func GetGroupsSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL) ([]ListGroups.Group, error) {
+	meta, err := CallMetadataSASL(bootstrap, tlsConfig, sasl, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting brokers: %w", err)
+	}
+	groups := []ListGroups.Group{}
+	var firstErr error
+	for _, b := range meta.Brokers {
+		resp := &ListGroups.Response{}
+		err := connectAndCall(b.Addr(), tlsConfig, sasl, ListGroups.NewRequest(), resp)
+		if err == nil && resp.ErrorCode != libkafka.ERR_NONE {
+			err = &libkafka.Error{Code: resp.ErrorCode}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error listing groups on broker %d: %w", b.NodeId, err)
+			}
+			continue
+		}
+		groups = append(groups, resp.Groups...)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].GroupId < groups[j].GroupId })
+	return groups, firstErr
+}
+
+// groupsByCoordinator looks up coordinators of groups and returns groups keyed
+// by coordinator address. Groups for which the FindCoordinator response has
+// an error code are returned in the second map (with the error code).
+func groupsByCoordinator(bootstrap string, tlsConfig *tls.Config, sasl SASL, groupIds []string) (map[string][]string, map[string]int16, error) {
+	byCoordinator := make(map[string][]string)
+	errorCodes := make(map[string]int16)
+	for _, g := range groupIds {
+		resp, err := CallFindCoordinatorSASL(bootstrap, tlsConfig, sasl, g)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error finding coordinator for group %q: %w", g, err)
+		}
+		if resp.ErrorCode != libkafka.ERR_NONE {
+			errorCodes[g] = resp.ErrorCode
+			continue
+		}
+		addr := net.JoinHostPort(resp.Host, strconv.Itoa(int(resp.Port)))
+		byCoordinator[addr] = append(byCoordinator[addr], g)
+	}
+	return byCoordinator, errorCodes, nil
+}
+
+// GroupDescription is the state of a group as reported by its coordinator.
+type GroupDescription struct {
+	GroupId string
+	// ErrorCode for the group (for example ERR_COORDINATOR_NOT_AVAILABLE
+	// or ERR_GROUP_AUTHORIZATION_FAILED). If not ERR_NONE, other fields
+	// are empty. Groups which do not exist are reported with state
+	// DescribeGroups.StateDead and no error.
+	ErrorCode    int16
+	State        string // one of DescribeGroups.State*
+	ProtocolType string
+	Protocol     string // for consumer groups name of the assignor
+	Members      []GroupMemberDescription
+}
+
+type GroupMemberDescription struct {
+	MemberId        string
+	GroupInstanceId string
+	ClientId        string
+	ClientHost      string
+	Metadata        []byte
+	Assignment      []byte
+	// Partitions decoded from the Assignment, if the group protocol type
+	// is "consumer" (and the assignment can be decoded); sorted.
+	Partitions []TopicPartition
+}
+
+func describeGroup(g *DescribeGroups.Group) *GroupDescription {
+	d := &GroupDescription{
+		GroupId:      g.GroupId,
+		ErrorCode:    g.ErrorCode,
+		State:        g.GroupState,
+		ProtocolType: g.ProtocolType,
+		Protocol:     g.ProtocolData,
+		Members:      []GroupMemberDescription{},
+	}
+	for _, m := range g.Members {
+		md := GroupMemberDescription{
+			MemberId:        m.MemberId,
+			GroupInstanceId: m.GroupInstanceId,
+			ClientId:        m.ClientId,
+			ClientHost:      m.ClientHost,
+			Metadata:        m.MemberMetadata,
+			Assignment:      m.MemberAssignment,
+		}
+		if g.ProtocolType == ConsumerProtocol.ProtocolType {
+			if a, err := ConsumerProtocol.UnmarshalAssignment(m.MemberAssignment); err == nil {
+				md.Partitions = []TopicPartition{}
+				for _, t := range a.AssignedPartitions {
+					for _, p := range t.Partitions {
+						md.Partitions = append(md.Partitions, TopicPartition{Topic: t.Topic, Partition: p})
+					}
+				}
+				SortTopicPartitions(md.Partitions)
+			}
+		}
+		d.Members = append(d.Members, md)
+	}
+	return d
+}
+
+// GetGroupDescriptions describes groups, in the order of groupIds. Requests
+// are sent to group coordinators (one request per coordinator). Errors are
+// returned only for round trip errors; group errors are in
+// GroupDescription.ErrorCode.
+func GetGroupDescriptions(bootstrap string, tlsConfig *tls.Config, groupIds []string) ([]*GroupDescription, error) {
+	return GetGroupDescriptionsSASL(bootstrap, tlsConfig, nil, groupIds)
+}
+
+// GetGroupDescriptionsSASL is GetGroupDescriptions on connections authenticated
+// with sasl.
+func GetGroupDescriptionsSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, groupIds []string) ([]*GroupDescription, error) {
+	byCoordinator, errorCodes, err := groupsByCoordinator(bootstrap, tlsConfig, sasl, groupIds)
+	if err != nil {
+		return nil, err
+	}
+	described := make(map[string]*GroupDescription)
+	for g, code := range errorCodes {
+		described[g] = &GroupDescription{GroupId: g, ErrorCode: code, Members: []GroupMemberDescription{}}
+	}
+	for addr, groups := range byCoordinator {
+		resp := &DescribeGroups.Response{}
+		if err := connectAndCall(addr, tlsConfig, sasl, DescribeGroups.NewRequest(groups), resp); err != nil {
+			return nil, fmt.Errorf("error describing groups: %w", err)
+		}
+		for i := range resp.Groups {
+			described[resp.Groups[i].GroupId] = describeGroup(&resp.Groups[i])
+		}
+	}
+	out := make([]*GroupDescription, len(groupIds))
+	for i, g := range groupIds {
+		if out[i] = described[g]; out[i] == nil {
+			return nil, fmt.Errorf("no description for group %q in response", g)
+		}
+	}
+	return out, nil
+}
+
+// DeleteEmptyGroups deletes groups and returns error codes for all groups
+// (keyed by group id): ERR_NONE if the group was deleted, ERR_NON_EMPTY_GROUP
+// if it has members, ERR_GROUP_ID_NOT_FOUND if it does not exist, or
+// coordinator errors. Deleting a group deletes its committed offsets.
+// Requests are sent to group coordinators (one request per coordinator).
+// Errors are returned only for round trip errors.
+func DeleteEmptyGroups(bootstrap string, tlsConfig *tls.Config, groupIds []string) (map[string]int16, error) {
+	return DeleteEmptyGroupsSASL(bootstrap, tlsConfig, nil, groupIds)
+}
+
+// DeleteEmptyGroupsSASL is DeleteEmptyGroups on connections authenticated with
+// sasl.
+func DeleteEmptyGroupsSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, groupIds []string) (map[string]int16, error) {
+	byCoordinator, errorCodes, err := groupsByCoordinator(bootstrap, tlsConfig, sasl, groupIds)
+	if err != nil {
+		return nil, err
+	}
+	for addr, groups := range byCoordinator {
+		resp := &DeleteGroups.Response{}
+		if err := connectAndCall(addr, tlsConfig, sasl, DeleteGroups.NewRequest(groups), resp); err != nil {
+			return nil, fmt.Errorf("error deleting groups: %w", err)
+		}
+		for _, r := range resp.Results {
+			errorCodes[r.GroupId] = r.ErrorCode
+		}
+	}
+	for _, g := range groupIds {
+		if _, ok := errorCodes[g]; !ok {
+			return nil, fmt.Errorf("no result for group %q in response", g)
+		}
+	}
+	return errorCodes, nil
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/ConsumerProtocol"
+	"github.com/mkocikowski/libkafka/api/DeleteGroups"
+	"github.com/mkocikowski/libkafka/api/DescribeGroups"
+	"github.com/mkocikowski/libkafka/api/FindCoordinator"
+	"github.com/mkocikowski/libkafka/api/ListGroups"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// fakeGroupCluster has two brokers. broker 1 coordinates groups "foo" (a
+// consumer group with one member) and "bar" (empty); broker 2 coordinates
+// group "baz" and is failing ListGroups.
+func fakeGroupCluster(t *testing.T) (*fakebroker.Broker, *fakebroker.Broker) {
+	t.Helper()
+	var b1, b2 *fakebroker.Broker
+	coordinators := map[string]*fakebroker.Broker{}
+	brokers := func() []Metadata.Broker {
+		return []Metadata.Broker{
+			{NodeId: 1, Host: b1.Host(), Port: b1.Port()},
+			{NodeId: 2, Host: b2.Host(), Port: b2.Port()},
+		}
+	}
+	assignment, _ := ConsumerProtocol.NewAssignment([]ConsumerProtocol.TopicPartitions{
+		{Topic: "t", Partitions: []int32{1, 0}},
+	}).Marshal()
+	b1, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{Brokers: brokers()}
+		case api.FindCoordinator:
+			r := &FindCoordinator.Request{}
+			req.Unmarshal(r)
+			c, ok := coordinators[r.Key]
+			if !ok {
+				return &FindCoordinator.Response{ErrorCode: libkafka.ERR_COORDINATOR_NOT_AVAILABLE}
+			}
+			return &FindCoordinator.Response{NodeId: c.NodeId, Host: c.Host(), Port: c.Port()}
+		case api.ListGroups:
+			return &ListGroups.Response{Groups: []ListGroups.Group{
+				{GroupId: "foo", ProtocolType: "consumer"},
+				{GroupId: "bar"},
+			}}
+		case api.DescribeGroups:
+			return &DescribeGroups.Response{Groups: []DescribeGroups.Group{
+				{
+					GroupId:      "foo",
+					GroupState:   DescribeGroups.StateStable,
+					ProtocolType: "consumer",
+					ProtocolData: "range",
+					Members: []DescribeGroups.Member{{
+						MemberId:         "m1",
+						ClientId:         "c1",
+						ClientHost:       "/10.0.0.1",
+						MemberMetadata:   []byte{},
+						MemberAssignment: assignment,
+					}},
+				},
+				{GroupId: "bar", GroupState: DescribeGroups.StateEmpty},
+			}}
+		case api.DeleteGroups:
+			r := &DeleteGroups.Request{}
+			req.Unmarshal(r)
+			resp := &DeleteGroups.Response{}
+			for _, g := range r.GroupsNames {
+				code := libkafka.ERR_NONE
+				if g == "foo" {
+					code = libkafka.ERR_NON_EMPTY_GROUP
+				}
+				resp.Results = append(resp.Results, DeleteGroups.Result{GroupId: g, ErrorCode: int16(code)})
+			}
+			return resp
+		}
+		return nil
+	})
+	b2, _ = fakebroker.Start(2, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.ListGroups:
+			return &ListGroups.Response{ErrorCode: libkafka.ERR_COORDINATOR_LOAD_IN_PROGRESS}
+		case api.DescribeGroups:
+			return &DescribeGroups.Response{Groups: []DescribeGroups.Group{
+				{GroupId: "baz", GroupState: DescribeGroups.StateDead},
+			}}
+		case api.DeleteGroups:
+			return &DeleteGroups.Response{Results: []DeleteGroups.Result{
+				{GroupId: "baz", ErrorCode: libkafka.ERR_GROUP_ID_NOT_FOUND},
+			}}
+		}
+		return nil
+	})
+	coordinators["foo"] = b1
+	coordinators["bar"] = b1
+	coordinators["baz"] = b2
+	return b1, b2
+}
+
+func TestUnitGetGroups(t *testing.T) {
+	b1, b2 := fakeGroupCluster(t)
+	defer b1.Close()
+	defer b2.Close()
+	groups, err := GetGroups(b1.Addr(), nil)
+	var e *libkafka.Error
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_COORDINATOR_LOAD_IN_PROGRESS {
+		t.Fatal(err)
+	}
+	want := []ListGroups.Group{{GroupId: "bar"}, {GroupId: "foo", ProtocolType: "consumer"}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatal(groups)
+	}
+}
+
+func TestUnitGetGroupDescriptions(t *testing.T) {
+	b1, b2 := fakeGroupCluster(t)
+	defer b1.Close()
+	defer b2.Close()
+	groups, err := GetGroupDescriptions(b1.Addr(), nil, []string{"baz", "foo", "bar", "qux"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 4 {
+		t.Fatal(len(groups))
+	}
+	if g := groups[0]; g.GroupId != "baz" || g.State != DescribeGroups.StateDead {
+		t.Fatalf("%+v", g)
+	}
+	foo := groups[1]
+	if foo.State != DescribeGroups.StateStable || foo.Protocol != "range" || len(foo.Members) != 1 {
+		t.Fatalf("%+v", foo)
+	}
+	m := foo.Members[0]
+	if m.MemberId != "m1" || m.ClientId != "c1" || m.ClientHost != "/10.0.0.1" {
+		t.Fatalf("%+v", m)
+	}
+	if want := []TopicPartition{{"t", 0}, {"t", 1}}; !reflect.DeepEqual(m.Partitions, want) {
+		t.Fatal(m.Partitions)
+	}
+	if g := groups[2]; g.State != DescribeGroups.StateEmpty || len(g.Members) != 0 {
+		t.Fatalf("%+v", g)
+	}
+	if g := groups[3]; g.ErrorCode != libkafka.ERR_COORDINATOR_NOT_AVAILABLE {
+		t.Fatalf("%+v", g)
+	}
+	// one request per coordinator
+	var n int
+	for _, r := range b1.Requests() {
+		if r.ApiKey == api.DescribeGroups {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestUnitDeleteEmptyGroups(t *testing.T) {
+	b1, b2 := fakeGroupCluster(t)
+	defer b1.Close()
+	defer b2.Close()
+	codes, err := DeleteEmptyGroups(b1.Addr(), nil, []string{"foo", "bar", "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int16{
+		"foo": libkafka.ERR_NON_EMPTY_GROUP,
+		"bar": libkafka.ERR_NONE,
+		"baz": libkafka.ERR_GROUP_ID_NOT_FOUND,
+	}
+	if !reflect.DeepEqual(codes, want) {
+		t.Fatal(codes)
+	}
+}
@@ -0,0 +1,147 @@
+// Package lag reports consumer group lag: for every partition of the given
+// topics, the offset committed by the group, log start and log end offsets,
+// the lag (number of messages between the committed offset and the log end),
+// and an estimate of how far behind (in time) the group is. Committed offsets
+// are fetched from the group coordinator in a single request, and log offsets
+// with ListOffsets requests batched per partition leader.
+package lag
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/ListOffsets"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// PartitionLag is the lag of the group on a single partition.
+type PartitionLag struct {
+	Topic     string
+	Partition int32
+	// CommittedOffset is -1 if the group has no offset committed for the
+	// partition.
+	CommittedOffset int64
+	LogStartOffset  int64
+	LogEndOffset    int64
+	// Lag is the number of messages between the committed offset and the
+	// log end offset (never negative). It is -1 if there is no committed
+	// offset.
+	Lag int64
+	// TimeLag estimates how long it would take to produce Lag messages at
+	// the rate at which messages were produced to the partition during
+	// the reporter Window. It is zero if Lag is zero, and -1 if it can not
+	// be estimated (there is no committed offset, or nothing was produced
+	// during the window: the group is then at least Window behind).
+	TimeLag time.Duration
+	// ErrorCode is the first error code from OffsetFetch or ListOffsets
+	// responses for the partition. If it is not ERR_NONE the offsets may
+	// be -1.
+	ErrorCode int16
+}
+
+// DefaultWindow is the default Reporter window.
+const DefaultWindow = 5 * time.Minute
+
+// Reporter reports lag of a group. The zero value is not usable: Bootstrap
+// and GroupId must be set.
+type Reporter struct {
+	Bootstrap string // srv or host:port
+	TLS       *tls.Config
+	SASL      client.SASL
+	GroupId   string
+	// Window over which the produce rate used for TimeLag estimates is
+	// measured. Default (0) is DefaultWindow.
+	Window time.Duration
+}
+
+func (r *Reporter) window() time.Duration {
+	if r.Window > 0 {
+		return r.Window
+	}
+	return DefaultWindow
+}
+
+// Report lag for all partitions of the topics, sorted by topic and
+// partition. Error is returned if the metadata for any of the topics has an
+// error (for example the topic does not exist), if committed offsets can not
+// be fetched, or if log offsets can not be listed for some of the partitions
+// (for partition level errors see PartitionLag.ErrorCode).
+func (r *Reporter) Report(topics []string) ([]*PartitionLag, error) {
+	tps, err := client.GetPartitionsSASL(r.Bootstrap, r.TLS, r.SASL, topics)
+	if err != nil {
+		return nil, err
+	}
+	c := &client.GroupClient{Bootstrap: r.Bootstrap, TLS: r.TLS, SASL: r.SASL, GroupId: r.GroupId}
+	defer c.Close()
+	committed, err := c.FetchOffsets(tps)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching committed offsets: %w", err)
+	}
+	window := r.window()
+	since := time.Now().Add(-window).UnixNano() / int64(time.Millisecond)
+	offsets, err := client.GetOffsetsSASL(r.Bootstrap, r.TLS, r.SASL, tps, ListOffsets.Earliest, ListOffsets.Latest, since)
+	if err != nil {
+		return nil, fmt.Errorf("error listing log offsets: %w", err)
+	}
+	lags := make([]*PartitionLag, len(tps))
+	for i, tp := range tps {
+		lags[i] = partitionLag(tp, committed[tp], listed(offsets[0], tp), listed(offsets[1], tp), listed(offsets[2], tp), window)
+	}
+	return lags, nil
+}
+
+// listed returns the partition result from GetOffsets. If the partition is
+// missing (the broker left it out of its response) the result has
+// ERR_UNKNOWN_SERVER_ERROR error code and offset -1, the same way GetOffsets
+// reports partitions with no leader.
+func listed(offsets map[client.TopicPartition]*ListOffsets.PartitionResponse, tp client.TopicPartition) *ListOffsets.PartitionResponse {
+	if p, ok := offsets[tp]; ok {
+		return p
+	}
+	return &ListOffsets.PartitionResponse{
+		Partition: tp.Partition,
+		ErrorCode: libkafka.ERR_UNKNOWN_SERVER_ERROR,
+		Timestamp: -1,
+		Offset:    -1,
+	}
+}
+
+func partitionLag(tp client.TopicPartition, committed *client.CommittedOffset, start, end, since *ListOffsets.PartitionResponse, window time.Duration) *PartitionLag {
+	if committed == nil { // not in the offset fetch response
+		committed = &client.CommittedOffset{Offset: -1}
+	}
+	l := &PartitionLag{
+		Topic:           tp.Topic,
+		Partition:       tp.Partition,
+		CommittedOffset: committed.Offset,
+		LogStartOffset:  start.Offset,
+		LogEndOffset:    end.Offset,
+		Lag:             -1,
+		TimeLag:         -1,
+	}
+	for _, code := range []int16{committed.ErrorCode, start.ErrorCode, end.ErrorCode, since.ErrorCode} {
+		if code != libkafka.ERR_NONE {
+			l.ErrorCode = code
+			break
+		}
+	}
+	if l.CommittedOffset < 0 || l.LogEndOffset < 0 {
+		return l
+	}
+	l.Lag = l.LogEndOffset - l.CommittedOffset
+	if l.Lag <= 0 {
+		l.Lag = 0
+		l.TimeLag = 0
+		return l
+	}
+	// offset of the first message produced during the window; -1 if
+	// nothing was produced
+	if since.Offset < 0 || since.Offset >= l.LogEndOffset {
+		return l
+	}
+	produced := l.LogEndOffset - since.Offset
+	l.TimeLag = time.Duration(float64(window) * float64(l.Lag) / float64(produced))
+	return l
+}
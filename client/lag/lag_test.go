@@ -0,0 +1,121 @@
+package lag
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/FindCoordinator"
+	"github.com/mkocikowski/libkafka/api/ListOffsets"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetFetch"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+func TestUnitReport(t *testing.T) {
+	committed := map[int32]int64{0: 50, 1: 100, 3: 120}
+	var listOffsetsCalls int
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{
+						{Partition: 3, Leader: 1},
+						{Partition: 2, Leader: 1},
+						{Partition: 1, Leader: 1},
+						{Partition: 0, Leader: 1},
+					}},
+				},
+			}
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.OffsetFetch:
+			r := &OffsetFetch.Request{}
+			req.Unmarshal(r)
+			resp := &OffsetFetch.Response{}
+			for _, t := range r.Topics {
+				tr := OffsetFetch.TopicResponse{Name: t.Name}
+				for _, p := range t.PartitionIndexes {
+					offset, ok := committed[p]
+					if !ok {
+						offset = -1
+					}
+					tr.Partitions = append(tr.Partitions, OffsetFetch.PartitionResponse{PartitionIndex: p, CommitedOffset: offset})
+				}
+				resp.Topics = append(resp.Topics, tr)
+			}
+			return resp
+		case api.ListOffsets:
+			listOffsetsCalls++
+			r := &ListOffsets.RequestBody{}
+			req.Unmarshal(r)
+			resp := &ListOffsets.Response{}
+			for _, t := range r.Topics {
+				tr := ListOffsets.TopicResponse{Topic: t.Topic}
+				for _, p := range t.Partitions {
+					pr := ListOffsets.PartitionResponse{Partition: p.Partition}
+					switch {
+					case p.Timestamp == ListOffsets.Earliest:
+						pr.Offset = 10
+					case p.Timestamp == ListOffsets.Latest:
+						pr.Offset = 100
+						if p.Partition == 3 {
+							pr.Offset = 130
+						}
+					case p.Partition == 0:
+						pr.Offset = 75 // 25 messages produced during window
+					case p.Partition == 2:
+						continue // missing from the response
+					default:
+						pr.Offset = -1 // nothing produced during window
+					}
+					tr.Partitions = append(tr.Partitions, pr)
+				}
+				resp.Responses = append(resp.Responses, tr)
+			}
+			return resp
+		}
+		return nil
+	})
+	defer b.Close()
+	r := &Reporter{Bootstrap: b.Addr(), GroupId: "test", Window: time.Minute}
+	lags, err := r.Report([]string{"foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listOffsetsCalls != 3 { // one per timestamp
+		t.Fatal(listOffsetsCalls)
+	}
+	if len(lags) != 4 {
+		t.Fatal(len(lags))
+	}
+	for i, l := range lags {
+		code := int16(libkafka.ERR_NONE)
+		if i == 2 {
+			code = libkafka.ERR_UNKNOWN_SERVER_ERROR
+		}
+		if l.Topic != "foo" || l.Partition != int32(i) || l.LogStartOffset != 10 || l.ErrorCode != code {
+			t.Fatalf("%+v", l)
+		}
+	}
+	// 50 behind, 25 produced during the 1 minute window
+	if l := lags[0]; l.CommittedOffset != 50 || l.Lag != 50 || l.TimeLag != 2*time.Minute {
+		t.Fatalf("%+v", l)
+	}
+	// caught up
+	if l := lags[1]; l.Lag != 0 || l.TimeLag != 0 {
+		t.Fatalf("%+v", l)
+	}
+	// no committed offset, and missing from the response for the window
+	if l := lags[2]; l.CommittedOffset != -1 || l.Lag != -1 || l.TimeLag != -1 {
+		t.Fatalf("%+v", l)
+	}
+	// behind, but nothing produced during window
+	if l := lags[3]; l.Lag != 10 || l.TimeLag != -1 {
+		t.Fatalf("%+v", l)
+	}
+}
@@ -4,17 +4,17 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"sync"
 	"time"
 
 	"github.com/mkocikowski/libkafka"
 	"github.com/mkocikowski/libkafka/api"
-	"github.com/mkocikowski/libkafka/api/ApiVersions"
+	"github.com/mkocikowski/libkafka/api/DeleteRecords"
 	"github.com/mkocikowski/libkafka/api/Fetch"
 	"github.com/mkocikowski/libkafka/api/ListOffsets"
 	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetForLeaderEpoch"
 	"github.com/mkocikowski/libkafka/api/Produce"
 )
 
@@ -24,7 +24,13 @@ var (
 )
 
 func GetPartitionLeader(bootstrap string, tlsConfig *tls.Config, topic string, partition int32) (*Metadata.Broker, error) {
-	meta, err := CallMetadata(bootstrap, tlsConfig, []string{topic})
+	return GetPartitionLeaderSASL(bootstrap, tlsConfig, nil, topic, partition)
+}
+
+// GetPartitionLeaderSASL is GetPartitionLeader on connections authenticated
+// with sasl.
+func GetPartitionLeaderSASL(bootstrap string, tlsConfig *tls.Config, sasl SASL, topic string, partition int32) (*Metadata.Broker, error) {
+	meta, err := CallMetadataSASL(bootstrap, tlsConfig, sasl, []string{topic})
 	if err != nil {
 		return nil, err
 	}
@@ -55,6 +61,7 @@ type PartitionClient struct {
 	sync.Mutex
 	Bootstrap string // srv or host:port
 	TLS       *tls.Config
+	SASL      SASL
 	ClientId  string
 	Topic     string
 	Partition int32
@@ -67,12 +74,18 @@ type PartitionClient struct {
 	// This way, if more than ConnMaxIdle passed since the last call,
 	// PartitionClient will close the current connection, and open a new
 	// one. Default value of 0 means that no check it made.
-	ConnMaxIdle  time.Duration
-	leader       *Metadata.Broker
-	versions     *ApiVersions.Response
-	conn         net.Conn
-	connOpened   time.Time
-	connLastUsed time.Time
+	ConnMaxIdle time.Duration
+	// When a client exceeds its quota, the broker sets ThrottleTimeMs in
+	// the response. For recent api versions (KIP-219, Kafka 2.0+) the
+	// broker responds right away, and expects the client not to send
+	// any more requests for the throttle time (if the client does, the
+	// broker mutes the connection). If HonorThrottle is true, the next
+	// call after a throttled response blocks (holding the client mutex)
+	// until the throttle time has passed. Throttle statistics are
+	// collected regardless of this setting; see ThrottleStats.
+	HonorThrottle bool
+	leader        *Metadata.Broker
+	brokerConn
 }
 
 // if the client has an open connection, check it for libkafka.ConnectionTTL
@@ -81,76 +94,24 @@ type PartitionClient struct {
 // IdleTimeout) find partition leader, connect to it, and set c.leader
 func (c *PartitionClient) connect() (err error) {
 	// no mutex here. connect() is called only from call(), and that is
-	// where the mutex is acquired for both connect() and disconnect()
-	if c.conn != nil {
-		switch {
-		case libkafka.ConnectionTTL > 0 && time.Since(c.connOpened) > libkafka.ConnectionTTL:
-			// connection exceeded TTL
-			c.disconnect()
-		case c.ConnMaxIdle > 0 && time.Since(c.connLastUsed) > c.ConnMaxIdle:
-			// connection exceeded MaxIdle
-			c.disconnect()
-		default:
-			// ConnTTL and ConnMaxIdle do not apply. Leave connection open
-			return nil
-		}
+	// where the mutex is acquired
+	if c.reuse(c.ConnMaxIdle) {
+		return nil
 	}
-	c.leader, err = GetPartitionLeader(c.Bootstrap, c.TLS, c.Topic, c.Partition)
+	c.leader, err = GetPartitionLeaderSASL(c.Bootstrap, c.TLS, c.SASL, c.Topic, c.Partition)
 	if err != nil {
 		return fmt.Errorf("error getting partition leader: %w", err)
 	}
-	if c.TLS != nil {
-		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: libkafka.DialTimeout}, "tcp", c.leader.Addr(), c.TLS)
-		if err != nil {
-			return err
-		}
-		c.conn = conn
-	}
-	if c.TLS == nil {
-		conn, err := net.DialTimeout("tcp", c.leader.Addr(), libkafka.DialTimeout)
-		if err != nil {
-			return err
-		}
-		c.conn = conn
-	}
-	c.connOpened = time.Now().UTC()
-	c.connLastUsed = c.connOpened
-	// version information is needed only for the kafka 1.0 produce hack
-	c.versions, err = apiVersions(c.conn)
-	if err != nil {
-		return fmt.Errorf("error getting api versions from broker: %w", err)
-	}
-	if code := c.versions.ErrorCode; code != libkafka.ERR_NONE {
-		return fmt.Errorf("error response for api versions call from broker: %w", libkafka.Error{Code: code})
-	}
-	return nil
-}
-
-// close connection to leader, but do not zero c.leader (so that it can still
-// be accessed with c.Leader call)
-func (c *PartitionClient) disconnect() error {
-	// no mutex here. disconnect() is called only from call() and from
-	// Close(), and that is where the mutex is acquired.
-	if c.conn == nil {
-		return nil
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("recovered in PartitionClient.disconnect: %v", r)
-		}
-	}()
-	c.conn.Close()
-	c.conn = nil
-	return nil
+	return c.open(c.leader.Addr(), c.TLS, c.SASL)
 }
 
 // Close the connection to the topic partition leader. Nop if no active
 // connection. If there is a request in progress blocks until the request
-// completes.
+// completes. Leader is not reset.
 func (c *PartitionClient) Close() error { // implement io.Closer
 	c.Lock()
 	defer c.Unlock()
-	c.disconnect()
+	c.close()
 	return nil
 }
 
@@ -173,23 +134,23 @@ func (c *PartitionClient) Conn() net.Conn {
 	return c.conn
 }
 
+// ThrottleStats returns statistics of broker throttling of the client.
+func (c *PartitionClient) ThrottleStats() ThrottleStats {
+	c.Lock()
+	defer c.Unlock()
+	return c.throttle.stats
+}
+
 func (c *PartitionClient) call(req *api.Request, v interface{}) error {
 	c.Lock()
 	defer c.Unlock()
 	if err := c.connect(); err != nil {
 		return fmt.Errorf("error connecting to partition leader (TLS: %v): %w", c.TLS != nil, err)
 	}
-	// TODO: remove
-	if req.ApiKey == api.Produce && c.versions.ApiKeys[api.Produce].MaxVersion == 5 {
-		req.ApiVersion = 5 // downgrade to be able to produce to kafka 1.0
+	if err := c.roundTrip(req, v, c.HonorThrottle); err != nil {
+		return fmt.Errorf("error making call to partition leader (TLS: %v): %w", c.TLS != nil, err)
 	}
-	err := call(c.conn, req, v)
-	if err != nil {
-		c.disconnect()
-		err = fmt.Errorf("error making call to partition leader (TLS: %v): %w", c.TLS != nil, err)
-	}
-	c.connLastUsed = time.Now().UTC()
-	return err
+	return nil
 }
 
 func (c *PartitionClient) ListOffsets(timestampMs int64) (*ListOffsets.Response, error) {
@@ -198,6 +159,41 @@ func (c *PartitionClient) ListOffsets(timestampMs int64) (*ListOffsets.Response,
 	return resp, c.call(req, resp)
 }
 
+// LeaderEpoch returns the current leader epoch of the partition (KIP-320), as
+// known to the broker to which the client is connected. It makes a Metadata
+// v7 call, and returns ERR_UNSUPPORTED_VERSION for brokers older than Kafka
+// 2.1. Unlike most other PartitionClient calls, it interprets the response:
+// an error code for the topic or the partition is returned as
+// *libkafka.Error.
+func (c *PartitionClient) LeaderEpoch() (int32, error) {
+	req := Metadata.NewLeaderEpochsRequest([]string{c.Topic})
+	resp := &Metadata.Response{}
+	if err := c.call(req, resp); err != nil {
+		return -1, err
+	}
+	for _, t := range resp.TopicMetadata {
+		if t.Topic == c.Topic && t.ErrorCode != libkafka.ERR_NONE {
+			return -1, &libkafka.Error{Code: t.ErrorCode}
+		}
+	}
+	p := resp.Partitions(c.Topic)[c.Partition]
+	if p == nil {
+		return -1, ErrPartitionDoesNotExist
+	}
+	if p.ErrorCode != libkafka.ERR_NONE {
+		return -1, &libkafka.Error{Code: p.ErrorCode}
+	}
+	return p.LeaderEpoch, nil
+}
+
+// OffsetForLeaderEpoch returns the end offset of leaderEpoch in the log of the
+// partition leader (KIP-320). See OffsetForLeaderEpoch.NewRequest.
+func (c *PartitionClient) OffsetForLeaderEpoch(currentLeaderEpoch, leaderEpoch int32) (*OffsetForLeaderEpoch.Response, error) {
+	req := OffsetForLeaderEpoch.NewRequest(c.Topic, c.Partition, currentLeaderEpoch, leaderEpoch)
+	resp := &OffsetForLeaderEpoch.Response{}
+	return resp, c.call(req, resp)
+}
+
 func (c *PartitionClient) Fetch(args *Fetch.Args) (*Fetch.Response, error) {
 	req := Fetch.NewRequest(args)
 	resp := &Fetch.Response{}
@@ -209,3 +205,28 @@ func (c *PartitionClient) Produce(args *Produce.Args, recordSet []byte) (*Produc
 	resp := &Produce.Response{}
 	return resp, c.call(req, resp)
 }
+
+// DeleteRecords deletes records with offsets lower than offset, advancing the
+// partition log start offset (DeleteRecords.HighWatermark deletes all records
+// up to the high watermark). Returns the new log start offset ("low
+// watermark"). Unlike other PartitionClient calls, DeleteRecords interprets
+// the response: an error code for the partition is returned as
+// *libkafka.Error (for example ERR_OFFSET_OUT_OF_RANGE if offset is greater
+// than the high watermark, or ERR_POLICY_VIOLATION for compacted topics).
+// TimeoutMs is how long the leader waits for the followers to delete the
+// records. Requires Kafka 1.0+.
+func (c *PartitionClient) DeleteRecords(offset int64, timeoutMs int32) (int64, error) {
+	req := DeleteRecords.NewRequest(c.Topic, c.Partition, offset, timeoutMs)
+	resp := &DeleteRecords.Response{}
+	if err := c.call(req, resp); err != nil {
+		return -1, err
+	}
+	if len(resp.Topics) != 1 || len(resp.Topics[0].Partitions) != 1 {
+		return -1, fmt.Errorf("unexpected delete records response: %+v", resp)
+	}
+	p := resp.Topics[0].Partitions[0]
+	if p.ErrorCode != libkafka.ERR_NONE {
+		return -1, &libkafka.Error{Code: p.ErrorCode}
+	}
+	return p.LowWatermark, nil
+}
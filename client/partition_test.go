@@ -8,7 +8,10 @@ import (
 	"time"
 
 	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/DeleteRecords"
 	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
 )
 
 func init() {
@@ -174,3 +177,57 @@ func TestUnitLeaderString(t *testing.T) {
 		t.Fatal(s)
 	}
 }
+
+func TestUnitPartitionClientDeleteRecords(t *testing.T) {
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0, Leader: 1}}},
+				},
+			}
+		case api.DeleteRecords:
+			r := &DeleteRecords.Request{}
+			req.Unmarshal(r)
+			p := r.Topics[0].Partitions[0]
+			pr := DeleteRecords.PartitionResponse{PartitionIndex: p.PartitionIndex, LowWatermark: p.Offset}
+			switch {
+			case p.Offset == DeleteRecords.HighWatermark:
+				pr.LowWatermark = 100
+			case p.Offset > 100:
+				pr.LowWatermark = -1
+				pr.ErrorCode = libkafka.ERR_OFFSET_OUT_OF_RANGE
+			}
+			return &DeleteRecords.Response{Topics: []DeleteRecords.TopicResponse{
+				{Name: r.Topics[0].Name, Partitions: []DeleteRecords.PartitionResponse{pr}},
+			}}
+		}
+		return nil
+	})
+	defer b.Close()
+	c := &PartitionClient{Bootstrap: b.Addr(), Topic: "foo", Partition: 0}
+	defer c.Close()
+	if offset, err := c.DeleteRecords(10, 1000); err != nil || offset != 10 {
+		t.Fatal(offset, err)
+	}
+	if offset, err := c.DeleteRecords(DeleteRecords.HighWatermark, 1000); err != nil || offset != 100 {
+		t.Fatal(offset, err)
+	}
+	var e *libkafka.Error
+	if _, err := c.DeleteRecords(200, 1000); !errors.As(err, &e) || e.Code != libkafka.ERR_OFFSET_OUT_OF_RANGE {
+		t.Fatal(err)
+	}
+	for _, req := range b.Requests() {
+		if req.ApiKey != api.DeleteRecords {
+			continue
+		}
+		r := &DeleteRecords.Request{}
+		req.Unmarshal(r)
+		if r.TimeoutMs != 1000 || r.Topics[0].Name != "foo" {
+			t.Fatalf("%+v", r)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package producer
+
+import (
+	"fmt"
+
+	"github.com/mkocikowski/libkafka/api/Produce"
+	"github.com/mkocikowski/libkafka/batch"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// split multi partition response into per partition responses. unlike
+// parseResponse, any number of topic and partition responses is valid.
+func parseMultiplePartitionsResponse(r *Produce.Response) []*Response {
+	var responses []*Response
+	for i := range r.TopicResponses {
+		tr := &(r.TopicResponses[i])
+		for j := range tr.PartitionResponses {
+			pr := &(tr.PartitionResponses[j])
+			responses = append(responses, &Response{
+				ThrottleTimeMs: r.ThrottleTimeMs,
+				Topic:          tr.Topic,
+				Partition:      pr.Partition,
+				ErrorCode:      pr.ErrorCode,
+				BaseOffset:     pr.BaseOffset,
+				LogAppendTime:  pr.LogAppendTime,
+				LogStartOffset: pr.LogStartOffset,
+			})
+		}
+	}
+	return responses
+}
+
+// BrokerProducer produces batches for multiple topic partitions led by the
+// same broker with a single Produce request. Use client.GroupByLeader to find
+// out which partitions are led by which broker. Partitions which are not led
+// by the broker identified by NodeId will come back with
+// ERR_NOT_LEADER_FOR_PARTITION in their responses.
+type BrokerProducer struct {
+	client.BrokerClient
+	Acks      int16 // 0: no, 1: leader only, -1: all ISRs (as specified by min.insync.replicas)
+	TimeoutMs int32
+}
+
+// Produce (send) batches to Kafka, at most one batch per topic partition, in a
+// single request. Returns one Response per topic partition. Each partition
+// response has its own ErrorCode, and these must be checked individually: some
+// batches may have been produced successfully while others failed. Error is
+// returned only if the request-response round trip could not be completed (in
+// which case it is possible, same as with PartitionProducer, that some or all
+// of the batches were produced). Batches are marshaled, which mutates their
+// Crc. If batches is empty no request is made. Nil batches are an error.
+func (p *BrokerProducer) Produce(batches map[client.TopicPartition]*batch.Batch) ([]*Response, error) {
+	partitions := make([]client.TopicPartition, 0, len(batches))
+	for tp := range batches {
+		partitions = append(partitions, tp)
+	}
+	client.SortTopicPartitions(partitions)
+	args := &Produce.MultiplePartitionsArgs{
+		ClientId:  p.ClientId,
+		Acks:      p.Acks,
+		TimeoutMs: p.TimeoutMs,
+	}
+	for _, tp := range partitions {
+		if batches[tp] == nil {
+			return nil, fmt.Errorf("nil batch for %v", tp)
+		}
+		args.RecordSets = append(args.RecordSets, Produce.PartitionRecordSet{
+			Topic:     tp.Topic,
+			Partition: tp.Partition,
+			RecordSet: batches[tp].Marshal(),
+		})
+	}
+	if len(args.RecordSets) == 0 {
+		return nil, nil
+	}
+	resp, err := p.BrokerClient.Produce(args)
+	if err != nil {
+		if broker := p.Broker(); broker != nil {
+			err = fmt.Errorf("error calling %+v: %w", broker, err)
+		}
+		return nil, err
+	}
+	broker := p.Broker()
+	responses := parseMultiplePartitionsResponse(resp)
+	for _, r := range responses {
+		r.Broker = broker
+	}
+	return responses, nil
+}
@@ -0,0 +1,115 @@
+package producer
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/Produce"
+	"github.com/mkocikowski/libkafka/batch"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+func TestUnitBrokerProducer(t *testing.T) {
+	var broker *fakebroker.Broker
+	broker, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: broker.Host(), Port: broker.Port()}},
+			}
+		case api.Produce:
+			r := &Produce.Request{}
+			if err := req.Unmarshal(r); err != nil {
+				return nil
+			}
+			resp := &Produce.Response{}
+			for _, td := range r.TopicData {
+				tr := Produce.TopicResponse{Topic: td.Topic}
+				for _, d := range td.Data {
+					pr := Produce.PartitionResponse{Partition: d.Partition, BaseOffset: int64(len(d.RecordSet))}
+					if d.Partition == 1 {
+						pr.ErrorCode = libkafka.ERR_NOT_LEADER_FOR_PARTITION
+					}
+					tr.PartitionResponses = append(tr.PartitionResponses, pr)
+				}
+				resp.TopicResponses = append(resp.TopicResponses, tr)
+			}
+			return resp
+		}
+		return nil
+	})
+	defer broker.Close()
+	p := &BrokerProducer{
+		BrokerClient: client.BrokerClient{Bootstrap: broker.Addr(), NodeId: 1},
+		Acks:         1,
+		TimeoutMs:    1000,
+	}
+	now := time.Now()
+	batches := make(map[client.TopicPartition]*batch.Batch)
+	for _, tp := range []client.TopicPartition{{Topic: "foo", Partition: 0}, {Topic: "foo", Partition: 1}, {Topic: "bar", Partition: 0}} {
+		batches[tp], _ = batch.NewBuilder(now).AddStrings("hello").Build(now)
+	}
+	responses, err := p.Produce(batches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(responses); n != 3 {
+		t.Fatal(n)
+	}
+	// sorted by topic, so "bar" first
+	if r := responses[0]; r.Topic != "bar" || r.ErrorCode != libkafka.ERR_NONE || r.Broker.NodeId != 1 {
+		t.Fatalf("%+v", r)
+	}
+	if r := responses[2]; r.Topic != "foo" || r.Partition != 1 || r.ErrorCode != libkafka.ERR_NOT_LEADER_FOR_PARTITION {
+		t.Fatalf("%+v", r)
+	}
+	if n := len(broker.Requests()); n != 2 { // metadata and produce
+		t.Fatal(n)
+	}
+	if _, err := p.Produce(map[client.TopicPartition]*batch.Batch{{Topic: "foo", Partition: 0}: nil}); err == nil {
+		t.Fatal("expected error for nil batch")
+	}
+}
+
+func TestIntegrationBrokerProducer(t *testing.T) {
+	bootstrap := "localhost:9092"
+	topic := fmt.Sprintf("test-%x", rand.Uint32())
+	if _, err := client.CallCreateTopic(bootstrap, nil, topic, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	partitions := []client.TopicPartition{{Topic: topic, Partition: 0}, {Topic: topic, Partition: 1}, {Topic: topic, Partition: 2}}
+	groups, _, err := client.GroupByLeader(bootstrap, nil, partitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, group := range groups {
+		p := &BrokerProducer{
+			BrokerClient: client.BrokerClient{Bootstrap: bootstrap, NodeId: id},
+			Acks:         1,
+			TimeoutMs:    1000,
+		}
+		now := time.Now()
+		batches := make(map[client.TopicPartition]*batch.Batch)
+		for _, tp := range group {
+			batches[tp], _ = batch.NewBuilder(now).AddStrings("foo", "bar").Build(now)
+		}
+		responses, err := p.Produce(batches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(responses) != len(group) {
+			t.Fatalf("%+v", responses)
+		}
+		for _, r := range responses {
+			if r.ErrorCode != libkafka.ERR_NONE || r.BaseOffset != 0 {
+				t.Fatalf("%+v", r)
+			}
+		}
+	}
+}
@@ -1,4 +1,5 @@
-// Package producer implements a single partition Kafka producer.
+// Package producer implements single partition and single broker (multiple
+// partitions led by the same broker) Kafka producers.
 package producer
 
 import (
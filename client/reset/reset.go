@@ -0,0 +1,280 @@
+// Package reset computes and applies new committed offsets for consumer
+// groups: to the earliest or latest offsets, to offsets at a point in time,
+// shifted by N, or to explicit values (which can be read from CSV or JSON
+// files). Computing a Plan has no side effects, so it can be used as a "dry
+// run" preview. Plans are applied only to groups with no active members: a
+// member of a running group would overwrite the offsets with its own commits.
+package reset
+
+import (
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/DescribeGroups"
+	"github.com/mkocikowski/libkafka/api/ListOffsets"
+	"github.com/mkocikowski/libkafka/client"
+)
+
+// ErrGroupNotEmpty is returned by Apply when the group has members.
+var ErrGroupNotEmpty = errors.New("group is not empty")
+
+// PartitionOffset is the new offset for a partition.
+type PartitionOffset struct {
+	Topic     string
+	Partition int32
+	// CurrentOffset committed for the group; -1 if none.
+	CurrentOffset int64
+	// TargetOffset is the new offset, always within log start and log
+	// end offsets.
+	TargetOffset   int64
+	LogStartOffset int64
+	LogEndOffset   int64
+}
+
+// Plan of new offsets for a group. Offsets are sorted by topic and partition.
+type Plan struct {
+	GroupId string
+	Offsets []*PartitionOffset
+}
+
+// WriteCSV writes "topic,partition,offset" lines with target offsets (the
+// format read by ReadCSV).
+func (p *Plan) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	for _, o := range p.Offsets {
+		line := []string{
+			o.Topic,
+			strconv.Itoa(int(o.Partition)),
+			strconv.FormatInt(o.TargetOffset, 10),
+		}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ReadCSV reads "topic,partition,offset" lines (as written by WriteCSV, or by
+// the kafka-consumer-groups.sh --export tool for a single group).
+func ReadCSV(r io.Reader) (map[client.TopicPartition]int64, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = 3
+	offsets := make(map[client.TopicPartition]int64)
+	for {
+		line, err := in.Read()
+		if err == io.EOF {
+			return offsets, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		partition, err := strconv.ParseInt(line[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing partition %q: %w", line[1], err)
+		}
+		offset, err := strconv.ParseInt(line[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing offset %q: %w", line[2], err)
+		}
+		offsets[client.TopicPartition{Topic: line[0], Partition: int32(partition)}] = offset
+	}
+}
+
+// ReadJSON reads a list of {"topic": "foo", "partition": 0, "offset": 10}
+// objects.
+func ReadJSON(r io.Reader) (map[client.TopicPartition]int64, error) {
+	var list []struct {
+		Topic     string `json:"topic"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+	}
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	offsets := make(map[client.TopicPartition]int64)
+	for _, o := range list {
+		offsets[client.TopicPartition{Topic: o.Topic, Partition: o.Partition}] = o.Offset
+	}
+	return offsets, nil
+}
+
+// Resetter computes plans for and applies them to the group.
+type Resetter struct {
+	Bootstrap string // srv or host:port
+	TLS       *tls.Config
+	SASL      client.SASL
+	GroupId   string
+}
+
+// plan gets current and log offsets for partitions and calls target for
+// each. partitions for which target returns false are left out of the plan.
+func (r *Resetter) plan(partitions []client.TopicPartition, timestampsMs []int64, target func(o *PartitionOffset, offsets []int64) bool) (*Plan, error) {
+	c := &client.GroupClient{Bootstrap: r.Bootstrap, TLS: r.TLS, SASL: r.SASL, GroupId: r.GroupId}
+	defer c.Close()
+	committed, err := c.FetchOffsets(partitions)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching committed offsets: %w", err)
+	}
+	timestampsMs = append([]int64{ListOffsets.Earliest, ListOffsets.Latest}, timestampsMs...)
+	listed, err := client.GetOffsetsSASL(r.Bootstrap, r.TLS, r.SASL, partitions, timestampsMs...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing log offsets: %w", err)
+	}
+	plan := &Plan{GroupId: r.GroupId, Offsets: []*PartitionOffset{}}
+	client.SortTopicPartitions(partitions)
+	for _, tp := range partitions {
+		offsets := make([]int64, len(timestampsMs))
+		for i := range timestampsMs {
+			p := listed[i][tp]
+			if p == nil {
+				return nil, fmt.Errorf("no offsets listed for %v", tp)
+			}
+			if p.ErrorCode != libkafka.ERR_NONE {
+				return nil, fmt.Errorf("error listing offsets for %v: %w", tp, &libkafka.Error{Code: p.ErrorCode})
+			}
+			offsets[i] = p.Offset
+		}
+		o := &PartitionOffset{
+			Topic:          tp.Topic,
+			Partition:      tp.Partition,
+			CurrentOffset:  -1,
+			LogStartOffset: offsets[0],
+			LogEndOffset:   offsets[1],
+		}
+		if c := committed[tp]; c != nil {
+			if c.ErrorCode != libkafka.ERR_NONE {
+				return nil, fmt.Errorf("error fetching committed offset for %v: %w", tp, &libkafka.Error{Code: c.ErrorCode})
+			}
+			o.CurrentOffset = c.Offset
+		}
+		if !target(o, offsets[2:]) {
+			continue
+		}
+		if o.TargetOffset < o.LogStartOffset {
+			o.TargetOffset = o.LogStartOffset
+		}
+		if o.TargetOffset > o.LogEndOffset {
+			o.TargetOffset = o.LogEndOffset
+		}
+		plan.Offsets = append(plan.Offsets, o)
+	}
+	return plan, nil
+}
+
+func (r *Resetter) topicsPlan(topics []string, timestampsMs []int64, target func(o *PartitionOffset, offsets []int64) bool) (*Plan, error) {
+	partitions, err := client.GetPartitionsSASL(r.Bootstrap, r.TLS, r.SASL, topics)
+	if err != nil {
+		return nil, err
+	}
+	return r.plan(partitions, timestampsMs, target)
+}
+
+// ToEarliest plans moving offsets for all partitions of the topics to log
+// start offsets.
+func (r *Resetter) ToEarliest(topics []string) (*Plan, error) {
+	return r.topicsPlan(topics, nil, func(o *PartitionOffset, _ []int64) bool {
+		o.TargetOffset = o.LogStartOffset
+		return true
+	})
+}
+
+// ToLatest plans moving offsets for all partitions of the topics to log end
+// offsets.
+func (r *Resetter) ToLatest(topics []string) (*Plan, error) {
+	return r.topicsPlan(topics, nil, func(o *PartitionOffset, _ []int64) bool {
+		o.TargetOffset = o.LogEndOffset
+		return true
+	})
+}
+
+// ToTime plans moving offsets for all partitions of the topics to the offsets
+// of the first messages with timestamps at or after t. Partitions with no
+// such messages are moved to log end offsets.
+func (r *Resetter) ToTime(topics []string, t time.Time) (*Plan, error) {
+	timestampMs := t.UnixNano() / int64(time.Millisecond)
+	return r.topicsPlan(topics, []int64{timestampMs}, func(o *PartitionOffset, offsets []int64) bool {
+		o.TargetOffset = offsets[0]
+		if o.TargetOffset < 0 {
+			o.TargetOffset = o.LogEndOffset
+		}
+		return true
+	})
+}
+
+// ShiftBy plans moving committed offsets for all partitions of the topics by
+// n (which can be negative). Partitions with no committed offsets are left
+// out of the plan.
+func (r *Resetter) ShiftBy(topics []string, n int64) (*Plan, error) {
+	return r.topicsPlan(topics, nil, func(o *PartitionOffset, _ []int64) bool {
+		if o.CurrentOffset < 0 {
+			return false
+		}
+		o.TargetOffset = o.CurrentOffset + n
+		return true
+	})
+}
+
+// ToOffsets plans moving offsets for partitions to explicit values (for
+// example read with ReadCSV or ReadJSON). Values outside of log start and log
+// end offsets are moved to the nearest of the two.
+func (r *Resetter) ToOffsets(offsets map[client.TopicPartition]int64) (*Plan, error) {
+	partitions := make([]client.TopicPartition, 0, len(offsets))
+	for tp := range offsets {
+		partitions = append(partitions, tp)
+	}
+	return r.plan(partitions, nil, func(o *PartitionOffset, _ []int64) bool {
+		o.TargetOffset = offsets[client.TopicPartition{Topic: o.Topic, Partition: o.Partition}]
+		return true
+	})
+}
+
+// Apply commits the plan's target offsets. The group must have no members
+// (it is described just before the commit): otherwise ErrGroupNotEmpty is
+// returned and nothing is committed. Error is also returned if the commit of
+// any of the offsets fails.
+func (r *Resetter) Apply(plan *Plan) error {
+	if plan.GroupId != r.GroupId {
+		return fmt.Errorf("plan is for group %q, not %q", plan.GroupId, r.GroupId)
+	}
+	groups, err := client.GetGroupDescriptionsSASL(r.Bootstrap, r.TLS, r.SASL, []string{r.GroupId})
+	if err != nil {
+		return fmt.Errorf("error describing group: %w", err)
+	}
+	g := groups[0]
+	if g.ErrorCode != libkafka.ERR_NONE {
+		return fmt.Errorf("error describing group: %w", &libkafka.Error{Code: g.ErrorCode})
+	}
+	if g.State != DescribeGroups.StateEmpty && g.State != DescribeGroups.StateDead {
+		return fmt.Errorf("group state %s: %w", g.State, ErrGroupNotEmpty)
+	}
+	req := &client.CommitOffsetsRequest{
+		GenerationId: -1,
+		RetentionMs:  -1,
+		Offsets:      make(map[client.TopicPartition]client.OffsetMetadata),
+	}
+	for _, o := range plan.Offsets {
+		tp := client.TopicPartition{Topic: o.Topic, Partition: o.Partition}
+		req.Offsets[tp] = client.OffsetMetadata{Offset: o.TargetOffset}
+	}
+	c := &client.GroupClient{Bootstrap: r.Bootstrap, TLS: r.TLS, SASL: r.SASL, GroupId: r.GroupId}
+	defer c.Close()
+	errorCodes, err := c.CommitOffsets(req)
+	if err != nil {
+		return err
+	}
+	for _, o := range plan.Offsets {
+		tp := client.TopicPartition{Topic: o.Topic, Partition: o.Partition}
+		if code := errorCodes[tp]; code != libkafka.ERR_NONE {
+			return fmt.Errorf("error committing offset for %v: %w", tp, &libkafka.Error{Code: code})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package reset
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/DescribeGroups"
+	"github.com/mkocikowski/libkafka/api/FindCoordinator"
+	"github.com/mkocikowski/libkafka/api/ListOffsets"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/OffsetCommit"
+	"github.com/mkocikowski/libkafka/api/OffsetFetch"
+	"github.com/mkocikowski/libkafka/client"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+// topic "foo" has 2 partitions with log start offset 10 and log end offset
+// 100. the group has offset 20 committed for partition 0, and none for
+// partition 1. at the reset time, partition 0 is at offset 50, and partition 1
+// has no messages after it.
+func startBroker(t *testing.T, state *string) *fakebroker.Broker {
+	t.Helper()
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{
+						{Partition: 1, Leader: 1},
+						{Partition: 0, Leader: 1},
+					}},
+				},
+			}
+		case api.FindCoordinator:
+			return &FindCoordinator.Response{NodeId: 1, Host: b.Host(), Port: b.Port()}
+		case api.OffsetFetch:
+			r := &OffsetFetch.Request{}
+			req.Unmarshal(r)
+			resp := &OffsetFetch.Response{}
+			for _, t := range r.Topics {
+				tr := OffsetFetch.TopicResponse{Name: t.Name}
+				for _, p := range t.PartitionIndexes {
+					offset := int64(-1)
+					if p == 0 {
+						offset = 20
+					}
+					tr.Partitions = append(tr.Partitions, OffsetFetch.PartitionResponse{PartitionIndex: p, CommitedOffset: offset})
+				}
+				resp.Topics = append(resp.Topics, tr)
+			}
+			return resp
+		case api.ListOffsets:
+			r := &ListOffsets.RequestBody{}
+			req.Unmarshal(r)
+			resp := &ListOffsets.Response{}
+			for _, t := range r.Topics {
+				tr := ListOffsets.TopicResponse{Topic: t.Topic}
+				for _, p := range t.Partitions {
+					pr := ListOffsets.PartitionResponse{Partition: p.Partition}
+					switch {
+					case p.Timestamp == ListOffsets.Earliest:
+						pr.Offset = 10
+					case p.Timestamp == ListOffsets.Latest:
+						pr.Offset = 100
+					case p.Partition == 0:
+						pr.Offset = 50
+					default:
+						pr.Offset = -1
+					}
+					tr.Partitions = append(tr.Partitions, pr)
+				}
+				resp.Responses = append(resp.Responses, tr)
+			}
+			return resp
+		case api.DescribeGroups:
+			return &DescribeGroups.Response{Groups: []DescribeGroups.Group{
+				{GroupId: "test", GroupState: *state},
+			}}
+		case api.OffsetCommit:
+			r := &OffsetCommit.Request{}
+			req.Unmarshal(r)
+			resp := &OffsetCommit.Response{}
+			for _, t := range r.Topics {
+				tr := OffsetCommit.TopicResponse{Name: t.Name}
+				for _, p := range t.Partitions {
+					tr.Partitions = append(tr.Partitions, OffsetCommit.PartitionResponse{PartitionIndex: p.PartitionIndex})
+				}
+				resp.Topics = append(resp.Topics, tr)
+			}
+			return resp
+		}
+		return nil
+	})
+	return b
+}
+
+func targets(plan *Plan) []int64 {
+	var offsets []int64
+	for _, o := range plan.Offsets {
+		offsets = append(offsets, o.TargetOffset)
+	}
+	return offsets
+}
+
+func TestUnitResetterPlans(t *testing.T) {
+	state := DescribeGroups.StateEmpty
+	b := startBroker(t, &state)
+	defer b.Close()
+	r := &Resetter{Bootstrap: b.Addr(), GroupId: "test"}
+	tests := []struct {
+		name string
+		plan func() (*Plan, error)
+		want []int64
+	}{
+		{"earliest", func() (*Plan, error) { return r.ToEarliest([]string{"foo"}) }, []int64{10, 10}},
+		{"latest", func() (*Plan, error) { return r.ToLatest([]string{"foo"}) }, []int64{100, 100}},
+		{"time", func() (*Plan, error) { return r.ToTime([]string{"foo"}, time.Now()) }, []int64{50, 100}},
+		{"shift", func() (*Plan, error) { return r.ShiftBy([]string{"foo"}, -15) }, []int64{10}},
+		{"offsets", func() (*Plan, error) {
+			return r.ToOffsets(map[client.TopicPartition]int64{{Topic: "foo", Partition: 1}: 30, {Topic: "foo", Partition: 0}: 1000})
+		}, []int64{100, 30}},
+	}
+	for _, test := range tests {
+		plan, err := test.plan()
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if got := targets(plan); !reflect.DeepEqual(got, test.want) {
+			t.Fatal(test.name, got)
+		}
+		if o := plan.Offsets[0]; o.Partition != 0 || o.CurrentOffset != 20 || o.LogStartOffset != 10 || o.LogEndOffset != 100 {
+			t.Fatalf("%s %+v", test.name, o)
+		}
+	}
+	// planning has no side effects
+	for _, req := range b.Requests() {
+		if req.ApiKey == api.OffsetCommit {
+			t.Fatal("offsets committed")
+		}
+	}
+}
+
+func TestUnitResetterApply(t *testing.T) {
+	state := DescribeGroups.StateStable
+	b := startBroker(t, &state)
+	defer b.Close()
+	r := &Resetter{Bootstrap: b.Addr(), GroupId: "test"}
+	plan, err := r.ToEarliest([]string{"foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Apply(plan); !errors.Is(err, ErrGroupNotEmpty) {
+		t.Fatal(err)
+	}
+	state = DescribeGroups.StateEmpty
+	if err := r.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+	var commits []*OffsetCommit.Request
+	for _, req := range b.Requests() {
+		if req.ApiKey != api.OffsetCommit {
+			continue
+		}
+		c := &OffsetCommit.Request{}
+		req.Unmarshal(c)
+		commits = append(commits, c)
+	}
+	if len(commits) != 1 {
+		t.Fatal(len(commits))
+	}
+	c := commits[0]
+	if c.GroupId != "test" || c.GenerationId != -1 || len(c.Topics) != 1 || len(c.Topics[0].Partitions) != 2 {
+		t.Fatalf("%+v", c)
+	}
+	for _, p := range c.Topics[0].Partitions {
+		if p.CommitedOffset != 10 {
+			t.Fatalf("%+v", p)
+		}
+	}
+	if err := (&Resetter{Bootstrap: b.Addr(), GroupId: "other"}).Apply(plan); err == nil {
+		t.Fatal("expected error applying plan for another group")
+	}
+}
+
+func TestUnitCSV(t *testing.T) {
+	plan := &Plan{Offsets: []*PartitionOffset{
+		{Topic: "foo", Partition: 0, TargetOffset: 10},
+		{Topic: "bar", Partition: 3, TargetOffset: 5},
+	}}
+	buf := new(bytes.Buffer)
+	if err := plan.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "foo,0,10\nbar,3,5\n" {
+		t.Fatal(s)
+	}
+	offsets, err := ReadCSV(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[client.TopicPartition]int64{{Topic: "foo", Partition: 0}: 10, {Topic: "bar", Partition: 3}: 5}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Fatal(offsets)
+	}
+	if _, err := ReadCSV(strings.NewReader("foo,x,10\n")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestUnitJSON(t *testing.T) {
+	s := `[{"topic": "foo", "partition": 0, "offset": 10}, {"topic": "bar", "partition": 3, "offset": 5}]`
+	offsets, err := ReadJSON(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[client.TopicPartition]int64{{Topic: "foo", Partition: 0}: 10, {Topic: "bar", Partition: 3}: 5}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Fatal(offsets)
+	}
+}
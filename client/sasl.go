@@ -0,0 +1,61 @@
+package client
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api/SaslAuthenticate"
+	"github.com/mkocikowski/libkafka/api/SaslHandshake"
+	"github.com/mkocikowski/libkafka/scram"
+)
+
+// SASL is called every time a client opens a connection to a broker, and
+// the connection is authenticated with the returned credentials
+// (SCRAM-SHA-256 or SCRAM-SHA-512, over the SASL_PLAINTEXT or SASL_SSL
+// listener). It is called for every connection so that short lived
+// credentials, such as delegation tokens, can be rotated. Clients have a SASL
+// field next to TLS, and functions with the SASL suffix take sasl next to
+// tlsConfig: nil means connections are not authenticated.
+type SASL func() (*scram.Credentials, error)
+
+func authenticate(conn net.Conn, creds *scram.Credentials) error {
+	handshake := &SaslHandshake.Response{}
+	if err := call(conn, SaslHandshake.NewRequest(creds.Mechanism.Name), handshake); err != nil {
+		return err
+	}
+	if handshake.ErrorCode != libkafka.ERR_NONE {
+		return &libkafka.Error{
+			Code:    handshake.ErrorCode,
+			Message: fmt.Sprintf("%s (broker supports %v)", creds.Mechanism.Name, handshake.Mechanisms),
+		}
+	}
+	conversation, err := scram.NewConversation(creds)
+	if err != nil {
+		return err
+	}
+	serverFirst, err := saslAuthenticate(conn, conversation.First())
+	if err != nil {
+		return err
+	}
+	clientFinal, err := conversation.Final(serverFirst)
+	if err != nil {
+		return err
+	}
+	serverFinal, err := saslAuthenticate(conn, clientFinal)
+	if err != nil {
+		return err
+	}
+	return conversation.Verify(serverFinal)
+}
+
+func saslAuthenticate(conn net.Conn, b []byte) ([]byte, error) {
+	resp := &SaslAuthenticate.Response{}
+	if err := call(conn, SaslAuthenticate.NewRequest(b), resp); err != nil {
+		return nil, err
+	}
+	if resp.ErrorCode != libkafka.ERR_NONE {
+		return nil, &libkafka.Error{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+	}
+	return resp.AuthBytes, nil
+}
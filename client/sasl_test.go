@@ -0,0 +1,126 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mkocikowski/libkafka"
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/SaslAuthenticate"
+	"github.com/mkocikowski/libkafka/api/SaslHandshake"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+	"github.com/mkocikowski/libkafka/scram"
+)
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// fakeScramBroker is the server side of SCRAM-SHA-256 for the user with the
+// password, which requires tokenauth=true if token is true.
+func fakeScramBroker(t *testing.T, user, password string, token bool) *fakebroker.Broker {
+	t.Helper()
+	salt := []byte("salt")
+	var clientFirstBare, serverFirst string
+	b, _ := fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.SaslHandshake:
+			clientFirstBare, serverFirst = "", ""
+			r := &SaslHandshake.Request{}
+			req.Unmarshal(r)
+			resp := &SaslHandshake.Response{Mechanisms: []string{"SCRAM-SHA-256"}}
+			if r.Mechanism != "SCRAM-SHA-256" {
+				resp.ErrorCode = libkafka.ERR_UNSUPPORTED_SASL_MECHANISM
+			}
+			return resp
+		case api.SaslAuthenticate:
+			r := &SaslAuthenticate.Request{}
+			req.Unmarshal(r)
+			msg := string(r.AuthBytes)
+			if clientFirstBare == "" {
+				clientFirstBare = strings.TrimPrefix(msg, "n,,")
+				want := "n=" + user + ",r="
+				if !strings.HasPrefix(clientFirstBare, want) || strings.HasSuffix(clientFirstBare, ",tokenauth=true") != token {
+					return &SaslAuthenticate.Response{ErrorCode: libkafka.ERR_SASL_AUTHENTICATION_FAILED, ErrorMessage: "unknown user"}
+				}
+				nonce := strings.TrimSuffix(strings.TrimPrefix(clientFirstBare, want), ",tokenauth=true")
+				serverFirst = "r=" + nonce + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+				return &SaslAuthenticate.Response{AuthBytes: []byte(serverFirst)}
+			}
+			i := strings.LastIndex(msg, ",p=")
+			authMessage := []byte(clientFirstBare + "," + serverFirst + "," + msg[:i])
+			salted := scram.SHA256.SaltedPassword(password, salt, 4096)
+			clientKey := hmacSHA256(salted, []byte("Client Key"))
+			storedKey := sha256.Sum256(clientKey)
+			signature := hmacSHA256(storedKey[:], authMessage)
+			proof, _ := base64.StdEncoding.DecodeString(msg[i+3:])
+			for j := range proof {
+				proof[j] ^= signature[j]
+			}
+			if !hmac.Equal(proof, clientKey) {
+				return &SaslAuthenticate.Response{ErrorCode: libkafka.ERR_SASL_AUTHENTICATION_FAILED, ErrorMessage: "invalid credentials"}
+			}
+			serverSignature := hmacSHA256(hmacSHA256(salted, []byte("Server Key")), authMessage)
+			return &SaslAuthenticate.Response{AuthBytes: []byte("v=" + base64.StdEncoding.EncodeToString(serverSignature))}
+		case api.Metadata:
+			return &Metadata.Response{}
+		}
+		return nil
+	})
+	return b
+}
+
+func TestUnitSASLTokenAuth(t *testing.T) {
+	hmacb64 := base64.StdEncoding.EncodeToString([]byte("token hmac"))
+	b := fakeScramBroker(t, "token1", hmacb64, true)
+	defer b.Close()
+	sasl := func() (*scram.Credentials, error) {
+		return &scram.Credentials{Mechanism: scram.SHA256, User: "token1", Password: hmacb64, TokenAuth: true}, nil
+	}
+	if _, err := CallMetadataSASL(b.Addr(), nil, sasl, nil); err != nil {
+		t.Fatal(err)
+	}
+	var keys []int16
+	for _, req := range b.Requests() {
+		keys = append(keys, req.ApiKey)
+	}
+	if len(keys) != 4 || keys[0] != api.SaslHandshake || keys[1] != api.SaslAuthenticate || keys[3] != api.Metadata {
+		t.Fatal(keys)
+	}
+}
+
+func TestUnitSASLErrors(t *testing.T) {
+	b := fakeScramBroker(t, "alice", "secret", false)
+	defer b.Close()
+	sasl := func() (*scram.Credentials, error) {
+		return &scram.Credentials{Mechanism: scram.SHA512, User: "alice", Password: "secret"}, nil
+	}
+	var e *libkafka.Error
+	_, err := CallMetadataSASL(b.Addr(), nil, sasl, nil)
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_UNSUPPORTED_SASL_MECHANISM {
+		t.Fatal(err)
+	}
+	sasl = func() (*scram.Credentials, error) {
+		return &scram.Credentials{Mechanism: scram.SHA256, User: "alice", Password: "nope"}, nil
+	}
+	_, err = CallMetadataSASL(b.Addr(), nil, sasl, nil)
+	if !errors.As(err, &e) || e.Code != libkafka.ERR_SASL_AUTHENTICATION_FAILED {
+		t.Fatal(err)
+	}
+	sasl = func() (*scram.Credentials, error) { return nil, errors.New("token service down") }
+	if _, err = CallMetadataSASL(b.Addr(), nil, sasl, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	for _, req := range b.Requests() {
+		if req.ApiKey == api.Metadata {
+			t.Fatal("unauthenticated request")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/mkocikowski/libkafka/api"
+)
+
+// ThrottleStats describe how a client has been throttled by the broker (for
+// exceeding its produce, fetch, or request quotas).
+type ThrottleStats struct {
+	Count int64         // number of responses with ThrottleTimeMs > 0
+	Total time.Duration // sum of throttle times
+	Max   time.Duration // longest throttle time
+	Last  time.Time     // when the last throttled response was received
+	// Delayed is the total time that calls were held back by the client
+	// before being sent. Always 0 if HonorThrottle is false.
+	Delayed time.Duration
+}
+
+// first versions of api calls for which brokers respond to throttled requests
+// immediately (and mute the channel) expecting the client to back off before
+// sending the next request (KIP-219, Kafka 2.0). for older versions brokers
+// delay the response by the throttle time themselves. apis not listed here
+// (and with keys greater than ElectPreferredLeaders) all came after KIP-219.
+var clientSideThrottleVersions = map[int16]int16{
+	api.Produce:                 6,
+	api.Fetch:                   8,
+	api.ListOffsets:             3,
+	api.Metadata:                6,
+	api.OffsetCommit:            4,
+	api.OffsetFetch:             4,
+	api.FindCoordinator:         2,
+	api.JoinGroup:               3,
+	api.Heartbeat:               2,
+	api.LeaveGroup:              2,
+	api.SyncGroup:               2,
+	api.DescribeGroups:          2,
+	api.ListGroups:              2,
+	api.ApiVersions:             2,
+	api.CreateTopics:            3,
+	api.DeleteTopics:            2,
+	api.DeleteRecords:           1,
+	api.InitProducerId:          1,
+	api.OffsetForLeaderEpoch:    2,
+	api.AddPartitionsToTxn:      1,
+	api.AddOffsetsToTxn:         1,
+	api.EndTxn:                  1,
+	api.TxnOffsetCommit:         1,
+	api.DescribeAcls:            1,
+	api.CreateAcls:              1,
+	api.DeleteAcls:              1,
+	api.DescribeConfigs:         2,
+	api.AlterConfigs:            1,
+	api.AlterReplicaLogDirs:     1,
+	api.DescribeLogDirs:         1,
+	api.CreatePartitions:        1,
+	api.CreateDelegationToken:   1,
+	api.RenewDelegationToken:    1,
+	api.ExpireDelegationToken:   1,
+	api.DescribeDelegationToken: 1,
+	api.DeleteGroups:            1,
+}
+
+func clientSideThrottle(apiKey, apiVersion int16) bool {
+	if v, ok := clientSideThrottleVersions[apiKey]; ok {
+		return apiVersion >= v
+	}
+	return apiKey > api.ElectPreferredLeaders
+}
+
+// throttleTime returns the value of the ThrottleTimeMs field of the response
+// struct pointed to by v, or 0 if there is no such field.
+func throttleTime(v interface{}) time.Duration {
+	r := reflect.ValueOf(v)
+	if r.Kind() != reflect.Ptr || r.Elem().Kind() != reflect.Struct {
+		return 0
+	}
+	f := r.Elem().FieldByName("ThrottleTimeMs")
+	if !f.IsValid() || f.Kind() != reflect.Int32 {
+		return 0
+	}
+	return time.Duration(f.Int()) * time.Millisecond
+}
+
+// throttle keeps track of broker throttling for a single client. not safe for
+// concurrent use: it is used by clients under their mutexes.
+type throttle struct {
+	until time.Time // do not send requests before
+	stats ThrottleStats
+}
+
+// wait until the throttle time from the last throttled response has passed.
+func (t *throttle) wait() {
+	d := time.Until(t.until)
+	if d <= 0 {
+		return
+	}
+	time.Sleep(d)
+	t.stats.Delayed += d
+}
+
+// update throttle stats from response v to request req. if honor is true and
+// the broker expects the client to back off then the next call to wait blocks
+// for the throttle time.
+func (t *throttle) update(req *api.Request, v interface{}, honor bool) {
+	d := throttleTime(v)
+	if d <= 0 {
+		return
+	}
+	now := time.Now()
+	t.stats.Count++
+	t.stats.Total += d
+	if d > t.stats.Max {
+		t.stats.Max = d
+	}
+	t.stats.Last = now
+	if honor && clientSideThrottle(req.ApiKey, req.ApiVersion) {
+		t.until = now.Add(d)
+	}
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/Fetch"
+	"github.com/mkocikowski/libkafka/api/Metadata"
+	"github.com/mkocikowski/libkafka/api/Produce"
+	"github.com/mkocikowski/libkafka/internal/fakebroker"
+)
+
+func TestUnitThrottleTime(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want time.Duration
+	}{
+		{&Produce.Response{ThrottleTimeMs: 10}, 10 * time.Millisecond},
+		{&Fetch.Response{ThrottleTimeMs: 20}, 20 * time.Millisecond},
+		{&Fetch.Response{}, 0},
+		{Fetch.Response{ThrottleTimeMs: 20}, 0}, // not a pointer
+		{&struct{ Foo int32 }{}, 0},
+		{&struct{ ThrottleTimeMs int64 }{ThrottleTimeMs: 1}, 0},
+		{nil, 0},
+	}
+	for i, test := range tests {
+		if got := throttleTime(test.v); got != test.want {
+			t.Fatal(i, got, test.want)
+		}
+	}
+}
+
+func TestUnitClientSideThrottle(t *testing.T) {
+	tests := []struct {
+		key     int16
+		version int16
+		want    bool
+	}{
+		{api.Produce, 5, false},
+		{api.Produce, 6, true},
+		{api.Produce, 7, true},
+		{api.Fetch, 6, false},
+		{api.Fetch, 8, true},
+		{api.ElectPreferredLeaders, 0, false},
+		{api.ElectPreferredLeaders + 1, 0, true},
+	}
+	for _, test := range tests {
+		if got := clientSideThrottle(test.key, test.version); got != test.want {
+			t.Fatalf("%+v %v", test, got)
+		}
+	}
+}
+
+func TestUnitPartitionClientHonorThrottle(t *testing.T) {
+	const throttle = 200 * time.Millisecond
+	var b *fakebroker.Broker
+	b, _ = fakebroker.Start(1, func(req *fakebroker.Request) interface{} {
+		switch req.ApiKey {
+		case api.Metadata:
+			return &Metadata.Response{
+				Brokers: []Metadata.Broker{{NodeId: 1, Host: b.Host(), Port: b.Port()}},
+				TopicMetadata: []Metadata.TopicMetadata{
+					{Topic: "foo", PartitionMetadata: []Metadata.PartitionMetadata{{Partition: 0, Leader: 1}}},
+				},
+			}
+		case api.Produce:
+			return &Produce.Response{
+				TopicResponses: []Produce.TopicResponse{{
+					Topic:              "foo",
+					PartitionResponses: []Produce.PartitionResponse{{Partition: 0}},
+				}},
+				ThrottleTimeMs: int32(throttle / time.Millisecond),
+			}
+		}
+		return nil
+	})
+	defer b.Close()
+	for _, honor := range []bool{false, true} {
+		c := &PartitionClient{
+			Bootstrap:     b.Addr(),
+			Topic:         "foo",
+			Partition:     0,
+			HonorThrottle: honor,
+		}
+		args := &Produce.Args{Topic: "foo", Partition: 0, Acks: 1, TimeoutMs: 1000}
+		for i := 0; i < 2; i++ {
+			if _, err := c.Produce(args, []byte{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stats := c.ThrottleStats()
+		if stats.Count != 2 || stats.Total != 2*throttle || stats.Max != throttle {
+			t.Fatalf("%+v", stats)
+		}
+		if honor && stats.Delayed < throttle/2 {
+			t.Fatalf("%+v", stats)
+		}
+		if !honor && stats.Delayed != 0 {
+			t.Fatalf("%+v", stats)
+		}
+		c.Close()
+	}
+}
@@ -99,6 +99,18 @@ const (
 	ERR_MEMBER_ID_REQUIRED                    = 79
 	ERR_PREFERRED_LEADER_NOT_AVAILABLE        = 80 // retriable: True
 	ERR_GROUP_MAX_SIZE_REACHED                = 81
+	ERR_FENCED_INSTANCE_ID                    = 82
+	ERR_ELIGIBLE_LEADERS_NOT_AVAILABLE        = 83 // retriable: True
+	ERR_ELECTION_NOT_NEEDED                   = 84
+	ERR_NO_REASSIGNMENT_IN_PROGRESS           = 85
+	ERR_GROUP_SUBSCRIBED_TO_TOPIC             = 86
+	ERR_INVALID_RECORD                        = 87
+	ERR_UNSTABLE_OFFSET_COMMIT                = 88 // retriable: True
+	ERR_THROTTLING_QUOTA_EXCEEDED             = 89 // retriable: True
+	ERR_PRODUCER_FENCED                       = 90
+	ERR_RESOURCE_NOT_FOUND                    = 91
+	ERR_DUPLICATE_RESOURCE                    = 92
+	ERR_UNACCEPTABLE_CREDENTIAL               = 93
 )
 
 var errorDescriptions = map[int]string{
@@ -185,4 +197,16 @@ var errorDescriptions = map[int]string{
 	79: "MEMBER_ID_REQUIRED",
 	80: "PREFERRED_LEADER_NOT_AVAILABLE",
 	81: "GROUP_MAX_SIZE_REACHED",
+	82: "FENCED_INSTANCE_ID",
+	83: "ELIGIBLE_LEADERS_NOT_AVAILABLE",
+	84: "ELECTION_NOT_NEEDED",
+	85: "NO_REASSIGNMENT_IN_PROGRESS",
+	86: "GROUP_SUBSCRIBED_TO_TOPIC",
+	87: "INVALID_RECORD",
+	88: "UNSTABLE_OFFSET_COMMIT",
+	89: "THROTTLING_QUOTA_EXCEEDED",
+	90: "PRODUCER_FENCED",
+	91: "RESOURCE_NOT_FOUND",
+	92: "DUPLICATE_RESOURCE",
+	93: "UNACCEPTABLE_CREDENTIAL",
 }
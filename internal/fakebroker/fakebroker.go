@@ -0,0 +1,191 @@
+// Package fakebroker implements a fake Kafka broker for use in unit tests. The
+// broker listens on a random localhost port, parses request headers, and hands
+// requests to a user supplied handler. ApiVersions requests are answered by
+// the broker itself. Responses returned by the handler are marshaled with the
+// wire package, according to the request api version (flexible api versions
+// are supported).
+package fakebroker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/mkocikowski/libkafka/api"
+	"github.com/mkocikowski/libkafka/api/ApiVersions"
+	"github.com/mkocikowski/libkafka/wire"
+)
+
+type Request struct {
+	ApiKey        int16
+	ApiVersion    int16
+	CorrelationId int32
+	ClientId      string
+	Body          []byte
+}
+
+// Unmarshal request body into v, according to the request api version.
+func (r *Request) Unmarshal(v interface{}) error {
+	if api.Flexible(r.ApiKey, r.ApiVersion) {
+		return wire.ReadFlexible(bytes.NewReader(r.Body), reflect.ValueOf(v), r.ApiVersion)
+	}
+	return wire.ReadVersion(bytes.NewReader(r.Body), reflect.ValueOf(v), r.ApiVersion)
+}
+
+// Handler returns the response for the request. Returning nil makes the broker
+// close the connection without responding.
+type Handler func(*Request) interface{}
+
+type Broker struct {
+	sync.Mutex
+	NodeId   int32
+	Handler  Handler
+	Versions *ApiVersions.Response // if nil, all api keys at versions 0-12
+	listener net.Listener
+	conns    []net.Conn
+	requests []*Request
+}
+
+// Start a broker listening on a random localhost port.
+func Start(nodeId int32, handler Handler) (*Broker, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &Broker{NodeId: nodeId, Handler: handler, listener: ln}
+	go b.serve()
+	return b, nil
+}
+
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+func (b *Broker) Host() string {
+	host, _, _ := net.SplitHostPort(b.Addr())
+	return host
+}
+
+func (b *Broker) Port() int32 {
+	_, port, _ := net.SplitHostPort(b.Addr())
+	p, _ := strconv.Atoi(port)
+	return int32(p)
+}
+
+// Requests returns all requests (other than ApiVersions) received so far.
+func (b *Broker) Requests() []*Request {
+	b.Lock()
+	defer b.Unlock()
+	return append([]*Request(nil), b.requests...)
+}
+
+// Close the listener and all open connections.
+func (b *Broker) Close() error {
+	b.Lock()
+	defer b.Unlock()
+	for _, c := range b.conns {
+		c.Close()
+	}
+	return b.listener.Close()
+}
+
+func (b *Broker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.Lock()
+		b.conns = append(b.conns, conn)
+		b.Unlock()
+		go b.handle(conn)
+	}
+}
+
+func defaultVersions() *ApiVersions.Response {
+	resp := &ApiVersions.Response{}
+	for k := 0; k < len(api.Keys); k++ {
+		resp.ApiKeys = append(resp.ApiKeys, ApiVersions.ApiKeyVersion{
+			ApiKey:     int16(k),
+			MinVersion: 0,
+			MaxVersion: 12,
+		})
+	}
+	return resp
+}
+
+func (b *Broker) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		req, err := readRequest(r)
+		if err != nil {
+			return
+		}
+		var resp interface{}
+		if req.ApiKey == api.ApiVersions {
+			resp = defaultVersions()
+			if b.Versions != nil {
+				resp = b.Versions
+			}
+		} else {
+			b.Lock()
+			b.requests = append(b.requests, req)
+			handler := b.Handler
+			b.Unlock()
+			resp = handler(req)
+		}
+		if resp == nil {
+			return
+		}
+		if _, err := conn.Write(marshalResponse(req, resp)); err != nil {
+			return
+		}
+	}
+}
+
+func readRequest(r io.Reader) (*Request, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	buf := bytes.NewReader(b)
+	req := &Request{}
+	header := []interface{}{&req.ApiKey, &req.ApiVersion, &req.CorrelationId, &req.ClientId}
+	for _, v := range header {
+		if err := wire.Read(buf, reflect.ValueOf(v)); err != nil {
+			return nil, err
+		}
+	}
+	if api.Flexible(req.ApiKey, req.ApiVersion) {
+		if err := wire.SkipTaggedFields(buf); err != nil {
+			return nil, err
+		}
+	}
+	req.Body = b[len(b)-buf.Len():]
+	return req, nil
+}
+
+func marshalResponse(req *Request, v interface{}) []byte {
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.BigEndian, req.CorrelationId)
+	if api.Flexible(req.ApiKey, req.ApiVersion) {
+		body.WriteByte(0) // no header tagged fields
+		wire.WriteFlexible(body, reflect.ValueOf(v), req.ApiVersion)
+	} else {
+		wire.WriteVersion(body, reflect.ValueOf(v), req.ApiVersion)
+	}
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, int32(body.Len()))
+	body.WriteTo(buf)
+	return buf.Bytes()
+}
@@ -33,7 +33,10 @@ Kafka broker. In addition, many API calls (such as Produce and Fetch) can
 combine data for multiple topics and partitions in a single call. Libkafka
 maintains a separate connection for every topic-partition and calls on that
 connection are synchronous, and each call is for only one topic-partition. That
-makes call handling (and failure) logic simpler.
+makes call handling (and failure) logic simpler. The exception is the
+BrokerClient (and fetchers and producers built on it) which combines
+topic-partitions led by the same broker in a single call, for consuming from
+and producing to many partitions efficiently.
 
 3. Wide use of reflection. All API calls (requests and responses) are defined
 as structs and marshaled using reflection. This is not a performance problem,
@@ -0,0 +1,176 @@
+// Package scram implements the client side of SCRAM-SHA-256 and
+// SCRAM-SHA-512 authentication (RFC 5802, RFC 7677) as used by Kafka,
+// including authentication with delegation tokens (KIP-48), and the salted
+// password derivation needed for creating SCRAM credentials.
+package scram
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// Mechanism is a SCRAM mechanism.
+type Mechanism struct {
+	// Name is the SASL mechanism name.
+	Name string
+	hash func() hash.Hash
+}
+
+var (
+	SHA256 = &Mechanism{Name: "SCRAM-SHA-256", hash: sha256.New}
+	SHA512 = &Mechanism{Name: "SCRAM-SHA-512", hash: sha512.New}
+)
+
+// MinIterations and MaxIterations accepted by Kafka for SCRAM credentials.
+const (
+	MinIterations = 4096
+	MaxIterations = 16384
+)
+
+func (m *Mechanism) hmac(key, data []byte) []byte {
+	h := hmac.New(m.hash, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (m *Mechanism) sum(data []byte) []byte {
+	h := m.hash()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// SaltedPassword returns Hi(password, salt, iterations): PBKDF2 with HMAC of
+// the mechanism hash, and the key length of the hash size.
+func (m *Mechanism) SaltedPassword(password string, salt []byte, iterations int) []byte {
+	prf := hmac.New(m.hash, []byte(password))
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1}) // INT(1)
+	u := prf.Sum(nil)
+	result := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// Credentials for SCRAM authentication.
+type Credentials struct {
+	Mechanism *Mechanism
+	User      string
+	Password  string
+	// TokenAuth is for authenticating with a delegation token: User is
+	// the token id, and Password is the token HMAC (base64 encoded).
+	TokenAuth bool
+}
+
+// ErrServerSignature is returned when the server proof in the server final
+// message does not match the credentials (the server does not know them).
+var ErrServerSignature = errors.New("invalid server signature")
+
+// Conversation is the client side of a single authentication exchange:
+// client first message, server first message, client final message, server
+// final message.
+type Conversation struct {
+	creds           *Credentials
+	nonce           string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+// NewConversation with a random client nonce.
+func NewConversation(creds *Credentials) (*Conversation, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	return &Conversation{creds: creds, nonce: base64.RawStdEncoding.EncodeToString(b)}, nil
+}
+
+// escape user name ("=" and "," are not allowed in saslname).
+func escape(s string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s)
+}
+
+// First returns the client first message.
+func (c *Conversation) First() []byte {
+	c.clientFirstBare = "n=" + escape(c.creds.User) + ",r=" + c.nonce
+	if c.creds.TokenAuth {
+		c.clientFirstBare += ",tokenauth=true"
+	}
+	return []byte("n,," + c.clientFirstBare)
+}
+
+func parse(msg []byte) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for _, kv := range strings.Split(string(msg), ",") {
+		if len(kv) < 2 || kv[1] != '=' {
+			return nil, fmt.Errorf("malformed message %q", msg)
+		}
+		attrs[kv[:1]] = kv[2:]
+	}
+	return attrs, nil
+}
+
+// Final returns the client final message for the server first message.
+func (c *Conversation) Final(serverFirst []byte) ([]byte, error) {
+	attrs, err := parse(serverFirst)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := attrs["e"]; ok {
+		return nil, fmt.Errorf("server error: %s", e)
+	}
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return nil, fmt.Errorf("invalid server nonce %q", nonce)
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, fmt.Errorf("invalid iteration count %q", attrs["i"])
+	}
+	m := c.creds.Mechanism
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte("n,,")) + ",r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + string(serverFirst) + "," + withoutProof)
+	salted := m.SaltedPassword(c.creds.Password, salt, iterations)
+	clientKey := m.hmac(salted, []byte("Client Key"))
+	clientSignature := m.hmac(m.sum(clientKey), authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	c.serverSignature = m.hmac(m.hmac(salted, []byte("Server Key")), authMessage)
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// Verify the server final message.
+func (c *Conversation) Verify(serverFinal []byte) error {
+	attrs, err := parse(serverFinal)
+	if err != nil {
+		return err
+	}
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("server error: %s", e)
+	}
+	v, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(v, c.serverSignature) {
+		return ErrServerSignature
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package scram
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// https://tools.ietf.org/html/rfc7677#section-3
+func TestUnitConversationSHA256(t *testing.T) {
+	c := &Conversation{
+		creds: &Credentials{Mechanism: SHA256, User: "user", Password: "pencil"},
+		nonce: "rOprNGfwEbeRWgbNEkqO",
+	}
+	if s := string(c.First()); s != "n,,n=user,r=rOprNGfwEbeRWgbNEkqO" {
+		t.Fatal(s)
+	}
+	serverFirst := "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	final, err := c.Final([]byte(serverFirst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	if string(final) != want {
+		t.Fatal(string(final))
+	}
+	if err := c.Verify([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify([]byte("v=AAAA")); err != ErrServerSignature {
+		t.Fatal(err)
+	}
+	if err := c.Verify([]byte("e=invalid-proof")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestUnitConversationTokenAuth(t *testing.T) {
+	c := &Conversation{
+		creds: &Credentials{Mechanism: SHA512, User: "token,id=", Password: "hmac", TokenAuth: true},
+		nonce: "abc",
+	}
+	if s := string(c.First()); s != "n,,n=token=2Cid=3D,r=abc,tokenauth=true" {
+		t.Fatal(s)
+	}
+	for _, serverFirst := range []string{
+		"r=abc,s=c2FsdA==,i=4096",     // nonce not extended
+		"r=xyzabc1,s=c2FsdA==,i=4096", // not client nonce prefix
+		"r=abc1,s=c2FsdA==,i=0",
+		"r=abc1,s=!!,i=4096",
+		"e=unknown-user",
+	} {
+		if _, err := c.Final([]byte(serverFirst)); err == nil {
+			t.Fatal(serverFirst)
+		}
+	}
+}
+
+// PBKDF2-HMAC-SHA256 with the inputs of the RFC 6070 (PBKDF2-HMAC-SHA1) test
+// vectors.
+func TestUnitSaltedPassword(t *testing.T) {
+	tests := []struct {
+		iterations int
+		want       string
+	}{
+		{1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, test := range tests {
+		b := SHA256.SaltedPassword("password", []byte("salt"), test.iterations)
+		if s := hex.EncodeToString(b); s != test.want {
+			t.Fatal(test.iterations, s)
+		}
+	}
+	if n := len(SHA512.SaltedPassword("password", []byte("salt"), 1)); n != 64 {
+		t.Fatal(n)
+	}
+}
@@ -1,44 +1,138 @@
 // Package wire implements functions for marshaling and unmarshaling Kafka requests and responses.
+//
+// Flexible versions (KIP-482) encode strings, arrays, and bytes as "compact"
+// (length+1 as unsigned varint, 0 for null), and end every struct with tagged
+// fields. Tagged fields are not supported: none are written, and all are
+// skipped when reading.
 package wire
 
 import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
 var ord = binary.BigEndian
 
+// inVersion checks the "versions" struct tag of the field. The tag follows the
+// convention of Kafka json message definitions: "7+" means version 7 and up,
+// "2-4" means versions 2 through 4, "3" means version 3 only. Fields with no
+// tag are present in all versions. When version is -1 the tag is ignored and
+// all fields are present.
+func inVersion(field reflect.StructField, version int16) bool {
+	tag := field.Tag.Get("versions")
+	if tag == "" || version < 0 {
+		return true
+	}
+	var min, max int
+	var err error
+	switch {
+	case strings.HasSuffix(tag, "+"):
+		min, err = strconv.Atoi(strings.TrimSuffix(tag, "+"))
+		max = 1<<15 - 1
+	case strings.Contains(tag, "-"):
+		parts := strings.SplitN(tag, "-", 2)
+		if min, err = strconv.Atoi(parts[0]); err == nil {
+			max, err = strconv.Atoi(parts[1])
+		}
+	default:
+		min, err = strconv.Atoi(tag)
+		max = min
+	}
+	if err != nil {
+		panic(fmt.Sprintf("invalid versions tag %q on field %s", tag, field.Name))
+	}
+	return int(version) >= min && int(version) <= max
+}
+
+// nullable string fields (tagged `wire:"nullable"`) are written as null (length
+// -1) when empty. reading null string sets the field to empty string.
+func nullable(field reflect.StructField) bool {
+	return field.Type.Kind() == reflect.String && field.Tag.Get("wire") == "nullable"
+}
+
+// skip fields that start with lowercase, are tagged `wire:"omit"`, or are not
+// present in the given version
+func skip(field reflect.StructField, version int16) bool {
+	if field.Name[0:1] == strings.ToLower(field.Name[0:1]) {
+		return true
+	}
+	if field.Tag.Get("wire") == "omit" {
+		return true
+	}
+	return !inVersion(field, version)
+}
+
+// Write marshals val ignoring "versions" struct tags (all fields are written).
 func Write(w io.Writer, val reflect.Value) error {
+	return write(w, val, -1, false)
+}
+
+// WriteVersion marshals val skipping struct fields which according to their
+// "versions" tags are not present in the given api version.
+func WriteVersion(w io.Writer, val reflect.Value, version int16) error {
+	return write(w, val, version, false)
+}
+
+// WriteFlexible is like WriteVersion but it uses the flexible version
+// encoding. Use it for api versions which are flexible.
+func WriteFlexible(w io.Writer, val reflect.Value, version int16) error {
+	return write(w, val, version, true)
+}
+
+func writeUvarint(w io.Writer, x uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	_, err := w.Write(buf[:binary.PutUvarint(buf, x)])
+	return err
+}
+
+// writeLength of arrays (int32) and strings (int16), or in flexible versions
+// length+1 (unsigned varint). -1 is null.
+func writeLength(w io.Writer, n int, flexible, str bool) error {
+	switch {
+	case flexible:
+		return writeUvarint(w, uint64(n+1))
+	case str:
+		return binary.Write(w, ord, int16(n))
+	default:
+		return binary.Write(w, ord, int32(n))
+	}
+}
+
+func write(w io.Writer, val reflect.Value, version int16, flexible bool) error {
 	switch val.Kind() {
 	case reflect.Ptr, reflect.Interface:
-		return Write(w, val.Elem())
+		return write(w, val.Elem(), version, flexible)
 	case reflect.Struct:
 		for i := 0; i < val.NumField(); i++ {
-			name := val.Type().Field(i).Name
-			if name[0:1] == strings.ToLower(name[0:1]) {
-				continue // skip fields that start with lowercase
+			field := val.Type().Field(i)
+			if skip(field, version) {
+				continue
 			}
-			if val.Type().Field(i).Tag.Get("wire") == "omit" {
+			if nullable(field) && val.Field(i).Len() == 0 {
+				if err := writeLength(w, -1, flexible, true); err != nil {
+					return err
+				}
 				continue
 			}
-			err := Write(w, val.Field(i))
+			err := write(w, val.Field(i), version, flexible)
 			if err != nil {
 				return err
 			}
 		}
+		if flexible {
+			return writeUvarint(w, 0) // no tagged fields
+		}
 		return nil
 	case reflect.Slice:
 		if val.IsNil() {
-			return binary.Write(w, ord, int32(-1))
+			return writeLength(w, -1, flexible, false)
 		}
-		l := int32(val.Len())
-		if l == 0 {
-			return binary.Write(w, ord, int32(0))
-		}
-		if err := binary.Write(w, ord, l); err != nil {
+		if err := writeLength(w, val.Len(), flexible, false); err != nil {
 			return err
 		}
 		typ := val.Type().Elem()
@@ -47,13 +141,20 @@ func Write(w io.Writer, val reflect.Value) error {
 			return err
 		}
 		for i := 0; i < val.Len(); i++ {
-			err := Write(w, val.Index(i))
+			err := write(w, val.Index(i), version, flexible)
 			if err != nil {
 				return err
 			}
 		}
 		return nil
 	case reflect.String:
+		if flexible {
+			if err := writeLength(w, val.Len(), flexible, true); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, val.String())
+			return err
+		}
 		l := int16(val.Len())
 		if l == 0 {
 			//return binary.Write(w, ord, int16(-1))
@@ -80,6 +181,8 @@ func Write(w io.Writer, val reflect.Value) error {
 	case reflect.Int64:
 		i := int64(val.Int())
 		return binary.Write(w, ord, i)
+	case reflect.Float64:
+		return binary.Write(w, ord, val.Float())
 	case reflect.Bool:
 		if val.Bool() {
 			_, err := w.Write([]byte{1})
@@ -91,33 +194,109 @@ func Write(w io.Writer, val reflect.Value) error {
 	return nil
 }
 
+// Read unmarshals into val ignoring "versions" struct tags (all fields are
+// read).
 func Read(r io.Reader, val reflect.Value) error {
+	return read(r, val, -1, false)
+}
+
+// ReadVersion unmarshals into val skipping struct fields which according to
+// their "versions" tags are not present in the given api version.
+func ReadVersion(r io.Reader, val reflect.Value, version int16) error {
+	return read(r, val, version, false)
+}
+
+// ReadFlexible is like ReadVersion but it uses the flexible version encoding.
+// Tagged fields are skipped.
+func ReadFlexible(r io.Reader, val reflect.Value, version int16) error {
+	return read(r, val, version, true)
+}
+
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	b := make([]byte, 1)
+	_, err := io.ReadFull(r, b)
+	return b[0], err
+}
+
+func readUvarint(r io.Reader) (uint64, error) {
+	if br, ok := r.(io.ByteReader); ok {
+		return binary.ReadUvarint(br)
+	}
+	return binary.ReadUvarint(byteReader{r})
+}
+
+// readLength of arrays and strings. returns -1 for null.
+func readLength(r io.Reader, flexible, str bool) (int32, error) {
+	switch {
+	case flexible:
+		n, err := readUvarint(r)
+		return int32(n) - 1, err
+	case str:
+		var n int16
+		err := binary.Read(r, ord, &n)
+		return int32(n), err
+	default:
+		var n int32
+		err := binary.Read(r, ord, &n)
+		return n, err
+	}
+}
+
+// SkipTaggedFields reads and discards tagged fields (of a struct, or of a
+// flexible request or response header).
+func SkipTaggedFields(r io.Reader) error {
+	n, err := readUvarint(r)
+	if err != nil {
+		return fmt.Errorf("error reading number of tagged fields: %v", err)
+	}
+	for i := uint64(0); i < n; i++ {
+		if _, err := readUvarint(r); err != nil {
+			return fmt.Errorf("error reading tag: %v", err)
+		}
+		size, err := readUvarint(r)
+		if err != nil {
+			return fmt.Errorf("error reading tagged field size: %v", err)
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, int64(size)); err != nil {
+			return fmt.Errorf("error reading tagged field: %v", err)
+		}
+	}
+	return nil
+}
+
+func read(r io.Reader, val reflect.Value, version int16, flexible bool) error {
 	//log.Println(val)
 	switch val.Kind() {
 	case reflect.Ptr, reflect.Interface:
-		return Read(r, val.Elem())
+		return read(r, val.Elem(), version, flexible)
 	case reflect.Struct:
 		for i := 0; i < val.NumField(); i++ {
-			name := val.Type().Field(i).Name
-			if name[0:1] == strings.ToLower(name[0:1]) {
-				continue // skip fields that start with lowercase
-			}
-			if val.Type().Field(i).Tag.Get("wire") == "omit" {
+			if skip(val.Type().Field(i), version) {
 				continue
 			}
-			err := Read(r, val.Field(i))
+			err := read(r, val.Field(i), version, flexible)
 			if err != nil {
 				return err
 			}
 		}
+		if flexible {
+			return SkipTaggedFields(r)
+		}
 		return nil
 	case reflect.Slice:
-		var n int32
-		if err := binary.Read(r, ord, &n); err != nil {
+		n, err := readLength(r, flexible, false)
+		if err != nil {
 			return fmt.Errorf("error reading array length: %v", err)
 		}
 		typ := val.Type().Elem()
 		if typ.Kind() == reflect.Uint8 { // []byte
+			if n < 0 {
+				return nil // null bytes
+			}
 			b := make([]byte, n)
 			if _, err := io.ReadFull(r, b); err != nil {
 				return fmt.Errorf("error reading []byte body: %v", err)
@@ -131,15 +310,15 @@ func Read(r io.Reader, val reflect.Value) error {
 		val.Set(reflect.MakeSlice(val.Type(), 0, 0)) // empty slice
 		for i := 0; i < int(n); i++ {
 			element := reflect.New(typ).Elem()
-			if err := Read(r, element); err != nil {
+			if err := read(r, element, version, flexible); err != nil {
 				return fmt.Errorf("error parsing array element: %v", err)
 			}
 			val.Set(reflect.Append(val, element))
 		}
 		return nil
 	case reflect.String:
-		var n int16
-		if err := binary.Read(r, ord, &n); err != nil {
+		n, err := readLength(r, flexible, true)
+		if err != nil {
 			return fmt.Errorf("error reading string length: %v", err)
 		}
 		if n < 0 {
@@ -186,6 +365,13 @@ func Read(r io.Reader, val reflect.Value) error {
 		}
 		val.SetInt(int64(i))
 		return nil
+	case reflect.Float64:
+		var f float64
+		if err := binary.Read(r, ord, &f); err != nil {
+			return fmt.Errorf("error reading float64: %v", err)
+		}
+		val.SetFloat(f)
+		return nil
 	case reflect.Bool:
 		b := make([]byte, 1)
 		_, err := r.Read(b)
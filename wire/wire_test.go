@@ -37,3 +37,127 @@ func TestWriteRead(t *testing.T) {
 	}
 	t.Logf("%+v", n)
 }
+
+type Versioned struct {
+	A int16
+	B int16 `versions:"2+"`
+	C int16 `versions:"1-2"`
+	D int16 `versions:"0"`
+}
+
+func TestUnitWriteReadVersion(t *testing.T) {
+	m := &Versioned{A: 1, B: 2, C: 3, D: 4}
+	tests := []struct {
+		version int16
+		size    int
+		want    Versioned
+	}{
+		{-1, 8, Versioned{1, 2, 3, 4}},
+		{0, 4, Versioned{A: 1, D: 4}},
+		{1, 4, Versioned{A: 1, C: 3}},
+		{2, 6, Versioned{A: 1, B: 2, C: 3}},
+		{3, 4, Versioned{A: 1, B: 2}},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		if err := WriteVersion(buf, reflect.ValueOf(m), test.version); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != test.size {
+			t.Fatal(test.version, buf.Len())
+		}
+		n := &Versioned{}
+		if err := ReadVersion(buf, reflect.ValueOf(n), test.version); err != nil {
+			t.Fatal(err)
+		}
+		if *n != test.want {
+			t.Fatal(test.version, n)
+		}
+	}
+}
+
+type Nullable struct {
+	String   string `wire:"nullable"`
+	Bytes    []byte
+	NotNull  string
+	Trailing int16
+}
+
+func TestUnitWriteReadNullable(t *testing.T) {
+	tests := []struct {
+		m    Nullable
+		want []byte
+	}{
+		{Nullable{Trailing: 1}, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 1}},
+		{Nullable{"a", []byte{}, "b", 1}, []byte{0, 1, 'a', 0, 0, 0, 0, 0, 1, 'b', 0, 1}},
+	}
+	for _, test := range tests {
+		buf := new(bytes.Buffer)
+		if err := Write(buf, reflect.ValueOf(&test.m)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), test.want) {
+			t.Fatal(buf.Bytes())
+		}
+		n := &Nullable{}
+		if err := Read(buf, reflect.ValueOf(n)); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*n, test.m) {
+			t.Fatalf("%+v", n)
+		}
+	}
+}
+
+type Flexible struct {
+	String   string `wire:"nullable"`
+	NotNull  string
+	Array    []Inner
+	Null     []int16
+	Float    float64
+	Trailing int16
+}
+
+func TestUnitWriteReadFlexible(t *testing.T) {
+	m := &Flexible{NotNull: "ab", Array: []Inner{{1}}, Float: 1.5, Trailing: 2}
+	want := []byte{
+		0,           // null string
+		3, 'a', 'b', // compact string
+		2, 0, 1, 0, // compact array of one struct (with no tagged fields)
+		0,                            // null array
+		0x3f, 0xf8, 0, 0, 0, 0, 0, 0, // float64
+		0, 2, // int16
+		0, // no tagged fields
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteFlexible(buf, reflect.ValueOf(m), 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatal(buf.Bytes())
+	}
+	n := &Flexible{}
+	if err := ReadFlexible(buf, reflect.ValueOf(n), 0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, m) {
+		t.Fatalf("%+v", n)
+	}
+}
+
+func TestUnitReadFlexibleSkipsTaggedFields(t *testing.T) {
+	b := []byte{
+		0, 1, // int16
+		2,          // 2 tagged fields
+		0, 1, 0xff, // tag 0, 1 byte
+		1, 2, 0xff, 0xff, // tag 1, 2 bytes
+	}
+	m := &Inner{}
+	r := bytes.NewReader(b)
+	if err := ReadFlexible(r, reflect.ValueOf(m), 0); err != nil {
+		t.Fatal(err)
+	}
+	if m.Int16 != 1 || r.Len() != 0 {
+		t.Fatal(m, r.Len())
+	}
+}